@@ -0,0 +1,414 @@
+package gokml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vesselIcon is the icon of vessel positions, pointing north until rotated.
+const vesselIcon = "http://earth.google.com/images/kml-icons/track-directional/track-0.png"
+
+// VesselTracker keeps the positions of vessels decoded from AIS messages
+// (!AIVDM and !AIVDO sentences), such as the output of an AIS receiver or
+// an AIS feed, and builds KML documents of them for a NetworkLink to
+// refresh.  It is safe for concurrent use, so one goroutine can read the
+// feed while others serve the documents.
+type VesselTracker struct {
+	vessels   map[int]*vessel
+	fragments map[string][]string // payloads of multi-sentence messages
+	latest    time.Time
+	maxAge    time.Duration
+	trail     time.Duration
+	now       func() time.Time
+	mutex     *sync.Mutex
+}
+
+// vessel is the state of a vessel, from all of its messages.
+type vessel struct {
+	mmsi                   int
+	name, callsign, dest   string
+	imo, shipType          int
+	speed, course, heading float64 // knots and degrees
+	hasCourse, hasHeading  bool
+	lastSeen               time.Time
+	times                  []time.Time
+	points                 []*Point
+}
+
+// NewVesselTracker returns a new VesselTracker, which forgets vessels not
+// heard from in 30 minutes and keeps an hour of track.
+func NewVesselTracker() *VesselTracker {
+	return &VesselTracker{make(map[int]*vessel), make(map[string][]string), time.Time{}, 30 * time.Minute, time.Hour, time.Now, new(sync.Mutex)}
+}
+
+// SetMaxAge sets how long a vessel is kept after its last message.  Values
+// that aren't positive are ignored.
+func (t *VesselTracker) SetMaxAge(d time.Duration) {
+	if d > 0 {
+		t.mutex.Lock()
+		t.maxAge = d
+		t.mutex.Unlock()
+	}
+}
+
+// SetTrailLength sets how much of the recent track of each vessel is kept.
+// Values that aren't positive are ignored.
+func (t *VesselTracker) SetTrailLength(d time.Duration) {
+	if d > 0 {
+		t.mutex.Lock()
+		t.trail = d
+		t.mutex.Unlock()
+	}
+}
+
+// ReadMessages reads AIS sentences from r, one per line, until it returns an
+// error or io.EOF, which isn't returned.  Malformed lines are skipped.
+func (t *VesselTracker) ReadMessages(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		t.HandleSentence(scanner.Text())
+	}
+
+	return scanner.Err()
+}
+
+// HandleSentence updates the tracker with an AIS sentence, such as
+// "!AIVDM,1,1,,B,15M67FC000G?ufbE`FepT@3n00Sa,0*5C".  Position reports
+// (message types 1, 2, 3, 18, and 19) add to the track of a vessel, and
+// static data (types 5, 19, and 24) set its name, callsign, IMO number,
+// ship type, and destination; other messages are ignored.  Messages split
+// over several sentences are decoded once all of them have arrived.  The
+// time of a message is taken from the c: parameter of its NMEA 4.0 tag
+// block, if any, or else is the time it was handled.  Returns an error for a
+// malformed sentence or a bad checksum.
+func (t *VesselTracker) HandleSentence(line string) error {
+	line = strings.TrimSpace(line)
+
+	var when time.Time
+	if strings.HasPrefix(line, "\\") {
+		if end := strings.IndexByte(line[1:], '\\'); end >= 0 {
+			tag := line[1 : end+1]
+			if star := strings.IndexByte(tag, '*'); star >= 0 {
+				tag = tag[:star]
+			}
+			for _, param := range strings.Split(tag, ",") {
+				if strings.HasPrefix(param, "c:") {
+					if sec, err := strconv.ParseInt(param[2:], 10, 64); err == nil {
+						if sec > 1e11 {
+							sec /= 1000 // milliseconds
+						}
+						when = time.Unix(sec, 0).UTC()
+					}
+				}
+			}
+			line = line[end+2:]
+		}
+	}
+
+	fields, ok := nmeaFields(line)
+	if !ok || len(fields) < 7 || len(fields[0]) != 5 || fields[0][2:] != "VDM" && fields[0][2:] != "VDO" {
+		return fmt.Errorf("gokml: invalid AIS sentence %q", line)
+	}
+
+	count, err1 := strconv.Atoi(fields[1])
+	number, err2 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil || number < 1 || number > count {
+		return fmt.Errorf("gokml: invalid AIS sentence %q", line)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if when.IsZero() {
+		when = t.now().UTC()
+	}
+
+	// collect the sentences of a multi-sentence message
+	payload := fields[5]
+	if count > 1 {
+		key := fields[3] + "," + fields[4]
+		if number == 1 {
+			t.fragments[key] = nil
+		}
+		if len(t.fragments[key]) != number-1 {
+			delete(t.fragments, key)
+			return nil // missed a sentence
+		}
+		t.fragments[key] = append(t.fragments[key], payload)
+		if number < count {
+			return nil
+		}
+		payload = strings.Join(t.fragments[key], "")
+		delete(t.fragments, key)
+	}
+
+	bits, err := aisBits(payload)
+	if err != nil {
+		return err
+	}
+
+	t.handleMessage(bits, when)
+
+	return nil
+}
+
+// handleMessage updates the tracker with a decoded AIS message.
+func (t *VesselTracker) handleMessage(b aisBitString, when time.Time) {
+	kind, mmsi := int(b.uint(0, 6)), int(b.uint(8, 30))
+	if mmsi == 0 {
+		return
+	}
+
+	// the offsets of the position report fields, by message type
+	var sog, lon, lat, cog, hdg int
+	switch kind {
+	case 1, 2, 3:
+		sog, lon, lat, cog, hdg = 50, 61, 89, 116, 128
+	case 18, 19:
+		sog, lon, lat, cog, hdg = 46, 57, 85, 112, 124
+	case 5, 24:
+	default:
+		return
+	}
+
+	v := t.vessels[mmsi]
+	if v == nil {
+		v = &vessel{mmsi: mmsi}
+		t.vessels[mmsi] = v
+	}
+	if when.After(v.lastSeen) {
+		v.lastSeen = when
+	}
+	if when.After(t.latest) {
+		t.latest = when
+	}
+
+	switch kind {
+	case 5:
+		v.imo = int(b.uint(40, 30))
+		v.callsign = b.text(70, 7)
+		v.name = b.text(112, 20)
+		v.shipType = int(b.uint(232, 8))
+		v.dest = b.text(302, 20)
+		return
+	case 19:
+		v.name = b.text(143, 20)
+		v.shipType = int(b.uint(263, 8))
+	case 24:
+		if b.uint(38, 2) == 0 {
+			v.name = b.text(40, 20)
+		} else {
+			v.shipType = int(b.uint(40, 8))
+			v.callsign = b.text(90, 7)
+		}
+		return
+	}
+
+	if s := b.uint(sog, 10); s != 1023 {
+		v.speed = float64(s) / 10
+	}
+	c, h := b.uint(cog, 12), b.uint(hdg, 9)
+	v.course, v.hasCourse = float64(c)/10, c < 3600
+	v.heading, v.hasHeading = float64(h), h < 360
+
+	// positions are in 1/10000 minutes, with 181 and 91 degrees for none
+	x, y := float64(b.int(lon, 28))/600000, float64(b.int(lat, 27))/600000
+	if p := NewPoint(y, x, 0); p != nil {
+		v.times = append(v.times, when)
+		v.points = append(v.points, p)
+	}
+
+	n := 0
+	for n < len(v.times) && v.times[n].Before(when.Add(-t.trail)) {
+		n++
+	}
+	v.times, v.points = v.times[n:], v.points[n:]
+}
+
+// KML returns a document of the vessels heard from recently, with a
+// Positions Folder of Placemarks at their latest positions, and a Tracks
+// Folder of their recent gx:Tracks.  Position icons are rotated to the
+// heading of each vessel (or its course, if the heading isn't known), and
+// the MMSI, name, callsign, IMO number, ship type, speed, course, heading,
+// and destination are kept as ExtendedData.  Placemarks have ids from the
+// MMSI, so successive documents can be compared with Delta.
+func (t *VesselTracker) KML() *KML {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var mmsis []int
+	for mmsi, v := range t.vessels {
+		if v.lastSeen.Before(t.latest.Add(-t.maxAge)) {
+			delete(t.vessels, mmsi)
+			continue
+		}
+		if len(v.points) > 0 {
+			mmsis = append(mmsis, mmsi)
+		}
+	}
+	sort.Ints(mmsis)
+
+	k := NewKML("Vessels")
+	positions := NewFolder("Positions", "")
+	tracks := NewFolder("Tracks", "")
+
+	trackStyle := NewStyle("vessel-track", 0xff, 0x00, 0xc0, 0xff)
+	trackStyle.SetIconScale(0)
+	k.AddFeature(trackStyle)
+
+	styled := make(map[string]bool)
+
+	for _, mmsi := range mmsis {
+		v := t.vessels[mmsi]
+		id := strconv.Itoa(mmsi)
+		name := v.name
+		if len(name) == 0 {
+			name = id
+		}
+
+		// icons are rotated in steps of 10 degrees, to share Styles
+		style := "vessel"
+		heading := 0.0
+		if v.hasHeading || v.hasCourse {
+			heading = v.course
+			if v.hasHeading {
+				heading = v.heading
+			}
+			heading = math.Mod(math.Round(heading/10)*10, 360)
+			style = fmt.Sprintf("vessel-%03.0f", heading)
+		}
+		if !styled[style] {
+			styled[style] = true
+			s := NewStyle(style, 0xff, 0x00, 0xc0, 0xff)
+			s.SetIconURL(vesselIcon)
+			s.SetIconHeading(heading)
+			k.AddFeature(s)
+		}
+
+		last := *v.points[len(v.points)-1]
+		pm := NewPlacemark(name, fmt.Sprintf("%.1f kt", v.speed), &last)
+		pm.SetID("vessel-" + id)
+		pm.SetStyle(style)
+		pm.SetData("mmsi", id)
+		for _, d := range []struct{ name, value string }{
+			{"name", v.name},
+			{"callsign", v.callsign},
+			{"imo", aisNumber(v.imo)},
+			{"shipType", aisNumber(v.shipType)},
+			{"speed", strconv.FormatFloat(v.speed, 'f', -1, 64)},
+		} {
+			if len(d.value) > 0 {
+				pm.SetData(d.name, d.value)
+			}
+		}
+		if v.hasCourse {
+			pm.SetData("course", strconv.FormatFloat(v.course, 'f', -1, 64))
+		}
+		if v.hasHeading {
+			pm.SetData("heading", strconv.FormatFloat(v.heading, 'f', -1, 64))
+		}
+		if len(v.dest) > 0 {
+			pm.SetData("destination", v.dest)
+		}
+		positions.AddFeature(pm)
+
+		if len(v.points) > 1 {
+			track := NewTrack()
+			for i, p := range v.points {
+				cp := *p
+				track.AddPoint(v.times[i], &cp)
+			}
+
+			pm := NewPlacemark(name, "", track)
+			pm.SetID("track-" + id)
+			pm.SetStyle("vessel-track")
+			tracks.AddFeature(pm)
+		}
+	}
+
+	k.AddFeature(positions)
+	k.AddFeature(tracks)
+
+	return k
+}
+
+// aisNumber formats a number, or returns an empty string for 0 (unknown).
+func aisNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(n)
+}
+
+// aisBitString is the bits of an AIS message, one per byte.
+type aisBitString []byte
+
+// aisBits decodes the 6-bit ASCII armoring of an AIS payload.
+func aisBits(payload string) (aisBitString, error) {
+	b := make(aisBitString, 0, 6*len(payload))
+	for i := 0; i < len(payload); i++ {
+		c := int(payload[i]) - 48
+		if c > 40 {
+			c -= 8
+		}
+		if c < 0 || c > 63 {
+			return nil, fmt.Errorf("gokml: invalid AIS payload %q", payload)
+		}
+		for bit := 5; bit >= 0; bit-- {
+			b = append(b, byte(c>>bit&1))
+		}
+	}
+
+	return b, nil
+}
+
+// uint returns the unsigned field of n bits at off, reading bits past the
+// end of a short message as zeros.
+func (b aisBitString) uint(off int, n int) uint64 {
+	var v uint64
+	for i := off; i < off+n; i++ {
+		v <<= 1
+		if i < len(b) {
+			v |= uint64(b[i])
+		}
+	}
+
+	return v
+}
+
+// int returns the two's complement signed field of n bits at off.
+func (b aisBitString) int(off int, n int) int64 {
+	v := int64(b.uint(off, n))
+	if v&(1<<(n-1)) != 0 {
+		v -= 1 << n
+	}
+
+	return v
+}
+
+// text returns the 6-bit text field of n characters at off, without its
+// padding ('@' and spaces).
+func (b aisBitString) text(off int, n int) string {
+	s := make([]byte, n)
+	for i := range s {
+		c := byte(b.uint(off+6*i, 6))
+		if c < 32 {
+			c += 64
+		}
+		s[i] = c
+	}
+
+	if end := strings.IndexByte(string(s), '@'); end >= 0 {
+		s = s[:end]
+	}
+
+	return strings.TrimSpace(string(s))
+}
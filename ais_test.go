@@ -0,0 +1,169 @@
+package gokml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// aisField is a field of an AIS message for aisSentences, either a number or
+// 6-bit text.
+type aisField struct {
+	bits  int
+	value int64
+	text  string
+}
+
+// aisSentences encodes an AIS message as !AIVDM sentences of at most 60
+// characters of payload, with tag as their NMEA 4.0 tag block, if any.
+func aisSentences(tag string, fields ...aisField) []string {
+	var bits []byte
+	for _, f := range fields {
+		if f.text != "" {
+			for i := 0; i < f.bits/6; i++ {
+				c := byte('@')
+				if i < len(f.text) {
+					c = f.text[i]
+				}
+				for bit := 5; bit >= 0; bit-- {
+					bits = append(bits, c&63>>bit&1)
+				}
+			}
+			continue
+		}
+		for bit := f.bits - 1; bit >= 0; bit-- {
+			bits = append(bits, byte(f.value>>bit&1))
+		}
+	}
+	for len(bits)%6 != 0 {
+		bits = append(bits, 0)
+	}
+
+	var payload []byte
+	for i := 0; i < len(bits); i += 6 {
+		c := bits[i]<<5 | bits[i+1]<<4 | bits[i+2]<<3 | bits[i+3]<<2 | bits[i+4]<<1 | bits[i+5]
+		if c += 48; c > 87 {
+			c += 8
+		}
+		payload = append(payload, c)
+	}
+
+	count := (len(payload) + 59) / 60
+	var lines []string
+	for i := 0; i < count; i++ {
+		part := payload[i*60 : minInt(len(payload), i*60+60)]
+		seq := ""
+		if count > 1 {
+			seq = "3"
+		}
+		body := fmt.Sprintf("AIVDM,%d,%d,%s,A,%s,0", count, i+1, seq, part)
+
+		var sum byte
+		for j := 0; j < len(body); j++ {
+			sum ^= body[j]
+		}
+		line := fmt.Sprintf("!%s*%02X", body, sum)
+		if tag != "" {
+			line = "\\" + tag + "\\" + line
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestVesselTracker(t *testing.T) {
+	tracker := NewVesselTracker()
+	start := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return start }
+
+	// a real class A position report
+	if err := tracker.HandleSentence("!AIVDM,1,1,,B,15M67FC000G?ufbE`FepT@3n00Sa,0*5C"); err != nil {
+		t.Fatal(err)
+	}
+
+	// static and voyage data, over two sentences
+	static := aisSentences("",
+		aisField{bits: 6, value: 5}, aisField{bits: 2}, aisField{bits: 30, value: 366053209},
+		aisField{bits: 2}, aisField{bits: 30, value: 9074729},
+		aisField{bits: 42, text: "WDC4263"}, aisField{bits: 120, text: "GOLDEN BEAR"},
+		aisField{bits: 8, value: 60}, aisField{bits: 62}, aisField{bits: 120, text: "OAKLAND"},
+		aisField{bits: 2})
+	if len(static) != 2 {
+		t.Fatalf("static data has %d sentences, want 2", len(static))
+	}
+
+	// a class B position report, timed by its tag block
+	moved := fmt.Sprintf("c:%d", start.Add(time.Minute).Unix())
+	classB := aisSentences(moved,
+		aisField{bits: 6, value: 18}, aisField{bits: 2}, aisField{bits: 30, value: 366053209},
+		aisField{bits: 8}, aisField{bits: 10, value: 123}, aisField{bits: 1},
+		aisField{bits: 28, value: int64(-122.34 * 600000)}, aisField{bits: 27, value: int64(37.81 * 600000)},
+		aisField{bits: 12, value: 455}, aisField{bits: 9, value: 511}, aisField{bits: 6})
+
+	feed := strings.Join(append(append(static, classB...), "!AIVDM,1,1,,B,15M67FC000G?ufbE`FepT@3n00Sa,0*00"), "\n")
+	if err := tracker.ReadMessages(strings.NewReader(feed)); err != nil {
+		t.Fatal(err)
+	}
+
+	k := tracker.KML()
+
+	positions := k.FindFolders("Positions")[0].features
+	if len(positions) != 1 {
+		t.Fatalf("got %d positions, want 1", len(positions))
+	}
+
+	pm := positions[0].(*Placemark)
+	if pm.name != "GOLDEN BEAR" || pm.id != "vessel-366053209" || pm.style != "vessel-050" {
+		t.Errorf("unexpected placemark %q %q %q", pm.name, pm.id, pm.style)
+	}
+	if p := pm.geometry.(*Point); p.Lat < 37.8099 || p.Lat > 37.8101 || p.Lon < -122.3401 || p.Lon > -122.3399 {
+		t.Errorf("unexpected position %v", p)
+	}
+	for name, want := range map[string]string{
+		"mmsi":        "366053209",
+		"callsign":    "WDC4263",
+		"imo":         "9074729",
+		"shipType":    "60",
+		"speed":       "12.3",
+		"course":      "45.5",
+		"heading":     "",
+		"destination": "OAKLAND",
+	} {
+		if v, _ := pm.Data(name); v != want {
+			t.Errorf("%s = %q, want %q", name, v, want)
+		}
+	}
+
+	track := k.FindFolders("Tracks")[0].features[0].(*Placemark).geometry.(*Track)
+	if len(track.points) != 2 || !track.times[1].Equal(start.Add(time.Minute)) {
+		t.Errorf("unexpected track %v %v", track.points, track.times)
+	}
+
+	if err := k.Validate(); err != nil {
+		t.Errorf("document is invalid: %v", err)
+	}
+
+	// vessels are forgotten after the maximum age
+	tracker.SetMaxAge(time.Minute)
+	later := aisSentences(fmt.Sprintf("c:%d", start.Add(time.Hour).Unix()),
+		aisField{bits: 6, value: 1}, aisField{bits: 2}, aisField{bits: 30, value: 211000001},
+		aisField{bits: 12}, aisField{bits: 10}, aisField{bits: 1}, aisField{bits: 28, value: 6000000}, aisField{bits: 27, value: 32000000},
+		aisField{bits: 12, value: 900}, aisField{bits: 9, value: 88}, aisField{bits: 40})
+	tracker.HandleSentence(later[0])
+	positions = tracker.KML().FindFolders("Positions")[0].features
+	if len(positions) != 1 || positions[0].(*Placemark).style != "vessel-090" {
+		t.Errorf("unexpected positions after an hour: %v", positions)
+	}
+
+	for _, line := range []string{
+		"!AIVDM,1,1,,B,15M67FC000G?ufbE`FepT@3n00Sa,0*00",
+		"$GPGGA,1,1,,B,15M67FC000G?ufbE`FepT@3n00Sa,0",
+		"!AIVDM,1,1,,B,15M67F~~,0",
+	} {
+		if err := tracker.HandleSentence(line); err == nil {
+			t.Errorf("no error for %q", line)
+		}
+	}
+}
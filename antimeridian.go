@@ -0,0 +1,292 @@
+package gokml
+
+import "math"
+
+// CrossesAntimeridian reports whether a segment of the LineString crosses
+// the antimeridian (180 degrees of longitude), taking each segment as the
+// short way around.  Google Earth draws such segments the long way, around
+// the globe.
+func (ls *LineString) CrossesAntimeridian() bool {
+	return crossesAntimeridian(ls.coordinates, false)
+}
+
+// CrossesAntimeridian reports whether the outer boundary of the Polygon
+// crosses the antimeridian (180 degrees of longitude), as for a LineString.
+func (poly *Polygon) CrossesAntimeridian() bool {
+	return crossesAntimeridian(poly.points, true)
+}
+
+// crossesAntimeridian reports whether a line, or a ring including its
+// closing segment, crosses the antimeridian.
+func crossesAntimeridian(points []*Point, ring bool) bool {
+	for i := 1; i < len(points); i++ {
+		if lon := points[i-1].Lon + normalizeLon(points[i].Lon-points[i-1].Lon); lon > 180 || lon < -180 {
+			return true
+		}
+	}
+
+	return ring && len(points) > 2 && crossesAntimeridian([]*Point{points[len(points)-1], points[0]}, false)
+}
+
+// SplitAntimeridian returns the parts of the LineString on either side of
+// the antimeridian, split where it crosses, with Points added on the
+// antimeridian at each end of a crossing segment.  The parts have the
+// altitude mode, extrusion, and draw order of the LineString.  A LineString
+// that doesn't cross is returned as it is.
+func (ls *LineString) SplitAntimeridian() []*LineString {
+	if !ls.CrossesAntimeridian() {
+		return []*LineString{ls}
+	}
+
+	var parts []*LineString
+	part := ls.splitPart()
+	part.AddPoint(ls.coordinates[0])
+
+	for i := 1; i < len(ls.coordinates); i++ {
+		a, b := ls.coordinates[i-1], ls.coordinates[i]
+		d := normalizeLon(b.Lon - a.Lon)
+
+		edge := 0.0
+		if a.Lon+d > 180 {
+			edge = 180
+		} else if a.Lon+d < -180 {
+			edge = -180
+		}
+		if edge != 0 {
+			// where the segment meets the antimeridian
+			f := (edge - a.Lon) / d
+			lat, alt := a.Lat+(b.Lat-a.Lat)*f, a.Alt+(b.Alt-a.Alt)*f
+			if f > 0 {
+				part.AddPoint(&Point{lat, edge, alt})
+			}
+			if len(part.coordinates) > 1 {
+				parts = append(parts, part)
+			}
+
+			part = ls.splitPart()
+			part.AddPoint(&Point{lat, -edge, alt})
+		}
+
+		part.AddPoint(b)
+	}
+	if len(part.coordinates) > 1 {
+		parts = append(parts, part)
+	}
+
+	return parts
+}
+
+// splitPart returns a new, empty LineString like ls.
+func (ls *LineString) splitPart() *LineString {
+	part := NewLineString()
+	part.altitudeMode, part.extrude = ls.altitudeMode, ls.extrude
+	part.drawOrder, part.hasDrawOrder = ls.drawOrder, ls.hasDrawOrder
+
+	return part
+}
+
+// EnclosesPole reports whether the outer boundary of the Polygon goes
+// around a pole, as a satellite footprint or an Arctic region might, so its
+// longitudes turn through 360 degrees.
+func (poly *Polygon) EnclosesPole() bool {
+	_, turned := unwrapRing(poly.points)
+	return turned != 0
+}
+
+// SplitAntimeridian returns the parts of the Polygon on either side of the
+// antimeridian, cut along it, with their holes, and the altitude mode of
+// the Polygon.  A Polygon around a pole, which Google Earth would draw as
+// a sliver or a band around the globe, becomes the cap of the globe beyond
+// the boundary, bounded by the antimeridian and the pole; the cap is around
+// the pole nearer to the boundary's mean latitude.  A Polygon that doesn't
+// cross, or a cap already cut at the antimeridian, is returned as it is.
+func (poly *Polygon) SplitAntimeridian() []*Polygon {
+	outer, turned := unwrapRing(poly.points)
+	if turned == 0 && !poly.CrossesAntimeridian() {
+		return []*Polygon{poly}
+	}
+
+	west, east := unwrappedSpan(outer)
+	if turned != 0 {
+		if east-west >= 360 {
+			// already a cap, cut at the antimeridian
+			return []*Polygon{poly}
+		}
+		outer = capRing(outer, turned)
+		west, east = unwrappedSpan(outer)
+	}
+
+	// cut the unwrapped rings into the parts within each turn of the globe
+	var parts []*Polygon
+	for turn := -1.0; turn <= 1; turn++ {
+		lo, hi := 360*turn-180, 360*turn+180
+		if east <= lo || west >= hi {
+			continue
+		}
+
+		ring := clipRing(outer, lo, hi)
+		if len(ring) < 3 {
+			continue
+		}
+
+		part := NewPolygon()
+		part.altitudeMode = poly.altitudeMode
+		for _, p := range ring {
+			part.AddPoint(&Point{p.Lat, p.Lon - 360*turn, p.Alt})
+		}
+
+		for _, hole := range poly.inner {
+			inner, turned := unwrapRing(hole)
+			if len(inner) == 0 || turned != 0 {
+				continue
+			}
+
+			// holes are unwrapped near the outer boundary
+			shift := 360 * math.Round((outer[0].Lon-inner[0].Lon)/360)
+			for i := range inner {
+				inner[i].Lon += shift
+			}
+
+			var points []*Point
+			for _, p := range clipRing(inner, lo, hi) {
+				points = append(points, &Point{p.Lat, p.Lon - 360*turn, p.Alt})
+			}
+			part.AddInnerBoundary(points...)
+		}
+
+		parts = append(parts, part)
+	}
+
+	return parts
+}
+
+// unwrapRing returns copies of the Points of a ring with their longitudes
+// unwrapped, changing continuously rather than jumping at the antimeridian,
+// and without the closing Point, with the longitude turned through around
+// the ring: 0, or 360 or -360 if it goes around a pole.
+func unwrapRing(ring []*Point) ([]Point, float64) {
+	if len(ring) > 1 && *ring[0] == *ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	if len(ring) == 0 {
+		return nil, 0
+	}
+
+	unwrapped := make([]Point, len(ring))
+	unwrapped[0] = *ring[0]
+	for i := 1; i < len(ring); i++ {
+		unwrapped[i] = *ring[i]
+		unwrapped[i].Lon = unwrapped[i-1].Lon + normalizeLon(ring[i].Lon-ring[i-1].Lon)
+	}
+
+	last := unwrapped[len(unwrapped)-1]
+	closing := last.Lon + normalizeLon(ring[0].Lon-ring[len(ring)-1].Lon)
+
+	return unwrapped, 360 * math.Round((closing-unwrapped[0].Lon)/360)
+}
+
+// unwrappedSpan returns the westernmost and easternmost longitudes of an
+// unwrapped ring.
+func unwrappedSpan(ring []Point) (float64, float64) {
+	west, east := math.Inf(1), math.Inf(-1)
+	for _, p := range ring {
+		west, east = math.Min(west, p.Lon), math.Max(east, p.Lon)
+	}
+
+	return west, east
+}
+
+// capRing returns an unwrapped ring around a pole, which turned through
+// 360 or -360 degrees of longitude, closed over the pole nearer to its mean
+// latitude, so it spans 360 degrees of longitude and can be clipped into
+// parts.
+func capRing(ring []Point, turned float64) []Point {
+	mean := 0.0
+	for _, p := range ring {
+		mean += p.Lat
+	}
+	pole := 90.0
+	if mean < 0 {
+		pole = -90
+	}
+
+	first := ring[0]
+	end := first.Lon + turned
+
+	capped := append(ring[:len(ring):len(ring)], Point{first.Lat, end, first.Alt})
+	return append(capped, Point{pole, end, first.Alt}, Point{pole, first.Lon, first.Alt})
+}
+
+// clipRing returns the part of a ring between two longitudes (the
+// Sutherland-Hodgman algorithm), with Points added where it crosses them.
+func clipRing(ring []Point, lo float64, hi float64) []Point {
+	clip := func(ring []Point, inside func(p Point) bool, edge float64) []Point {
+		var clipped []Point
+		for i, p := range ring {
+			prev := ring[(i+len(ring)-1)%len(ring)]
+			if inside(p) != inside(prev) {
+				f := (edge - prev.Lon) / (p.Lon - prev.Lon)
+				clipped = append(clipped, Point{prev.Lat + (p.Lat-prev.Lat)*f, edge, prev.Alt + (p.Alt-prev.Alt)*f})
+			}
+			if inside(p) {
+				clipped = append(clipped, p)
+			}
+		}
+		return clipped
+	}
+
+	ring = clip(ring, func(p Point) bool { return p.Lon >= lo }, lo)
+	if len(ring) == 0 {
+		return nil
+	}
+
+	return clip(ring, func(p Point) bool { return p.Lon <= hi }, hi)
+}
+
+// SplitAntimeridian splits the LineStrings and Polygons of the document's
+// Placemarks that cross the antimeridian (180 degrees of longitude), which
+// Google Earth would draw the long way around the globe, into their parts
+// on either side, in a MultiGeometry.  Polygons around a pole become caps,
+// as by Polygon.SplitAntimeridian.  Tracks aren't split, so they can still
+// be animated.
+func (k *KML) SplitAntimeridian() {
+	walkPlacemarks(k.rootFolder, func(pm *Placemark) {
+		pm.geometry = splitGeometry(pm.geometry)
+	})
+}
+
+// splitGeometry returns a geometry split at the antimeridian, as a
+// MultiGeometry of its parts, or the geometry if it doesn't cross.
+func splitGeometry(geom renderable) renderable {
+	var parts []renderable
+	switch g := geom.(type) {
+	case *LineString:
+		for _, part := range g.SplitAntimeridian() {
+			parts = append(parts, part)
+		}
+	case *Polygon:
+		for _, part := range g.SplitAntimeridian() {
+			parts = append(parts, part)
+		}
+	case *MultiGeometry:
+		g.mutex.Lock()
+		for i, child := range g.geometries {
+			g.geometries[i] = splitGeometry(child)
+		}
+		g.mutex.Unlock()
+		return g
+	default:
+		return geom
+	}
+
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	mg := NewMultiGeometry()
+	for _, part := range parts {
+		mg.AddGeometry(part)
+	}
+
+	return mg
+}
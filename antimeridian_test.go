@@ -0,0 +1,185 @@
+package gokml
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSplitLineAntimeridian(t *testing.T) {
+	line := NewLineString()
+	line.SetAltitudeMode(Absolute)
+	for _, lon := range []float64{170, 178, -178, -170, 179} {
+		line.AddPoint(NewPoint(lon/10, lon, 100))
+	}
+	if !line.CrossesAntimeridian() {
+		t.Fatal("crossing not detected")
+	}
+
+	parts := line.SplitAntimeridian()
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts, want 3", len(parts))
+	}
+
+	want := [][]float64{{170, 178, 180}, {-180, -178, -170, -180}, {180, 179}}
+	for i, part := range parts {
+		if part.altitudeMode != Absolute {
+			t.Errorf("part %d lost its altitude mode", i)
+		}
+		if part.CrossesAntimeridian() {
+			t.Errorf("part %d crosses the antimeridian", i)
+		}
+		var lons []float64
+		for _, p := range part.coordinates {
+			lons = append(lons, p.Lon)
+		}
+		if len(lons) != len(want[i]) {
+			t.Errorf("part %d has longitudes %v, want %v", i, lons, want[i])
+			continue
+		}
+		for j := range lons {
+			if lons[j] != want[i][j] {
+				t.Errorf("part %d has longitudes %v, want %v", i, lons, want[i])
+				break
+			}
+		}
+	}
+
+	// the crossing is halfway between 178 and -178
+	if p := parts[0].coordinates[2]; math.Abs(p.Lat-(17.8+(-17.8-17.8)/2)) > 1e-9 || p.Alt != 100 {
+		t.Errorf("unexpected crossing %v", p)
+	}
+
+	plain := NewLineString()
+	plain.AddPoint(NewPoint(0, 170, 0))
+	plain.AddPoint(NewPoint(0, -170, 0))
+	plain.AddPoint(NewPoint(0, 170, 0))
+	if parts := plain.SplitAntimeridian(); len(parts) != 3 {
+		t.Errorf("got %d parts going back and forth, want 3", len(parts))
+	}
+
+	east := NewLineString()
+	east.AddPoint(NewPoint(0, 10, 0))
+	east.AddPoint(NewPoint(0, 20, 0))
+	if east.CrossesAntimeridian() || east.SplitAntimeridian()[0] != east {
+		t.Errorf("line that doesn't cross was split")
+	}
+}
+
+func TestSplitPolygonAntimeridian(t *testing.T) {
+	poly := NewPolygon()
+	for _, p := range [][2]float64{{-10, 170}, {-10, -170}, {10, -170}, {10, 170}} {
+		poly.AddPoint(NewPoint(p[0], p[1], 0))
+	}
+	poly.AddInnerBoundary(NewPoint(-1, 179, 0), NewPoint(-1, -179, 0), NewPoint(1, -179, 0), NewPoint(1, 179, 0))
+	if !poly.CrossesAntimeridian() {
+		t.Fatal("crossing not detected")
+	}
+
+	parts := poly.SplitAntimeridian()
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	for i, want := range []Bounds{{10, -10, 180, 170}, {10, -10, -170, -180}} {
+		if b, _ := parts[i].Bounds(); b != want {
+			t.Errorf("part %d bounds = %v, want %v", i, b, want)
+		}
+		if len(parts[i].inner) != 1 {
+			t.Errorf("part %d has %d holes, want 1", i, len(parts[i].inner))
+		}
+		if parts[i].CrossesAntimeridian() {
+			t.Errorf("part %d crosses the antimeridian", i)
+		}
+	}
+
+	// a cap that's already cut at the antimeridian is left as it is
+	polar := Circle(NewPoint(89, 0, 0), 500000, 36)
+	if !polar.EnclosesPole() {
+		t.Errorf("polar cap doesn't enclose the pole")
+	}
+	if parts := polar.SplitAntimeridian(); len(parts) != 1 || parts[0] != polar {
+		t.Errorf("polar cap was split")
+	}
+	if poly.EnclosesPole() {
+		t.Errorf("pacific polygon encloses a pole")
+	}
+}
+
+func TestSplitPolarPolygon(t *testing.T) {
+	// a satellite footprint around the north pole
+	footprint := NewPolygon()
+	for lon := 0.0; lon < 360; lon += 30 {
+		footprint.AddPoint(NewPoint(80, normalizeLon(lon), 0))
+	}
+	if !footprint.EnclosesPole() {
+		t.Fatal("pole not detected")
+	}
+
+	parts := footprint.SplitAntimeridian()
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	for i, want := range []Bounds{{90, 80, 180, 0}, {90, 80, 0, -180}} {
+		if b, _ := parts[i].Bounds(); b != want {
+			t.Errorf("part %d bounds = %v, want %v", i, b, want)
+		}
+		if parts[i].CrossesAntimeridian() {
+			t.Errorf("part %d crosses the antimeridian", i)
+		}
+	}
+
+	// a clockwise ring around the south pole, starting on the antimeridian
+	south := NewPolygon()
+	for lon := 180.0; lon > -180; lon -= 45 {
+		south.AddPoint(NewPoint(-70, lon, 0))
+	}
+	parts = south.SplitAntimeridian()
+	if len(parts) != 1 {
+		t.Fatalf("got %d parts around the south pole, want 1", len(parts))
+	}
+	west, east, pole := 0.0, 0.0, 0.0
+	for _, p := range parts[0].points {
+		west, east, pole = math.Min(west, p.Lon), math.Max(east, p.Lon), math.Min(pole, p.Lat)
+	}
+	if west != -180 || east != 180 || pole != -90 {
+		t.Errorf("south cap spans %v to %v, to %v", west, east, pole)
+	}
+}
+
+func TestKMLSplitAntimeridian(t *testing.T) {
+	line := NewLineString()
+	line.AddPoint(NewPoint(0, 170, 0))
+	line.AddPoint(NewPoint(0, -170, 0))
+	poly := NewPolygon()
+	for _, p := range [][2]float64{{-10, 170}, {-10, -170}, {10, -170}, {10, 170}} {
+		poly.AddPoint(NewPoint(p[0], p[1], 0))
+	}
+	mg := NewMultiGeometry()
+	mg.AddGeometry(poly)
+	mg.AddGeometry(NewPoint(0, 0, 0))
+
+	k := NewKML("Pacific")
+	k.AddFeature(NewPlacemark("Line", "", line))
+	k.AddFeature(NewPlacemark("Multi", "", mg))
+	k.AddFeature(NewPlacemark("Point", "", NewPoint(1, 1, 0)))
+	k.SplitAntimeridian()
+
+	pms := k.FindPlacemarks(func(*Placemark) bool { return true })
+	if split, ok := pms[0].geometry.(*MultiGeometry); !ok || len(split.geometries) != 2 {
+		t.Errorf("line wasn't split: %v", pms[0].geometry)
+	}
+	if inner, ok := mg.geometries[0].(*MultiGeometry); !ok || len(inner.geometries) != 2 {
+		t.Errorf("polygon in a MultiGeometry wasn't split: %v", mg.geometries[0])
+	}
+	if _, ok := pms[2].geometry.(*Point); !ok {
+		t.Errorf("point was changed")
+	}
+
+	out := k.Render()
+	if strings.Contains(out, "-170.000000,0.000000,0.000000 170") {
+		t.Errorf("output still crosses:\n%s", out)
+	}
+	if err := k.Validate(); err != nil {
+		t.Errorf("document is invalid: %v", err)
+	}
+}
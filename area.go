@@ -0,0 +1,72 @@
+package gokml
+
+import "math"
+
+// Area returns the area of the Polygon in square meters, less its holes, on
+// a sphere the mean size of the Earth, which is within about 0.5% of the
+// area on the ellipsoid; see EllipsoidArea.  A Polygon around a pole has
+// the area of the cap around the pole nearer to its mean latitude, as for
+// SplitAntimeridian.
+func (poly *Polygon) Area() float64 {
+	return polygonArea(poly, earthRadius, func(lat float64) float64 { return math.Sin(radians(lat)) })
+}
+
+// EllipsoidArea returns the area of the Polygon in square meters, less its
+// holes, on the WGS84 ellipsoid, such as for the acreage of a parcel or
+// zone, as for Area.  The sphere of the same area as the ellipsoid is used,
+// with latitudes mapped to it by area (the authalic latitude), which is
+// exact for edges along meridians and parallels.
+func (poly *Polygon) EllipsoidArea() float64 {
+	e := wgs84
+	e2 := e.f * (2 - e.f)
+	ecc := math.Sqrt(e2)
+
+	// q is proportional to the area from the equator to a latitude
+	q := func(lat float64) float64 {
+		sin := math.Sin(radians(lat))
+		return (1 - e2) * (sin/(1-e2*sin*sin) + math.Atanh(ecc*sin)/ecc)
+	}
+	qp := q(90)
+
+	return polygonArea(poly, e.a*math.Sqrt(qp/2), func(lat float64) float64 { return q(lat) / qp })
+}
+
+// polygonArea returns the area of the Polygon's outer boundary less its
+// holes on a sphere of the radius, with the sine of each latitude mapped to
+// the sphere.
+func polygonArea(poly *Polygon, radius float64, sine func(lat float64) float64) float64 {
+	poly.mutex.Lock()
+	defer poly.mutex.Unlock()
+
+	area := sphereRingArea(poly.points, radius, sine)
+	for _, hole := range poly.inner {
+		area -= sphereRingArea(hole, radius, sine)
+	}
+
+	return math.Max(area, 0)
+}
+
+// sphereRingArea returns the area of a ring on a sphere of the radius, by
+// summing the area between each edge and the south pole (after Chamberlain
+// and Duquette), or the area of the cap around the pole nearer to its mean
+// latitude if it goes around a pole.
+func sphereRingArea(ring []*Point, radius float64, sine func(lat float64) float64) float64 {
+	if len(ring) < 3 {
+		return 0
+	}
+
+	sum, mean := 0.0, 0.0
+	for i, p := range ring {
+		next := ring[(i+1)%len(ring)]
+		sum += radians(normalizeLon(next.Lon-p.Lon)) * (2 + sine(p.Lat) + sine(next.Lat))
+		mean += p.Lat
+	}
+	area := math.Abs(sum) / 2 * radius * radius
+
+	// around a pole, the sum is the area to the south
+	if _, turned := unwrapRing(ring); turned != 0 && mean > 0 {
+		area = 4*math.Pi*radius*radius - area
+	}
+
+	return area
+}
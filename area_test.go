@@ -0,0 +1,70 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPolygonArea(t *testing.T) {
+	// a degree square on the equator is bounded by meridians and parallels
+	square := polygonOf([2]float64{0, 0}, [2]float64{0, 1}, [2]float64{1, 1}, [2]float64{1, 0})
+	want := earthRadius * earthRadius * radians(1) * math.Sin(radians(1))
+	if got := square.Area(); math.Abs(got-want) > 1e-6*want {
+		t.Errorf("square area = %v, want %v", got, want)
+	}
+
+	// the same across the antimeridian, and clockwise
+	pacific := polygonOf([2]float64{0, 179.5}, [2]float64{1, 179.5}, [2]float64{1, -179.5}, [2]float64{0, -179.5})
+	if got := pacific.Area(); math.Abs(got-want) > 1e-6*want {
+		t.Errorf("pacific area = %v, want %v", got, want)
+	}
+
+	// a hole of a quarter of it
+	square.AddInnerBoundary(NewPoint(0, 0, 0), NewPoint(0, 0.5, 0), NewPoint(0.5, 0.5, 0), NewPoint(0.5, 0, 0))
+	if got := square.Area(); math.Abs(got-0.75*want) > 0.01*want {
+		t.Errorf("area with a hole = %v, want about %v", got, 0.75*want)
+	}
+
+	// a circle of 10 km is close to a spherical cap
+	circle := Circle(NewPoint(45, 10, 0), 10000, 360)
+	spherical := 2 * math.Pi * earthRadius * earthRadius * (1 - math.Cos(10000/earthRadius))
+	if got := circle.Area(); math.Abs(got-spherical) > 1e-3*spherical {
+		t.Errorf("circle area = %v, want about %v", got, spherical)
+	}
+
+	// a polar cap, cut at the antimeridian or not
+	arctic := polygonOf([2]float64{80, 0}, [2]float64{80, 90}, [2]float64{80, 180}, [2]float64{80, -90})
+	want = 2 * math.Pi * earthRadius * earthRadius * (1 - math.Sin(radians(80)))
+	if got := arctic.Area(); math.Abs(got-want) > 1e-6*want {
+		t.Errorf("arctic area = %v, want %v", got, want)
+	}
+	antarctic := polygonOf([2]float64{-80, 0}, [2]float64{-80, -90}, [2]float64{-80, 180}, [2]float64{-80, 90})
+	if got := antarctic.Area(); math.Abs(got-want) > 1e-6*want {
+		t.Errorf("antarctic area = %v, want %v", got, want)
+	}
+
+	if NewPolygon().Area() != 0 {
+		t.Errorf("empty polygon has an area")
+	}
+}
+
+func TestPolygonEllipsoidArea(t *testing.T) {
+	// the northern hemisphere is half the 510065621.7 square km of WGS84
+	north := polygonOf([2]float64{0, 0}, [2]float64{0, 90}, [2]float64{0, 180}, [2]float64{0, -90})
+	if got := north.EllipsoidArea() / 1e6; math.Abs(got-510065621.7/2) > 1 {
+		t.Errorf("hemisphere area = %v square km", got)
+	}
+
+	// a degree square on the equator, from the area of the ellipsoid's zone
+	square := polygonOf([2]float64{0, 0}, [2]float64{0, 1}, [2]float64{1, 1}, [2]float64{1, 0})
+	e2 := wgs84.f * (2 - wgs84.f)
+	sin := math.Sin(radians(1))
+	want := wgs84.a * wgs84.a * (1 - e2) / 2 * radians(1) * (sin/(1-e2*sin*sin) + math.Atanh(math.Sqrt(e2)*sin)/math.Sqrt(e2))
+	got := square.EllipsoidArea()
+	if math.Abs(got-want) > 1e-6*want {
+		t.Errorf("square area = %v, want %v", got, want)
+	}
+	if sphere := square.Area(); math.Abs(sphere-got)/got > 0.005 {
+		t.Errorf("spherical area %v is far from %v", sphere, got)
+	}
+}
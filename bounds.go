@@ -0,0 +1,169 @@
+package gokml
+
+import (
+	"math"
+	"sort"
+)
+
+// Bounds returns the Point as a bounding rectangle of no size.  It returns
+// false if the Point is nil.
+func (p *Point) Bounds() (Bounds, bool) {
+	return boundsOf(p)
+}
+
+// Bounds returns the smallest bounding rectangle holding the Points of the
+// LineString, or false if it has none.  Rectangles across the antimeridian
+// have a West edge greater than the East edge, as in a LatLonBox.
+func (ls *LineString) Bounds() (Bounds, bool) {
+	return boundsOf(ls)
+}
+
+// Bounds returns the smallest bounding rectangle holding the Points of the
+// Polygon, or false if it has none.  Rectangles across the antimeridian
+// have a West edge greater than the East edge, as in a LatLonBox.
+func (poly *Polygon) Bounds() (Bounds, bool) {
+	return boundsOf(poly)
+}
+
+// Bounds returns the smallest bounding rectangle holding the Points of the
+// geometries, or false if there are none.  Rectangles across the
+// antimeridian have a West edge greater than the East edge, as in a
+// LatLonBox.
+func (mg *MultiGeometry) Bounds() (Bounds, bool) {
+	return boundsOf(mg)
+}
+
+// Bounds returns the smallest bounding rectangle holding the Points of the
+// Track, or false if it has none.  Rectangles across the antimeridian have
+// a West edge greater than the East edge, as in a LatLonBox.
+func (t *Track) Bounds() (Bounds, bool) {
+	return boundsOf(t)
+}
+
+// Bounds returns the smallest bounding rectangle holding the geometry of the
+// Placemark, or false if it has none.  Rectangles across the antimeridian
+// have a West edge greater than the East edge, as in a LatLonBox.
+func (pm *Placemark) Bounds() (Bounds, bool) {
+	return boundsOf(pm)
+}
+
+// Bounds returns the smallest bounding rectangle holding the overlay, from
+// its LatLonBox (ignoring any rotation) or its corners.
+func (overlay *GroundOverlay) Bounds() (Bounds, bool) {
+	return boundsOf(overlay)
+}
+
+// Bounds returns the smallest bounding rectangle holding the Placemarks and
+// GroundOverlays in the Folder and its subfolders, such as for a Region or
+// a view of the Folder, or false if there are none.  Rectangles across the
+// antimeridian have a West edge greater than the East edge, as in a
+// LatLonBox.
+func (f *Folder) Bounds() (Bounds, bool) {
+	return boundsOf(f)
+}
+
+// Bounds returns the smallest bounding rectangle holding the Placemarks and
+// GroundOverlays of the document, or false if there are none.  Rectangles
+// across the antimeridian have a West edge greater than the East edge, as
+// in a LatLonBox.
+func (k *KML) Bounds() (Bounds, bool) {
+	return boundsOf(k.rootFolder)
+}
+
+// boundsOf returns the smallest bounding rectangle holding the Points of a
+// feature or geometry, or false if it has none.
+func boundsOf(feature renderable) (Bounds, bool) {
+	b := Bounds{North: -90, South: 90}
+	var lons []float64
+
+	add := func(p *Point) {
+		b.North = math.Max(b.North, p.Lat)
+		b.South = math.Min(b.South, p.Lat)
+		lons = append(lons, p.Lon)
+	}
+
+	var walk func(feature renderable)
+	walk = func(feature renderable) {
+		switch f := feature.(type) {
+		case *Folder:
+			for _, child := range f.features {
+				walk(child)
+			}
+		case *GroundOverlay:
+			if len(f.quad) > 0 {
+				for _, p := range f.quad {
+					add(p)
+				}
+			} else {
+				add(&Point{f.box.North, f.box.West, 0})
+				add(&Point{f.box.South, f.box.East, 0})
+			}
+		case *Point:
+			if f != nil {
+				add(f)
+			}
+		default:
+			walkPoints(feature, add)
+		}
+	}
+	walk(feature)
+
+	if len(lons) == 0 {
+		return Bounds{}, false
+	}
+
+	b.West, b.East = lonSpan(lons)
+
+	return b, true
+}
+
+// lonSpan returns the west and east edges of the shortest span of longitude
+// holding all of lons, which is across the antimeridian (west greater than
+// east) if that's shorter.
+func lonSpan(lons []float64) (float64, float64) {
+	sort.Float64s(lons)
+
+	// the span is all but the widest gap between neighboring longitudes,
+	// which is the gap across the antimeridian for most data
+	west, east := lons[0], lons[len(lons)-1]
+	widest := lons[0] + 360 - lons[len(lons)-1]
+	for i := 1; i < len(lons); i++ {
+		if gap := lons[i] - lons[i-1]; gap > widest {
+			widest = gap
+			west, east = lons[i], lons[i-1]
+		}
+	}
+
+	return west, east
+}
+
+// contains reports whether the rectangle holds a location, including its
+// edges.  Rectangles across the antimeridian have a West edge greater than
+// the East edge.
+func (b *Bounds) contains(lat float64, lon float64) bool {
+	if lat < b.South || lat > b.North {
+		return false
+	}
+	if b.West > b.East {
+		return lon >= b.West || lon <= b.East
+	}
+
+	return lon >= b.West && lon <= b.East
+}
+
+// padded returns the rectangle grown to at least span degrees in each
+// direction, about its center, so a rectangle around a single location still
+// covers an area.  Latitudes are kept within ±90.
+func (b Bounds) padded(span float64) Bounds {
+	if b.North-b.South < span {
+		mid := (b.North + b.South) / 2
+		b.North, b.South = math.Min(mid+span/2, 90), math.Max(mid-span/2, -90)
+	}
+
+	if width := b.East - b.West; width >= 0 && width < span {
+		mid := (b.East + b.West) / 2
+		b.East, b.West = normalizeLon(mid+span/2), normalizeLon(mid-span/2)
+	}
+
+	return b
+}
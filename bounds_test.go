@@ -0,0 +1,62 @@
+package gokml
+
+import "testing"
+
+func TestBounds(t *testing.T) {
+	line := NewLineString()
+	line.AddPoint(NewPoint(40, -105, 0))
+	line.AddPoint(NewPoint(41, -104, 0))
+	if b, ok := line.Bounds(); !ok || b != (Bounds{41, 40, -104, -105}) {
+		t.Errorf("line bounds = %v, %v", b, ok)
+	}
+
+	if b, ok := NewPoint(10, 20, 5).Bounds(); !ok || b != (Bounds{10, 10, 20, 20}) {
+		t.Errorf("point bounds = %v, %v", b, ok)
+	}
+	var none *Point
+	if _, ok := none.Bounds(); ok {
+		t.Errorf("nil point has bounds")
+	}
+	if _, ok := NewPolygon().Bounds(); ok {
+		t.Errorf("empty polygon has bounds")
+	}
+
+	// across the antimeridian, the short way
+	pacific := NewPolygon()
+	pacific.AddPoint(NewPoint(-10, 170, 0))
+	pacific.AddPoint(NewPoint(-20, -175, 0))
+	pacific.AddPoint(NewPoint(-15, 178, 0))
+	if b, ok := pacific.Bounds(); !ok || b != (Bounds{-10, -20, -175, 170}) {
+		t.Errorf("pacific bounds = %v, %v", b, ok)
+	}
+
+	k := NewKML("Bounds")
+	folder := NewFolder("Lines", "")
+	folder.AddFeature(NewPlacemark("Line", "", line))
+	folder.AddFeature(NewPlacemark("Empty", "", nil))
+	k.AddFeature(folder)
+	k.AddFeature(NewGroundOverlay("Map", "", "map.png", Bounds{42, 41.5, -103, -103.5}))
+	k.AddFeature(NewStyle("style", 0xff, 0, 0, 0))
+
+	if b, ok := folder.Bounds(); !ok || b != (Bounds{41, 40, -104, -105}) {
+		t.Errorf("folder bounds = %v, %v", b, ok)
+	}
+	if b, ok := k.Bounds(); !ok || b != (Bounds{42, 40, -103, -105}) {
+		t.Errorf("document bounds = %v, %v", b, ok)
+	}
+	if _, ok := NewKML("Empty").Bounds(); ok {
+		t.Errorf("empty document has bounds")
+	}
+}
+
+func TestBoundsPadded(t *testing.T) {
+	if b := (Bounds{40, 40, -105, -105}).padded(1); b != (Bounds{40.5, 39.5, -104.5, -105.5}) {
+		t.Errorf("point padded to %v", b)
+	}
+	if b := (Bounds{90, 90, 180, 180}).padded(1); b != (Bounds{90, 89.5, -179.5, 179.5}) {
+		t.Errorf("corner padded to %v", b)
+	}
+	if b := (Bounds{41, 40, -104, -105}).padded(1); b != (Bounds{41, 40, -104, -105}) {
+		t.Errorf("big enough rectangle padded to %v", b)
+	}
+}
@@ -0,0 +1,148 @@
+package gokml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// bundleDepth is how deep WriteBundle follows NetworkLinks in linked
+// documents.
+const bundleDepth = 5
+
+// WriteBundle writes a self-contained KMZ archive of the document for use
+// without a network connection, such as in the field.  NetworkLinks to
+// remote documents are replaced by Folders of the linked documents'
+// features, and remote icons, overlay images, models, and other resources
+// are downloaded with client and embedded (see InlineResources).  If client
+// is nil, http.DefaultClient is used.  The document itself isn't changed.
+// If progress isn't nil, it receives the number of linked documents
+// fetched, as the "linking" stage, and then the resources downloaded, as
+// the "downloading" stage.  Returns an error, without writing anything, if
+// a resource can't be downloaded or is larger than maxSize bytes.
+func (k *KML) WriteBundle(w io.Writer, client *http.Client, maxSize int64, progress Progress) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	b := &bundler{k.Snapshot(), client, maxSize, progress, 0}
+	if err := b.materialize(b.k.rootFolder, 0); err != nil {
+		return err
+	}
+
+	if err := b.k.InlineResources(client, maxSize, progress); err != nil {
+		return err
+	}
+
+	var remote error
+	walkHrefs(b.k.rootFolder, func(href string) {
+		if isRemote(href) && remote == nil {
+			remote = fmt.Errorf("gokml: %s is larger than %d bytes", href, maxSize)
+		}
+	})
+	if remote != nil {
+		return remote
+	}
+
+	return b.k.WriteKMZ(w)
+}
+
+// bundler builds the document written by WriteBundle.
+type bundler struct {
+	k        *KML
+	client   *http.Client
+	maxSize  int64
+	progress Progress
+	fetched  int // number of linked documents fetched
+}
+
+// materialize replaces the NetworkLinks to remote documents in the folder
+// with Folders of the linked documents' features.
+func (b *bundler) materialize(f *Folder, depth int) error {
+	for i, feature := range f.features {
+		switch c := feature.(type) {
+		case *Folder:
+			if err := b.materialize(c, depth); err != nil {
+				return err
+			}
+		case *NetworkLink:
+			if !isRemote(c.href) {
+				continue
+			}
+			if depth >= bundleDepth {
+				return fmt.Errorf("gokml: %s is linked more than %d NetworkLinks deep", c.href, bundleDepth)
+			}
+
+			linked, err := b.fetchDocument(c.href)
+			if err != nil {
+				return err
+			}
+
+			b.fetched++
+			if b.progress != nil {
+				b.progress.Report("linking", int64(b.fetched), -1)
+			}
+			if err := b.materialize(linked.rootFolder, depth+1); err != nil {
+				return err
+			}
+
+			folder := NewFolder(c.name, c.description)
+			folder.features = linked.rootFolder.features
+			folder.extra = linked.rootFolder.extra
+			f.features[i] = folder
+		}
+	}
+
+	return nil
+}
+
+// fetchDocument downloads and parses a linked KML or KMZ document.  Relative
+// hrefs in it are resolved against its URL, or embedded if they refer to
+// files in the KMZ archive.
+func (b *bundler) fetchDocument(href string) (*KML, error) {
+	data, err := fetchResource(b.client, href, b.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("gokml: %s is larger than %d bytes", href, b.maxSize)
+	}
+
+	base, err := url.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+
+	var linked *KML
+	var files fs.FS
+	if bytes.HasPrefix(data, []byte("PK")) {
+		linked, files, err = ReadKMZ(bytes.NewReader(data), int64(len(data)))
+	} else {
+		linked, err = Parse(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gokml: reading %s: %v", href, err)
+	}
+
+	linked.RewriteHrefs(func(h string) string {
+		u, err := url.Parse(h)
+		if err != nil || u.IsAbs() || len(h) == 0 {
+			return h
+		}
+
+		if name := strings.TrimPrefix(u.Path, "./"); files != nil && fs.ValidPath(name) {
+			if data, err := fs.ReadFile(files, name); err == nil {
+				return b.k.AddAssetData(path.Base(name), data)
+			}
+		}
+
+		return base.ResolveReference(u).String()
+	})
+
+	return linked, nil
+}
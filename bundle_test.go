@@ -0,0 +1,103 @@
+package gokml
+
+import (
+	"bytes"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteBundle(t *testing.T) {
+	layer := NewKML("Layer")
+	style := NewStyle("pin", 255, 255, 0, 0)
+	style.SetIconURL("icons/pin.png")
+	layer.AddFeature(style)
+	pm := NewPlacemark("Well", "", NewPoint(1, 2, 0))
+	pm.SetStyle("pin")
+	layer.AddFeature(pm)
+
+	packed := NewKML("Packed")
+	overlay := NewGroundOverlay("Sheet", "", packed.AddAssetData("sheet.png", []byte("sheet")), Bounds{1, 0, 1, 0})
+	packed.AddFeature(overlay)
+	var kmz bytes.Buffer
+	if err := packed.WriteKMZ(&kmz); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/layer.kml":
+			w.Write(layer.RenderBytes())
+		case "/packed.kmz":
+			w.Write(kmz.Bytes())
+		case "/icons/pin.png":
+			w.Write([]byte("pin"))
+		case "/models/tower.dae":
+			w.Write([]byte("<COLLADA/>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	doc := `<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+	<NetworkLink><name>Layer</name><Link><href>` + server.URL + `/layer.kml</href></Link></NetworkLink>
+	<Folder><name>Maps</name>
+		<NetworkLink><name>Packed</name><Link><href>` + server.URL + `/packed.kmz</href></Link></NetworkLink>
+	</Folder>
+	<Placemark><name>Tower</name><Model><Link><href>` + server.URL + `/models/tower.dae</href></Link></Model></Placemark>
+</Document></kml>`
+	k, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := k.Render()
+
+	var buf bytes.Buffer
+	stages := make(map[string]int64)
+	progress := ProgressFunc(func(stage string, current int64, total int64) {
+		stages[stage] = current
+	})
+
+	if err := k.WriteBundle(&buf, server.Client(), 1<<20, progress); err != nil {
+		t.Fatal(err)
+	}
+	if stages["linking"] != 2 || stages["downloading"] != 2 {
+		t.Errorf("unexpected progress %v", stages)
+	}
+	if k.Render() != before {
+		t.Error("document changed")
+	}
+
+	bundle, files, err := ReadKMZ(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walkHrefs(bundle.rootFolder, func(href string) {
+		if isRemote(href) {
+			t.Errorf("remote href %q in the bundle", href)
+		}
+	})
+
+	if len(bundle.Placemarks()) != 2 {
+		t.Errorf("got %d placemarks, want the linked one too", len(bundle.Placemarks()))
+	}
+	for name, want := range map[string]string{"files/pin.png": "pin", "files/sheet.png": "sheet", "files/tower.dae": "<COLLADA/>"} {
+		if data, err := fs.ReadFile(files, name); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v", name, data, err)
+		}
+	}
+
+	if err := k.WriteBundle(&buf, server.Client(), 4, nil); err == nil {
+		t.Error("resource larger than the limit not reported")
+	}
+
+	broken := NewKML("Broken")
+	broken.AddFeature(NewNetworkLink("Gone", "", server.URL+"/gone.kml"))
+	if err := broken.WriteBundle(&buf, server.Client(), 1<<20, nil); err == nil {
+		t.Error("missing linked document not reported")
+	}
+}
@@ -0,0 +1,199 @@
+package gokml
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// catalogMinSpan is the smallest extent, in degrees, of a catalog Region;
+// products at a single location get a Region about 100 meters across.
+const catalogMinSpan = 0.001
+
+// NewCatalog scans dir (recursively) for KML and KMZ products and returns a
+// master catalog document containing a NetworkLink for each one.  Each link
+// carries the product's document name and description (falling back to the
+// file name), a Region covering the product's coordinates, and a TimeStamp
+// of the file's last-updated time.  Links use paths relative to dir, so the
+// catalog should be written into dir.
+func NewCatalog(name string, dir string) (*KML, error) {
+	k := NewKML(name)
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".kml" && ext != ".kmz" {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entry, err := readCatalogEntry(p, ext == ".kmz")
+		if err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		if len(entry.name) == 0 {
+			entry.name = strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+		}
+
+		nl := NewNetworkLink(entry.name, entry.description, filepath.ToSlash(rel))
+		nl.SetTimeStamp(info.ModTime().UTC().Truncate(time.Second))
+		if entry.hasBounds {
+			nl.SetRegion(entry.bounds.padded(catalogMinSpan))
+		}
+		k.AddFeature(nl)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+type catalogEntry struct {
+	name        string
+	description string
+	bounds      Bounds
+	hasBounds   bool
+}
+
+func readCatalogEntry(p string, isKMZ bool) (*catalogEntry, error) {
+	if !isKMZ {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return scanCatalogEntry(f)
+	}
+
+	z, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	root := kmzRoot(&z.Reader)
+	if root == nil {
+		return nil, fmt.Errorf("no KML document in archive")
+	}
+
+	r, err := root.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return scanCatalogEntry(r)
+}
+
+// scanCatalogEntry reads the top-level name and description of a KML
+// document and the bounds of all of its coordinates.
+func scanCatalogEntry(r io.Reader) (*catalogEntry, error) {
+	entry := new(catalogEntry)
+	d := xml.NewDecoder(r)
+	var stack []string
+	var text strings.Builder
+	gotName, gotDesc := false, false
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			parent := ""
+			if len(stack) > 1 {
+				parent = stack[len(stack)-2]
+			}
+			topLevel := len(stack) <= 3 && (parent == "Document" || parent == "Folder")
+
+			switch t.Name.Local {
+			case "name":
+				if topLevel && !gotName {
+					entry.name = strings.TrimSpace(text.String())
+					gotName = true
+				}
+			case "description":
+				if topLevel && !gotDesc {
+					entry.description = strings.TrimSpace(text.String())
+					gotDesc = true
+				}
+			case "coordinates":
+				entry.addCoordinates(text.String())
+			}
+
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	return entry, nil
+}
+
+func (entry *catalogEntry) addCoordinates(s string) {
+	for _, tuple := range strings.Fields(s) {
+		parts := strings.Split(tuple, ",")
+		if len(parts) < 2 {
+			continue
+		}
+
+		lon, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			continue
+		}
+
+		lat, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			continue
+		}
+
+		if !entry.hasBounds {
+			entry.bounds = Bounds{lat, lat, lon, lon}
+			entry.hasBounds = true
+			continue
+		}
+
+		entry.bounds.North = math.Max(entry.bounds.North, lat)
+		entry.bounds.South = math.Min(entry.bounds.South, lat)
+		entry.bounds.East = math.Max(entry.bounds.East, lon)
+		entry.bounds.West = math.Min(entry.bounds.West, lon)
+	}
+}
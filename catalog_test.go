@@ -0,0 +1,75 @@
+package gokml
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewCatalog(t *testing.T) {
+	dir := t.TempDir()
+
+	k := NewKML("Cities")
+	k.AddFeature(NewPlacemark("Denver", "", NewPoint(39.74, -104.99, 0.0)))
+	k.AddFeature(NewPlacemark("Boulder", "", NewPoint(40.01, -105.27, 0.0)))
+	if err := os.WriteFile(filepath.Join(dir, "cities.kml"), []byte(k.Render()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	depot := NewKML("Depot")
+	depot.AddFeature(NewPlacemark("Depot", "", NewPoint(40.0, -105.0, 0.0)))
+	if err := os.WriteFile(filepath.Join(dir, "depot.kml"), []byte(depot.Render()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "sub", "archive.kmz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(f)
+	w, _ := z.Create("doc.kml")
+	w.Write([]byte(NewKML("").Render()))
+	z.Close()
+	f.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, err := NewCatalog("Catalog", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := catalog.Render()
+
+	for _, want := range []string{
+		"<name>Cities</name>",
+		"<href>cities.kml</href>",
+		"<north>40.010000</north>",
+		"<west>-105.270000</west>",
+		"<name>archive</name>",
+		"<href>sub/archive.kmz</href>",
+		"<TimeStamp>",
+		"<north>40.000500</north>",
+		"<west>-105.000500</west>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("catalog missing %q", want)
+		}
+	}
+
+	if strings.Contains(out, "Last updated") {
+		t.Error("update time should be a TimeStamp, not description text")
+	}
+
+	if strings.Contains(out, "notes") {
+		t.Error("catalog should only include KML and KMZ files")
+	}
+}
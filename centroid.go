@@ -0,0 +1,213 @@
+package gokml
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Centroid returns the center of the Polygon's area, less its holes, such
+// as to place a label for a region.  The centroid of a region that isn't
+// convex may be outside it; see PoleOfInaccessibility.  It returns false if
+// the Polygon has no area or goes around a pole.
+func (poly *Polygon) Centroid() (*Point, bool) {
+	rings, ok := poly.unwrappedRings()
+	if !ok {
+		return nil, false
+	}
+
+	x, y, ok := ringsCentroid(rings)
+	if !ok {
+		return nil, false
+	}
+
+	return &Point{y, normalizeLon(x), 0}, true
+}
+
+// PoleOfInaccessibility returns the Point inside the Polygon furthest from
+// its boundaries and holes, to within a precision in meters (the polylabel
+// algorithm), which is a better place than the centroid for a label inside
+// an irregular region.  It returns false if the precision isn't positive,
+// or if the Polygon has no area or goes around a pole.
+func (poly *Polygon) PoleOfInaccessibility(precision float64) (*Point, bool) {
+	if !(precision > 0) {
+		return nil, false
+	}
+
+	rings, ok := poly.unwrappedRings()
+	if !ok {
+		return nil, false
+	}
+
+	west, east := unwrappedSpan(rings[0])
+	south, north := math.Inf(1), math.Inf(-1)
+	for _, p := range rings[0] {
+		south, north = math.Min(south, p.Lat), math.Max(north, p.Lat)
+	}
+	if !(east > west) || !(north > south) {
+		return nil, false
+	}
+
+	// work in meters on a plane tangent to the Earth at the middle
+	ky := earthRadius * math.Pi / 180 // meters per degree
+	kx := ky * math.Cos(radians((north+south)/2))
+	plane := make([][][2]float64, len(rings))
+	for i, ring := range rings {
+		plane[i] = make([][2]float64, len(ring))
+		for j, p := range ring {
+			plane[i][j] = [2]float64{(p.Lon - west) * kx, (p.Lat - south) * ky}
+		}
+	}
+	width, height := (east-west)*kx, (north-south)*ky
+
+	cell := func(x, y, half float64) labelCell {
+		d := planeDistance(plane, x, y)
+		return labelCell{x, y, half, d, d + half*math.Sqrt2}
+	}
+
+	// the best so far, starting from the centroid or the middle
+	best := cell(width/2, height/2, 0)
+	if x, y, ok := ringsCentroid(rings); ok {
+		if c := cell((x-west)*kx, (y-south)*ky, 0); c.distance > best.distance {
+			best = c
+		}
+	}
+
+	// cover the Polygon with square cells, then split the cells that could
+	// hold a better Point, most promising first
+	size := math.Min(width, height)
+	var cells labelCells
+	for x := 0.0; x < width; x += size {
+		for y := 0.0; y < height; y += size {
+			cells = append(cells, cell(x+size/2, y+size/2, size/2))
+		}
+	}
+	heap.Init(&cells)
+
+	for cells.Len() > 0 {
+		c := heap.Pop(&cells).(labelCell)
+		if c.distance > best.distance {
+			best = c
+		}
+		if c.potential-best.distance <= precision {
+			continue
+		}
+
+		half := c.half / 2
+		for _, d := range [][2]float64{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+			heap.Push(&cells, cell(c.x+d[0]*half, c.y+d[1]*half, half))
+		}
+	}
+
+	return &Point{south + best.y/ky, normalizeLon(west + best.x/kx), 0}, true
+}
+
+// unwrappedRings returns the outer boundary and holes of the Polygon, with
+// their longitudes unwrapped across the antimeridian, or false if it has
+// no outer boundary or goes around a pole.
+func (poly *Polygon) unwrappedRings() ([][]Point, bool) {
+	poly.mutex.Lock()
+	defer poly.mutex.Unlock()
+
+	outer, turned := unwrapRing(poly.points)
+	if len(outer) < 3 || turned != 0 {
+		return nil, false
+	}
+
+	rings := [][]Point{outer}
+	for _, hole := range poly.inner {
+		inner, turned := unwrapRing(hole)
+		if len(inner) < 3 || turned != 0 {
+			continue
+		}
+
+		// holes are unwrapped near the outer boundary
+		shift := 360 * math.Round((outer[0].Lon-inner[0].Lon)/360)
+		for i := range inner {
+			inner[i].Lon += shift
+		}
+		rings = append(rings, inner)
+	}
+
+	return rings, true
+}
+
+// ringsCentroid returns the centroid, in unwrapped degrees, of the area of
+// the outer ring less the holes, or false if it has none.  Degrees of
+// longitude aren't scaled to their length, which moves the centroid little
+// for regions of a few degrees.
+func ringsCentroid(rings [][]Point) (float64, float64, bool) {
+	var area, x, y float64
+	for i, ring := range rings {
+		// the area of each ring, taken positively, with the holes subtracted
+		var a, cx, cy float64
+		for j, p := range ring {
+			next := ring[(j+1)%len(ring)]
+			cross := p.Lon*next.Lat - next.Lon*p.Lat
+			a += cross
+			cx += (p.Lon + next.Lon) * cross
+			cy += (p.Lat + next.Lat) * cross
+		}
+		sign := 1.0
+		if (a < 0) != (i > 0) {
+			sign = -1
+		}
+		area, x, y = area+sign*a, x+sign*cx, y+sign*cy
+	}
+	if !(math.Abs(area) > 0) {
+		return 0, 0, false
+	}
+
+	return x / (3 * area), y / (3 * area), true
+}
+
+// planeDistance returns the distance from a point to the nearest edge of
+// the rings, negative if it's outside the outer ring or inside a hole.
+func planeDistance(rings [][][2]float64, x, y float64) float64 {
+	inside := false
+	distance := math.Inf(1)
+	for _, ring := range rings {
+		for i, a := range ring {
+			b := ring[(i+1)%len(ring)]
+			if (a[1] > y) != (b[1] > y) && x < a[0]+(y-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+				inside = !inside
+			}
+
+			bx, by := b[0]-a[0], b[1]-a[1]
+			f := 0.0
+			if length := bx*bx + by*by; length > 0 {
+				f = math.Max(0, math.Min(1, ((x-a[0])*bx+(y-a[1])*by)/length))
+			}
+			distance = math.Min(distance, math.Hypot(x-a[0]-f*bx, y-a[1]-f*by))
+		}
+	}
+
+	if !inside {
+		return -distance
+	}
+	return distance
+}
+
+// labelCell is a square cell searched for the pole of inaccessibility, with
+// the distance from its center to the boundaries and the furthest any Point
+// in it could be.
+type labelCell struct {
+	x, y      float64
+	half      float64 // half the width
+	distance  float64
+	potential float64
+}
+
+// labelCells is a heap of cells, the most promising first.
+type labelCells []labelCell
+
+func (c labelCells) Len() int            { return len(c) }
+func (c labelCells) Less(i, j int) bool  { return c[i].potential > c[j].potential }
+func (c labelCells) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *labelCells) Push(x interface{}) { *c = append(*c, x.(labelCell)) }
+
+func (c *labelCells) Pop() interface{} {
+	old := *c
+	cell := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return cell
+}
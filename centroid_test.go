@@ -0,0 +1,79 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+func polygonOf(corners ...[2]float64) *Polygon {
+	poly := NewPolygon()
+	for _, c := range corners {
+		poly.AddPoint(NewPoint(c[0], c[1], 0))
+	}
+
+	return poly
+}
+
+func TestCentroid(t *testing.T) {
+	square := polygonOf([2]float64{0, 0}, [2]float64{0, 2}, [2]float64{2, 2}, [2]float64{2, 0})
+	if c, ok := square.Centroid(); !ok || math.Abs(c.Lat-1) > 1e-9 || math.Abs(c.Lon-1) > 1e-9 {
+		t.Errorf("square centroid = %v, %v", c, ok)
+	}
+
+	// a hole in the east half moves the centroid west
+	square.AddInnerBoundary(NewPoint(0.5, 1.5, 0), NewPoint(1.5, 1.5, 0), NewPoint(1.5, 1.9, 0), NewPoint(0.5, 1.9, 0))
+	if c, ok := square.Centroid(); !ok || c.Lon >= 1 || math.Abs(c.Lat-1) > 1e-9 {
+		t.Errorf("centroid with a hole = %v, %v", c, ok)
+	}
+
+	pacific := polygonOf([2]float64{-1, 179}, [2]float64{-1, -179}, [2]float64{1, -179}, [2]float64{1, 179})
+	if c, ok := pacific.Centroid(); !ok || math.Abs(math.Abs(c.Lon)-180) > 1e-9 || math.Abs(c.Lat) > 1e-9 {
+		t.Errorf("pacific centroid = %v, %v", c, ok)
+	}
+
+	if _, ok := Circle(NewPoint(90, 0, 0), 100000, 36).Centroid(); ok {
+		t.Errorf("polar cap has a centroid")
+	}
+	if _, ok := NewPolygon().Centroid(); ok {
+		t.Errorf("empty polygon has a centroid")
+	}
+	if _, ok := polygonOf([2]float64{0, 0}, [2]float64{1, 1}, [2]float64{2, 2}).Centroid(); ok {
+		t.Errorf("flat polygon has a centroid")
+	}
+}
+
+func TestPoleOfInaccessibility(t *testing.T) {
+	// a C shape open to the east, whose centroid is in the gap
+	c := polygonOf(
+		[2]float64{0, 0}, [2]float64{0, 3}, [2]float64{1, 3}, [2]float64{1, 1},
+		[2]float64{2, 1}, [2]float64{2, 3}, [2]float64{3, 3}, [2]float64{3, 0},
+	)
+	centroid, _ := c.Centroid()
+	if centroid.Lon <= 1 {
+		t.Fatalf("centroid %v is inside the C", centroid)
+	}
+
+	p, ok := c.PoleOfInaccessibility(100)
+	if !ok {
+		t.Fatal("no pole of inaccessibility")
+	}
+	if p.Lon >= 1 || p.Lon <= 0 || p.Lat <= 0 || p.Lat >= 3 {
+		t.Errorf("pole of inaccessibility %v is outside the C", p)
+	}
+	// about half a degree from the edges of the back of the C
+	if p.Lon < 0.45 || p.Lat < 0.45 || p.Lat > 2.55 {
+		t.Errorf("pole of inaccessibility %v is near an edge", p)
+	}
+
+	square := polygonOf([2]float64{-1, 179}, [2]float64{-1, -179}, [2]float64{1, -179}, [2]float64{1, 179})
+	if p, ok := square.PoleOfInaccessibility(10); !ok || math.Abs(math.Abs(p.Lon)-180) > 0.001 || math.Abs(p.Lat) > 0.001 {
+		t.Errorf("pacific pole of inaccessibility = %v, %v", p, ok)
+	}
+
+	if _, ok := c.PoleOfInaccessibility(0); ok {
+		t.Errorf("zero precision accepted")
+	}
+	if _, ok := NewPolygon().PoleOfInaccessibility(1); ok {
+		t.Errorf("empty polygon has a pole of inaccessibility")
+	}
+}
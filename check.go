@@ -0,0 +1,73 @@
+package gokml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DocumentError describes problems in a document that would produce broken
+// KML.
+type DocumentError struct {
+	Problems []string
+}
+
+func (e *DocumentError) Error() string {
+	return "gokml: " + strings.Join(e.Problems, "; ")
+}
+
+// check returns a DocumentError if any Placemark references a Style that
+// isn't in the document or has a geometry that's empty, such as a LineString
+// with a single point.  A Placemark without a geometry is valid KML, and is
+// left to Lint's no-geometry finding.  Styles, StyleMaps, and geometries kept
+// as they are from a parsed document count too.
+func (k *KML) check() error {
+	defined := make(map[string]bool)
+	walkStyles(k.rootFolder, func(s *Style) { defined[s.name] = true })
+	walkExtras(k.rootFolder, func(n *node) {
+		switch n.name.Local {
+		case "Style", "StyleMap":
+			defined[n.attr("id")] = true
+		}
+	})
+
+	problems := make([]string, 0)
+
+	walkPlacemarks(k.rootFolder, func(pm *Placemark) {
+		if len(pm.style) > 0 && !defined[pm.style] {
+			problems = append(problems, fmt.Sprintf("placemark %q references undefined style %q", pm.name, pm.style))
+		}
+
+		if pm.geometry != nil && emptyGeometry(pm.geometry) {
+			problems = append(problems, fmt.Sprintf("placemark %q has an empty geometry", pm.name))
+		}
+	})
+
+	if len(problems) > 0 {
+		return &DocumentError{problems}
+	}
+
+	return nil
+}
+
+// emptyGeometry reports whether geom would render nothing.
+func emptyGeometry(geom renderable) bool {
+	switch g := geom.(type) {
+	case nil:
+		return true
+	case *LineString:
+		return len(g.coordinates) < 2
+	case *Polygon:
+		return len(g.points) < 3
+	case *Track:
+		return len(g.points) == 0
+	case *MultiGeometry:
+		for _, geom := range g.geometries {
+			if !emptyGeometry(geom) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
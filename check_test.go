@@ -0,0 +1,116 @@
+package gokml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWrite(t *testing.T) {
+	k := NewKML("Write")
+	k.AddFeature(NewStyle("Known", 255, 0, 0, 0))
+
+	pm := NewPlacemark("Good", "", NewPoint(0.0, 0.0, 0.0))
+	pm.SetStyle("Known")
+	k.AddFeature(pm)
+
+	var buf bytes.Buffer
+	if err := k.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != k.Render() {
+		t.Error("Write output differs from Render")
+	}
+
+	if err := k.Write(failingWriter{}); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the write error, got %v", err)
+	}
+
+	dangling := NewPlacemark("Dangling", "", NewPoint(0.0, 0.0, 0.0))
+	dangling.SetStyle("Unknown")
+	k.AddFeature(dangling)
+	k.AddFeature(NewPlacemark("Empty", "", NewLineString()))
+	k.AddFeature(NewPlacemark("Nil", "", nil))
+
+	buf.Reset()
+	err := k.Write(&buf)
+
+	var docErr *DocumentError
+	if !errors.As(err, &docErr) {
+		t.Fatalf("expected a DocumentError, got %v", err)
+	}
+
+	if len(docErr.Problems) != 2 {
+		t.Errorf("expected 2 problems, got %q", docErr.Problems)
+	}
+
+	if buf.Len() != 0 {
+		t.Error("document with problems was written")
+	}
+
+	if err := k.WriteKMZ(&buf); err == nil {
+		t.Error("expected WriteKMZ to report problems")
+	}
+
+	// a Feature with a null geometry imports as a Placemark without one
+	nowhere, err := FromGeoJSON(strings.NewReader(`{"type": "Feature", "geometry": null, "properties": {"name": "Nowhere"}}`), DefaultGeoJSONMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nowhere.Write(&buf); err != nil {
+		t.Errorf("Placemark without a geometry not written: %v", err)
+	}
+}
+
+func TestWriteParsed(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+<Document>
+	<StyleMap id="pair">
+		<Pair><key>normal</key><styleUrl>#plain</styleUrl></Pair>
+		<Pair><key>highlight</key><styleUrl>#plain</styleUrl></Pair>
+	</StyleMap>
+	<Style id="plain"><LabelStyle><scale>0.8</scale></LabelStyle></Style>
+	<Placemark>
+		<name>Site</name>
+		<styleUrl>#pair</styleUrl>
+		<Point><coordinates>1,2</coordinates></Point>
+	</Placemark>
+	<Placemark>
+		<name>Building</name>
+		<styleUrl>#plain</styleUrl>
+		<Model><Link><href>building.dae</href></Link></Model>
+	</Placemark>
+	<Placemark>
+		<name>Drive</name>
+		<gx:MultiTrack><gx:Track><when>2024-05-01T07:00:00Z</when><gx:coord>1 2 0</gx:coord></gx:Track></gx:MultiTrack>
+	</Placemark>
+</Document>
+</kml>`
+
+	k, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := k.Write(&buf); err != nil {
+		t.Fatalf("parsed document not written: %v", err)
+	}
+	if err := k.WriteKMZ(&buf); err != nil {
+		t.Fatalf("parsed document not written as KMZ: %v", err)
+	}
+
+	k.AddFeature(NewPlacemark("Dangling", "", NewPoint(0, 0, 0)))
+	k.Placemarks()[3].SetStyle("missing")
+	if err := k.Write(&buf); err == nil {
+		t.Error("undefined style not reported")
+	}
+}
@@ -0,0 +1,163 @@
+package gokml
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVColumns names the columns FromCSV reads Placemark fields from.  Column
+// names are matched against the header row without regard to case.  Lat and
+// Lon are required; the other columns are optional, and are ignored if empty
+// or not in the file.
+type CSVColumns struct {
+	Lat         string // latitude in degrees
+	Lon         string // longitude in degrees
+	Alt         string // altitude in meters
+	Name        string
+	Description string // markup is treated as HTML
+	Time        string // KML dateTime (RFC 3339, or a date)
+
+	Check    *CoordinateCheck // reports suspect rows, if not nil
+	Progress Progress         // receives the bytes read, if not nil
+}
+
+// DefaultCSVColumns reads the lat, lon, alt, name, description, and time
+// columns.
+var DefaultCSVColumns = CSVColumns{"lat", "lon", "alt", "name", "description", "time", nil, nil}
+
+// FromCSV reads a CSV file with a header row and returns a Folder with the
+// given name containing a Placemark for each row, located at the columns
+// named by columns.  The other columns of each row become ExtendedData,
+// named by the header.  A row with invalid coordinates or an invalid time
+// is an error, except that rows with coordinates out of range are reported
+// to the Suspect function of columns.Check, if there is one, and skipped.
+// Rows with coordinates the Check finds suspect otherwise are reported and
+// kept.
+func FromCSV(r io.Reader, name string, columns CSVColumns) (*Folder, error) {
+	f := NewFolder(name, "")
+
+	err := readCSV(r, columns, func(pm *Placemark) error {
+		f.AddFeature(pm)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// StreamCSV is like FromCSV, but writes the Folder to sw as the rows are
+// read, so files larger than memory can be converted in a single pass.
+func StreamCSV(sw *StreamWriter, r io.Reader, name string, columns CSVColumns) error {
+	if err := sw.StartFolder(NewFolder(name, "")); err != nil {
+		return err
+	}
+
+	err := readCSV(r, columns, func(pm *Placemark) error {
+		return sw.AddFeature(pm)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sw.EndFolder()
+}
+
+// readCSV reads the rows of a CSV file as Placemarks, passing each to add.
+func readCSV(r io.Reader, columns CSVColumns, add func(*Placemark) error) error {
+	cr := csv.NewReader(readProgress(r, columns.Progress))
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return fmt.Errorf("gokml: CSV file has no header")
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff") // byte order mark
+	}
+
+	index := func(column string) int {
+		for i, h := range header {
+			if len(column) > 0 && strings.EqualFold(strings.TrimSpace(h), column) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	lat, lon := index(columns.Lat), index(columns.Lon)
+	if lat < 0 || lon < 0 {
+		return fmt.Errorf("gokml: CSV file has no %q or %q column", columns.Lat, columns.Lon)
+	}
+
+	alt, nameCol, desc, when := index(columns.Alt), index(columns.Name), index(columns.Description), index(columns.Time)
+	mapped := map[int]bool{lat: true, lon: true, alt: true, nameCol: true, desc: true, when: true}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := cr.FieldPos(0)
+		field := func(i int) string {
+			if i < 0 {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		latV, latErr := strconv.ParseFloat(field(lat), 64)
+		lonV, lonErr := strconv.ParseFloat(field(lon), 64)
+		altV, _ := strconv.ParseFloat(field(alt), 64)
+
+		if latErr != nil || lonErr != nil {
+			return fmt.Errorf("gokml: CSV line %d: invalid coordinates %q, %q", line, field(lat), field(lon))
+		}
+
+		reported := columns.Check.report(line, latV, lonV)
+
+		p := NewPoint(latV, lonV, altV)
+		if p == nil && reported {
+			continue
+		}
+		if p == nil {
+			return fmt.Errorf("gokml: CSV line %d: invalid coordinates %q, %q", line, field(lat), field(lon))
+		}
+
+		pm := NewPlacemark(field(nameCol), "", p)
+		pm.description = field(desc)
+		pm.html = hasMarkup(pm.description)
+
+		if s := field(when); len(s) > 0 {
+			t, err := parseTime(s)
+			if err != nil {
+				return fmt.Errorf("gokml: CSV line %d: invalid time %q", line, s)
+			}
+			pm.SetTime(t, t)
+		}
+
+		for i, h := range header {
+			if !mapped[i] {
+				pm.SetData(h, row[i])
+			}
+		}
+
+		if err := add(pm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
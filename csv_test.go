@@ -0,0 +1,102 @@
+package gokml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const csvFixture = "\ufeffName,Latitude,Longitude,Elevation,Notes,Observed,Count\n" +
+	"Depot,40.0,-105.0,1600,<b>Main</b>,2024-06-01T08:00:00Z,12\n" +
+	"\"Yard, North\",41.5,-104.5,,,2024-06-02,\n"
+
+func TestFromCSV(t *testing.T) {
+	columns := CSVColumns{Lat: "latitude", Lon: "longitude", Alt: "elevation", Name: "name", Description: "notes", Time: "observed"}
+
+	f, err := FromCSV(strings.NewReader(csvFixture), "Sites", columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.name != "Sites" || len(f.features) != 2 {
+		t.Fatalf("unexpected folder %q with %d features", f.name, len(f.features))
+	}
+
+	depot := f.features[0].(*Placemark)
+	if p := depot.geometry.(*Point); depot.name != "Depot" || p.Lat != 40.0 || p.Lon != -105.0 || p.Alt != 1600 {
+		t.Errorf("unexpected placemark %q at %v", depot.name, p)
+	}
+	if !depot.html || depot.description != "<b>Main</b>" || !depot.hasTime || depot.beginTime.Hour() != 8 {
+		t.Errorf("description or time not set: %+v", depot)
+	}
+	if count, _ := depot.Data("Count"); count != "12" || len(depot.data) != 1 {
+		t.Errorf("unexpected data %v", depot.data)
+	}
+
+	yard := f.features[1].(*Placemark)
+	if yard.name != "Yard, North" || yard.geometry.(*Point).Alt != 0 || yard.beginTime.Day() != 2 {
+		t.Errorf("unexpected placemark %+v", yard)
+	}
+}
+
+func TestFromCSVErrors(t *testing.T) {
+	for _, doc := range []string{
+		"",
+		"name,x,y\nA,1,2\n",
+		"lat,lon\n95,0\n",
+		"lat,lon\nabc,0\n",
+		"lat,lon,time\n1,2,yesterday\n",
+		"lat,lon\n1,2,3\n",
+	} {
+		if _, err := FromCSV(strings.NewReader(doc), "", DefaultCSVColumns); err == nil {
+			t.Errorf("no error for %q", doc)
+		}
+	}
+
+	_, err := FromCSV(strings.NewReader("lat,lon\n1,2\n95,0\n"), "", DefaultCSVColumns)
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("error doesn't give the line: %v", err)
+	}
+}
+
+func TestFromCSVSuspect(t *testing.T) {
+	var suspect []string
+	columns := DefaultCSVColumns
+	columns.Check = &CoordinateCheck{
+		Region: &Bounds{41, 37, -102, -109}, // Colorado
+		Suspect: func(row int, lat float64, lon float64, problem string) {
+			suspect = append(suspect, fmt.Sprintf("%d: %s", row, problem))
+		},
+	}
+
+	f, err := FromCSV(strings.NewReader("lat,lon\n40,-105\n-105,40\n0,0\n45,-105\n"), "", columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.features) != 3 {
+		t.Errorf("got %d placemarks, want 3", len(f.features))
+	}
+
+	want := []string{"3: latitude and longitude may be swapped", "4: at or near 0,0", "5: outside the expected region"}
+	if fmt.Sprint(suspect) != fmt.Sprint(want) {
+		t.Errorf("got suspect rows %q, want %q", suspect, want)
+	}
+}
+
+func TestStreamCSV(t *testing.T) {
+	columns := CSVColumns{Lat: "latitude", Lon: "longitude", Alt: "elevation", Name: "name", Description: "notes", Time: "observed"}
+
+	f, err := FromCSV(strings.NewReader(csvFixture), "Sites", columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := streamed(t, func(sw *StreamWriter) error {
+		return StreamCSV(sw, strings.NewReader(csvFixture), "Sites", columns)
+	})
+
+	want := make([]*Placemark, 0)
+	walkPlacemarks(f, func(pm *Placemark) { want = append(want, pm) })
+	samePlacemarks(t, got, want)
+}
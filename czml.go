@@ -0,0 +1,290 @@
+package gokml
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"time"
+)
+
+// czmlPacket is a CZML packet.  Only the properties this package writes are
+// included.
+type czmlPacket struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name,omitempty"`
+	Version      string         `json:"version,omitempty"`
+	Parent       string         `json:"parent,omitempty"`
+	Description  string         `json:"description,omitempty"`
+	Availability string         `json:"availability,omitempty"`
+	Clock        *czmlClock     `json:"clock,omitempty"`
+	Position     *czmlPositions `json:"position,omitempty"`
+	Point        *czmlPoint     `json:"point,omitempty"`
+	Billboard    *czmlBillboard `json:"billboard,omitempty"`
+	Polyline     *czmlPolyline  `json:"polyline,omitempty"`
+	Polygon      *czmlPolygon   `json:"polygon,omitempty"`
+	Path         *czmlPath      `json:"path,omitempty"`
+}
+
+type czmlClock struct {
+	Interval    string `json:"interval"`
+	CurrentTime string `json:"currentTime"`
+}
+
+type czmlPositions struct {
+	Epoch               string    `json:"epoch,omitempty"`
+	CartographicDegrees []float64 `json:"cartographicDegrees"`
+}
+
+type czmlHoles struct {
+	CartographicDegrees [][]float64 `json:"cartographicDegrees"`
+}
+
+type czmlColor struct {
+	RGBA []int `json:"rgba"`
+}
+
+type czmlMaterial struct {
+	SolidColor struct {
+		Color czmlColor `json:"color"`
+	} `json:"solidColor"`
+}
+
+type czmlPoint struct {
+	Color           czmlColor `json:"color"`
+	PixelSize       float64   `json:"pixelSize"`
+	HeightReference string    `json:"heightReference"`
+}
+
+type czmlBillboard struct {
+	Image           string  `json:"image"`
+	Scale           float64 `json:"scale"`
+	HeightReference string  `json:"heightReference"`
+}
+
+type czmlPolyline struct {
+	Positions     czmlPositions `json:"positions"`
+	Material      czmlMaterial  `json:"material"`
+	Width         float64       `json:"width"`
+	ClampToGround bool          `json:"clampToGround"`
+}
+
+type czmlPolygon struct {
+	Positions       czmlPositions `json:"positions"`
+	Holes           *czmlHoles    `json:"holes,omitempty"`
+	Material        czmlMaterial  `json:"material"`
+	Fill            bool          `json:"fill"`
+	Outline         bool          `json:"outline"`
+	HeightReference string        `json:"heightReference"`
+}
+
+type czmlPath struct {
+	Material czmlMaterial `json:"material"`
+	Width    float64      `json:"width"`
+}
+
+// WriteCZML converts the document to CZML and writes it to w, so it can be
+// shown on a CesiumJS globe.  Folders and Placemarks become packets, with
+// each packet's parent set to its Folder.  Points are shown with the icon of
+// their Style (or as a dot), LineStrings as polylines, and Polygons as
+// polygons, in the color of their Style and clamped to the ground.  Tracks
+// become time-tagged positions with a path, and a Placemark's TimeSpan
+// becomes its availability.  The parts of a MultiGeometry become packets
+// with the Placemark as their parent.  NetworkLinks and GroundOverlays
+// aren't converted.
+func (k *KML) WriteCZML(w io.Writer) error {
+	c := &czmlWriter{styles: make(map[string]*Style)}
+	collectStyles(k.rootFolder, c.styles)
+
+	doc := &czmlPacket{ID: "document", Name: k.rootFolder.name, Version: "1.0"}
+	c.packets = append(c.packets, doc)
+	c.folder(k.rootFolder, "")
+
+	if !c.begin.IsZero() {
+		doc.Clock = &czmlClock{czmlInterval(c.begin, c.end), c.begin.UTC().Format(time.RFC3339)}
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[\n")
+	for i, p := range c.packets {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		bw.Write(b)
+		if i < len(c.packets)-1 {
+			bw.WriteByte(',')
+		}
+		bw.WriteByte('\n')
+	}
+	bw.WriteString("]\n")
+
+	return bw.Flush()
+}
+
+type czmlWriter struct {
+	styles  map[string]*Style
+	packets []*czmlPacket
+	ids     int       // packets given generated ids so far
+	begin   time.Time // extent of the times in the document
+	end     time.Time
+}
+
+// id returns id, or a new generated id if it is empty.
+func (c *czmlWriter) id(id string, kind string) string {
+	if len(id) > 0 {
+		return id
+	}
+
+	c.ids++
+	return kind + "-" + strconv.Itoa(c.ids)
+}
+
+// extend widens the extent of the document's times to include t.
+func (c *czmlWriter) extend(t time.Time) {
+	if c.begin.IsZero() || t.Before(c.begin) {
+		c.begin = t
+	}
+	if c.end.IsZero() || t.After(c.end) {
+		c.end = t
+	}
+}
+
+func (c *czmlWriter) folder(f *Folder, parent string) {
+	id := "document"
+	if len(parent) > 0 {
+		id = c.id(f.id, "folder")
+		c.packets = append(c.packets, &czmlPacket{ID: id, Name: f.name, Parent: parent, Description: czmlDescription(f.description, f.html)})
+	}
+
+	for _, child := range f.features {
+		switch feature := child.(type) {
+		case *Folder:
+			c.folder(feature, id)
+		case *Placemark:
+			c.placemark(feature, id)
+		}
+	}
+}
+
+func (c *czmlWriter) placemark(pm *Placemark, parent string) {
+	desc, isHTML := pm.balloonDescription()
+	packet := &czmlPacket{
+		ID:          c.id(pm.id, "placemark"),
+		Name:        pm.name,
+		Parent:      parent,
+		Description: czmlDescription(desc, isHTML),
+	}
+
+	if pm.hasTime {
+		packet.Availability = czmlInterval(pm.beginTime, pm.endTime)
+		c.extend(pm.beginTime)
+		c.extend(pm.endTime)
+	}
+
+	c.packets = append(c.packets, packet)
+
+	style := c.styles[pm.style]
+	if mg, ok := pm.geometry.(*MultiGeometry); ok {
+		c.parts(mg, packet, style, new(int))
+		return
+	}
+
+	c.geometry(packet, pm.geometry, style)
+}
+
+// parts adds a packet for each geometry of mg, numbered from *n.
+func (c *czmlWriter) parts(mg *MultiGeometry, parent *czmlPacket, style *Style, n *int) {
+	for _, geom := range mg.geometries {
+		if sub, ok := geom.(*MultiGeometry); ok {
+			c.parts(sub, parent, style, n)
+			continue
+		}
+
+		*n++
+		part := &czmlPacket{ID: fmt.Sprintf("%s/%d", parent.ID, *n), Parent: parent.ID, Availability: parent.Availability}
+		c.packets = append(c.packets, part)
+		c.geometry(part, geom, style)
+	}
+}
+
+// geometry sets the graphics of packet for geom.
+func (c *czmlWriter) geometry(packet *czmlPacket, geom renderable, style *Style) {
+	color := czmlColor{[]int{255, 255, 255, 255}}
+	fill := true
+	if style != nil {
+		color.RGBA = []int{int(style.red), int(style.green), int(style.blue), int(style.alpha)}
+		fill = style.fill != 0
+	}
+
+	var material czmlMaterial
+	material.SolidColor.Color = color
+
+	marker := func() {
+		if style != nil && len(style.iconURL) > 0 {
+			packet.Billboard = &czmlBillboard{style.iconURL, style.iconScale, "CLAMP_TO_GROUND"}
+		} else {
+			packet.Point = &czmlPoint{color, 10, "CLAMP_TO_GROUND"}
+		}
+	}
+
+	switch g := geom.(type) {
+	case *Point:
+		packet.Position = &czmlPositions{CartographicDegrees: czmlDegrees([]*Point{g})}
+		marker()
+	case *LineString:
+		packet.Polyline = &czmlPolyline{czmlPositions{CartographicDegrees: czmlDegrees(g.coordinates)}, material, 3, true}
+	case *Polygon:
+		poly := &czmlPolygon{czmlPositions{CartographicDegrees: czmlDegrees(closeRing(g.points))}, nil, material, fill, true, "CLAMP_TO_GROUND"}
+		if len(g.inner) > 0 {
+			poly.Holes = &czmlHoles{}
+			for _, ring := range g.inner {
+				poly.Holes.CartographicDegrees = append(poly.Holes.CartographicDegrees, czmlDegrees(ring))
+			}
+		}
+		packet.Polygon = poly
+	case *Track:
+		if len(g.points) == 0 {
+			return
+		}
+
+		epoch := g.times[0]
+		pos := &czmlPositions{Epoch: epoch.UTC().Format(time.RFC3339)}
+		for i, p := range g.points {
+			pos.CartographicDegrees = append(pos.CartographicDegrees, g.times[i].Sub(epoch).Seconds(), p.Lon, p.Lat, p.Alt)
+			c.extend(g.times[i])
+		}
+
+		packet.Position = pos
+		if len(packet.Availability) == 0 {
+			packet.Availability = czmlInterval(epoch, g.times[len(g.times)-1])
+		}
+		packet.Path = &czmlPath{material, 3}
+		marker()
+	}
+}
+
+func czmlDegrees(points []*Point) []float64 {
+	v := make([]float64, 0, len(points)*3)
+	for _, p := range points {
+		v = append(v, p.Lon, p.Lat, p.Alt)
+	}
+
+	return v
+}
+
+func czmlInterval(begin time.Time, end time.Time) string {
+	return begin.UTC().Format(time.RFC3339) + "/" + end.UTC().Format(time.RFC3339)
+}
+
+// czmlDescription returns a description as HTML, which is what CZML
+// descriptions are.
+func czmlDescription(desc string, isHTML bool) string {
+	if isHTML {
+		return desc
+	}
+
+	return html.EscapeString(desc)
+}
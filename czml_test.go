@@ -0,0 +1,88 @@
+package gokml
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteCZML(t *testing.T) {
+	k := NewKML("Fleet")
+	k.AddFeature(NewStyle("red", 200, 255, 0, 0))
+
+	depot := NewPlacemark("Depot", "Fuel & parking", NewPoint(40.0, -105.0, 1600.0))
+	depot.SetID("depot")
+	depot.SetStyle("red")
+	k.AddFeature(depot)
+
+	f := NewFolder("Routes", "")
+	k.AddFeature(f)
+
+	start := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	track := NewTrack()
+	track.AddPoint(start, NewPoint(40.0, -105.0, 0.0))
+	track.AddPoint(start.Add(90*time.Second), NewPoint(40.1, -105.1, 0.0))
+	f.AddFeature(NewPlacemark("Truck", "", track))
+
+	poly := NewPolygon()
+	poly.AddPoint(NewPoint(0.0, 0.0, 0.0))
+	poly.AddPoint(NewPoint(0.0, 10.0, 0.0))
+	poly.AddPoint(NewPoint(10.0, 10.0, 0.0))
+	poly.AddInnerBoundary(NewPoint(1.0, 1.0, 0.0), NewPoint(1.0, 2.0, 0.0), NewPoint(2.0, 2.0, 0.0))
+	line := NewLineString()
+	line.AddPoint(NewPoint(0.0, 0.0, 0.0))
+	line.AddPoint(NewPoint(1.0, 1.0, 0.0))
+	mg := NewMultiGeometry()
+	mg.AddGeometry(poly)
+	mg.AddGeometry(line)
+	f.AddFeature(NewPlacemark("Area", "", mg))
+
+	var buf bytes.Buffer
+	if err := k.WriteCZML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var packets []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &packets); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+
+	byID := make(map[string]map[string]interface{})
+	for _, p := range packets {
+		byID[p["id"].(string)] = p
+	}
+
+	doc := byID["document"]
+	if doc["version"] != "1.0" || doc["name"] != "Fleet" {
+		t.Errorf("unexpected document packet %v", doc)
+	}
+	if clock := doc["clock"].(map[string]interface{}); clock["interval"] != "2024-06-01T08:00:00Z/2024-06-01T08:01:30Z" {
+		t.Errorf("unexpected clock %v", clock)
+	}
+
+	d := byID["depot"]
+	if d["description"] != "Fuel &amp; parking" || d["parent"] != "document" || d["billboard"] == nil {
+		t.Errorf("unexpected depot packet %v", d)
+	}
+	if pos := d["position"].(map[string]interface{})["cartographicDegrees"].([]interface{}); len(pos) != 3 || pos[0] != -105.0 {
+		t.Errorf("unexpected depot position %v", pos)
+	}
+
+	truck := byID["placemark-2"]
+	if truck["parent"] != "folder-1" || truck["path"] == nil || truck["availability"] != "2024-06-01T08:00:00Z/2024-06-01T08:01:30Z" {
+		t.Errorf("unexpected track packet %v", truck)
+	}
+	pos := truck["position"].(map[string]interface{})
+	if pos["epoch"] != "2024-06-01T08:00:00Z" || pos["cartographicDegrees"].([]interface{})[4] != 90.0 {
+		t.Errorf("unexpected track position %v", pos)
+	}
+
+	area := byID["placemark-3/1"]
+	if area["parent"] != "placemark-3" || area["polygon"].(map[string]interface{})["holes"] == nil {
+		t.Errorf("unexpected polygon packet %v", area)
+	}
+	if byID["placemark-3/2"]["polyline"] == nil {
+		t.Errorf("line of MultiGeometry not converted")
+	}
+}
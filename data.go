@@ -0,0 +1,138 @@
+package gokml
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+type dataField struct {
+	name  string
+	value string
+}
+
+type sourceLink struct {
+	label    string
+	template string
+}
+
+// SetData sets a named ExtendedData value of the Placemark, replacing any
+// existing value with the same name.  Empty names are ignored.
+func (pm *Placemark) SetData(name string, value string) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return
+	}
+
+	for i := range pm.data {
+		if pm.data[i].name == name {
+			pm.data[i].value = value
+			return
+		}
+	}
+
+	pm.data = append(pm.data, dataField{name, value})
+}
+
+// Data returns the named ExtendedData value of the Placemark, and whether it
+// is set.
+func (pm *Placemark) Data(name string) (string, bool) {
+	for _, d := range pm.data {
+		if d.name == name {
+			return d.value, true
+		}
+	}
+
+	return "", false
+}
+
+// AddSourceLink adds a link back to the Placemark's record in a source
+// system, shown as a button in its balloon.  The URL template may contain
+// {name} placeholders, which are replaced with the (escaped) ExtendedData
+// values of the same names when the Placemark is rendered, e.g.
+// "https://crm.example.com/accounts/{account}".  Links with placeholders for
+// values that aren't set are left out.  Adding a link renders the
+// description as HTML.
+func (pm *Placemark) AddSourceLink(label string, urlTemplate string) {
+	urlTemplate = strings.TrimSpace(urlTemplate)
+	if len(urlTemplate) == 0 {
+		return
+	}
+
+	pm.links = append(pm.links, sourceLink{label, urlTemplate})
+}
+
+// fill returns the link's URL with its placeholders replaced by data values,
+// or false if a value is missing.
+func (l sourceLink) fill(pm *Placemark) (string, bool) {
+	var b strings.Builder
+	rest := l.template
+
+	for {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			break
+		}
+
+		end := strings.Index(rest[open:], "}")
+		if end < 0 {
+			break
+		}
+
+		value, ok := pm.Data(rest[open+1 : open+end])
+		if !ok {
+			return "", false
+		}
+
+		b.WriteString(rest[:open])
+		b.WriteString(url.PathEscape(value))
+		rest = rest[open+end+1:]
+	}
+
+	b.WriteString(rest)
+	return b.String(), true
+}
+
+// balloonDescription returns the Placemark's description, with buttons for
+// its source links appended, and whether it is HTML.
+func (pm *Placemark) balloonDescription() (string, bool) {
+	if len(pm.links) == 0 {
+		return pm.description, pm.html
+	}
+
+	desc := pm.description
+	if !pm.html {
+		desc = html.EscapeString(desc)
+	}
+
+	var b strings.Builder
+	b.WriteString(desc)
+
+	for _, l := range pm.links {
+		href, ok := l.fill(pm)
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(&b, `<p><a href="%s" style="display:inline-block;padding:4px 10px;border:1px solid #888;border-radius:4px;text-decoration:none">%s</a></p>`,
+			html.EscapeString(href), html.EscapeString(l.label))
+	}
+
+	return b.String(), true
+}
+
+// renderData writes the Placemark's ExtendedData, if it has any.
+func (pm *Placemark) renderData(e *encoder) {
+	if len(pm.data) == 0 {
+		return
+	}
+
+	e.start("ExtendedData")
+	for _, d := range pm.data {
+		e.start("Data", attr("name", d.name))
+		e.element("value", d.value)
+		e.end("Data")
+	}
+	e.end("ExtendedData")
+}
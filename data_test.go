@@ -0,0 +1,44 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtendedData(t *testing.T) {
+	pm := NewPlacemark("Account", "Big customer", NewPoint(40.0, -105.0, 0.0))
+	pm.SetData("account", "A&B 42")
+	pm.SetData("region", "west")
+	pm.SetData("region", "east")
+	pm.SetData(" ", "ignored")
+
+	if v, ok := pm.Data("region"); !ok || v != "east" {
+		t.Errorf("region = %q, %v", v, ok)
+	}
+
+	pm.AddSourceLink("Open in CRM", "https://crm.example.com/accounts/{account}?r={region}")
+	pm.AddSourceLink("Ticket", "https://tickets.example.com/{ticket}")
+
+	k := NewKML("Accounts")
+	k.AddFeature(pm)
+	out := unindent(k.Render())
+
+	for _, want := range []string{
+		`<Data name="account">
+<value>A&amp;B 42</value>
+</Data>
+<Data name="region">
+<value>east</value>
+</Data>`,
+		"Big customer<p><a href=\"https://crm.example.com/accounts/A&amp;B%2042?r=east\"",
+		">Open in CRM</a></p>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("render missing %q:\n%s", want, out)
+		}
+	}
+
+	if strings.Contains(out, "Ticket") {
+		t.Errorf("link with a missing value rendered:\n%s", out)
+	}
+}
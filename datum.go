@@ -0,0 +1,106 @@
+package gokml
+
+import "math"
+
+// Datum represents a geodetic datum other than WGS84, the datum of KML
+// coordinates, such as of older maps and surveys.  Coordinates on it are
+// converted to WGS84 by a seven-parameter Helmert transformation, which is
+// accurate to within a few meters, rather than tens to hundreds of meters
+// off as the untransformed coordinates would be.
+type Datum struct {
+	ellipsoid ellipsoid
+	shift     [3]float64 // meters
+	rotation  [3]float64 // arcseconds, of the position vector
+	scale     float64    // parts per million
+}
+
+var (
+	// NAD83 is the North American Datum of 1983, within about a meter of
+	// WGS84.
+	NAD83 = Datum{ellipsoid{6378137, 1 / 298.257222101}, [3]float64{1.004, -1.910, -0.515}, [3]float64{-0.0267, -0.00034, -0.011}, -0.0015}
+
+	// ED50 is the European Datum of 1950.
+	ED50 = Datum{ellipsoid{6378388, 1.0 / 297}, [3]float64{-89.5, -93.8, -123.1}, [3]float64{0, 0, -0.156}, 1.2}
+
+	// Tokyo is the Tokyo datum of Japan, used before 2002.
+	Tokyo = Datum{ellipsoid{6377397.155, 1 / 299.1528128}, [3]float64{-148, 507, 685}, [3]float64{}, 0}
+)
+
+// ToWGS84 returns the Point on WGS84 of a Point on the datum, with its
+// altitude converted too.
+func (d Datum) ToWGS84(p *Point) *Point {
+	x, y, z := d.ellipsoid.cartesian(p)
+	x, y, z = d.helmert(x, y, z, 1)
+
+	return wgs84.geodetic(x, y, z)
+}
+
+// FromWGS84 returns the Point on the datum of a Point on WGS84, with its
+// altitude converted too.
+func (d Datum) FromWGS84(p *Point) *Point {
+	x, y, z := wgs84.cartesian(p)
+	x, y, z = d.helmert(x, y, z, -1)
+
+	return d.ellipsoid.geodetic(x, y, z)
+}
+
+// ConvertDatum converts the coordinates of every Placemark geometry in the
+// document from a datum to WGS84, such as for a legacy dataset loaded from
+// an older survey.
+func (k *KML) ConvertDatum(from Datum) {
+	k.Coordinates(func(pm *Placemark, p *Point) {
+		*p = *from.ToWGS84(p)
+	})
+}
+
+// helmert applies the datum's transformation to WGS84 to Earth-centered
+// coordinates, or its inverse for a sign of -1, which is accurate for the
+// small rotations of datums.
+func (d Datum) helmert(x, y, z float64, sign float64) (float64, float64, float64) {
+	tx, ty, tz := sign*d.shift[0], sign*d.shift[1], sign*d.shift[2]
+	rx, ry, rz := sign*radians(d.rotation[0]/3600), sign*radians(d.rotation[1]/3600), sign*radians(d.rotation[2]/3600)
+	s := 1 + sign*d.scale/1e6
+
+	return tx + s*x - rz*y + ry*z,
+		ty + rz*x + s*y - rx*z,
+		tz - ry*x + rx*y + s*z
+}
+
+// cartesian returns the Earth-centered, Earth-fixed coordinates in meters of
+// a Point on the ellipsoid.
+func (e ellipsoid) cartesian(p *Point) (float64, float64, float64) {
+	sinLat, cosLat := math.Sincos(radians(p.Lat))
+	sinLon, cosLon := math.Sincos(radians(p.Lon))
+	e2 := e.f * (2 - e.f)
+	nu := e.a / math.Sqrt(1-e2*sinLat*sinLat) // radius of curvature
+
+	return (nu + p.Alt) * cosLat * cosLon, (nu + p.Alt) * cosLat * sinLon, ((1-e2)*nu + p.Alt) * sinLat
+}
+
+// geodetic returns the Point on the ellipsoid at Earth-centered, Earth-fixed
+// coordinates in meters (Bowring's method, accurate to within a millimeter
+// near the Earth).
+func (e ellipsoid) geodetic(x, y, z float64) *Point {
+	b := e.a * (1 - e.f)
+	e2 := e.f * (2 - e.f)
+	eps2 := e2 / (1 - e2)
+	p := math.Hypot(x, y)
+	r := math.Hypot(p, z)
+
+	// the parametric latitude
+	tanBeta := b * z / (e.a * p) * (1 + eps2*b/r)
+	sinBeta := tanBeta / math.Sqrt(1+tanBeta*tanBeta)
+	cosBeta := sinBeta / tanBeta
+	if math.IsNaN(cosBeta) {
+		cosBeta = 0
+	}
+
+	lat := math.Atan2(z+eps2*b*sinBeta*sinBeta*sinBeta, p-e2*e.a*cosBeta*cosBeta*cosBeta)
+	lon := math.Atan2(y, x)
+
+	sinLat, cosLat := math.Sincos(lat)
+	nu := e.a / math.Sqrt(1-e2*sinLat*sinLat)
+	alt := p*cosLat + z*sinLat - e.a*e.a/nu
+
+	return &Point{degrees(lat), degrees(lon), alt}
+}
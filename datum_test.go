@@ -0,0 +1,59 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDatumToWGS84(t *testing.T) {
+	// the Japanese approximation for Tokyo, good to a few meters
+	tokyo := NewPoint(35.6586, 139.7454, 0)
+	want := NewPoint(35.6586-0.00010695*35.6586+0.000017464*139.7454+0.0046017, 139.7454-0.000046038*35.6586-0.000083043*139.7454+0.010040, 0)
+	got := Tokyo.ToWGS84(tokyo)
+	if d := got.Distance(want); d > 10 {
+		t.Errorf("Tokyo %v is %v m from %v", got, d, want)
+	}
+	if d := got.Distance(tokyo); d < 300 || d > 600 {
+		t.Errorf("Tokyo moved %v m", d)
+	}
+
+	// NAD83 is within about a meter of WGS84, and ED50 is a hundred or so off
+	if d := NAD83.ToWGS84(NewPoint(40, -100, 0)).Distance(NewPoint(40, -100, 0)); d > 2 {
+		t.Errorf("NAD83 moved %v m", d)
+	}
+	paris := NewPoint(48.8566, 2.3522, 0)
+	if d := ED50.ToWGS84(paris).Distance(paris); d < 50 || d > 200 {
+		t.Errorf("ED50 moved %v m", d)
+	}
+
+	for _, datum := range []Datum{NAD83, ED50, Tokyo} {
+		p := NewPoint(-33.5, 150.25, 120)
+		q := datum.FromWGS84(datum.ToWGS84(p))
+		if math.Abs(q.Lat-p.Lat) > 1e-7 || math.Abs(q.Lon-p.Lon) > 1e-7 || math.Abs(q.Alt-p.Alt) > 0.01 {
+			t.Errorf("round trip of %v gave %v", p, q)
+		}
+	}
+}
+
+func TestEllipsoidCartesian(t *testing.T) {
+	for _, p := range []*Point{NewPoint(0, 0, 0), NewPoint(51.4778, -0.0014, 45), NewPoint(-89.9, 120, 3000), NewPoint(90, 0, 10)} {
+		q := wgs84.geodetic(wgs84.cartesian(p))
+		if math.Abs(q.Lat-p.Lat) > 1e-9 || math.Abs(normalizeLon(q.Lon-p.Lon)*math.Cos(radians(p.Lat))) > 1e-9 || math.Abs(q.Alt-p.Alt) > 0.001 {
+			t.Errorf("round trip of %v gave %v", p, q)
+		}
+	}
+	if x, _, _ := wgs84.cartesian(NewPoint(0, 0, 0)); x != wgs84.a {
+		t.Errorf("equator at %v m", x)
+	}
+}
+
+func TestConvertDatum(t *testing.T) {
+	p := NewPoint(35.6586, 139.7454, 0)
+	k := NewKML("Survey")
+	k.AddFeature(NewPlacemark("Tower", "", p))
+	k.ConvertDatum(Tokyo)
+
+	if *p != *Tokyo.ToWGS84(NewPoint(35.6586, 139.7454, 0)) {
+		t.Errorf("point wasn't converted: %v", p)
+	}
+}
@@ -0,0 +1,85 @@
+package gokml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Decoder reads the Placemarks of a KML document one at a time, without
+// decoding the rest of the document, so huge documents can be filtered or
+// converted in bounded memory.  Placemarks are decoded like Parse does.
+type Decoder struct {
+	d       *xml.Decoder
+	folders []string // names of the open Document and Folders
+	started bool     // the kml element has been read
+}
+
+// NewDecoder returns a pointer to a new Decoder reading the document from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{xml.NewDecoder(r), nil, false}
+}
+
+// Next returns the next Placemark in the document.  It returns io.EOF when
+// there are no more Placemarks.
+func (dec *Decoder) Next() (*Placemark, error) {
+	for {
+		tok, err := dec.d.Token()
+		if err == io.EOF {
+			if !dec.started {
+				return nil, fmt.Errorf("gokml: not a KML document")
+			}
+			if len(dec.folders) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if !dec.started {
+				if t.Name.Local != "kml" {
+					return nil, fmt.Errorf("gokml: not a KML document (root element is %s)", t.Name.Local)
+				}
+				dec.started = true
+				continue
+			}
+
+			switch t.Name.Local {
+			case "Document", "Folder":
+				dec.folders = append(dec.folders, "")
+			case "name":
+				n, err := readNode(dec.d, t)
+				if err != nil {
+					return nil, err
+				}
+				if len(dec.folders) > 0 {
+					dec.folders[len(dec.folders)-1] = n.text
+				}
+			case "Placemark":
+				n, err := readNode(dec.d, t)
+				if err != nil {
+					return nil, err
+				}
+				return parsePlacemark(n), nil
+			default:
+				if err := dec.d.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "Document" || t.Name.Local == "Folder" {
+				dec.folders = dec.folders[:len(dec.folders)-1]
+			}
+		}
+	}
+}
+
+// Path returns the names of the Document and Folders containing the last
+// Placemark returned by Next, outermost first.
+func (dec *Decoder) Path() []string {
+	return append([]string(nil), dec.folders...)
+}
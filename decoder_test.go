@@ -0,0 +1,49 @@
+package gokml
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	doc := parseFixture().Render()
+	dec := NewDecoder(strings.NewReader(doc))
+
+	var names []string
+	var paths [][]string
+	for {
+		pm, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, pm.name)
+		paths = append(paths, dec.Path())
+	}
+
+	if want := []string{"Depot", "Route", "Area"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got placemarks %v, want %v", names, want)
+	}
+
+	if want := []string{"Round Trip", "Sites"}; !reflect.DeepEqual(paths[0], want) {
+		t.Errorf("got path %v, want %v", paths[0], want)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	for _, doc := range []string{
+		"<html></html>",
+		"<kml><Document><Placemark><name>Cut off",
+		"<kml><Document>",
+	} {
+		dec := NewDecoder(strings.NewReader(doc))
+		if _, err := dec.Next(); err == nil || err == io.EOF {
+			t.Errorf("got %v decoding %q", err, doc)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package gokml
+
+import "math"
+
+// Densify adds Points along the great circles between the Points of the
+// LineString, so that no segment is longer than a maximum in meters.  Lines
+// clamped to the ground then follow the terrain between distant Points
+// instead of cutting through hills.  Altitudes change evenly along each
+// segment.  Maximums that aren't positive are ignored.
+func (ls *LineString) Densify(max float64) {
+	if !(max > 0) {
+		return
+	}
+
+	ls.mutex.Lock()
+	ls.coordinates = densifyPoints(ls.coordinates, max)
+	ls.mutex.Unlock()
+}
+
+// Densify adds Points along the great circles between the Points of the
+// Polygon's boundaries, including the segment closing each ring, as for a
+// LineString.  Maximums that aren't positive are ignored.
+func (poly *Polygon) Densify(max float64) {
+	if !(max > 0) {
+		return
+	}
+
+	poly.mutex.Lock()
+	defer poly.mutex.Unlock()
+
+	poly.points = densifyRing(poly.points, max)
+	for i, ring := range poly.inner {
+		poly.inner[i] = densifyRing(ring, max)
+	}
+}
+
+// densifyRing returns a ring densified to a maximum segment length in
+// meters, closed if it was.
+func densifyRing(ring []*Point, max float64) []*Point {
+	if len(ring) < 2 || *ring[0] == *ring[len(ring)-1] {
+		return densifyPoints(ring, max)
+	}
+
+	dense := densifyPoints(append(ring[:len(ring):len(ring)], ring[0]), max)
+
+	return dense[:len(dense)-1]
+}
+
+// densifyPoints returns the Points of a line with Points added so that no
+// segment is longer than a maximum in meters.  Segments between antipodal
+// Points, with no single great circle, are left as they are.
+func densifyPoints(points []*Point, max float64) []*Point {
+	if len(points) < 2 {
+		return points
+	}
+
+	dense := make([]*Point, 0, len(points))
+	for i, p := range points {
+		if i > 0 {
+			prev := points[i-1]
+			d := greatCircleDistance(prev, p)
+			if d > max && math.Abs(d-math.Pi*earthRadius) >= 1 {
+				n := int(math.Ceil(d / max))
+				for j := 1; j < n; j++ {
+					dense = append(dense, intermediate(prev, p, float64(j)/float64(n)))
+				}
+			}
+		}
+		dense = append(dense, p)
+	}
+
+	return dense
+}
@@ -0,0 +1,53 @@
+package gokml
+
+import "testing"
+
+func TestDensify(t *testing.T) {
+	line := NewLineString()
+	line.AddPoint(NewPoint(0, 0, 0))
+	line.AddPoint(NewPoint(0, 0.01, 100))
+	line.AddPoint(NewPoint(0, 0.1, 1000))
+	line.Densify(2000)
+
+	// 1.1 km, then 10 km in six segments
+	if n := len(line.coordinates); n != 8 {
+		t.Fatalf("got %d points, want 8", n)
+	}
+	for i := 1; i < len(line.coordinates); i++ {
+		if d := greatCircleDistance(line.coordinates[i-1], line.coordinates[i]); d > 2000 {
+			t.Errorf("segment %d is %f m", i, d)
+		}
+	}
+	if p := line.coordinates[4]; p.Alt != 550 {
+		t.Errorf("altitude %f, want 550", p.Alt)
+	}
+
+	line.Densify(0)
+	if n := len(line.coordinates); n != 8 {
+		t.Errorf("got %d points after an invalid maximum, want 8", n)
+	}
+
+	// the closing segment of an unclosed ring is densified too
+	for _, closed := range []bool{false, true} {
+		poly := NewPolygon()
+		poly.AddPoint(NewPoint(0, 0, 0))
+		poly.AddPoint(NewPoint(0, 0.01, 0))
+		poly.AddPoint(NewPoint(0.01, 0.01, 0))
+		poly.AddPoint(NewPoint(0.01, 0, 0))
+		if closed {
+			poly.AddPoint(NewPoint(0, 0, 0))
+		}
+		poly.Densify(600)
+
+		want := 8
+		if closed {
+			want = 9
+		}
+		if len(poly.points) != want {
+			t.Errorf("closed %v: got %d points, want %d", closed, len(poly.points), want)
+		}
+		if last := poly.points[len(poly.points)-1]; closed != (*last == Point{0, 0, 0}) {
+			t.Errorf("closed %v: ends at %v", closed, last)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package gokml
+
+import (
+	"fmt"
+)
+
+// Difference describes a feature that differs between two documents.
+type Difference struct {
+	Kind    string     // "added", "removed", "changed", "moved", or "uncompared"
+	Element string     // Folder, Placemark, or Style
+	ID      string     // id of the feature (the name of a Style)
+	Old     renderable // the feature in the old document, nil if added
+	New     renderable // the feature in the new document, nil if removed
+	Path    string     // names of the Folders and feature, for features without an id
+}
+
+func (d *Difference) String() string {
+	if len(d.ID) == 0 {
+		return fmt.Sprintf("%s %s at %q", d.Kind, d.Element, d.Path)
+	}
+	return fmt.Sprintf("%s %s %q", d.Kind, d.Element, d.ID)
+}
+
+// Diff compares two documents and returns the features that were added,
+// removed, changed, or moved to another Folder, in document order (removed
+// features last).  Like Delta, it matches Folders, Placemarks, and Styles by
+// id, and only a Folder's own properties are compared, not its contents.  A
+// feature that was moved and changed is reported twice.  Delta returns the
+// same differences as an Update, for sending to clients through a
+// NetworkLinkControl.
+//
+// Folders and Placemarks without an id are matched by their path instead:
+// the names of their Folders and their own name, separated by "/".  They
+// can't be told apart from others with the same path, so those are reported
+// as uncompared, and one that was moved or renamed is reported as removed
+// and added.
+func Diff(old *KML, new *KML) []*Difference {
+	diffs := make([]*Difference, 0)
+	oldIndex := indexDiff(old.rootFolder)
+	newIndex := indexDiff(new.rootFolder)
+
+	for _, entry := range newIndex.ordered {
+		if len(entry.id) == 0 {
+			prev, ok := oldIndex.match(entry)
+			switch {
+			case !ok || !newIndex.unique(entry):
+				diffs = append(diffs, &Difference{"uncompared", entry.element, "", nil, entry.feature, entry.path})
+			case prev == nil:
+				diffs = append(diffs, &Difference{"added", entry.element, "", nil, entry.feature, entry.path})
+			default:
+				if change, replace := compareFeatures(prev.idEntry, entry.idEntry); change != nil || replace {
+					diffs = append(diffs, &Difference{"changed", entry.element, "", prev.feature, entry.feature, entry.path})
+				}
+			}
+			continue
+		}
+
+		prev, ok := oldIndex.ids.entries[entry.id]
+		if !ok || prev.element != entry.element {
+			diffs = append(diffs, &Difference{"added", entry.element, entry.id, nil, entry.feature, ""})
+			continue
+		}
+
+		if prev.parentID() != entry.parentID() {
+			diffs = append(diffs, &Difference{"moved", entry.element, entry.id, prev.feature, entry.feature, ""})
+		}
+
+		if change, replace := compareFeatures(prev, entry.idEntry); change != nil || replace {
+			diffs = append(diffs, &Difference{"changed", entry.element, entry.id, prev.feature, entry.feature, ""})
+		}
+	}
+
+	for _, entry := range oldIndex.ordered {
+		if len(entry.id) == 0 {
+			next, ok := newIndex.match(entry)
+			switch {
+			case !ok || !oldIndex.unique(entry):
+				diffs = append(diffs, &Difference{"uncompared", entry.element, "", entry.feature, nil, entry.path})
+			case next == nil:
+				diffs = append(diffs, &Difference{"removed", entry.element, "", entry.feature, nil, entry.path})
+			}
+			continue
+		}
+
+		if next, ok := newIndex.ids.entries[entry.id]; !ok || next.element != entry.element {
+			diffs = append(diffs, &Difference{"removed", entry.element, entry.id, entry.feature, nil, ""})
+		}
+	}
+
+	return diffs
+}
+
+// diffEntry is a feature compared by Diff: one indexed by id, or a Folder
+// or Placemark without an id, which is matched by its path.
+type diffEntry struct {
+	*idEntry
+	path string
+}
+
+type diffIndex struct {
+	ids     *idIndex
+	paths   map[string][]*diffEntry // by element and path
+	ordered []*diffEntry
+}
+
+// indexDiff indexes the features with ids in the tree as indexIDs does, and
+// the Folders and Placemarks without ids by their path.
+func indexDiff(root *Folder) *diffIndex {
+	index := &diffIndex{indexIDs(root), make(map[string][]*diffEntry), nil}
+
+	var walk func(f *Folder, prefix string)
+	walk = func(f *Folder, prefix string) {
+		for _, child := range f.features {
+			var id, element, name string
+
+			switch c := child.(type) {
+			case *Folder:
+				id, element, name = c.id, "Folder", c.name
+			case *Placemark:
+				id, element, name = c.id, "Placemark", c.name
+			case *Style:
+				id, element = c.name, "Style"
+			default:
+				continue
+			}
+
+			if len(id) > 0 {
+				// features with a duplicate id are only indexed once
+				if entry := index.ids.entries[id]; entry.feature == child {
+					index.ordered = append(index.ordered, &diffEntry{entry, ""})
+				}
+			} else if element != "Style" {
+				entry := &diffEntry{&idEntry{"", element, child, nil}, prefix + name}
+				key := element + " " + entry.path
+				index.paths[key] = append(index.paths[key], entry)
+				index.ordered = append(index.ordered, entry)
+			}
+
+			if sub, ok := child.(*Folder); ok {
+				walk(sub, prefix+name+"/")
+			}
+		}
+	}
+	walk(root, "")
+
+	return index
+}
+
+// match returns the entry in the index with the same element and path as
+// entry, or nil if there is none.  It returns false if there's more than
+// one, which can't be told apart.
+func (index *diffIndex) match(entry *diffEntry) (*diffEntry, bool) {
+	switch matches := index.paths[entry.element+" "+entry.path]; len(matches) {
+	case 0:
+		return nil, true
+	case 1:
+		return matches[0], true
+	}
+
+	return nil, false
+}
+
+// unique reports whether entry is the only one in the index with its
+// element and path.
+func (index *diffIndex) unique(entry *diffEntry) bool {
+	return len(index.paths[entry.element+" "+entry.path]) == 1
+}
@@ -0,0 +1,102 @@
+package gokml
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	k, f, pm := deltaFixture()
+
+	style := NewStyle("red", 255, 255, 0, 0)
+	k.AddFeature(style)
+
+	gone := NewPlacemark("Van 3", "", NewPoint(40.5, -105.5, 0.0))
+	gone.SetID("van-3")
+	f.AddFeature(gone)
+
+	old := k.Snapshot()
+
+	if diffs := Diff(old, k.Snapshot()); len(diffs) != 0 {
+		t.Errorf("unexpected differences between identical documents: %v", diffs)
+	}
+
+	// move the truck out of its folder and change it
+	removeFeature(k.rootFolder, pm)
+	pm.geometry.(*Point).Lat = 41.0
+	k.AddFeature(pm)
+
+	removeFeature(k.rootFolder, gone)
+
+	added := NewPlacemark("Car 7", "", NewPoint(39.0, -104.0, 0.0))
+	added.SetID("car-7")
+	f.AddFeature(added)
+
+	style.SetIconScale(2.0)
+
+	want := []string{
+		`added Placemark "car-7"`,
+		`changed Style "red"`,
+		`moved Placemark "truck-12"`,
+		`changed Placemark "truck-12"`,
+		`removed Placemark "van-3"`,
+	}
+
+	diffs := Diff(old, k.Snapshot())
+	if len(diffs) != len(want) {
+		t.Fatalf("got %v, want %v", diffs, want)
+	}
+
+	for i, d := range diffs {
+		if d.String() != want[i] {
+			t.Errorf("difference %d = %s, want %s", i, d, want[i])
+		}
+	}
+
+	if diffs[4].Old == nil || diffs[4].New != nil {
+		t.Error("removed difference should only have the old feature")
+	}
+}
+
+func TestDiffWithoutIDs(t *testing.T) {
+	k := NewKML("Survey")
+	plots := NewFolder("Plots", "")
+	k.AddFeature(plots)
+
+	plot := NewPlacemark("Plot 7", "", NewPoint(40.0, -105.0, 0.0))
+	plots.AddFeature(plot)
+	plots.AddFeature(NewPlacemark("Camp", "", NewPoint(40.1, -105.1, 0.0)))
+	plots.AddFeature(NewPlacemark("Unnamed", "", NewPoint(40.2, -105.2, 0.0)))
+	plots.AddFeature(NewPlacemark("Unnamed", "", NewPoint(40.3, -105.3, 0.0)))
+
+	old := k.Snapshot()
+
+	// the ambiguous placemarks are reported from both documents
+	if diffs := Diff(old, k.Snapshot()); len(diffs) != 4 || diffs[0].Kind != "uncompared" || diffs[0].New == nil || diffs[3].Old == nil {
+		t.Errorf("got %v, want only the ambiguous placemarks uncompared", diffs)
+	}
+
+	plot.geometry.(*Point).Lat = 40.5
+	removeFeature(plots, plots.features[1])
+	k.AddFeature(NewPlacemark("Camp", "", NewPoint(40.1, -105.1, 0.0)))
+
+	want := []string{
+		`changed Placemark at "Plots/Plot 7"`,
+		`uncompared Placemark at "Plots/Unnamed"`,
+		`uncompared Placemark at "Plots/Unnamed"`,
+		`added Placemark at "Camp"`,
+		`removed Placemark at "Plots/Camp"`,
+		`uncompared Placemark at "Plots/Unnamed"`,
+		`uncompared Placemark at "Plots/Unnamed"`,
+	}
+
+	diffs := Diff(old, k.Snapshot())
+	if len(diffs) != len(want) {
+		t.Fatalf("got %v, want %v", diffs, want)
+	}
+
+	for i, d := range diffs {
+		if d.String() != want[i] {
+			t.Errorf("difference %d = %s, want %s", i, d, want[i])
+		}
+	}
+}
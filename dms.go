@@ -0,0 +1,181 @@
+package gokml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DMSFormat describes how coordinates are written in degrees, minutes, and
+// seconds, as in aviation charts and legal descriptions, such as
+// 38°53'23"N 77°00'27"W.
+type DMSFormat struct {
+	Degree    string // after the degrees
+	Minute    string // after the minutes
+	Second    string // after the seconds
+	Separator string // between latitude and longitude
+	Decimals  int    // decimal places of the seconds
+	Pad       bool   // zero-pad degrees to 2 digits of latitude, 3 of longitude
+}
+
+var (
+	// DMSStandard writes 38°53'23"N 77°00'27"W.
+	DMSStandard = DMSFormat{"°", "'", "\"", " ", 0, false}
+
+	// DMSTypographic writes 38°53′23.4″N 77°00′27.1″W, with primes.
+	DMSTypographic = DMSFormat{"°", "′", "″", " ", 1, false}
+
+	// DMSCompact writes 385323N 0770027W, as in aviation.
+	DMSCompact = DMSFormat{"", "", "", " ", 0, true}
+)
+
+// dmsSymbols are the symbols also accepted for degrees, minutes, and
+// seconds, whatever the format.
+var dmsSymbols = []string{"°", "º", "′", "″", "''", "'", "\"", ":"}
+
+// Format writes the latitude and longitude of the Point, rounded to the
+// decimal places of the seconds.
+func (f DMSFormat) Format(p *Point) string {
+	return f.angle(p.Lat, "N", "S", 2) + f.Separator + f.angle(normalizeLon(p.Lon), "E", "W", 3)
+}
+
+// angle writes an angle in degrees with a hemisphere letter.
+func (f DMSFormat) angle(v float64, positive, negative string, width int) string {
+	hemisphere := positive
+	if v < 0 {
+		hemisphere = negative
+	}
+
+	scale := math.Pow(10, float64(maxInt(f.Decimals, 0)))
+	seconds := math.Round(math.Abs(v)*3600*scale) / scale
+	d := math.Floor(seconds / 3600)
+	m := math.Floor((seconds - d*3600) / 60)
+	s := seconds - d*3600 - m*60
+
+	degrees := strconv.Itoa(int(d))
+	if f.Pad {
+		degrees = fmt.Sprintf("%0*d", width, int(d))
+	}
+	secWidth := 2
+	if f.Decimals > 0 {
+		secWidth += 1 + f.Decimals
+	}
+
+	return fmt.Sprintf("%s%s%02d%s%0*.*f%s%s", degrees, f.Degree, int(m), f.Minute, secWidth, maxInt(f.Decimals, 0), s, f.Second, hemisphere)
+}
+
+// Parse returns the Point at a latitude and longitude in degrees, minutes,
+// and seconds, written with the format's symbols or the usual others (°,
+// ', ", primes, or colons), or compactly as in aviation (385323N), with
+// hemisphere letters before or after each, or signs.  Minutes and seconds
+// may be left out or have decimals.  The latitude comes first unless the
+// hemisphere letters show otherwise.
+func (f DMSFormat) Parse(s string) (*Point, error) {
+	invalid := fmt.Errorf("gokml: invalid DMS coordinates %q", s)
+
+	// the format's symbols as written, so they can be letters
+	for _, symbol := range []string{f.Degree, f.Minute, f.Second} {
+		if symbol != "" {
+			s = strings.ReplaceAll(s, symbol, " ")
+		}
+	}
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, symbol := range dmsSymbols {
+		s = strings.ReplaceAll(s, symbol, " ")
+	}
+
+	parts := splitDMS(s, f.Separator)
+	if len(parts) != 2 {
+		return nil, invalid
+	}
+
+	var lat, lon float64
+	var hasLat, hasLon bool
+	for i, part := range parts {
+		v, hemisphere, ok := parseDMSAngle(strings.Trim(part, " ,;"+f.Separator))
+		if !ok {
+			return nil, invalid
+		}
+
+		switch {
+		case hemisphere == 'N' || hemisphere == 'S' || (hemisphere == 0 && i == 0 && !hasLat):
+			if hasLat {
+				return nil, invalid
+			}
+			lat, hasLat = v, true
+		default:
+			if hasLon {
+				return nil, invalid
+			}
+			lon, hasLon = v, true
+		}
+	}
+	if !hasLat || !hasLon || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return nil, invalid
+	}
+
+	return NewPoint(lat, lon, 0), nil
+}
+
+// splitDMS splits coordinates into the latitude and longitude, after the
+// first hemisphere letter if they follow the angles, before the second if
+// they lead them, or else at a comma or the separator.
+func splitDMS(s string, separator string) []string {
+	hemispheres := func(r rune) bool { return r == 'N' || r == 'S' || r == 'E' || r == 'W' }
+
+	if first := strings.IndexFunc(s, hemispheres); first == 0 {
+		if second := strings.IndexFunc(s[1:], hemispheres); second >= 0 {
+			return []string{s[:second+1], s[second+1:]}
+		}
+	} else if first > 0 && strings.TrimSpace(s[first+1:]) != "" {
+		return []string{s[:first+1], s[first+1:]}
+	}
+
+	for _, sep := range []string{",", ";", strings.TrimSpace(separator)} {
+		if sep != "" && strings.Contains(s, sep) {
+			return strings.SplitN(s, sep, 2)
+		}
+	}
+
+	// two plain numbers
+	return strings.Fields(s)
+}
+
+// parseDMSAngle parses an angle in degrees, minutes, and seconds separated
+// by spaces, or compact, with a hemisphere letter or sign, returning it in
+// signed degrees with the hemisphere letter, if any.
+func parseDMSAngle(s string) (float64, byte, bool) {
+	s = strings.TrimSpace(s)
+
+	var hemisphere byte
+	if s != "" && strings.ContainsAny(s[:1], "NSEW") {
+		hemisphere, s = s[0], s[1:]
+	} else if s != "" && strings.ContainsAny(s[len(s)-1:], "NSEW") {
+		hemisphere, s = s[len(s)-1], s[:len(s)-1]
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 3 {
+		return 0, 0, false
+	}
+
+	negative := strings.HasPrefix(fields[0], "-")
+	fields[0] = strings.TrimPrefix(strings.TrimPrefix(fields[0], "-"), "+")
+
+	// compact degrees, minutes, and seconds, such as 385323 or 0770027.5
+	if whole := strings.SplitN(fields[0], ".", 2)[0]; len(fields) == 1 && len(whole) >= 5 && len(whole) <= 7 {
+		d := len(whole) - 4
+		fields = []string{fields[0][:d], fields[0][d : d+2], fields[0][d+2:]}
+	}
+
+	v, err := parseDMS(strings.Join(fields, ":"))
+	if err != nil || (negative && hemisphere != 0) {
+		return 0, 0, false
+	}
+	if negative || hemisphere == 'S' || hemisphere == 'W' {
+		v = -v
+	}
+
+	return v, hemisphere, true
+}
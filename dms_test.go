@@ -0,0 +1,69 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDMSFormat(t *testing.T) {
+	p := NewPoint(38.88972, -77.0075, 0)
+	tests := []struct {
+		format DMSFormat
+		want   string
+	}{
+		{DMSStandard, `38°53'23"N 77°00'27"W`},
+		{DMSTypographic, `38°53′23.0″N 77°00′27.0″W`},
+		{DMSCompact, `385323N 0770027W`},
+		{DMSFormat{"d", "m", "s", ", ", 2, false}, `38d53m22.99sN, 77d00m27.00sW`},
+	}
+	for _, test := range tests {
+		if got := test.format.Format(p); got != test.want {
+			t.Errorf("Format = %q, want %q", got, test.want)
+		}
+	}
+
+	// seconds that round up carry into the minutes and degrees
+	if got := DMSStandard.Format(NewPoint(-33.99999, 179.99999, 0)); got != `34°00'00"S 180°00'00"E` {
+		t.Errorf("rounded Format = %q", got)
+	}
+}
+
+func TestDMSParse(t *testing.T) {
+	want := NewPoint(38+53.0/60+23.0/3600, -(77 + 27.0/3600), 0)
+	for _, s := range []string{
+		`38°53'23"N 77°00'27"W`,
+		`38°53′23″N, 77°0′27″W`,
+		`N38 53 23 W77 0 27`,
+		`385323N 0770027W`,
+		`38:53:23 N 77:00:27 W`,
+		`77°00'27"W 38°53'23"N`,
+		`38 53 23, -77 0 27`,
+		`38°53.38333'N 77°0.45'W`,
+	} {
+		p, err := DMSStandard.Parse(s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", s, err)
+			continue
+		}
+		if math.Abs(p.Lat-want.Lat) > 1e-6 || math.Abs(p.Lon-want.Lon) > 1e-6 {
+			t.Errorf("Parse(%q) = %v, want %v", s, p, want)
+		}
+	}
+
+	custom := DMSFormat{"d", "m", "s", " / ", 1, false}
+	if p, err := custom.Parse(custom.Format(want)); err != nil || math.Abs(p.Lat-want.Lat) > 1e-4 || math.Abs(p.Lon-want.Lon) > 1e-4 {
+		t.Errorf("custom round trip = %v, %v", p, err)
+	}
+	for _, format := range []DMSFormat{DMSStandard, DMSTypographic, DMSCompact} {
+		p, err := format.Parse(format.Format(NewPoint(-12.5, 130.25, 0)))
+		if err != nil || math.Abs(p.Lat+12.5) > 1e-9 || math.Abs(p.Lon-130.25) > 1e-9 {
+			t.Errorf("round trip of %q = %v, %v", format.Format(NewPoint(-12.5, 130.25, 0)), p, err)
+		}
+	}
+
+	for _, s := range []string{"", `38°53'23"N`, `91°00'00"N 0°00'00"E`, `38°61'00"N 77°00'27"W`, `38°53'23"N 39°00'00"S`, `-38°53'23"N 77°00'27"W`, "x y"} {
+		if _, err := DMSStandard.Parse(s); err == nil {
+			t.Errorf("Parse(%q) succeeded", s)
+		}
+	}
+}
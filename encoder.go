@@ -0,0 +1,414 @@
+package gokml
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	kmlNamespace  = "http://www.opengis.net/kml/2.2"
+	gxNamespace   = "http://www.google.com/kml/ext/2.2"
+	atomNamespace = "http://www.w3.org/2005/Atom"
+)
+
+// extensionNamespaces are declared on the kml element only if the document
+// uses them.
+var extensionNamespaces = []struct {
+	prefix string
+	uri    string
+	used   func(renderable) bool
+}{
+	{"gx", gxNamespace, usesGx},
+	{"atom", atomNamespace, usesAtom},
+}
+
+// namespaceAttrs returns the namespace declarations for a kml element
+// containing the features: the KML namespace, and each extension namespace
+// used by any of the features.
+func namespaceAttrs(features ...renderable) []xml.Attr {
+	attrs := []xml.Attr{attr("xmlns", kmlNamespace)}
+
+	for _, ns := range extensionNamespaces {
+		for _, f := range features {
+			if ns.used(f) {
+				attrs = append(attrs, attr("xmlns:"+ns.prefix, ns.uri))
+				break
+			}
+		}
+	}
+
+	return attrs
+}
+
+// maxPooledBuffer is the capacity above which buffers aren't returned to
+// bufferPool, so one huge render doesn't pin its memory.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool holds scratch buffers for rendering, so documents that are
+// regenerated frequently don't allocate new buffers for every feature.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// renderOptions controls the layout of rendered documents.
+type renderOptions struct {
+	indent          string // indentation of nested elements
+	newline         string // line ending, or empty for single-line output
+	minify          bool   // no whitespace and no trailing zeros
+	latLonPrecision int    // decimal places of latitudes and longitudes
+	altPrecision    int    // decimal places of altitudes
+	sorted          bool   // render Styles first and features in a stable order
+	parallelism     int    // goroutines rendering the document's features
+	bidiIsolate     bool   // wrap names containing right-to-left text in isolation marks
+	stable          int    // stable output form, or 0 for none
+}
+
+// defaultPrecision is the default number of decimal places of rendered
+// numbers.
+const defaultPrecision = 6
+
+func defaultRenderOptions() renderOptions {
+	return renderOptions{"  ", "\n", false, defaultPrecision, defaultPrecision, false, 1, false, 0}
+}
+
+// encoder writes KML elements through an xml.Encoder, so element text and
+// attribute values are always escaped.  The first error is kept and all
+// later writes are skipped, so render methods don't have to check every
+// write.
+type encoder struct {
+	w       *bufio.Writer
+	xml     *xml.Encoder
+	opts    renderOptions
+	depth   int
+	inline  bool // the current element has no child elements so far
+	started bool
+	err     error
+
+	ctx      context.Context // checked for cancellation, if not nil
+	elements int
+}
+
+// ctxCheckInterval is the number of elements written between checks for
+// cancellation of the encoder's context.
+const ctxCheckInterval = 256
+
+// StableOutput1 is the first stable output form.  See KML.SetStableOutput.
+const StableOutput1 = 1
+
+// pinned returns the options with the layout options replaced by those of
+// the stable output form, if one is set.  Options that don't change the
+// output are kept.
+func (opts renderOptions) pinned() renderOptions {
+	switch opts.stable {
+	case StableOutput1:
+		return renderOptions{"  ", "\n", false, 6, 6, true, opts.parallelism, false, opts.stable}
+	}
+
+	return opts
+}
+
+func newEncoder(w io.Writer, opts renderOptions) *encoder {
+	opts = opts.pinned()
+
+	if opts.minify {
+		opts.newline = ""
+	}
+
+	bw := bufio.NewWriter(w)
+	return &encoder{w: bw, xml: xml.NewEncoder(bw), opts: opts}
+}
+
+func (e *encoder) token(t xml.Token) {
+	if e.err == nil {
+		e.err = e.xml.EncodeToken(t)
+	}
+}
+
+// raw writes s directly to the output.  The xml.Encoder escapes tabs and
+// carriage returns in text, so layout whitespace has to bypass it.
+func (e *encoder) raw(s string) {
+	if e.err == nil {
+		e.err = e.xml.Flush()
+	}
+
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+// rawBytes is like raw, for output that is already in a byte slice.
+func (e *encoder) rawBytes(b []byte) {
+	if e.err == nil {
+		e.err = e.xml.Flush()
+	}
+
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+// rawFrom is like raw, for output read from r.
+func (e *encoder) rawFrom(r io.Reader) {
+	if e.err == nil {
+		e.err = e.xml.Flush()
+	}
+
+	if e.err == nil {
+		_, e.err = io.Copy(e.w, r)
+	}
+}
+
+// header writes the XML declaration.
+func (e *encoder) header() {
+	e.raw(strings.TrimSuffix(xml.Header, "\n"))
+	e.started = true
+}
+
+// newline starts a new, indented line for the next element.  Elements are
+// always written on a new line, except for the first.
+func (e *encoder) newline() {
+	if len(e.opts.newline) == 0 {
+		return
+	}
+
+	if !e.started {
+		e.started = true
+		return
+	}
+
+	e.raw(e.opts.newline + strings.Repeat(e.opts.indent, e.depth))
+}
+
+// start opens the named element.
+func (e *encoder) start(name string, attrs ...xml.Attr) {
+	e.checkContext()
+	e.newline()
+	e.token(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+	e.depth++
+	e.inline = true
+}
+
+// checkContext stops the encoder with the context's error once the context
+// is done.  The context is only checked every ctxCheckInterval elements.
+func (e *encoder) checkContext() {
+	if e.ctx == nil || e.err != nil {
+		return
+	}
+
+	e.elements++
+	if e.elements%ctxCheckInterval == 0 {
+		e.err = e.ctx.Err()
+	}
+}
+
+// end closes the named element.  Elements containing only text are closed
+// on the same line.
+func (e *encoder) end(name string) {
+	e.depth--
+
+	if !e.inline {
+		e.newline()
+	}
+	e.inline = false
+
+	e.token(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+// text writes character data into the current element.
+func (e *encoder) text(s string) {
+	e.token(xml.CharData(s))
+}
+
+// element writes a complete element containing only text.
+func (e *encoder) element(name string, value string) {
+	e.start(name)
+	e.text(value)
+	e.end(name)
+}
+
+// cdata writes a complete element containing text wrapped in a CDATA
+// section.
+func (e *encoder) cdata(name string, value string) {
+	e.newline()
+	e.inline = false
+
+	if e.err == nil {
+		v := struct {
+			Text string `xml:",cdata"`
+		}{xmlSafe(value)}
+		e.err = e.xml.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: name}})
+	}
+}
+
+// label writes a name element.  Names containing right-to-left text are
+// wrapped in Unicode isolation marks if the bidiIsolate option is set, so
+// they don't reorder surrounding left-to-right text where they're shown.
+func (e *encoder) label(value string) {
+	if e.opts.bidiIsolate && hasRTL(value) {
+		value = "\u2068" + value + "\u2069"
+	}
+
+	e.element("name", value)
+}
+
+// description writes a description element, as CDATA if it contains HTML.
+func (e *encoder) description(desc string, html bool) {
+	if html {
+		e.cdata("description", desc)
+	} else {
+		e.element("description", desc)
+	}
+}
+
+// float writes a complete element containing a floating point value.
+func (e *encoder) float(name string, v float64) {
+	e.element(name, e.formatFloat(v, defaultPrecision))
+}
+
+// latLon writes a complete element containing a latitude or longitude.
+func (e *encoder) latLon(name string, v float64) {
+	e.element(name, e.formatFloat(v, e.opts.latLonPrecision))
+}
+
+// altitude writes a complete element containing an altitude.
+func (e *encoder) altitude(name string, v float64) {
+	e.element(name, e.formatFloat(v, e.opts.altPrecision))
+}
+
+// int writes a complete element containing an integer value.
+func (e *encoder) int(name string, v int) {
+	e.element(name, strconv.Itoa(v))
+}
+
+// coordinates writes a coordinates element containing the points as
+// space-separated lon,lat[,alt] tuples.
+func (e *encoder) coordinates(points []*Point, withAlt bool) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	for i, p := range points {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+
+		buf.Write(e.appendFloat(buf.AvailableBuffer(), p.Lon, e.opts.latLonPrecision))
+		buf.WriteByte(',')
+		buf.Write(e.appendFloat(buf.AvailableBuffer(), p.Lat, e.opts.latLonPrecision))
+
+		if withAlt {
+			buf.WriteByte(',')
+			buf.Write(e.appendFloat(buf.AvailableBuffer(), p.Alt, e.opts.altPrecision))
+		}
+	}
+
+	// the text is escaped and written out before the buffer is reused
+	e.start("coordinates")
+	e.token(xml.CharData(buf.Bytes()))
+	e.end("coordinates")
+}
+
+// flush writes any buffered output and returns the first error encountered.
+func (e *encoder) flush() error {
+	if e.err == nil {
+		e.err = e.xml.Flush()
+	}
+
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+
+	return e.err
+}
+
+func attr(name string, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+}
+
+// idAttrs returns the id attribute for an element, or nothing if the element
+// has no id.
+func idAttrs(id string) []xml.Attr {
+	if len(id) == 0 {
+		return nil
+	}
+
+	return []xml.Attr{attr("id", id)}
+}
+
+// formatFloat formats v with the given number of decimal places, without
+// trailing zeros when minifying.
+func (e *encoder) formatFloat(v float64, precision int) string {
+	var b [32]byte
+	return string(e.appendFloat(b[:0], v, precision))
+}
+
+// appendFloat appends v formatted like formatFloat to dst.
+func (e *encoder) appendFloat(dst []byte, v float64, precision int) []byte {
+	start := len(dst)
+	dst = strconv.AppendFloat(dst, v, 'f', precision, 64)
+
+	if e.opts.minify {
+		s := dst[start:]
+		if bytes.IndexByte(s, '.') >= 0 {
+			s = bytes.TrimRight(bytes.TrimRight(s, "0"), ".")
+		}
+		if string(s) == "-0" {
+			s = s[1:]
+		}
+		dst = append(dst[:start], s...)
+	}
+
+	return dst
+}
+
+// xmlSafe replaces invalid UTF-8 and characters that aren't allowed in XML
+// documents with U+FFFD, as the xml.Encoder does for text.  CDATA sections
+// aren't escaped, so their contents have to be made safe first.
+func xmlSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if isXMLChar(r) {
+			return r
+		}
+		return utf8.RuneError
+	}, strings.ToValidUTF8(s, string(utf8.RuneError)))
+}
+
+// isXMLChar reports whether r is allowed in XML documents.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+// hasRTL reports whether s contains characters from right-to-left scripts.
+func hasRTL(s string) bool {
+	for _, r := range s {
+		if unicode.In(r, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana,
+			unicode.Nko, unicode.Samaritan, unicode.Mandaic, unicode.Adlam) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,353 @@
+package gokml
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// extraXML holds the attributes and child elements of a parsed feature that
+// this package doesn't model (vendor extensions, gx elements, etc.), so they
+// are rendered again instead of being dropped.  It is never modified after
+// parsing, so copies of a feature can share it.
+type extraXML struct {
+	attrs []xml.Attr
+	nodes []*node
+}
+
+// parseExtra returns the attributes of n other than id and the child elements
+// of n that aren't decoded, or nil if there are none.
+func parseExtra(n *node, known knownElements) *extraXML {
+	x := new(extraXML)
+
+	for _, a := range n.attrs {
+		if a.Name.Local == "id" && len(a.Name.Space) == 0 || isNamespaceDecl(a) {
+			continue
+		}
+		x.attrs = append(x.attrs, a)
+	}
+
+	for _, c := range n.children {
+		if !known.decodes(c) {
+			x.nodes = append(x.nodes, c)
+		}
+	}
+
+	if len(x.attrs) == 0 && len(x.nodes) == 0 {
+		return nil
+	}
+
+	return x
+}
+
+// startAttrs returns attrs followed by the preserved attributes.
+func (x *extraXML) startAttrs(attrs []xml.Attr) []xml.Attr {
+	if x == nil || len(x.attrs) == 0 {
+		return attrs
+	}
+
+	ret := append([]xml.Attr(nil), attrs...)
+	for _, a := range x.attrs {
+		name, decl := prefixedName(a.Name, len(ret))
+		ret = append(ret, attr(name, a.Value))
+		if decl != nil {
+			ret = append(ret, *decl)
+		}
+	}
+
+	return ret
+}
+
+// render writes the preserved child elements.
+func (x *extraXML) render(e *encoder) {
+	if x == nil {
+		return
+	}
+
+	for _, n := range x.nodes {
+		renderNode(e, n, kmlNamespace)
+	}
+}
+
+// extraWriter writes the preserved child elements of a feature in their
+// places in the schema, among the elements the feature writes itself.
+// Elements the schema doesn't place, such as those in other namespaces, are
+// written last.
+type extraWriter struct {
+	x      *extraXML
+	e      *encoder
+	groups []schemaGroup
+	rank   int // the elements ranked before this are written
+}
+
+// writer returns an extraWriter for the preserved elements of the named
+// feature element.
+func (x *extraXML) writer(e *encoder, element string) *extraWriter {
+	return &extraWriter{x, e, schemaOrder[element], 0}
+}
+
+// before writes the preserved elements that come before the named element
+// and weren't written yet.
+func (w *extraWriter) before(name string) {
+	w.upTo(w.rankOf(name))
+}
+
+// rest writes the preserved elements that weren't written yet.
+func (w *extraWriter) rest() {
+	w.upTo(len(w.groups) + 1)
+}
+
+func (w *extraWriter) upTo(rank int) {
+	if rank <= w.rank {
+		return
+	}
+
+	if w.x != nil {
+		for _, n := range w.x.nodes {
+			if r := w.rankOf(schemaName(n.name)); r >= w.rank && r < rank {
+				renderNode(w.e, n, kmlNamespace)
+			}
+		}
+	}
+
+	w.rank = rank
+}
+
+// rankOf returns the position of the group of the named element, or the
+// number of groups if the schema doesn't place it.
+func (w *extraWriter) rankOf(name string) int {
+	for rank, g := range w.groups {
+		for _, n := range g.names {
+			if n == name {
+				return rank
+			}
+		}
+	}
+
+	return len(w.groups)
+}
+
+// uses reports whether any preserved element or attribute is in the
+// namespace.
+func (x *extraXML) uses(space string) bool {
+	if x == nil {
+		return false
+	}
+
+	for _, a := range x.attrs {
+		if a.Name.Space == space {
+			return true
+		}
+	}
+
+	for _, n := range x.nodes {
+		if nodeUses(n, space) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func nodeUses(n *node, space string) bool {
+	if n.name.Space == space {
+		return true
+	}
+
+	for _, a := range n.attrs {
+		if a.Name.Space == space {
+			return true
+		}
+	}
+
+	for _, c := range n.children {
+		if nodeUses(c, space) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renderNode writes a parsed element.  The gx and atom namespaces use the
+// prefixes declared on the kml element; other namespaces are declared on
+// the element itself.  def is the default namespace of the parent element.
+func renderNode(e *encoder, n *node, def string) {
+	name := n.name.Local
+	var attrs []xml.Attr
+
+	switch space := n.name.Space; space {
+	case gxNamespace:
+		name = "gx:" + name
+	case atomNamespace:
+		name = "atom:" + name
+	default:
+		if len(space) == 0 {
+			space = kmlNamespace
+		}
+		if space != def {
+			attrs = append(attrs, attr("xmlns", space))
+			def = space
+		}
+	}
+
+	for _, a := range n.attrs {
+		if isNamespaceDecl(a) {
+			continue
+		}
+
+		aname, decl := prefixedName(a.Name, len(attrs))
+		attrs = append(attrs, attr(aname, a.Value))
+		if decl != nil {
+			attrs = append(attrs, *decl)
+		}
+	}
+
+	e.start(name, attrs...)
+
+	if len(n.text) > 0 {
+		e.text(n.text)
+	}
+
+	for _, c := range n.children {
+		renderNode(e, c, def)
+	}
+
+	e.end(name)
+}
+
+// prefixedName returns the name to write for an attribute, and a namespace
+// declaration for it if it is in a namespace other than gx or atom.  Unique
+// is used to make up a unique prefix.
+func prefixedName(name xml.Name, unique int) (string, *xml.Attr) {
+	switch name.Space {
+	case "":
+		return name.Local, nil
+	case gxNamespace:
+		return "gx:" + name.Local, nil
+	case atomNamespace:
+		return "atom:" + name.Local, nil
+	}
+
+	prefix := "ns" + strconv.Itoa(unique)
+	decl := attr("xmlns:"+prefix, name.Space)
+
+	return prefix + ":" + name.Local, &decl
+}
+
+func isNamespaceDecl(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || len(a.Name.Space) == 0 && a.Name.Local == "xmlns"
+}
+
+// rewriteHrefs returns a copy of x with the text of every href element
+// replaced by the result of fn, and whether any changed.  x itself isn't
+// modified, since copies of features share it.
+func (x *extraXML) rewriteHrefs(fn func(string) string) (*extraXML, bool) {
+	if x == nil {
+		return nil, false
+	}
+
+	cp := &extraXML{x.attrs, make([]*node, len(x.nodes))}
+	changed := false
+	for i, n := range x.nodes {
+		var c bool
+		cp.nodes[i], c = rewriteNodeHrefs(n, fn)
+		changed = changed || c
+	}
+
+	return cp, changed
+}
+
+// rewriteNodeHrefs returns n, or a copy of n with its hrefs rewritten if any
+// changed.
+func rewriteNodeHrefs(n *node, fn func(string) string) (*node, bool) {
+	if n.name.Local == "href" {
+		if href := fn(n.text); href != n.text {
+			cp := *n
+			cp.text = href
+			return &cp, true
+		}
+		return n, false
+	}
+
+	var children []*node
+	for i, c := range n.children {
+		rc, changed := rewriteNodeHrefs(c, fn)
+		if changed && children == nil {
+			children = append([]*node(nil), n.children...)
+		}
+		if changed {
+			children[i] = rc
+		}
+	}
+	if children == nil {
+		return n, false
+	}
+
+	cp := *n
+	cp.children = children
+	return &cp, true
+}
+
+// extraOf returns the preserved XML of a feature, or nil.
+func extraOf(feature renderable) *extraXML {
+	switch f := feature.(type) {
+	case *Folder:
+		return f.extra
+	case *Placemark:
+		return f.extra
+	case *Style:
+		return f.extra
+	case *NetworkLink:
+		return f.extra
+	case *GroundOverlay:
+		return f.extra
+	}
+
+	return nil
+}
+
+// setExtra replaces the preserved XML of a feature.
+func setExtra(feature renderable, x *extraXML) {
+	switch f := feature.(type) {
+	case *Folder:
+		f.extra = x
+	case *Placemark:
+		f.extra = x
+	case *Style:
+		f.extra = x
+	case *NetworkLink:
+		f.extra = x
+	case *GroundOverlay:
+		f.extra = x
+	}
+}
+
+// knownElements are the child elements of a feature that Parse decodes, by
+// local name, each with a test of whether an element can be decoded without
+// losing anything, or nil if it always can.  Elements that fail the test are
+// kept as they are instead.
+type knownElements map[string]func(*node) bool
+
+// decodes reports whether n is decoded by Parse.
+func (k knownElements) decodes(n *node) bool {
+	test, ok := k[n.name.Local]
+	return ok && (test == nil || test(n))
+}
+
+// elements of each feature that are decoded by Parse
+var (
+	knownFolder = knownElements{
+		"name": nil, "description": nil, "open": nil, "author": decodableAuthor, "link": decodableLink,
+		"LookAt": decodableLookAt, "Region": decodableRegion, "Document": nil, "Folder": nil, "Placemark": nil,
+		"Style": decodableStyle, "NetworkLink": decodableNetworkLink, "GroundOverlay": decodableGroundOverlay,
+	}
+	knownPlacemark = knownElements{
+		"name": nil, "description": nil, "visibility": nil, "balloonVisibility": decodableBalloon, "styleUrl": nil,
+		"TimeSpan": decodableTimeSpan, "ExtendedData": decodableData,
+		"Point": decodableGeometry, "LineString": decodableGeometry, "Polygon": decodableGeometry,
+		"MultiGeometry": decodableGeometry, "Track": decodableGeometry,
+	}
+	knownStyle         = knownElements{"IconStyle": nil, "LineStyle": nil, "PolyStyle": nil}
+	knownNetworkLink   = knownElements{"name": nil, "description": nil, "TimeStamp": decodableTimeStamp, "Region": decodableRegion, "Link": nil}
+	knownGroundOverlay = knownElements{"name": nil, "description": nil, "Icon": nil, "LatLonBox": nil, "LatLonQuad": nil}
+)
@@ -0,0 +1,53 @@
+package gokml
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes the document to the named file like Write.  The document
+// is written to a temporary file in the same directory, which then replaces
+// the named file, so clients fetching the file (for example through a
+// NetworkLink) never see a partially written document.
+func (k *KML) WriteFile(name string) error {
+	return writeFileAtomic(name, k.Write)
+}
+
+// WriteKMZFile writes the document to the named file as a KMZ archive like
+// WriteKMZ, replacing the file atomically like WriteFile.
+func (k *KML) WriteKMZFile(name string) error {
+	return writeFileAtomic(name, k.WriteKMZ)
+}
+
+// writeFileAtomic writes a temporary file with write and renames it to name.
+// The temporary file is removed if anything fails.
+func writeFileAtomic(name string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// CreateTemp creates the file readable only by its owner
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+	}
+
+	return err
+}
@@ -0,0 +1,85 @@
+package gokml
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "doc.kml")
+
+	if err := os.WriteFile(name, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	k := NewKML("File")
+	k.AddFeature(NewPlacemark("Place", "", NewPoint(40.0, -105.0, 0.0)))
+
+	if err := k.WriteFile(name); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != k.Render() {
+		t.Errorf("file doesn't contain the document:\n%s", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
+
+func TestWriteFileBroken(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "doc.kml")
+
+	if err := os.WriteFile(name, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	k := NewKML("Broken")
+	pm := NewPlacemark("Place", "", NewPoint(40.0, -105.0, 0.0))
+	pm.SetStyle("Missing")
+	k.AddFeature(pm)
+
+	if err := k.WriteFile(name); err == nil {
+		t.Error("no error for a broken document")
+	}
+
+	data, _ := os.ReadFile(name)
+	if string(data) != "old" {
+		t.Errorf("existing file replaced by a broken document: %q", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
+
+func TestWriteKMZFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "doc.kmz")
+
+	k := NewKML("Archive")
+	if err := k.WriteKMZFile(name); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+
+	if len(z.File) != 1 || z.File[0].Name != "doc.kml" {
+		t.Errorf("unexpected archive contents: %v", z.File)
+	}
+}
@@ -0,0 +1,252 @@
+package gokml
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	fitRecord    = 20         // global message number of record messages
+	fitTimestamp = 253        // field number of timestamps in any message
+	fitEpoch     = 631065600  // 1989-12-31T00:00:00Z in Unix time
+	fitInvalid32 = 0x7fffffff // invalid value of sint32 fields
+)
+
+// fitCRCTable is the nibble table of the FIT file checksum.
+var fitCRCTable = [16]uint16{
+	0x0000, 0xcc01, 0xd801, 0x1400, 0xf001, 0x3c00, 0x2800, 0xe401,
+	0xa001, 0x6c00, 0x7800, 0xb401, 0x5000, 0x9c01, 0x8801, 0x4400,
+}
+
+// fitCRC returns the FIT checksum of b.
+func fitCRC(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		for _, nibble := range []byte{c & 0xf, c >> 4} {
+			tmp := fitCRCTable[crc&0xf]
+			crc = (crc >> 4) & 0x0fff
+			crc = crc ^ tmp ^ fitCRCTable[nibble]
+		}
+	}
+
+	return crc
+}
+
+// fitDefinition is the layout of the data messages of a local message type.
+type fitDefinition struct {
+	order   binary.ByteOrder
+	global  uint16
+	fields  []fitField
+	devSize int // total size of developer fields, which are skipped
+}
+
+type fitField struct {
+	num, size byte
+}
+
+// FromFIT converts a Garmin FIT activity file, as saved by most fitness
+// devices, into a KML document with a Placemark whose gx:Track has the
+// position, altitude, and time of each record message, named by the time of
+// the first one.  The heart rate, cadence, and power of each record are kept
+// as gx:SimpleArrayData so Google Earth can chart them.  Records without a
+// position are dropped, as are all other messages.  Chained FIT files are
+// read in turn, and a file with a bad checksum is an error.  The bytes read
+// are reported to progress, if it isn't nil.
+func FromFIT(r io.Reader, progress Progress) (*KML, error) {
+	b, err := io.ReadAll(readProgress(r, progress))
+	if err != nil {
+		return nil, err
+	}
+
+	track := NewTrack()
+	var sensors sensorArrays
+
+	for first := true; first || len(b) > 0; first = false {
+		if len(b) < 12 || b[0] < 12 || string(b[8:12]) != ".FIT" {
+			return nil, fmt.Errorf("gokml: not a FIT file")
+		}
+
+		headerSize := int(b[0])
+		end := headerSize + int(binary.LittleEndian.Uint32(b[4:8]))
+		if end+2 > len(b) || end < headerSize {
+			return nil, fmt.Errorf("gokml: truncated FIT file")
+		}
+		if fitCRC(b[:end]) != binary.LittleEndian.Uint16(b[end:]) {
+			return nil, fmt.Errorf("gokml: bad FIT file checksum")
+		}
+
+		if err := readFITRecords(b[headerSize:end], track, &sensors); err != nil {
+			return nil, err
+		}
+		b = b[end+2:]
+	}
+
+	sensors.setOn(track)
+
+	name := ""
+	if len(track.times) > 0 {
+		name = track.times[0].Format(time.RFC3339)
+	}
+
+	k := NewKML("")
+	k.AddFeature(NewPlacemark(name, "", track))
+
+	return k, nil
+}
+
+// readFITRecords adds the record messages in the data section b of a FIT
+// file to track and sensors.
+func readFITRecords(b []byte, track *Track, sensors *sensorArrays) error {
+	var defs [16]*fitDefinition
+	var last uint32 // the most recent timestamp, for compressed timestamps
+
+	truncated := fmt.Errorf("gokml: truncated FIT message")
+
+	for len(b) > 0 {
+		header := b[0]
+		b = b[1:]
+
+		local := header & 0x0f
+		var timestamp uint32
+		compressed := header&0x80 != 0
+		if compressed {
+			// compressed timestamp header: the low 5 bits of the timestamp
+			// as an offset from the last one
+			local = (header >> 5) & 0x03
+			offset := uint32(header & 0x1f)
+			timestamp = last&^0x1f + offset
+			if offset < last&0x1f {
+				timestamp += 0x20
+			}
+			last = timestamp
+		} else if header&0x40 != 0 {
+			// definition message
+			if len(b) < 5 {
+				return truncated
+			}
+
+			def := &fitDefinition{order: binary.LittleEndian}
+			if b[1] == 1 {
+				def.order = binary.BigEndian
+			}
+			def.global = def.order.Uint16(b[2:4])
+
+			n := int(b[4])
+			b = b[5:]
+			if len(b) < 3*n {
+				return truncated
+			}
+			for i := 0; i < n; i++ {
+				def.fields = append(def.fields, fitField{b[3*i], b[3*i+1]})
+			}
+			b = b[3*n:]
+
+			if header&0x20 != 0 {
+				if len(b) < 1 || len(b) < 1+3*int(b[0]) {
+					return truncated
+				}
+				n := int(b[0])
+				for i := 0; i < n; i++ {
+					def.devSize += int(b[2+3*i])
+				}
+				b = b[1+3*n:]
+			}
+
+			defs[local] = def
+			continue
+		}
+
+		def := defs[local]
+		if def == nil {
+			return fmt.Errorf("gokml: FIT data message with undefined local type %d", local)
+		}
+
+		var lat, lon, alt, enhancedAlt int64 = fitInvalid32, fitInvalid32, -1, -1
+		heartRate, cadence, power := math.NaN(), math.NaN(), math.NaN()
+
+		for _, f := range def.fields {
+			if len(b) < int(f.size) {
+				return truncated
+			}
+			v, ok := fitValue(b[:f.size], def.order)
+			b = b[f.size:]
+			if !ok {
+				continue
+			}
+
+			if f.num == fitTimestamp && f.size == 4 {
+				timestamp, last = uint32(v), uint32(v)
+				continue
+			}
+			if def.global != fitRecord {
+				continue
+			}
+
+			switch {
+			case f.num == 0 && f.size == 4:
+				lat = int64(int32(v))
+			case f.num == 1 && f.size == 4:
+				lon = int64(int32(v))
+			case f.num == 2 && f.size == 2:
+				alt = int64(v)
+			case f.num == 78 && f.size == 4:
+				enhancedAlt = int64(v)
+			case f.num == 3 && f.size == 1:
+				heartRate = float64(v)
+			case f.num == 4 && f.size == 1:
+				cadence = float64(v)
+			case f.num == 7 && f.size == 2:
+				power = float64(v)
+			}
+		}
+
+		if len(b) < def.devSize {
+			return truncated
+		}
+		b = b[def.devSize:]
+
+		if def.global != fitRecord || lat == fitInvalid32 || lon == fitInvalid32 || (timestamp == 0 && !compressed) {
+			continue
+		}
+
+		if enhancedAlt >= 0 {
+			alt = enhancedAlt
+		}
+		altitude := 0.0
+		if alt >= 0 {
+			altitude = float64(alt)/5 - 500
+		}
+
+		// positions are in semicircles: 2^31 of them make 180 degrees
+		p := NewPoint(float64(lat)*180/(1<<31), float64(lon)*180/(1<<31), altitude)
+		if p == nil {
+			continue
+		}
+
+		track.AddPoint(time.Unix(fitEpoch+int64(timestamp), 0).UTC(), p)
+		sensors.add(heartRate, cadence, power)
+	}
+
+	return nil
+}
+
+// fitValue returns the unsigned value of a 1, 2, or 4 byte field, and false
+// if the field has another size or holds the invalid value of its unsigned
+// type (all bits set).  Signed fields check their own invalid values.
+func fitValue(b []byte, order binary.ByteOrder) (uint64, bool) {
+	switch len(b) {
+	case 1:
+		return uint64(b[0]), b[0] != 0xff
+	case 2:
+		v := order.Uint16(b)
+		return uint64(v), v != 0xffff
+	case 4:
+		v := order.Uint32(b)
+		return uint64(v), v != 0xffffffff
+	}
+
+	return 0, false
+}
@@ -0,0 +1,109 @@
+package gokml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// fitFile wraps the data records b in a FIT header and checksum.
+func fitFile(b []byte) []byte {
+	header := []byte{14, 0x20, 0, 0, 0, 0, 0, 0, '.', 'F', 'I', 'T', 0, 0}
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(b)))
+	file := append(header, b...)
+
+	return binary.LittleEndian.AppendUint16(file, fitCRC(file))
+}
+
+// fitRecords returns a definition of record messages with a timestamp,
+// position, altitude, heart rate, and power, and a record for each sample.
+func fitRecords(samples ...[5]int64) []byte {
+	b := []byte{0x40, 0, 0, fitRecord, 0, 6,
+		253, 4, 0x86, 0, 4, 0x85, 1, 4, 0x85, 2, 2, 0x84, 3, 1, 0x02, 7, 2, 0x84}
+
+	for _, s := range samples {
+		b = append(b, 0x00)
+		b = binary.LittleEndian.AppendUint32(b, uint32(s[0]))
+		b = binary.LittleEndian.AppendUint32(b, uint32(int32(s[1])))
+		b = binary.LittleEndian.AppendUint32(b, uint32(int32(s[2])))
+		b = binary.LittleEndian.AppendUint16(b, uint16(s[3]))
+		b = append(b, byte(s[4]))
+		b = binary.LittleEndian.AppendUint16(b, 0xffff)
+	}
+
+	return b
+}
+
+func TestFromFIT(t *testing.T) {
+	const start = 1086163200 // 2024-06-01T08:00:00Z
+	lat, lon := int64(40*(1<<31)/180), int64(-105*(1<<31)/180)
+
+	data := fitRecords(
+		[5]int64{start, lat, lon, (1655 + 500) * 5, 120},
+		[5]int64{start + 1, fitInvalid32, fitInvalid32, 0xffff, 121}, // no fix yet
+		[5]int64{start + 30, lat + 1000, lon, 0xffff, 0xff},
+	)
+	// a compressed timestamp record, whose definition has no timestamp
+	data = append(data, 0x41, 0, 0, fitRecord, 0, 5, 0, 4, 0x85, 1, 4, 0x85, 2, 2, 0x84, 3, 1, 0x02, 7, 2, 0x84)
+	data = append(data, 0x80|1<<5|(start+31)&0x1f)
+	data = binary.LittleEndian.AppendUint32(data, uint32(lat+2000))
+	data = binary.LittleEndian.AppendUint32(data, uint32(lon))
+	data = binary.LittleEndian.AppendUint16(data, (1660+500)*5)
+	data = append(data, 130)
+	data = binary.LittleEndian.AppendUint16(data, 250)
+
+	k, err := FromFIT(bytes.NewReader(fitFile(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := k.rootFolder.features[0].(*Placemark)
+	if pm.name != "2024-06-01T08:00:00Z" {
+		t.Errorf("name = %q", pm.name)
+	}
+
+	track := pm.geometry.(*Track)
+	if len(track.points) != 3 {
+		t.Fatalf("got %d points, want 3: %v", len(track.points), track.points)
+	}
+	if p := track.points[0]; math.Abs(p.Lat-40) > 1e-6 || math.Abs(p.Lon+105) > 1e-6 || p.Alt != 1655 {
+		t.Errorf("unexpected first point %v", p)
+	}
+	if track.points[1].Alt != 0 || track.points[2].Alt != 1660 {
+		t.Errorf("unexpected altitudes %v", track.points)
+	}
+	if want := time.Date(2024, 6, 1, 8, 0, 31, 0, time.UTC); !track.times[2].Equal(want) {
+		t.Errorf("compressed timestamp = %v, want %v", track.times[2], want)
+	}
+
+	if len(track.arrays) != 2 || track.arrays[0].name != "heartrate" || track.arrays[1].name != "power" {
+		t.Fatalf("unexpected array data %v", track.arrays)
+	}
+	if hr := track.arrays[0].values; hr[0] != 120 || !math.IsNaN(hr[1]) || hr[2] != 130 {
+		t.Errorf("unexpected heart rate %v", hr)
+	}
+
+	if err := k.Validate(); err != nil {
+		t.Errorf("converted document is invalid: %v", err)
+	}
+}
+
+func TestFromFITErrors(t *testing.T) {
+	good := fitFile(fitRecords([5]int64{1086163200, 0, 0, 0, 0}))
+	bad := append([]byte(nil), good...)
+	bad[20]++
+
+	for _, b := range [][]byte{
+		nil,
+		[]byte("not a fit file"),
+		good[:len(good)-3],
+		bad,
+		fitFile([]byte{0x01, 0, 0}), // data message with no definition
+	} {
+		if _, err := FromFIT(bytes.NewReader(b), nil); err == nil {
+			t.Errorf("no error for % x", b)
+		}
+	}
+}
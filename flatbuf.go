@@ -0,0 +1,310 @@
+package gokml
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// fbReader reads tables and vectors from a FlatBuffers buffer.  Reads out of
+// bounds set a sticky error and return zero values, so callers can check
+// the error once after reading what they need.
+type fbReader struct {
+	b   []byte
+	err error
+}
+
+func (r *fbReader) check(off int, n int) bool {
+	if r.err != nil {
+		return false
+	}
+
+	if off < 0 || n < 0 || off > len(r.b)-n {
+		r.err = fmt.Errorf("offset %d out of range", off)
+		return false
+	}
+
+	return true
+}
+
+func (r *fbReader) u8(off int) uint8 {
+	if !r.check(off, 1) {
+		return 0
+	}
+	return r.b[off]
+}
+
+func (r *fbReader) u16(off int) uint16 {
+	if !r.check(off, 2) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(r.b[off:])
+}
+
+func (r *fbReader) u32(off int) uint32 {
+	if !r.check(off, 4) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(r.b[off:])
+}
+
+func (r *fbReader) u64(off int) uint64 {
+	if !r.check(off, 8) {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(r.b[off:])
+}
+
+// root returns the position of the root table.
+func (r *fbReader) root() int {
+	return int(r.u32(0))
+}
+
+// field returns the position of field i of the table at pos, or 0 if the
+// field isn't present.
+func (r *fbReader) field(table int, i int) int {
+	vtable := table - int(int32(r.u32(table)))
+	size := int(r.u16(vtable))
+
+	if 4+2*i+2 > size {
+		return 0
+	}
+
+	off := int(r.u16(vtable + 4 + 2*i))
+	if off == 0 || r.err != nil {
+		return 0
+	}
+
+	return table + off
+}
+
+// ref follows the offset stored in field i of the table at pos, returning
+// the position of the table, vector, or string it refers to, or 0.
+func (r *fbReader) ref(table int, i int) int {
+	off := r.field(table, i)
+	if off == 0 {
+		return 0
+	}
+
+	return off + int(r.u32(off))
+}
+
+// vector returns the position of the first element and the length of the
+// vector in field i of the table, with elements of the given size.
+func (r *fbReader) vector(table int, i int, size int) (int, int) {
+	pos := r.ref(table, i)
+	if pos == 0 {
+		return 0, 0
+	}
+
+	n := int(r.u32(pos))
+	if !r.check(pos+4, n*size) {
+		return 0, 0
+	}
+
+	return pos + 4, n
+}
+
+func (r *fbReader) str(table int, i int) string {
+	pos, n := r.vector(table, i, 1)
+	if n == 0 {
+		return ""
+	}
+
+	return string(r.b[pos : pos+n])
+}
+
+func (r *fbReader) doubles(table int, i int) []float64 {
+	pos, n := r.vector(table, i, 8)
+
+	v := make([]float64, n)
+	for j := range v {
+		v[j] = math.Float64frombits(binary.LittleEndian.Uint64(r.b[pos+8*j:]))
+	}
+
+	return v
+}
+
+func (r *fbReader) uint32s(table int, i int) []uint32 {
+	pos, n := r.vector(table, i, 4)
+
+	v := make([]uint32, n)
+	for j := range v {
+		v[j] = binary.LittleEndian.Uint32(r.b[pos+4*j:])
+	}
+
+	return v
+}
+
+// tables returns the positions of the tables in the vector in field i.
+func (r *fbReader) tables(table int, i int) []int {
+	pos, n := r.vector(table, i, 4)
+
+	v := make([]int, n)
+	for j := range v {
+		off := pos + 4*j
+		v[j] = off + int(r.u32(off))
+	}
+
+	return v
+}
+
+// fbBuilder writes a FlatBuffers buffer front to back: each table is
+// preceded by its vtable and followed by the objects it refers to, so every
+// offset points forward.
+type fbBuilder struct {
+	b []byte
+}
+
+// fbField is a field of a table being built: a scalar of 1, 2, 4, or 8
+// bytes, or a reference to an object written after the table.
+type fbField struct {
+	size  int
+	value uint64
+	ref   func(fb *fbBuilder) int // writes the object and returns its position
+}
+
+func fbScalar(size int, value uint64) *fbField {
+	return &fbField{size: size, value: value}
+}
+
+func fbRef(ref func(fb *fbBuilder) int) *fbField {
+	return &fbField{size: 4, ref: ref}
+}
+
+// buildFlatBuffer returns a buffer whose root table has the fields.
+func buildFlatBuffer(root []*fbField) []byte {
+	fb := &fbBuilder{make([]byte, 4)}
+	pos := fb.table(root)
+	binary.LittleEndian.PutUint32(fb.b, uint32(pos))
+
+	return fb.b
+}
+
+func (fb *fbBuilder) pad(align int) {
+	for len(fb.b)%align != 0 {
+		fb.b = append(fb.b, 0)
+	}
+}
+
+func (fb *fbBuilder) put(size int, v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	fb.b = append(fb.b, buf[:size]...)
+}
+
+// table writes a table with the fields (nil for absent fields) and the
+// objects they refer to, and returns its position.
+func (fb *fbBuilder) table(fields []*fbField) int {
+	fb.pad(2)
+	vtable := len(fb.b)
+	vtableSize := 4 + 2*len(fields)
+
+	start := vtable + vtableSize
+	start += (4 - start%4) % 4
+
+	// lay out the fields after the vtable offset, aligned to their size
+	offsets := make([]int, len(fields))
+	pos := start + 4
+	for i, f := range fields {
+		if f == nil {
+			continue
+		}
+		pos += (f.size - pos%f.size) % f.size
+		offsets[i] = pos - start
+		pos += f.size
+	}
+
+	fb.put(2, uint64(vtableSize))
+	fb.put(2, uint64(pos-start))
+	for _, off := range offsets {
+		fb.put(2, uint64(off))
+	}
+
+	fb.pad(4)
+	fb.put(4, uint64(uint32(int32(start-vtable))))
+
+	refs := make([]int, len(fields))
+	for i, f := range fields {
+		if f == nil {
+			continue
+		}
+		fb.pad(f.size)
+		refs[i] = len(fb.b)
+		fb.put(f.size, f.value)
+	}
+
+	for i, f := range fields {
+		if f != nil && f.ref != nil {
+			fb.patch(refs[i], f.ref(fb))
+		}
+	}
+
+	return start
+}
+
+// patch sets the offset at pos to refer to target.
+func (fb *fbBuilder) patch(pos int, target int) {
+	binary.LittleEndian.PutUint32(fb.b[pos:], uint32(target-pos))
+}
+
+// vector starts a vector of n elements of the given size, and returns its
+// position.  The caller appends the elements.
+func (fb *fbBuilder) vector(n int, size int) int {
+	fb.pad(4)
+	for size > 4 && (len(fb.b)+4)%size != 0 {
+		fb.put(4, 0)
+	}
+
+	pos := len(fb.b)
+	fb.put(4, uint64(n))
+
+	return pos
+}
+
+func (fb *fbBuilder) str(s string) int {
+	pos := fb.vector(len(s), 1)
+	fb.b = append(fb.b, s...)
+	fb.b = append(fb.b, 0)
+
+	return pos
+}
+
+func (fb *fbBuilder) bytes(v []byte) int {
+	pos := fb.vector(len(v), 1)
+	fb.b = append(fb.b, v...)
+
+	return pos
+}
+
+func (fb *fbBuilder) doubles(v []float64) int {
+	pos := fb.vector(len(v), 8)
+	for _, f := range v {
+		fb.put(8, math.Float64bits(f))
+	}
+
+	return pos
+}
+
+func (fb *fbBuilder) uint32s(v []uint32) int {
+	pos := fb.vector(len(v), 4)
+	for _, u := range v {
+		fb.put(4, uint64(u))
+	}
+
+	return pos
+}
+
+// tables writes a vector of tables.
+func (fb *fbBuilder) tables(tables [][]*fbField) int {
+	pos := fb.vector(len(tables), 4)
+	for range tables {
+		fb.put(4, 0)
+	}
+
+	for i, fields := range tables {
+		fb.patch(pos+4+4*i, fb.table(fields))
+	}
+
+	return pos
+}
@@ -0,0 +1,622 @@
+package gokml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var fgbMagic = []byte{'f', 'g', 'b', 3, 'f', 'g', 'b', 0}
+
+// FlatGeobuf geometry types
+const (
+	fgbUnknown            = 0
+	fgbPoint              = 1
+	fgbLineString         = 2
+	fgbPolygon            = 3
+	fgbMultiPoint         = 4
+	fgbMultiLineString    = 5
+	fgbMultiPolygon       = 6
+	fgbGeometryCollection = 7
+)
+
+// FlatGeobuf column types
+const (
+	fgbByte = iota
+	fgbUByte
+	fgbBool
+	fgbShort
+	fgbUShort
+	fgbInt
+	fgbUInt
+	fgbLong
+	fgbULong
+	fgbFloat
+	fgbDouble
+	fgbString
+	fgbJSON
+	fgbDateTime
+	fgbBinary
+)
+
+// ReadFlatGeobuf converts a FlatGeobuf file into a KML document, with a
+// Placemark for each feature.  The name and description columns (matched
+// without regard to case) become the Placemark's name and description, and
+// the other columns become ExtendedData.  Multi-part geometries and
+// collections become MultiGeometries.  Coordinates must be longitude and
+// latitude; positions out of range are dropped.  The spatial index, if the
+// file has one, is skipped.  The bytes read are reported to progress, if it
+// isn't nil.
+func ReadFlatGeobuf(r io.Reader, progress Progress) (*KML, error) {
+	var k *KML
+
+	err := readFlatGeobuf(readProgress(r, progress), func(name string) error {
+		k = NewKML(name)
+		return nil
+	}, func(pm *Placemark) error {
+		k.AddFeature(pm)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// StreamFlatGeobuf is like ReadFlatGeobuf, but writes the features to sw as
+// they're read, in a Folder named for the dataset, so files larger than
+// memory can be converted in a single pass.
+func StreamFlatGeobuf(sw *StreamWriter, r io.Reader, progress Progress) error {
+	err := readFlatGeobuf(readProgress(r, progress), func(name string) error {
+		return sw.StartFolder(NewFolder(name, ""))
+	}, func(pm *Placemark) error {
+		return sw.AddFeature(pm)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sw.EndFolder()
+}
+
+// readFlatGeobuf reads a FlatGeobuf file, passing the name of the dataset
+// to begin, and then each feature as a Placemark to add.
+func readFlatGeobuf(r io.Reader, begin func(name string) error, add func(*Placemark) error) error {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(fgbMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic[:3], fgbMagic[:3]) || magic[3] != 3 {
+		return fmt.Errorf("gokml: not a FlatGeobuf file")
+	}
+
+	buf, err := readSizePrefixed(br)
+	if err != nil {
+		return fmt.Errorf("gokml: invalid FlatGeobuf header: %v", err)
+	}
+
+	h := &fbReader{b: buf}
+	header := h.root()
+	geomType := int(h.u8(h.field(header, 2)))
+	hasZ := h.u8(h.field(header, 3)) != 0
+	count := h.u64(h.field(header, 8))
+	nodeSize := 16
+	if off := h.field(header, 9); off != 0 {
+		nodeSize = int(h.u16(off))
+	}
+
+	var columns []fgbColumn
+	for _, c := range h.tables(header, 7) {
+		columns = append(columns, fgbColumn{h.str(c, 0), int(h.u8(h.field(c, 1)))})
+	}
+
+	name := h.str(header, 0)
+	if h.err != nil {
+		return fmt.Errorf("gokml: invalid FlatGeobuf header: %v", h.err)
+	}
+
+	if err := begin(name); err != nil {
+		return err
+	}
+
+	if nodeSize > 0 && count > 0 {
+		size := fgbIndexSize(count, nodeSize)
+		if n, err := io.CopyN(io.Discard, br, size); err != nil {
+			return fmt.Errorf("gokml: FlatGeobuf index truncated after %d bytes", n)
+		}
+	}
+
+	for i := 0; ; i++ {
+		buf, err := readSizePrefixed(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("gokml: invalid FlatGeobuf feature %d: %v", i, err)
+		}
+
+		pm, err := fgbFeature(&fbReader{b: buf}, geomType, hasZ, columns)
+		if err != nil {
+			return fmt.Errorf("gokml: invalid FlatGeobuf feature %d: %v", i, err)
+		}
+		if err := add(pm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type fgbColumn struct {
+	name string
+	kind int
+}
+
+// readSizePrefixed reads a buffer preceded by its 32-bit size.  It returns
+// io.EOF if there are no more buffers.
+func readSizePrefixed(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated size")
+		}
+		return nil, err
+	}
+
+	n := int64(binary.LittleEndian.Uint32(size[:]))
+	buf, err := io.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return buf, nil
+}
+
+// fgbIndexSize returns the size in bytes of the packed Hilbert R-tree of a
+// file with count features.
+func fgbIndexSize(count uint64, nodeSize int) int64 {
+	if nodeSize < 2 {
+		nodeSize = 2
+	}
+
+	n := count
+	nodes := n
+	for n != 1 {
+		n = (n + uint64(nodeSize) - 1) / uint64(nodeSize)
+		nodes += n
+	}
+
+	return int64(nodes) * 40
+}
+
+func fgbFeature(r *fbReader, geomType int, hasZ bool, columns []fgbColumn) (*Placemark, error) {
+	feature := r.root()
+
+	var geom renderable
+	if g := r.ref(feature, 0); g != 0 {
+		var err error
+		if geom, err = fgbGeometry(r, g, geomType, hasZ); err != nil {
+			return nil, err
+		}
+	}
+
+	pm := NewPlacemark("", "", geom)
+
+	pos, n := r.vector(feature, 1, 1)
+	props := r.b[pos : pos+n]
+	for len(props) > 0 && r.err == nil {
+		if len(props) < 2 {
+			return nil, fmt.Errorf("truncated properties")
+		}
+
+		i := int(binary.LittleEndian.Uint16(props))
+		if i >= len(columns) {
+			return nil, fmt.Errorf("property of unknown column %d", i)
+		}
+
+		value, size, err := fgbValue(props[2:], columns[i].kind)
+		if err != nil {
+			return nil, err
+		}
+		props = props[2+size:]
+
+		switch strings.ToLower(columns[i].name) {
+		case "name":
+			pm.name = value
+		case "description":
+			pm.description, pm.html = value, hasMarkup(value)
+		default:
+			pm.SetData(columns[i].name, value)
+		}
+	}
+
+	return pm, r.err
+}
+
+// fgbValue decodes a property value of a column type, returning it as text
+// and the number of bytes it used.
+func fgbValue(b []byte, kind int) (string, int, error) {
+	sizes := map[int]int{fgbByte: 1, fgbUByte: 1, fgbBool: 1, fgbShort: 2, fgbUShort: 2, fgbInt: 4, fgbUInt: 4, fgbLong: 8, fgbULong: 8, fgbFloat: 4, fgbDouble: 8}
+
+	size, fixed := sizes[kind]
+	if !fixed {
+		if kind > fgbBinary {
+			return "", 0, fmt.Errorf("unknown column type %d", kind)
+		}
+		if len(b) < 4 {
+			return "", 0, fmt.Errorf("truncated property")
+		}
+		size = int(binary.LittleEndian.Uint32(b))
+		b = b[4:]
+	}
+
+	if len(b) < size {
+		return "", 0, fmt.Errorf("truncated property")
+	}
+
+	var v uint64
+	if fixed {
+		var buf [8]byte
+		copy(buf[:], b[:size])
+		v = binary.LittleEndian.Uint64(buf[:])
+	}
+
+	var s string
+	switch kind {
+	case fgbByte:
+		s = strconv.Itoa(int(int8(v)))
+	case fgbShort:
+		s = strconv.Itoa(int(int16(v)))
+	case fgbInt:
+		s = strconv.Itoa(int(int32(v)))
+	case fgbLong:
+		s = strconv.FormatInt(int64(v), 10)
+	case fgbUByte, fgbUShort, fgbUInt, fgbULong:
+		s = strconv.FormatUint(v, 10)
+	case fgbBool:
+		s = strconv.FormatBool(v != 0)
+	case fgbFloat:
+		s = strconv.FormatFloat(float64(math.Float32frombits(uint32(v))), 'g', -1, 32)
+	case fgbDouble:
+		s = strconv.FormatFloat(math.Float64frombits(v), 'g', -1, 64)
+	case fgbBinary:
+		s = base64.StdEncoding.EncodeToString(b[:size])
+	default:
+		s = string(b[:size])
+	}
+
+	if !fixed {
+		size += 4
+	}
+
+	return s, size, nil
+}
+
+// fgbGeometry decodes the Geometry table at pos.  geomType is the type from
+// the header, which is used unless it is unknown.
+func fgbGeometry(r *fbReader, pos int, geomType int, hasZ bool) (renderable, error) {
+	if geomType == fgbUnknown {
+		geomType = int(r.u8(r.field(pos, 6)))
+	}
+
+	xy := r.doubles(pos, 1)
+	z := r.doubles(pos, 2)
+	ends := r.uint32s(pos, 0)
+
+	points := make([]*Point, 0, len(xy)/2)
+	for i := 0; i+1 < len(xy); i += 2 {
+		alt := 0.0
+		if hasZ && i/2 < len(z) {
+			alt = z[i/2]
+		}
+		points = append(points, NewPoint(xy[i+1], xy[i], alt))
+	}
+
+	// parts splits the points at the ends
+	parts := func() [][]*Point {
+		if len(ends) == 0 {
+			return [][]*Point{points}
+		}
+
+		ret := make([][]*Point, 0, len(ends))
+		start := 0
+		for _, end := range ends {
+			if int(end) < start || int(end) > len(points) {
+				return nil
+			}
+			ret = append(ret, points[start:end])
+			start = int(end)
+		}
+		return ret
+	}
+
+	switch geomType {
+	case fgbPoint:
+		if len(points) == 0 || points[0] == nil {
+			return nil, r.err
+		}
+		return points[0], r.err
+	case fgbMultiPoint:
+		mg := NewMultiGeometry()
+		for _, p := range points {
+			if p != nil {
+				mg.AddGeometry(p)
+			}
+		}
+		return mg, r.err
+	case fgbLineString:
+		return lineStringFrom(points), r.err
+	case fgbMultiLineString:
+		mg := NewMultiGeometry()
+		for _, line := range parts() {
+			mg.AddGeometry(lineStringFrom(line))
+		}
+		return mg, r.err
+	case fgbPolygon:
+		return polygonFrom(parts()), r.err
+	case fgbMultiPolygon, fgbGeometryCollection:
+		mg := NewMultiGeometry()
+		for _, part := range r.tables(pos, 7) {
+			partType := fgbPolygon
+			if geomType == fgbGeometryCollection {
+				partType = fgbUnknown
+			}
+			geom, err := fgbGeometry(r, part, partType, hasZ)
+			if err != nil {
+				return nil, err
+			}
+			mg.AddGeometry(geom)
+		}
+		return mg, r.err
+	}
+
+	return nil, fmt.Errorf("unsupported geometry type %d", geomType)
+}
+
+func lineStringFrom(points []*Point) *LineString {
+	ls := NewLineString()
+	for _, p := range points {
+		ls.AddPoint(p)
+	}
+
+	return ls
+}
+
+// polygonFrom returns a Polygon with the first ring as its outer boundary
+// and the others as holes.
+func polygonFrom(rings [][]*Point) *Polygon {
+	poly := NewPolygon()
+	for i, ring := range rings {
+		if i == 0 {
+			for _, p := range ring {
+				poly.AddPoint(p)
+			}
+		} else {
+			poly.AddInnerBoundary(ring...)
+		}
+	}
+
+	return poly
+}
+
+// WriteFlatGeobuf writes the Placemarks of the document to w as a
+// FlatGeobuf file, without a spatial index, in WGS 84 (EPSG:4326).  Each
+// Placemark becomes a feature, with its name, description, and ExtendedData
+// as string columns.  Tracks are written as LineStrings, without their
+// times.  Placemarks without a geometry are written without one.
+func (k *KML) WriteFlatGeobuf(w io.Writer) error {
+	var placemarks []*Placemark
+	walkPlacemarks(k.rootFolder, func(pm *Placemark) { placemarks = append(placemarks, pm) })
+
+	// columns are the name, description, and every ExtendedData name, in
+	// the order they are first seen
+	columns := []string{"name", "description"}
+	index := map[string]int{"name": 0, "description": 1}
+	geomType := -1
+	hasZ := false
+
+	for _, pm := range placemarks {
+		for _, d := range pm.data {
+			if _, ok := index[d.name]; !ok {
+				index[d.name] = len(columns)
+				columns = append(columns, d.name)
+			}
+		}
+
+		if t := fgbType(pm.geometry); geomType < 0 {
+			geomType = t
+		} else if t != geomType {
+			geomType = fgbUnknown
+		}
+
+		walkPoints(pm.geometry, func(p *Point) { hasZ = hasZ || p.Alt != 0 })
+	}
+
+	if geomType < 0 {
+		geomType = fgbUnknown
+	}
+
+	columnTables := make([][]*fbField, len(columns))
+	for i, name := range columns {
+		name := name
+		columnTables[i] = []*fbField{
+			fbRef(func(fb *fbBuilder) int { return fb.str(name) }),
+			fbScalar(1, fgbString),
+		}
+	}
+
+	name := k.rootFolder.name
+	header := buildFlatBuffer([]*fbField{
+		fbRef(func(fb *fbBuilder) int { return fb.str(name) }),
+		nil, // envelope
+		fbScalar(1, uint64(geomType)),
+		fbScalar(1, fgbBool01(hasZ)),
+		nil, nil, nil, // has_m, has_t, has_tm
+		fbRef(func(fb *fbBuilder) int { return fb.tables(columnTables) }),
+		fbScalar(8, uint64(len(placemarks))),
+		fbScalar(2, 0), // index_node_size: no index
+		fbRef(func(fb *fbBuilder) int {
+			return fb.table([]*fbField{
+				fbRef(func(fb *fbBuilder) int { return fb.str("EPSG") }),
+				fbScalar(4, 4326),
+			})
+		}),
+	})
+
+	bw := bufio.NewWriter(w)
+	bw.Write(fgbMagic)
+	writeSizePrefixed(bw, header)
+
+	for _, pm := range placemarks {
+		var props []byte
+		addProp := func(column int, value string) {
+			if len(value) == 0 {
+				return
+			}
+			props = binary.LittleEndian.AppendUint16(props, uint16(column))
+			props = binary.LittleEndian.AppendUint32(props, uint32(len(value)))
+			props = append(props, value...)
+		}
+
+		addProp(0, pm.name)
+		addProp(1, pm.description)
+		for _, d := range pm.data {
+			addProp(index[d.name], d.value)
+		}
+
+		var geom *fbField
+		if fields := fgbGeometryFields(pm.geometry, hasZ, geomType == fgbUnknown); fields != nil {
+			geom = fbRef(func(fb *fbBuilder) int { return fb.table(fields) })
+		}
+
+		writeSizePrefixed(bw, buildFlatBuffer([]*fbField{
+			geom,
+			fbRef(func(fb *fbBuilder) int { return fb.bytes(props) }),
+		}))
+	}
+
+	return bw.Flush()
+}
+
+func writeSizePrefixed(w *bufio.Writer, buf []byte) {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(buf)))
+	w.Write(size[:])
+	w.Write(buf)
+}
+
+func fgbBool01(v bool) uint64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// fgbType returns the FlatGeobuf type of a geometry.
+func fgbType(geom renderable) int {
+	switch g := geom.(type) {
+	case *Point:
+		return fgbPoint
+	case *LineString, *Track:
+		return fgbLineString
+	case *Polygon:
+		return fgbPolygon
+	case *MultiGeometry:
+		switch wktMultiKind(g.geometries) {
+		case "MULTIPOINT":
+			return fgbMultiPoint
+		case "MULTILINESTRING":
+			return fgbMultiLineString
+		case "MULTIPOLYGON":
+			return fgbMultiPolygon
+		}
+		return fgbGeometryCollection
+	}
+
+	return fgbUnknown
+}
+
+// fgbGeometryFields returns the fields of the Geometry table for geom, or
+// nil if it has no geometry.  typed is true if the type must be included.
+func fgbGeometryFields(geom renderable, hasZ bool, typed bool) []*fbField {
+	t := fgbType(geom)
+	if t == fgbUnknown {
+		return nil
+	}
+
+	var rings [][]*Point
+	var parts [][]*fbField
+
+	switch g := geom.(type) {
+	case *Point:
+		rings = [][]*Point{{g}}
+	case *LineString:
+		rings = [][]*Point{g.coordinates}
+	case *Track:
+		rings = [][]*Point{g.points}
+	case *Polygon:
+		rings = append([][]*Point{closeRing(g.points)}, g.inner...)
+	case *MultiGeometry:
+		for _, part := range g.geometries {
+			switch p := part.(type) {
+			case *Point:
+				if t == fgbMultiPoint {
+					rings = append(rings, []*Point{p})
+					continue
+				}
+			case *LineString:
+				if t == fgbMultiLineString {
+					rings = append(rings, p.coordinates)
+					continue
+				}
+			case *Track:
+				if t == fgbMultiLineString {
+					rings = append(rings, p.points)
+					continue
+				}
+			}
+
+			if fields := fgbGeometryFields(part, hasZ, t == fgbGeometryCollection); fields != nil {
+				parts = append(parts, fields)
+			}
+		}
+	}
+
+	var xy, z []float64
+	var ends []uint32
+	for _, ring := range rings {
+		for _, p := range ring {
+			xy = append(xy, p.Lon, p.Lat)
+			z = append(z, p.Alt)
+		}
+		ends = append(ends, uint32(len(xy)/2))
+	}
+
+	fields := make([]*fbField, 8)
+	if len(ends) > 1 && t != fgbMultiPoint {
+		fields[0] = fbRef(func(fb *fbBuilder) int { return fb.uint32s(ends) })
+	}
+	if len(xy) > 0 {
+		fields[1] = fbRef(func(fb *fbBuilder) int { return fb.doubles(xy) })
+	}
+	if len(z) > 0 && hasZ {
+		fields[2] = fbRef(func(fb *fbBuilder) int { return fb.doubles(z) })
+	}
+	if typed {
+		fields[6] = fbScalar(1, uint64(t))
+	}
+	if len(parts) > 0 {
+		fields[7] = fbRef(func(fb *fbBuilder) int { return fb.tables(parts) })
+	}
+
+	return fields
+}
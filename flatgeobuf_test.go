@@ -0,0 +1,175 @@
+package gokml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func flatGeobufFixture() *KML {
+	k := NewKML("Parcels")
+
+	depot := NewPlacemark("Depot", "<b>Main</b>", NewPoint(40.0, -105.0, 1600.0))
+	depot.SetData("owner", "City")
+	k.AddFeature(depot)
+
+	f := NewFolder("Areas", "")
+	k.AddFeature(f)
+
+	poly := NewPolygon()
+	poly.AddPoint(NewPoint(0.0, 0.0, 0.0))
+	poly.AddPoint(NewPoint(0.0, 10.0, 0.0))
+	poly.AddPoint(NewPoint(10.0, 10.0, 0.0))
+	poly.AddInnerBoundary(NewPoint(1.0, 1.0, 0.0), NewPoint(1.0, 2.0, 0.0), NewPoint(2.0, 2.0, 0.0))
+	park := NewPlacemark("Park", "", poly)
+	park.SetData("zone", "R1")
+	f.AddFeature(park)
+
+	line1 := NewLineString()
+	line1.AddPoint(NewPoint(0.0, 0.0, 0.0))
+	line1.AddPoint(NewPoint(1.0, 1.0, 0.0))
+	line2 := NewLineString()
+	line2.AddPoint(NewPoint(2.0, 2.0, 0.0))
+	line2.AddPoint(NewPoint(3.0, 3.0, 0.0))
+	lines := NewMultiGeometry()
+	lines.AddGeometry(line1)
+	lines.AddGeometry(line2)
+	f.AddFeature(NewPlacemark("Trails", "", lines))
+
+	mixed := NewMultiGeometry()
+	mixed.AddGeometry(NewPoint(5.0, 5.0, 0.0))
+	mixed.AddGeometry(poly)
+	f.AddFeature(NewPlacemark("Mixed", "", mixed))
+
+	return k
+}
+
+func TestFlatGeobufRoundTrip(t *testing.T) {
+	k := flatGeobufFixture()
+
+	var buf bytes.Buffer
+	if err := k.WriteFlatGeobuf(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(buf.Bytes(), []byte("fgb\x03fgb\x00")) {
+		t.Errorf("missing magic bytes: %x", buf.Bytes()[:8])
+	}
+
+	again, err := ReadFlatGeobuf(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.rootFolder.name != "Parcels" || len(again.rootFolder.features) != 4 {
+		t.Fatalf("unexpected document %q with %d features", again.rootFolder.name, len(again.rootFolder.features))
+	}
+
+	for i, want := range []string{
+		"POINT Z (-105 40 1600)",
+		"POLYGON ((0 0, 10 0, 10 10, 0 0), (1 1, 2 1, 2 2, 1 1))",
+		"MULTILINESTRING ((0 0, 1 1), (2 2, 3 3))",
+		"GEOMETRYCOLLECTION (POINT (5 5), POLYGON ((0 0, 10 0, 10 10, 0 0), (1 1, 2 1, 2 2, 1 1)))",
+	} {
+		pm := again.rootFolder.features[i].(*Placemark)
+		if got, _ := FormatWKT(pm.geometry); got != want {
+			t.Errorf("feature %d geometry = %s, want %s", i, got, want)
+		}
+	}
+
+	depot := again.rootFolder.features[0].(*Placemark)
+	if owner, _ := depot.Data("owner"); depot.name != "Depot" || !depot.html || owner != "City" || len(depot.data) != 1 {
+		t.Errorf("unexpected depot %+v", depot)
+	}
+
+	park := again.rootFolder.features[1].(*Placemark)
+	if zone, _ := park.Data("zone"); zone != "R1" || len(park.data) != 1 {
+		t.Errorf("unexpected park data %v", park.data)
+	}
+}
+
+func TestFlatGeobufAlignment(t *testing.T) {
+	var buf bytes.Buffer
+	if err := flatGeobufFixture().WriteFlatGeobuf(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the features_count field of the header must be 8-byte aligned within
+	// the header buffer
+	b := buf.Bytes()[8:]
+	size := binary.LittleEndian.Uint32(b)
+	h := &fbReader{b: b[4 : 4+size]}
+	if off := h.field(h.root(), 8); off%8 != 0 || h.u64(off) != 4 {
+		t.Errorf("features_count at offset %d = %d", off, h.u64(off))
+	}
+}
+
+func TestReadFlatGeobufIndex(t *testing.T) {
+	var buf bytes.Buffer
+	if err := flatGeobufFixture().WriteFlatGeobuf(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the header to declare a spatial index, and insert one
+	b := buf.Bytes()
+	size := binary.LittleEndian.Uint32(b[8:])
+	header := b[12 : 12+size]
+	features := b[12+size:]
+
+	h := &fbReader{b: header}
+	binary.LittleEndian.PutUint16(header[h.field(h.root(), 9):], 16)
+
+	var file bytes.Buffer
+	file.Write(b[:12+size])
+	file.Write(make([]byte, fgbIndexSize(4, 16)))
+	file.Write(features)
+
+	k, err := ReadFlatGeobuf(&file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k.rootFolder.features) != 4 {
+		t.Errorf("got %d features after the index, want 4", len(k.rootFolder.features))
+	}
+
+	if got := fgbIndexSize(100, 16); got != (100+7+1)*40 {
+		t.Errorf("index size = %d", got)
+	}
+}
+
+func TestReadFlatGeobufErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := flatGeobufFixture().WriteFlatGeobuf(&buf); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.String()
+
+	for _, doc := range []string{
+		"",
+		"not a flatgeobuf file",
+		good[:12],
+		good[:len(good)-3],
+	} {
+		if _, err := ReadFlatGeobuf(strings.NewReader(doc), nil); err == nil {
+			t.Errorf("no error for %d bytes", len(doc))
+		}
+	}
+}
+
+func TestStreamFlatGeobuf(t *testing.T) {
+	var buf bytes.Buffer
+	if err := flatGeobufFixture().WriteFlatGeobuf(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := ReadFlatGeobuf(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := streamed(t, func(sw *StreamWriter) error {
+		return StreamFlatGeobuf(sw, bytes.NewReader(buf.Bytes()), nil)
+	})
+	samePlacemarks(t, got, k.Placemarks())
+}
@@ -0,0 +1,120 @@
+package gokml
+
+import "math"
+
+// earthRadius is the mean radius of the Earth in meters, for calculations on
+// a sphere.
+const earthRadius = 6371008.8
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+
+// destination returns the Point reached by traveling distance meters from p
+// along a great circle, starting on the bearing (in degrees clockwise from
+// north).  The altitude of p is kept.
+func destination(p *Point, bearing float64, distance float64) *Point {
+	lat1, lon1 := radians(p.Lat), radians(p.Lon)
+	theta, delta := radians(bearing), distance/earthRadius
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(delta) + math.Cos(lat1)*math.Sin(delta)*math.Cos(theta))
+	lon2 := lon1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(lat1), math.Cos(delta)-math.Sin(lat1)*math.Sin(lat2))
+
+	return &Point{degrees(lat2), normalizeLon(degrees(lon2)), p.Alt}
+}
+
+// initialBearing returns the bearing (in degrees clockwise from north, from 0
+// up to 360) at a of the great circle from a to b.
+func initialBearing(a *Point, b *Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLon := radians(b.Lon - a.Lon)
+
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+
+	return math.Mod(degrees(math.Atan2(y, x))+360, 360)
+}
+
+// greatCircleDistance returns the distance in meters between a and b along a
+// great circle, ignoring their altitudes.
+func greatCircleDistance(a *Point, b *Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLon := lat2-lat1, radians(b.Lon-a.Lon)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// intermediate returns the Point the fraction f of the way from a to b along
+// the great circle between them, with its altitude interpolated linearly.
+// a and b must not be antipodal.
+func intermediate(a *Point, b *Point, f float64) *Point {
+	delta := greatCircleDistance(a, b) / earthRadius
+	alt := a.Alt + (b.Alt-a.Alt)*f
+	if delta == 0 {
+		return &Point{a.Lat, a.Lon, alt}
+	}
+
+	lat1, lon1 := radians(a.Lat), radians(a.Lon)
+	lat2, lon2 := radians(b.Lat), radians(b.Lon)
+	wa, wb := math.Sin((1-f)*delta)/math.Sin(delta), math.Sin(f*delta)/math.Sin(delta)
+
+	x := wa*math.Cos(lat1)*math.Cos(lon1) + wb*math.Cos(lat2)*math.Cos(lon2)
+	y := wa*math.Cos(lat1)*math.Sin(lon1) + wb*math.Cos(lat2)*math.Sin(lon2)
+	z := wa*math.Sin(lat1) + wb*math.Sin(lat2)
+
+	return &Point{degrees(math.Atan2(z, math.Hypot(x, y))), degrees(math.Atan2(y, x)), alt}
+}
+
+// rhumbParts returns the change in latitude, in isometric latitude (the
+// Mercator y), and in longitude (the short way around) in radians from a to
+// b, for rhumb lines.
+func rhumbParts(a *Point, b *Point) (float64, float64, float64) {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dPsi := math.Log(math.Tan(math.Pi/4+lat2/2) / math.Tan(math.Pi/4+lat1/2))
+	dLon := radians(normalizeLon(b.Lon - a.Lon))
+
+	return dLat, dPsi, dLon
+}
+
+// rhumbDistance returns the distance in meters from a to b along the rhumb
+// line (loxodrome), the path of constant bearing, ignoring their altitudes.
+func rhumbDistance(a *Point, b *Point) float64 {
+	dLat, dPsi, dLon := rhumbParts(a, b)
+
+	// the stretch of the Mercator projection, or the cosine of the latitude
+	// along an east-west line
+	q := math.Cos(radians(a.Lat))
+	if math.Abs(dPsi) > 1e-12 {
+		q = dLat / dPsi
+	}
+
+	return math.Hypot(dLat, q*dLon) * earthRadius
+}
+
+// rhumbIntermediate returns the Point the fraction f of the way from a to b
+// along the rhumb line between them, with its altitude interpolated
+// linearly.  Neither Point may be a pole.
+func rhumbIntermediate(a *Point, b *Point, f float64) *Point {
+	dLat, dPsi, dLon := rhumbParts(a, b)
+
+	lat := radians(a.Lat) + f*dLat
+	lon := radians(a.Lon) + f*dLon
+	if math.Abs(dPsi) > 1e-12 {
+		psi := math.Log(math.Tan(math.Pi/4+lat/2) / math.Tan(math.Pi/4+radians(a.Lat)/2))
+		lon = radians(a.Lon) + dLon*psi/dPsi
+	}
+
+	return &Point{degrees(lat), normalizeLon(degrees(lon)), a.Alt + (b.Alt-a.Alt)*f}
+}
+
+// normalizeLon wraps a longitude into -180 to 180 degrees.
+func normalizeLon(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+
+	return lon - 180
+}
@@ -0,0 +1,64 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDestination(t *testing.T) {
+	start := NewPoint(40, -105, 10)
+
+	for _, bearing := range []float64{0, 45, 90, 180, 270} {
+		p := destination(start, bearing, 100000)
+		if d := greatCircleDistance(start, p); math.Abs(d-100000) > 1e-6 {
+			t.Errorf("bearing %v: distance %f", bearing, d)
+		}
+		if b := initialBearing(start, p); math.Abs(b-bearing) > 1e-9 {
+			t.Errorf("bearing %v: got bearing %f", bearing, b)
+		}
+		if p.Alt != 10 {
+			t.Errorf("altitude not kept: %v", p)
+		}
+	}
+
+	// across the antimeridian
+	if p := destination(NewPoint(0, 179.5, 0), 90, 111195); math.Abs(p.Lon+179.5) > 1e-3 {
+		t.Errorf("longitude not wrapped: %v", p)
+	}
+}
+
+func TestIntermediate(t *testing.T) {
+	a, b := NewPoint(51.47, -0.45, 0), NewPoint(40.64, -73.78, 1000)
+	total := greatCircleDistance(a, b)
+
+	for _, f := range []float64{0, 0.25, 0.5, 1} {
+		p := intermediate(a, b, f)
+		if d := greatCircleDistance(a, p); math.Abs(d-f*total) > 1e-3 {
+			t.Errorf("f %v: %f m from the start, want %f", f, d, f*total)
+		}
+		if d := greatCircleDistance(a, p) + greatCircleDistance(p, b); math.Abs(d-total) > 1e-3 {
+			t.Errorf("f %v: point %v is off the great circle", f, p)
+		}
+		if p.Alt != 1000*f {
+			t.Errorf("f %v: altitude %f", f, p.Alt)
+		}
+	}
+}
+
+func TestRhumb(t *testing.T) {
+	// Dover to Calais, a worked example of rhumb lines
+	a, b := NewPoint(51.127, 1.338, 0), NewPoint(50.964, 1.853, 0)
+	if d := rhumbDistance(a, b); math.Abs(d-40310) > 10 {
+		t.Errorf("distance %f, want 40310", d)
+	}
+
+	// along a parallel, the distance is that of the parallel
+	if d := rhumbDistance(NewPoint(60, 0, 0), NewPoint(60, 10, 0)); math.Abs(d-earthRadius*radians(10)/2) > 1e-6 {
+		t.Errorf("distance along a parallel %f", d)
+	}
+
+	// across the antimeridian, the short way
+	if p := rhumbIntermediate(NewPoint(10, 179, 0), NewPoint(20, -179, 0), 0.5); math.Abs(math.Abs(p.Lon)-180) > 0.1 {
+		t.Errorf("midpoint %v isn't near the antimeridian", p)
+	}
+}
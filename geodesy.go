@@ -0,0 +1,101 @@
+package gokml
+
+import "math"
+
+// ellipsoid is a reference ellipsoid for a datum, by its semi-major axis in
+// meters and its flattening.
+type ellipsoid struct {
+	a float64
+	f float64
+}
+
+// wgs84 is the ellipsoid of WGS84, the datum of KML coordinates.
+var wgs84 = ellipsoid{6378137, 1 / 298.257223563}
+
+// Distance returns the distance in meters from p to q along a great circle
+// (the haversine formula), on a sphere the mean size of the Earth, ignoring
+// altitude.  It's within about 0.5% of the distance on the ellipsoid; see
+// EllipsoidDistance.
+func (p *Point) Distance(q *Point) float64 {
+	return greatCircleDistance(p, q)
+}
+
+// EllipsoidDistance returns the distance in meters from p to q along the
+// shortest path on the WGS84 ellipsoid (Vincenty's inverse formula), which
+// is accurate to within a millimeter, ignoring altitude.  It returns false
+// if the formula doesn't converge, as for some nearly antipodal Points.
+func (p *Point) EllipsoidDistance(q *Point) (float64, bool) {
+	return wgs84.inverse(p, q)
+}
+
+// InitialBearing returns the bearing, in degrees clockwise from north from
+// 0 up to 360, to set out on from p to reach q along a great circle.
+func (p *Point) InitialBearing(q *Point) float64 {
+	return initialBearing(p, q)
+}
+
+// FinalBearing returns the bearing, in degrees clockwise from north from 0
+// up to 360, on arriving at q from p along a great circle.  It differs from
+// the initial bearing except along a meridian or the equator.
+func (p *Point) FinalBearing(q *Point) float64 {
+	return math.Mod(initialBearing(q, p)+180, 360)
+}
+
+// Destination returns the Point reached by traveling a distance in meters
+// from p along a great circle, setting out on a bearing in degrees
+// clockwise from north.  The Point has the altitude of p.
+func (p *Point) Destination(bearing float64, distance float64) *Point {
+	return destination(p, bearing, distance)
+}
+
+// inverse returns the distance in meters from p to q on the ellipsoid, by
+// Vincenty's inverse formula, or false if it doesn't converge.
+func (e ellipsoid) inverse(p *Point, q *Point) (float64, bool) {
+	b := e.a * (1 - e.f)
+	l := radians(normalizeLon(q.Lon - p.Lon))
+
+	// reduced latitudes
+	u1 := math.Atan((1 - e.f) * math.Tan(radians(p.Lat)))
+	u2 := math.Atan((1 - e.f) * math.Tan(radians(q.Lat)))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	lambda := l
+	var sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma = math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return 0, true // the same Point
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0.0 // along the equator
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+
+		c := e.f / 16 * cosSqAlpha * (4 + e.f*(4-3*cosSqAlpha))
+		previous := lambda
+		lambda = l + (1-c)*e.f*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-previous) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return 0, false
+	}
+
+	uSq := cosSqAlpha * (e.a*e.a - b*b) / (b * b)
+	bigA := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	bigB := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := bigB * sinSigma * (cos2SigmaM + bigB/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		bigB/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+
+	return b * bigA * (sigma - deltaSigma), true
+}
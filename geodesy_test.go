@@ -0,0 +1,67 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+// testDMS returns degrees, minutes, and seconds as degrees.
+func testDMS(d, m, s float64) float64 {
+	if d < 0 {
+		return d - m/60 - s/3600
+	}
+	return d + m/60 + s/3600
+}
+
+func TestEllipsoidDistance(t *testing.T) {
+	// Vincenty's own example, from Flinders Peak to Buninyong
+	flinders := NewPoint(testDMS(-37, 57, 3.72030), testDMS(144, 25, 29.52440), 0)
+	buninyong := NewPoint(testDMS(-37, 39, 10.15610), testDMS(143, 55, 35.38390), 0)
+
+	d, ok := flinders.EllipsoidDistance(buninyong)
+	if !ok || math.Abs(d-54972.271) > 0.001 {
+		t.Errorf("distance = %.4f, %v, want 54972.271", d, ok)
+	}
+	if d, ok := buninyong.EllipsoidDistance(buninyong); !ok || d != 0 {
+		t.Errorf("distance to itself = %v, %v", d, ok)
+	}
+
+	// along the equator, a quarter of the way around
+	if d, ok := NewPoint(0, 0, 0).EllipsoidDistance(NewPoint(0, 90, 0)); !ok || math.Abs(d-wgs84.a*math.Pi/2) > 0.001 {
+		t.Errorf("equatorial distance = %v, %v", d, ok)
+	}
+
+	// the haversine distance is close
+	if h := flinders.Distance(buninyong); math.Abs(h-d)/d > 0.005 {
+		t.Errorf("haversine distance = %v", h)
+	}
+
+	if _, ok := NewPoint(0, 0, 0).EllipsoidDistance(NewPoint(0.5, 179.7, 0)); ok {
+		t.Errorf("nearly antipodal points converged")
+	}
+}
+
+func TestBearings(t *testing.T) {
+	a, b := NewPoint(0, 0, 0), NewPoint(0, 90, 0)
+	if got := a.InitialBearing(b); math.Abs(got-90) > 1e-9 {
+		t.Errorf("initial bearing along the equator = %v", got)
+	}
+	if got := a.FinalBearing(b); math.Abs(got-90) > 1e-9 {
+		t.Errorf("final bearing along the equator = %v", got)
+	}
+
+	// setting out north-east from the equator, arriving further east
+	c := NewPoint(45, 90, 0)
+	if got := a.InitialBearing(c); math.Abs(got-45) > 1e-9 {
+		t.Errorf("initial bearing = %v, want 45", got)
+	}
+	if got := a.FinalBearing(c); math.Abs(got-90) > 1e-9 {
+		t.Errorf("final bearing = %v, want 90", got)
+	}
+
+	p := NewPoint(51.5, -0.1, 30)
+	q := p.Destination(120, 100000)
+	if math.Abs(p.Distance(q)-100000) > 1e-6 || math.Abs(p.InitialBearing(q)-120) > 1e-9 || q.Alt != 30 {
+		t.Errorf("unexpected destination %v", q)
+	}
+}
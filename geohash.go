@@ -0,0 +1,115 @@
+package gokml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// geohashAlphabet are the base 32 digits of geohashes.
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash returns the geohash of the cell holding the Point, with from 1 to
+// 12 characters, for cells from about 5000 km down to 4 cm across.  It
+// returns false for other precisions.
+func (p *Point) Geohash(precision int) (string, bool) {
+	if precision < 1 || precision > 12 {
+		return "", false
+	}
+
+	lat, lon := [2]float64{-90, 90}, [2]float64{-180, 180}
+	value := [2]float64{p.Lat, normalizeLon(p.Lon)}
+
+	// the bits interleave longitude and latitude, halving each in turn
+	var hash strings.Builder
+	bits, digit := 0, 0
+	for hash.Len() < precision {
+		span, v := &lon, value[1]
+		if bits%2 == 1 {
+			span, v = &lat, value[0]
+		}
+
+		mid := (span[0] + span[1]) / 2
+		digit <<= 1
+		if v >= mid {
+			digit |= 1
+			span[0] = mid
+		} else {
+			span[1] = mid
+		}
+
+		if bits++; bits%5 == 0 {
+			hash.WriteByte(geohashAlphabet[digit])
+			digit = 0
+		}
+	}
+
+	return hash.String(), true
+}
+
+// GeohashBounds returns the bounding rectangle of the cell of a geohash.
+// It returns an error if the geohash is empty or has characters other than
+// its base 32 digits, in either case.
+func GeohashBounds(hash string) (Bounds, error) {
+	if hash == "" {
+		return Bounds{}, fmt.Errorf("gokml: empty geohash")
+	}
+
+	b := Bounds{90, -90, 180, -180}
+	even := true // the first bit is longitude
+	for _, c := range strings.ToLower(hash) {
+		digit := strings.IndexRune(geohashAlphabet, c)
+		if digit < 0 {
+			return Bounds{}, fmt.Errorf("gokml: invalid geohash %q", hash)
+		}
+
+		for bit := 4; bit >= 0; bit-- {
+			set := digit>>uint(bit)&1 == 1
+			if even {
+				mid := (b.West + b.East) / 2
+				if set {
+					b.West = mid
+				} else {
+					b.East = mid
+				}
+			} else {
+				mid := (b.South + b.North) / 2
+				if set {
+					b.South = mid
+				} else {
+					b.North = mid
+				}
+			}
+			even = !even
+		}
+	}
+
+	return b, nil
+}
+
+// ParseGeohash returns the Point at the center of the cell of a geohash.
+func ParseGeohash(hash string) (*Point, error) {
+	b, err := GeohashBounds(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPoint((b.North+b.South)/2, (b.East+b.West)/2, 0), nil
+}
+
+// GeohashPolygon returns the boundary of the cell of a geohash, as a
+// counterclockwise Polygon from its south-west corner, such as to draw
+// geohash-bucketed data as a grid of cells.
+func GeohashPolygon(hash string) (*Polygon, error) {
+	b, err := GeohashBounds(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	poly := NewPolygon()
+	poly.AddPoint(NewPoint(b.South, b.West, 0))
+	poly.AddPoint(NewPoint(b.South, b.East, 0))
+	poly.AddPoint(NewPoint(b.North, b.East, 0))
+	poly.AddPoint(NewPoint(b.North, b.West, 0))
+
+	return poly, nil
+}
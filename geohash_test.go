@@ -0,0 +1,58 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPointGeohash(t *testing.T) {
+	p := NewPoint(57.64911, 10.40744, 0)
+	if got, ok := p.Geohash(11); !ok || got != "u4pruydqqvj" {
+		t.Errorf("Geohash(11) = %q, %v", got, ok)
+	}
+	if got, _ := p.Geohash(1); got != "u" {
+		t.Errorf("Geohash(1) = %q", got)
+	}
+	if got, _ := NewPoint(-25.382708, -49.265506, 0).Geohash(8); got != "6gkzwgjz" {
+		t.Errorf("southern geohash = %q", got)
+	}
+	if _, ok := p.Geohash(13); ok {
+		t.Errorf("precision 13 accepted")
+	}
+}
+
+func TestGeohashCells(t *testing.T) {
+	b, err := GeohashBounds("ezs42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Bounds{42.626953125, 42.5830078125, -5.5810546875, -5.625}
+	if b != want {
+		t.Errorf("GeohashBounds = %v, want %v", b, want)
+	}
+
+	center, err := ParseGeohash("EZS42")
+	if err != nil || math.Abs(center.Lat-42.60498046875) > 1e-12 || math.Abs(center.Lon+5.60302734375) > 1e-12 {
+		t.Errorf("ParseGeohash = %v, %v", center, err)
+	}
+	if got, _ := center.Geohash(5); got != "ezs42" {
+		t.Errorf("center is in %q", got)
+	}
+
+	poly, err := GeohashPolygon("ezs42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(poly.points) != 4 || ringArea(poly.points) <= 0 {
+		t.Errorf("unexpected cell %v", poly.points)
+	}
+	if pb, _ := poly.Bounds(); pb != want {
+		t.Errorf("cell bounds = %v", pb)
+	}
+
+	for _, hash := range []string{"", "ezs4a", "ez s"} {
+		if _, err := GeohashPolygon(hash); err == nil {
+			t.Errorf("GeohashPolygon(%q) succeeded", hash)
+		}
+	}
+}
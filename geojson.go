@@ -0,0 +1,403 @@
+package gokml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// GeoJSONMapping describes how the properties of GeoJSON features become
+// Placemark fields in FromGeoJSON.  Properties that aren't mapped become
+// ExtendedData.  Empty property names aren't mapped.
+type GeoJSONMapping struct {
+	Name        string // property for the Placemark name
+	Description string // property for the description; markup is treated as HTML
+	Style       string // property holding the name of a Style for the Placemark
+	Folder      string // property whose value groups Placemarks into Folders
+
+	Check    *CoordinateCheck // reports suspect positions, if not nil
+	Progress Progress         // receives the bytes read, if not nil
+}
+
+// DefaultGeoJSONMapping maps the name and description properties.
+var DefaultGeoJSONMapping = GeoJSONMapping{"name", "description", "", "", nil, nil}
+
+// geoJSONObject is any GeoJSON object: a FeatureCollection, Feature, or
+// geometry.
+type geoJSONObject struct {
+	Type        string                     `json:"type"`
+	Name        json.RawMessage            `json:"name"` // not in the standard, but common
+	Features    []*geoJSONObject           `json:"features"`
+	ID          json.RawMessage            `json:"id"`
+	Properties  map[string]json.RawMessage `json:"properties"`
+	Geometry    *geoJSONObject             `json:"geometry"`
+	Geometries  []*geoJSONObject           `json:"geometries"`
+	Coordinates json.RawMessage            `json:"coordinates"`
+}
+
+// FromGeoJSON converts a GeoJSON FeatureCollection, Feature, or geometry
+// (RFC 7946) into a KML document.  Each Feature becomes a Placemark, with
+// its properties mapped by mapping, and its id as the Placemark id (or as
+// ExtendedData if it isn't a valid XML name).  Multi-part geometries and
+// GeometryCollections become MultiGeometries, and the holes of Polygons
+// become inner boundaries.  Positions outside the valid latitude and
+// longitude ranges are dropped.  Suspect positions, dropped ones included,
+// are reported to the Suspect function of mapping.Check, if there is one,
+// with the index of their feature in the collection (0 for a lone Feature
+// or geometry).  Styles named by the Style property must be
+// added to the document separately.
+func FromGeoJSON(r io.Reader, mapping GeoJSONMapping) (*KML, error) {
+	var obj geoJSONObject
+	if err := json.NewDecoder(readProgress(r, mapping.Progress)).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("gokml: invalid GeoJSON: %v", err)
+	}
+
+	name, _ := geoJSONText(obj.Name)
+	k := NewKML(name)
+	folders := make(map[string]*Folder)
+
+	err := readGeoJSON(&obj, mapping, func(pm *Placemark, group string, grouped bool) error {
+		if !grouped {
+			k.AddFeature(pm)
+			return nil
+		}
+
+		folder := folders[group]
+		if folder == nil {
+			folder = NewFolder(group, "")
+			folders[group] = folder
+			k.AddFeature(folder)
+		}
+		folder.AddFeature(pm)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// StreamGeoJSON is like FromGeoJSON, but writes the features to sw as
+// they're read, decoding the features of a FeatureCollection one at a time,
+// so files larger than memory can be converted in a single pass.  Features
+// grouped by the Folder property are written with AddToFolder, so they
+// follow the ungrouped ones, and are moved to temporary files beyond the
+// StreamWriter's memory budget.  The name of the collection is ignored,
+// since the document has already been started.
+func StreamGeoJSON(sw *StreamWriter, r io.Reader, mapping GeoJSONMapping) error {
+	add := func(pm *Placemark, group string, grouped bool) error {
+		if grouped {
+			return sw.AddToFolder(group, pm)
+		}
+		return sw.AddFeature(pm)
+	}
+
+	d := json.NewDecoder(readProgress(r, mapping.Progress))
+	if tok, err := d.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("gokml: invalid GeoJSON: not an object")
+	}
+
+	// the members other than features, to decode once they're all read
+	members := make(map[string]json.RawMessage)
+	hasFeatures := false
+
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("gokml: invalid GeoJSON: %v", err)
+		}
+
+		key, _ := tok.(string)
+		if !strings.EqualFold(key, "features") {
+			var raw json.RawMessage
+			if err := d.Decode(&raw); err != nil {
+				return fmt.Errorf("gokml: invalid GeoJSON: %v", err)
+			}
+			members[key] = raw
+			continue
+		}
+
+		if tok, err := d.Token(); err != nil || tok != json.Delim('[') {
+			return fmt.Errorf("gokml: invalid GeoJSON: features is not an array")
+		}
+
+		for i := 0; d.More(); i++ {
+			var f *geoJSONObject
+			if err := d.Decode(&f); err != nil {
+				return fmt.Errorf("gokml: invalid GeoJSON: %v", err)
+			}
+			if err := geoJSONFeature(i, f, mapping, add); err != nil {
+				return err
+			}
+		}
+
+		if _, err := d.Token(); err != nil {
+			return fmt.Errorf("gokml: invalid GeoJSON: %v", err)
+		}
+		hasFeatures = true
+	}
+
+	if _, err := d.Token(); err != nil {
+		return fmt.Errorf("gokml: invalid GeoJSON: %v", err)
+	}
+
+	if hasFeatures {
+		return nil
+	}
+
+	// a lone Feature or geometry, which isn't large
+	b, _ := json.Marshal(members)
+	var obj geoJSONObject
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return fmt.Errorf("gokml: invalid GeoJSON: %v", err)
+	}
+
+	return readGeoJSON(&obj, mapping, add)
+}
+
+// geoJSONAdd receives a Placemark converted from GeoJSON, with the value of
+// its Folder property if it has one.
+type geoJSONAdd func(pm *Placemark, group string, grouped bool) error
+
+// readGeoJSON converts a FeatureCollection, Feature, or geometry, passing
+// each Placemark to add.
+func readGeoJSON(obj *geoJSONObject, mapping GeoJSONMapping, add geoJSONAdd) error {
+	switch obj.Type {
+	case "FeatureCollection":
+		for i, f := range obj.Features {
+			if err := geoJSONFeature(i, f, mapping, add); err != nil {
+				return err
+			}
+		}
+	case "Feature":
+		pm, err := geoJSONPlacemark(obj, mapping, 0)
+		if err != nil {
+			return fmt.Errorf("gokml: GeoJSON feature: %v", err)
+		}
+		return add(pm, "", false)
+	default:
+		geom, err := geoJSONGeometry(obj, mapping.Check.reporter(0))
+		if err != nil {
+			return fmt.Errorf("gokml: %v", err)
+		}
+		return add(NewPlacemark("", "", geom), "", false)
+	}
+
+	return nil
+}
+
+// geoJSONFeature converts feature i of a FeatureCollection, passing the
+// Placemark to add.
+func geoJSONFeature(i int, f *geoJSONObject, mapping GeoJSONMapping, add geoJSONAdd) error {
+	if f == nil || f.Type != "Feature" {
+		return fmt.Errorf("gokml: GeoJSON feature %d is not a Feature", i)
+	}
+
+	pm, err := geoJSONPlacemark(f, mapping, i)
+	if err != nil {
+		return fmt.Errorf("gokml: GeoJSON feature %d: %v", i, err)
+	}
+
+	group, ok := geoJSONText(f.Properties[mapping.Folder])
+	return add(pm, group, ok && len(mapping.Folder) > 0)
+}
+
+func geoJSONPlacemark(f *geoJSONObject, mapping GeoJSONMapping, row int) (*Placemark, error) {
+	var geom renderable
+	if f.Geometry != nil {
+		var err error
+		if geom, err = geoJSONGeometry(f.Geometry, mapping.Check.reporter(row)); err != nil {
+			return nil, err
+		}
+	}
+
+	pm := NewPlacemark("", "", geom)
+
+	// ids that aren't valid in XML (numbers, usually) are kept as data
+	if id, ok := geoJSONText(f.ID); ok && isXMLName(id) {
+		pm.SetID(id)
+	} else if ok {
+		pm.SetData("id", id)
+	}
+
+	names := make([]string, 0, len(f.Properties))
+	for name := range f.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, ok := geoJSONText(f.Properties[name])
+		if !ok {
+			continue
+		}
+
+		switch {
+		case len(name) == 0:
+			continue
+		case name == mapping.Name:
+			pm.name = value
+		case name == mapping.Description:
+			pm.description, pm.html = value, hasMarkup(value)
+		case name == mapping.Style:
+			pm.SetStyle(value)
+		default:
+			pm.SetData(name, value)
+		}
+	}
+
+	return pm, nil
+}
+
+// geoJSONText returns a property value as text: strings as they are, and
+// other values as JSON.  It returns false for missing and null values.
+func geoJSONText(raw json.RawMessage) (string, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", false
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, true
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw), true
+	}
+
+	return buf.String(), true
+}
+
+// geoJSONGeometry returns the geometry for a GeoJSON geometry object,
+// passing each position to check.
+func geoJSONGeometry(g *geoJSONObject, check func(lat float64, lon float64)) (renderable, error) {
+	var err error
+
+	switch g.Type {
+	case "Point":
+		var pos []float64
+		if err = json.Unmarshal(g.Coordinates, &pos); err == nil {
+			if p := geoJSONPoint(pos, check); p != nil {
+				return p, nil
+			}
+			return nil, nil
+		}
+	case "MultiPoint":
+		var positions [][]float64
+		if err = json.Unmarshal(g.Coordinates, &positions); err == nil {
+			mg := NewMultiGeometry()
+			for _, pos := range positions {
+				if p := geoJSONPoint(pos, check); p != nil {
+					mg.AddGeometry(p)
+				}
+			}
+			return mg, nil
+		}
+	case "LineString":
+		var line [][]float64
+		if err = json.Unmarshal(g.Coordinates, &line); err == nil {
+			return geoJSONLineString(line, check), nil
+		}
+	case "MultiLineString":
+		var lines [][][]float64
+		if err = json.Unmarshal(g.Coordinates, &lines); err == nil {
+			mg := NewMultiGeometry()
+			for _, line := range lines {
+				mg.AddGeometry(geoJSONLineString(line, check))
+			}
+			return mg, nil
+		}
+	case "Polygon":
+		var rings [][][]float64
+		if err = json.Unmarshal(g.Coordinates, &rings); err == nil {
+			return geoJSONPolygon(rings, check), nil
+		}
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err = json.Unmarshal(g.Coordinates, &polygons); err == nil {
+			mg := NewMultiGeometry()
+			for _, rings := range polygons {
+				mg.AddGeometry(geoJSONPolygon(rings, check))
+			}
+			return mg, nil
+		}
+	case "GeometryCollection":
+		mg := NewMultiGeometry()
+		for _, child := range g.Geometries {
+			if child == nil {
+				continue
+			}
+			geom, err := geoJSONGeometry(child, check)
+			if err != nil {
+				return nil, err
+			}
+			mg.AddGeometry(geom)
+		}
+		return mg, nil
+	default:
+		return nil, fmt.Errorf("unsupported GeoJSON type %q", g.Type)
+	}
+
+	return nil, fmt.Errorf("invalid %s coordinates: %v", g.Type, err)
+}
+
+// geoJSONPoint returns the Point at a [lon, lat, alt] position, or nil if
+// the position is invalid.
+func geoJSONPoint(pos []float64, check func(lat float64, lon float64)) *Point {
+	if len(pos) < 2 {
+		return nil
+	}
+
+	check(pos[1], pos[0])
+
+	alt := 0.0
+	if len(pos) > 2 {
+		alt = pos[2]
+	}
+
+	return NewPoint(pos[1], pos[0], alt)
+}
+
+func geoJSONPoints(positions [][]float64, check func(lat float64, lon float64)) []*Point {
+	points := make([]*Point, 0, len(positions))
+	for _, pos := range positions {
+		if p := geoJSONPoint(pos, check); p != nil {
+			points = append(points, p)
+		}
+	}
+
+	return points
+}
+
+func geoJSONLineString(positions [][]float64, check func(lat float64, lon float64)) *LineString {
+	ls := NewLineString()
+	for _, p := range geoJSONPoints(positions, check) {
+		ls.AddPoint(p)
+	}
+
+	return ls
+}
+
+// geoJSONPolygon returns a Polygon for the rings of a GeoJSON Polygon: the
+// exterior ring followed by any holes.
+func geoJSONPolygon(rings [][][]float64, check func(lat float64, lon float64)) *Polygon {
+	poly := NewPolygon()
+
+	for i, ring := range rings {
+		if i == 0 {
+			for _, p := range geoJSONPoints(ring, check) {
+				poly.AddPoint(p)
+			}
+		} else {
+			poly.AddInnerBoundary(geoJSONPoints(ring, check)...)
+		}
+	}
+
+	return poly
+}
@@ -0,0 +1,205 @@
+package gokml
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const geoJSONFixture = `{
+	"type": "FeatureCollection",
+	"name": "Parks",
+	"features": [
+		{
+			"type": "Feature",
+			"id": 7,
+			"geometry": {"type": "Point", "coordinates": [-105.27, 40.01, 1655]},
+			"properties": {"title": "Chautauqua", "kind": "city", "acres": 40, "info": "<b>Trailhead</b>", "open": true, "tags": ["a", "b"], "closed": null}
+		},
+		{
+			"type": "Feature",
+			"id": "pawnee",
+			"geometry": {
+				"type": "Polygon",
+				"coordinates": [
+					[[-105.0, 40.0], [-104.0, 40.0], [-104.0, 41.0], [-105.0, 41.0], [-105.0, 40.0]],
+					[[-104.8, 40.2], [-104.2, 40.2], [-104.2, 40.8]]
+				]
+			},
+			"properties": {"title": "Pawnee", "kind": "national"}
+		},
+		{
+			"type": "Feature",
+			"geometry": {"type": "MultiLineString", "coordinates": [[[-105, 39], [-104, 39]], [[-103, 38], [-102, 38]]]},
+			"properties": {"title": "Trails", "kind": "city", "style": "trail"}
+		},
+		{
+			"type": "Feature",
+			"geometry": null,
+			"properties": {"title": "Unmapped"}
+		}
+	]
+}`
+
+func TestFromGeoJSON(t *testing.T) {
+	mapping := GeoJSONMapping{Name: "title", Description: "info", Style: "style", Folder: "kind"}
+
+	k, err := FromGeoJSON(strings.NewReader(geoJSONFixture), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k.rootFolder.name != "Parks" {
+		t.Errorf("document name = %q, want Parks", k.rootFolder.name)
+	}
+
+	city := k.FindFolders("city")
+	if len(city) != 1 || len(city[0].features) != 2 || len(k.FindFolders("national")) != 1 {
+		t.Fatalf("placemarks not grouped into folders: %v", k.rootFolder.features)
+	}
+
+	pm := city[0].features[0].(*Placemark)
+	if pm.name != "Chautauqua" || len(pm.id) > 0 || !pm.html || pm.description != "<b>Trailhead</b>" {
+		t.Errorf("properties not mapped: %+v", pm)
+	}
+
+	for name, want := range map[string]string{"id": "7", "acres": "40", "open": "true", "tags": `["a","b"]`, "kind": "city"} {
+		if got, _ := pm.Data(name); got != want {
+			t.Errorf("data %s = %q, want %q", name, got, want)
+		}
+	}
+	if _, ok := pm.Data("closed"); ok {
+		t.Error("null property added as data")
+	}
+
+	if p := pm.geometry.(*Point); p.Lat != 40.01 || p.Lon != -105.27 || p.Alt != 1655 {
+		t.Errorf("unexpected point %v", p)
+	}
+
+	if pawnee := k.FindByID("pawnee"); pawnee == nil {
+		t.Error("string id not used as the placemark id")
+	}
+
+	trails := city[0].features[1].(*Placemark)
+	if trails.style != "trail" {
+		t.Errorf("style = %q, want trail", trails.style)
+	}
+	if mg, ok := trails.geometry.(*MultiGeometry); !ok || len(mg.geometries) != 2 {
+		t.Errorf("MultiLineString not converted to MultiGeometry: %v", trails.geometry)
+	}
+
+	out := unindent(k.Render())
+	for _, want := range []string{
+		"<innerBoundaryIs>\n<LinearRing>\n<coordinates>-104.800000,40.200000,0.000000 -104.200000,40.200000,0.000000 -104.200000,40.800000,0.000000 -104.800000,40.200000,0.000000</coordinates>",
+		"<MultiGeometry>\n<LineString>",
+		"<name>Unmapped</name>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if err := k.Validate(); err != nil {
+		t.Errorf("converted document is invalid: %v", err)
+	}
+}
+
+func TestFromGeoJSONGeometry(t *testing.T) {
+	k, err := FromGeoJSON(strings.NewReader(`{"type": "GeometryCollection", "geometries": [
+		{"type": "MultiPoint", "coordinates": [[1, 2], [200, 2]]},
+		{"type": "MultiPolygon", "coordinates": [[[[0, 0], [1, 0], [1, 1]]]]}
+	]}`), DefaultGeoJSONMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mg := k.rootFolder.features[0].(*Placemark).geometry.(*MultiGeometry)
+	if len(mg.geometries) != 2 || len(mg.geometries[0].(*MultiGeometry).geometries) != 1 {
+		t.Errorf("unexpected geometry %v", mg.geometries)
+	}
+}
+
+func TestFromGeoJSONErrors(t *testing.T) {
+	for _, doc := range []string{
+		`not json`,
+		`{"type": "Feature", "geometry": {"type": "Circle", "coordinates": [0, 0]}}`,
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": "0,0"}}`,
+		`{"type": "FeatureCollection", "features": [{"type": "Point", "coordinates": [0, 0]}]}`,
+	} {
+		if _, err := FromGeoJSON(strings.NewReader(doc), DefaultGeoJSONMapping); err == nil {
+			t.Errorf("no error for %s", doc)
+		}
+	}
+}
+
+func TestFromGeoJSONSuspect(t *testing.T) {
+	var suspect []string
+	mapping := DefaultGeoJSONMapping
+	mapping.Check = &CoordinateCheck{
+		Tolerance: 0.5,
+		Suspect: func(row int, lat float64, lon float64, problem string) {
+			suspect = append(suspect, fmt.Sprintf("%d: %v,%v %s", row, lat, lon, problem))
+		},
+	}
+
+	k, err := FromGeoJSON(strings.NewReader(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-105, 40]}},
+		{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[40, -105], [0.1, 0.2]]}}
+	]}`), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ls := k.rootFolder.features[1].(*Placemark).geometry.(*LineString)
+	if len(ls.coordinates) != 1 {
+		t.Errorf("got %d points, want the out of range one dropped", len(ls.coordinates))
+	}
+
+	want := []string{"1: -105,40 latitude and longitude may be swapped", "1: 0.2,0.1 at or near 0,0"}
+	if fmt.Sprint(suspect) != fmt.Sprint(want) {
+		t.Errorf("got suspect positions %q, want %q", suspect, want)
+	}
+}
+
+func TestStreamGeoJSON(t *testing.T) {
+	mapping := GeoJSONMapping{Name: "title", Description: "info", Style: "style", Folder: "kind"}
+
+	k, err := FromGeoJSON(strings.NewReader(geoJSONFixture), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := streamed(t, func(sw *StreamWriter) error {
+		sw.SetMemoryBudget(1)
+		return StreamGeoJSON(sw, strings.NewReader(geoJSONFixture), mapping)
+	})
+
+	// grouped features follow the others when streamed
+	want := make([]*Placemark, 0)
+	for _, f := range k.rootFolder.features {
+		if pm, ok := f.(*Placemark); ok {
+			want = append(want, pm)
+		}
+	}
+	for _, f := range k.rootFolder.features {
+		if folder, ok := f.(*Folder); ok {
+			walkPlacemarks(folder, func(pm *Placemark) { want = append(want, pm) })
+		}
+	}
+	samePlacemarks(t, got, want)
+
+	got = streamed(t, func(sw *StreamWriter) error {
+		return StreamGeoJSON(sw, strings.NewReader(`{"type": "Feature", "properties": {"name": "Lone"}, "geometry": {"type": "Point", "coordinates": [1, 2]}}`), DefaultGeoJSONMapping)
+	})
+	if len(got) != 1 || got[0].name != "Lone" {
+		t.Errorf("lone feature not streamed: %v", got)
+	}
+
+	for _, doc := range []string{`[]`, `{"type": "FeatureCollection", "features": [{"type": "Point", "coordinates": [0, 0]}]}`} {
+		sw, _ := NewKML("").Stream(io.Discard)
+		if err := StreamGeoJSON(sw, strings.NewReader(doc), DefaultGeoJSONMapping); err == nil {
+			t.Errorf("no error for %s", doc)
+		}
+	}
+}
@@ -0,0 +1,581 @@
+package gokml
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TIFF tags
+const (
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffCompression     = 259
+	tiffPhotometric     = 262
+	tiffStripOffsets    = 273
+	tiffSamplesPerPixel = 277
+	tiffRowsPerStrip    = 278
+	tiffStripByteCounts = 279
+	tiffPlanarConfig    = 284
+	tiffPredictor       = 317
+	tiffColorMap        = 320
+	tiffTileWidth       = 322
+	tiffTileLength      = 323
+	tiffTileOffsets     = 324
+	tiffTileByteCounts  = 325
+	tiffSampleFormat    = 339
+	tiffPixelScale      = 33550
+	tiffTiepoint        = 33922
+	tiffTransformation  = 34264
+	tiffGeoKeys         = 34735
+	tiffNoData          = 42113 // GDAL_NODATA
+)
+
+// GeoTIFF keys
+const (
+	geoModelType  = 1024
+	geoRasterType = 1025
+	geoProjected  = 3072
+)
+
+// GeoTIFF is a georeferenced raster image, as read by ReadGeoTIFF.
+type GeoTIFF struct {
+	Image image.Image
+
+	// Corners holds the lower left, lower right, upper right, and upper left
+	// corners of the image (the order of gx:LatLonQuad), with zero altitudes.
+	Corners [4]*Point
+}
+
+// Bounds returns the smallest LatLonBox holding the corners of the image.
+func (g *GeoTIFF) Bounds() Bounds {
+	b := Bounds{North: -90, South: 90, East: -180, West: 180}
+	for _, p := range g.Corners {
+		b.North = math.Max(b.North, p.Lat)
+		b.South = math.Min(b.South, p.Lat)
+		b.East = math.Max(b.East, p.Lon)
+		b.West = math.Min(b.West, p.Lon)
+	}
+
+	return b
+}
+
+// rectangular reports whether the image is aligned with the meridians and
+// parallels, so a LatLonBox places it exactly.
+func (g *GeoTIFF) rectangular() bool {
+	ll, lr, ur, ul := g.Corners[0], g.Corners[1], g.Corners[2], g.Corners[3]
+	return ll.Lon == ul.Lon && lr.Lon == ur.Lon && ll.Lat == lr.Lat && ul.Lat == ur.Lat
+}
+
+// ReadGeoTIFF decodes the first image of a GeoTIFF file and its
+// georeferencing.  Images with 8 or 16 bit unsigned samples in grayscale,
+// RGB, or palette color, with or without alpha, are supported, stored in
+// strips or tiles, uncompressed or with LZW, Deflate, or PackBits
+// compression.  Pixels matching the GDAL nodata value are made
+// transparent.  The image must be in geographic coordinates (longitude and
+// latitude); projected images are an error, since they would need to be
+// warped.  The datum isn't converted.
+func ReadGeoTIFF(r io.Reader) (*GeoTIFF, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := newTIFFReader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := t.ifd()
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := t.image(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	corners, err := t.corners(tags, img.Bounds().Dx(), img.Bounds().Dy())
+	if err != nil {
+		return nil, err
+	}
+
+	return &GeoTIFF{img, corners}, nil
+}
+
+// AddGeoTIFF reads a GeoTIFF like ReadGeoTIFF and returns a GroundOverlay
+// with the given name showing it, which can then be added to the document.
+// The image is converted to PNG, or to JPEG if jpg is true (smaller, for
+// opaque imagery such as aerial photos), and embedded in KMZ archives
+// written by WriteKMZ like AddAssetData.  Images aligned with the meridians
+// are placed by a LatLonBox, and rotated or skewed ones by a gx:LatLonQuad.
+func (k *KML) AddGeoTIFF(name string, r io.Reader, jpg bool) (*GroundOverlay, error) {
+	g, err := ReadGeoTIFF(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	ext := ".png"
+	if jpg {
+		ext = ".jpg"
+		err = jpeg.Encode(&buf, g.Image, nil)
+	} else {
+		err = png.Encode(&buf, g.Image)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	href := k.AddAssetData(name+ext, buf.Bytes())
+
+	overlay := NewGroundOverlay(name, "", href, g.Bounds())
+	if !g.rectangular() {
+		overlay.SetLatLonQuad(g.Corners[0], g.Corners[1], g.Corners[2], g.Corners[3])
+	}
+
+	return overlay, nil
+}
+
+// tiffEntry is the value of a tag in a TIFF image file directory.
+type tiffEntry struct {
+	kind  uint16
+	count int
+	data  []byte
+}
+
+type tiffReader struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func newTIFFReader(b []byte) (*tiffReader, error) {
+	t := &tiffReader{b: b}
+
+	switch {
+	case len(b) >= 8 && string(b[:4]) == "II*\x00":
+		t.order = binary.LittleEndian
+	case len(b) >= 8 && string(b[:4]) == "MM\x00*":
+		t.order = binary.BigEndian
+	case len(b) >= 4 && (string(b[:4]) == "II+\x00" || string(b[:4]) == "MM\x00+"):
+		return nil, fmt.Errorf("gokml: BigTIFF files aren't supported")
+	default:
+		return nil, fmt.Errorf("gokml: not a TIFF file")
+	}
+
+	return t, nil
+}
+
+// ifd returns the tags of the first image file directory.
+func (t *tiffReader) ifd() (map[uint16]tiffEntry, error) {
+	off := int(t.order.Uint32(t.b[4:]))
+	if off+2 > len(t.b) {
+		return nil, fmt.Errorf("gokml: truncated TIFF file")
+	}
+
+	n := int(t.order.Uint16(t.b[off:]))
+	off += 2
+	if off+12*n > len(t.b) {
+		return nil, fmt.Errorf("gokml: truncated TIFF file")
+	}
+
+	// sizes of the TIFF field types, by type number
+	sizes := []int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+	tags := make(map[uint16]tiffEntry, n)
+	for i := 0; i < n; i++ {
+		entry := t.b[off+12*i : off+12*i+12]
+		kind := t.order.Uint16(entry[2:])
+		count := int(t.order.Uint32(entry[4:]))
+		if int(kind) >= len(sizes) || sizes[kind] == 0 {
+			continue // unknown type
+		}
+
+		size := sizes[kind] * count
+		data := entry[8:12]
+		if size > 4 {
+			at := int(t.order.Uint32(entry[8:]))
+			if at < 0 || size < 0 || at+size > len(t.b) {
+				return nil, fmt.Errorf("gokml: truncated TIFF file")
+			}
+			data = t.b[at : at+size]
+		}
+
+		tags[t.order.Uint16(entry)] = tiffEntry{kind, count, data[:size]}
+	}
+
+	return tags, nil
+}
+
+// ints returns the values of a BYTE, SHORT, or LONG tag.
+func (t *tiffReader) ints(e tiffEntry) []int {
+	var v []int
+	for i := 0; i < e.count; i++ {
+		switch e.kind {
+		case 1:
+			v = append(v, int(e.data[i]))
+		case 3:
+			v = append(v, int(t.order.Uint16(e.data[2*i:])))
+		case 4:
+			v = append(v, int(t.order.Uint32(e.data[4*i:])))
+		default:
+			return nil
+		}
+	}
+
+	return v
+}
+
+// int returns the first value of an integer tag, or def if it isn't set.
+func (t *tiffReader) int(tags map[uint16]tiffEntry, tag uint16, def int) int {
+	if v := t.ints(tags[tag]); len(v) > 0 {
+		return v[0]
+	}
+
+	return def
+}
+
+// floats returns the values of a DOUBLE tag.
+func (t *tiffReader) floats(e tiffEntry) []float64 {
+	if e.kind != 12 {
+		return nil
+	}
+
+	v := make([]float64, e.count)
+	for i := range v {
+		v[i] = math.Float64frombits(t.order.Uint64(e.data[8*i:]))
+	}
+
+	return v
+}
+
+// image decodes the raster of an image file directory.
+func (t *tiffReader) image(tags map[uint16]tiffEntry) (*image.NRGBA, error) {
+	width, height := t.int(tags, tiffImageWidth, 0), t.int(tags, tiffImageLength, 0)
+	spp := t.int(tags, tiffSamplesPerPixel, 1)
+	bps := t.int(tags, tiffBitsPerSample, 1)
+	photometric := t.int(tags, tiffPhotometric, -1)
+	compression := t.int(tags, tiffCompression, 1)
+	predictor := t.int(tags, tiffPredictor, 1)
+
+	switch {
+	case width <= 0 || height <= 0 || width > 1<<16 || height > 1<<16 || width*height > 1<<26:
+		return nil, fmt.Errorf("gokml: unsupported TIFF size %dx%d", width, height)
+	case bps != 8 && bps != 16:
+		return nil, fmt.Errorf("gokml: unsupported TIFF bits per sample %d", bps)
+	case t.int(tags, tiffSampleFormat, 1) != 1:
+		return nil, fmt.Errorf("gokml: unsupported TIFF sample format %d", t.int(tags, tiffSampleFormat, 1))
+	case t.int(tags, tiffPlanarConfig, 1) != 1 && spp > 1:
+		return nil, fmt.Errorf("gokml: TIFF planar configuration isn't supported")
+	case predictor != 1 && predictor != 2:
+		return nil, fmt.Errorf("gokml: unsupported TIFF predictor %d", predictor)
+	}
+
+	want := map[int]int{0: 1, 1: 1, 2: 3, 3: 1}
+	if n, ok := want[photometric]; !ok || spp < n || spp > n+1 {
+		return nil, fmt.Errorf("gokml: unsupported TIFF color (photometric %d, %d samples)", photometric, spp)
+	}
+
+	// the image is read in blocks: strips, or tiles
+	blockW, blockH := width, t.int(tags, tiffRowsPerStrip, height)
+	offsets, counts := t.ints(tags[tiffStripOffsets]), t.ints(tags[tiffStripByteCounts])
+	if _, tiled := tags[tiffTileWidth]; tiled {
+		blockW, blockH = t.int(tags, tiffTileWidth, 0), t.int(tags, tiffTileLength, 0)
+		offsets, counts = t.ints(tags[tiffTileOffsets]), t.ints(tags[tiffTileByteCounts])
+	}
+	if blockW <= 0 || blockH <= 0 {
+		return nil, fmt.Errorf("gokml: invalid TIFF strip or tile size")
+	}
+	blockH = minInt(blockH, height)
+
+	across, down := (width+blockW-1)/blockW, (height+blockH-1)/blockH
+	if len(offsets) < across*down || len(counts) < len(offsets) {
+		return nil, fmt.Errorf("gokml: missing TIFF strips or tiles")
+	}
+
+	pixel := spp * bps / 8
+	raster := make([]byte, width*height*pixel)
+
+	for i := 0; i < across*down; i++ {
+		off, n := offsets[i], counts[i]
+		if off < 0 || n < 0 || off+n > len(t.b) {
+			return nil, fmt.Errorf("gokml: truncated TIFF file")
+		}
+
+		size := blockW * blockH * pixel
+		block, err := tiffDecompress(compression, t.b[off:off+n], size)
+		if err != nil {
+			return nil, err
+		}
+		if len(block) < size {
+			// strips at the bottom of the image may be short
+			block = append(block, make([]byte, size-len(block))...)
+		}
+
+		if predictor == 2 {
+			tiffUndoPredictor(block, blockW, spp, bps, t.order)
+		}
+
+		x0, y0 := i%across*blockW, i/across*blockH
+		rowBytes := (minInt(x0+blockW, width) - x0) * pixel
+		for y := y0; y < minInt(y0+blockH, height); y++ {
+			copy(raster[(y*width+x0)*pixel:], block[(y-y0)*blockW*pixel:][:rowBytes])
+		}
+	}
+
+	sample := func(i int) int {
+		if bps == 16 {
+			return int(t.order.Uint16(raster[2*i:]))
+		}
+		return int(raster[i])
+	}
+	scale := func(v int) uint8 {
+		if bps == 16 {
+			return uint8(v >> 8)
+		}
+		return uint8(v)
+	}
+
+	nodata, hasNoData := 0.0, false
+	if e, ok := tags[tiffNoData]; ok {
+		v, err := strconv.ParseFloat(strings.Trim(string(e.data), "\x00 "), 64)
+		nodata, hasNoData = v, err == nil
+	}
+
+	colorMap := t.ints(tags[tiffColorMap])
+	if photometric == 3 && len(colorMap) < 3<<bps {
+		return nil, fmt.Errorf("gokml: missing TIFF color map")
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * spp
+			c := color.NRGBA{A: 255}
+
+			switch photometric {
+			case 0, 1:
+				v := scale(sample(i))
+				if photometric == 0 {
+					v = 255 - v
+				}
+				c.R, c.G, c.B = v, v, v
+			case 2:
+				c.R, c.G, c.B = scale(sample(i)), scale(sample(i+1)), scale(sample(i+2))
+			case 3:
+				v, n := sample(i), 1<<bps
+				c.R, c.G, c.B = uint8(colorMap[v]>>8), uint8(colorMap[n+v]>>8), uint8(colorMap[2*n+v]>>8)
+			}
+
+			if spp > want[photometric] {
+				c.A = scale(sample(i + spp - 1))
+			}
+
+			if hasNoData {
+				blank := true
+				for s := 0; s < want[photometric]; s++ {
+					blank = blank && float64(sample(i+s)) == nodata
+				}
+				if blank {
+					c = color.NRGBA{}
+				}
+			}
+
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img, nil
+}
+
+// corners returns the positions of the corners of an image from its
+// GeoTIFF tags, in the order of GeoTIFF.Corners.
+func (t *tiffReader) corners(tags map[uint16]tiffEntry, width, height int) ([4]*Point, error) {
+	var corners [4]*Point
+
+	keys := map[int]int{}
+	if dir := t.ints(tags[tiffGeoKeys]); len(dir) >= 4 {
+		for i := 0; i < dir[3] && 4+4*i+3 < len(dir); i++ {
+			key := dir[4+4*i:]
+			if key[1] == 0 { // the value is stored in the key itself
+				keys[key[0]] = key[3]
+			}
+		}
+	}
+
+	if keys[geoModelType] == 1 {
+		return corners, fmt.Errorf("gokml: GeoTIFF is projected (EPSG:%d); only geographic coordinates are supported", keys[geoProjected])
+	}
+
+	// the affine transformation from raster to model coordinates:
+	// lon = a*i + b*j + c, lat = d*i + e*j + f
+	var a, b, c, d, e, f float64
+	tiepoints, scale := t.floats(tags[tiffTiepoint]), t.floats(tags[tiffPixelScale])
+	if m := t.floats(tags[tiffTransformation]); len(m) >= 8 {
+		a, b, c, d, e, f = m[0], m[1], m[3], m[4], m[5], m[7]
+	} else if len(tiepoints) >= 6 && len(scale) >= 2 {
+		a, e = scale[0], -scale[1]
+		c, f = tiepoints[3]-tiepoints[0]*a, tiepoints[4]-tiepoints[1]*e
+	} else {
+		return corners, fmt.Errorf("gokml: TIFF file isn't georeferenced")
+	}
+
+	// raster coordinates are of pixel corners, unless the raster type is
+	// PixelIsPoint
+	offset := 0.0
+	if keys[geoRasterType] == 2 {
+		offset = -0.5
+	}
+
+	for n, pos := range [4][2]float64{{0, float64(height)}, {float64(width), float64(height)}, {float64(width), 0}, {0, 0}} {
+		i, j := pos[0]+offset, pos[1]+offset
+		corners[n] = NewPoint(d*i+e*j+f, a*i+b*j+c, 0)
+		if corners[n] == nil {
+			return corners, fmt.Errorf("gokml: GeoTIFF coordinates aren't longitude and latitude")
+		}
+	}
+
+	return corners, nil
+}
+
+// tiffDecompress decompresses a strip or tile, which should have size bytes.
+func tiffDecompress(compression int, b []byte, size int) ([]byte, error) {
+	switch compression {
+	case 1:
+		return b, nil
+	case 5:
+		return tiffLZW(b, size)
+	case 8, 32946:
+		r, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("gokml: invalid TIFF Deflate data: %v", err)
+		}
+		out, err := io.ReadAll(io.LimitReader(r, int64(size)))
+		if err != nil {
+			return nil, fmt.Errorf("gokml: invalid TIFF Deflate data: %v", err)
+		}
+		return out, nil
+	case 32773:
+		return tiffPackBits(b, size), nil
+	}
+
+	return nil, fmt.Errorf("gokml: unsupported TIFF compression %d", compression)
+}
+
+// tiffLZW decodes TIFF's variant of LZW (most significant bit first, with
+// the code width growing one code early), up to size bytes.
+func tiffLZW(b []byte, size int) ([]byte, error) {
+	const clear, eoi = 256, 257
+
+	out := make([]byte, 0, size)
+	var table [][]byte
+	reset := func() {
+		table = table[:0]
+		for i := 0; i < 258; i++ {
+			table = append(table, []byte{byte(i)})
+		}
+	}
+	reset()
+
+	var bits, nbits uint32
+	width := uint32(9)
+	prev := -1
+
+	for pos := 0; len(out) < size; {
+		for nbits < width {
+			if pos == len(b) {
+				return out, nil
+			}
+			bits = bits<<8 | uint32(b[pos])
+			nbits += 8
+			pos++
+		}
+		code := int(bits >> (nbits - width) & (1<<width - 1))
+		nbits -= width
+
+		switch {
+		case code == eoi:
+			return out, nil
+		case code == clear:
+			reset()
+			width, prev = 9, -1
+			continue
+		case prev < 0:
+			if code > 255 {
+				return nil, fmt.Errorf("gokml: invalid TIFF LZW data")
+			}
+			out = append(out, byte(code))
+		case code < len(table):
+			out = append(out, table[code]...)
+			table = append(table, append(append([]byte(nil), table[prev]...), table[code][0]))
+		case code == len(table):
+			entry := append(append([]byte(nil), table[prev]...), table[prev][0])
+			out = append(out, entry...)
+			table = append(table, entry)
+		default:
+			return nil, fmt.Errorf("gokml: invalid TIFF LZW data")
+		}
+		prev = code
+
+		if len(table)+1 >= 1<<width && width < 12 {
+			width++
+		}
+	}
+
+	return out[:size], nil
+}
+
+// tiffPackBits decodes PackBits run-length encoding, up to size bytes.
+func tiffPackBits(b []byte, size int) []byte {
+	out := make([]byte, 0, size)
+
+	for i := 0; i < len(b) && len(out) < size; {
+		n := int(int8(b[i]))
+		i++
+		switch {
+		case n >= 0:
+			end := minInt(i+n+1, len(b))
+			out = append(out, b[i:end]...)
+			i = end
+		case n != -128:
+			if i < len(b) {
+				out = append(out, bytes.Repeat(b[i:i+1], 1-n)...)
+				i++
+			}
+		}
+	}
+
+	return out
+}
+
+// tiffUndoPredictor reverses horizontal differencing, in which each sample
+// is stored as the difference from the same sample of the pixel to its left.
+func tiffUndoPredictor(block []byte, width int, spp int, bps int, order binary.ByteOrder) {
+	if bps == 8 {
+		row := width * spp
+		for y := 0; y+row <= len(block); y += row {
+			for i := y + spp; i < y+row; i++ {
+				block[i] += block[i-spp]
+			}
+		}
+		return
+	}
+
+	row := width * spp * 2
+	for y := 0; y+row <= len(block); y += row {
+		for i := y + 2*spp; i < y+row; i += 2 {
+			order.PutUint16(block[i:], order.Uint16(block[i:])+order.Uint16(block[i-2*spp:]))
+		}
+	}
+}
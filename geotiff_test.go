@@ -0,0 +1,317 @@
+package gokml
+
+import (
+	"bytes"
+	"compress/lzw"
+	"compress/zlib"
+	"encoding/binary"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+// tiffTag is a tag of a TIFF file written by tiffFile, with a []uint16,
+// []uint32, []float64, or string value.
+type tiffTag struct {
+	tag   uint16
+	value interface{}
+}
+
+// tiffFile returns a little-endian TIFF file with one image, whose strips or
+// tiles are given by data and referenced by the offsets tag (273 or 324).
+func tiffFile(offsets uint16, data [][]byte, tags ...tiffTag) []byte {
+	var counts, offs []uint32
+	for _, d := range data {
+		counts = append(counts, uint32(len(d)))
+	}
+	tags = append(tags, tiffTag{offsets, offs}, tiffTag{offsets + 6, counts})
+	if offsets == tiffTileOffsets {
+		tags[len(tags)-1].tag = tiffTileByteCounts
+	}
+
+	// the image data and large tag values follow the directory
+	ifdSize := 2 + 12*len(tags) + 4
+	extra := new(bytes.Buffer)
+	pos := uint32(8 + ifdSize)
+	for _, d := range data {
+		offs = append(offs, pos+uint32(extra.Len()))
+		extra.Write(d)
+	}
+	tags[len(tags)-2].value = offs
+
+	b := []byte("II*\x00\x08\x00\x00\x00")
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(tags)))
+	for _, t := range tags {
+		var kind uint16
+		var v []byte
+		switch t := t.value.(type) {
+		case []uint16:
+			kind = 3
+			for _, x := range t {
+				v = binary.LittleEndian.AppendUint16(v, x)
+			}
+		case []uint32:
+			kind = 4
+			for _, x := range t {
+				v = binary.LittleEndian.AppendUint32(v, x)
+			}
+		case []float64:
+			kind = 12
+			for _, x := range t {
+				v = binary.LittleEndian.AppendUint64(v, math.Float64bits(x))
+			}
+		case string:
+			kind = 2
+			v = append([]byte(t), 0)
+		}
+
+		size := map[uint16]int{2: 1, 3: 2, 4: 4, 12: 8}[kind]
+		b = binary.LittleEndian.AppendUint16(b, t.tag)
+		b = binary.LittleEndian.AppendUint16(b, kind)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(v)/size))
+		if len(v) <= 4 {
+			b = append(b, append(v, make([]byte, 4-len(v))...)...)
+		} else {
+			b = binary.LittleEndian.AppendUint32(b, pos+uint32(extra.Len()))
+			extra.Write(v)
+		}
+	}
+	b = append(b, 0, 0, 0, 0)
+
+	return append(b, extra.Bytes()...)
+}
+
+// geographic holds the GeoTIFF keys of a geographic image with pixels as
+// areas.
+var geographic = tiffTag{tiffGeoKeys, []uint16{1, 1, 0, 2, geoModelType, 0, 1, 2, 2048, 0, 1, 4326}}
+
+func TestReadGeoTIFF(t *testing.T) {
+	rgb := tiffFile(tiffStripOffsets,
+		[][]byte{{255, 0, 0, 0, 255, 0}, {0, 0, 255, 0, 0, 0}},
+		tiffTag{tiffImageWidth, []uint16{2}},
+		tiffTag{tiffImageLength, []uint16{2}},
+		tiffTag{tiffBitsPerSample, []uint16{8, 8, 8}},
+		tiffTag{tiffPhotometric, []uint16{2}},
+		tiffTag{tiffSamplesPerPixel, []uint16{3}},
+		tiffTag{tiffRowsPerStrip, []uint16{1}},
+		tiffTag{tiffPixelScale, []float64{0.5, 0.25, 0}},
+		tiffTag{tiffTiepoint, []float64{0, 0, 0, -105, 40, 0}},
+		geographic,
+		tiffTag{tiffNoData, "0"},
+	)
+
+	g, err := ReadGeoTIFF(bytes.NewReader(rgb))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := g.Bounds(); got != (Bounds{North: 40, South: 39.5, East: -104, West: -105}) {
+		t.Errorf("bounds = %+v", got)
+	}
+	if !g.rectangular() {
+		t.Errorf("image not rectangular: %v", g.Corners)
+	}
+
+	for _, c := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{0, 0, color.NRGBA{255, 0, 0, 255}},
+		{1, 0, color.NRGBA{0, 255, 0, 255}},
+		{0, 1, color.NRGBA{0, 0, 255, 255}},
+		{1, 1, color.NRGBA{}}, // nodata
+	} {
+		if got := color.NRGBAModel.Convert(g.Image.At(c.x, c.y)); got != c.want {
+			t.Errorf("pixel %d,%d = %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+}
+
+func TestReadGeoTIFFCompression(t *testing.T) {
+	// a 3x3 grayscale image in 2x2 tiles, with horizontal differencing
+	tiles := [][]byte{{10, 1, 40, 1}, {30, 0, 60, 0}, {70, 1, 0, 0}, {90, 0, 0, 0}}
+	want := []uint8{10, 11, 30, 40, 41, 60, 70, 71, 90}
+
+	lzwData := func(b []byte) []byte {
+		var buf bytes.Buffer
+		w := lzw.NewWriter(&buf, lzw.MSB, 8)
+		w.Write(b)
+		w.Close()
+		return buf.Bytes()
+	}
+	deflate := func(b []byte) []byte {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(b)
+		w.Close()
+		return buf.Bytes()
+	}
+	packBits := func(b []byte) []byte {
+		return append([]byte{byte(len(b) - 1)}, b...)
+	}
+
+	for compression, encode := range map[uint16]func([]byte) []byte{
+		1:     func(b []byte) []byte { return b },
+		5:     lzwData,
+		8:     deflate,
+		32773: packBits,
+	} {
+		var data [][]byte
+		for _, tile := range tiles {
+			data = append(data, encode(tile))
+		}
+
+		b := tiffFile(tiffTileOffsets, data,
+			tiffTag{tiffImageWidth, []uint16{3}},
+			tiffTag{tiffImageLength, []uint16{3}},
+			tiffTag{tiffBitsPerSample, []uint16{8}},
+			tiffTag{tiffCompression, []uint16{compression}},
+			tiffTag{tiffPhotometric, []uint16{1}},
+			tiffTag{tiffPredictor, []uint16{2}},
+			tiffTag{tiffTileWidth, []uint16{2}},
+			tiffTag{tiffTileLength, []uint16{2}},
+			tiffTag{tiffPixelScale, []float64{1, 1, 0}},
+			tiffTag{tiffTiepoint, []float64{0, 0, 0, 10, 20, 0}},
+		)
+
+		g, err := ReadGeoTIFF(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("compression %d: %v", compression, err)
+			continue
+		}
+
+		for i, v := range want {
+			if got := color.GrayModel.Convert(g.Image.At(i%3, i/3)).(color.Gray).Y; got != v {
+				t.Errorf("compression %d: pixel %d = %d, want %d", compression, i, got, v)
+			}
+		}
+	}
+}
+
+func TestTIFFLZW(t *testing.T) {
+	// long enough for the code width to grow
+	var in []byte
+	for i := 0; i < 4000; i++ {
+		in = append(in, byte(i*i%251))
+	}
+
+	out, err := tiffLZW(tiffLZWEncode(in), len(in))
+	if err != nil || !bytes.Equal(out, in) {
+		t.Errorf("round trip failed: %v", err)
+	}
+}
+
+// tiffLZWEncode encodes b with TIFF LZW.
+func tiffLZWEncode(b []byte) []byte {
+	var out []byte
+	var bits, nbits uint32
+	width := uint32(9)
+	emit := func(code int) {
+		bits = bits<<width | uint32(code)
+		nbits += width
+		for nbits >= 8 {
+			out = append(out, byte(bits>>(nbits-8)))
+			nbits -= 8
+		}
+	}
+
+	table := map[string]int{}
+	reset := func() {
+		table = map[string]int{}
+		for i := 0; i < 256; i++ {
+			table[string([]byte{byte(i)})] = i
+		}
+		width = 9
+	}
+
+	emit(256)
+	reset()
+	next := 258
+	w := ""
+	for _, c := range b {
+		wc := w + string([]byte{c})
+		if _, ok := table[wc]; ok {
+			w = wc
+			continue
+		}
+		emit(table[w])
+		table[wc] = next
+		next++
+		if next >= 1<<width && width < 12 {
+			width++
+		}
+		if next == 4094 {
+			emit(256)
+			reset()
+			next = 258
+		}
+		w = string([]byte{c})
+	}
+	emit(table[w])
+	emit(257)
+	if nbits > 0 {
+		out = append(out, byte(bits<<(8-nbits)))
+	}
+
+	return out
+}
+
+func TestAddGeoTIFF(t *testing.T) {
+	// rotated 90 degrees: east along the rows, north along the columns
+	b := tiffFile(tiffStripOffsets, [][]byte{{1, 2, 3, 4}},
+		tiffTag{tiffImageWidth, []uint16{2}},
+		tiffTag{tiffImageLength, []uint16{2}},
+		tiffTag{tiffBitsPerSample, []uint16{8}},
+		tiffTag{tiffPhotometric, []uint16{1}},
+		tiffTag{tiffTransformation, []float64{0, 1, 0, -105, 1, 0, 0, 40, 0, 0, 0, 0, 0, 0, 0, 1}},
+		geographic,
+	)
+
+	k := NewKML("Imagery")
+	overlay, err := k.AddGeoTIFF("scan", bytes.NewReader(b), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.AddFeature(overlay)
+
+	if overlay.href != "files/scan.png" || len(k.assets) != 1 {
+		t.Errorf("image not added as an asset: %q %v", overlay.href, k.assets)
+	}
+	if overlay.quad == nil {
+		t.Errorf("rotated image placed by a LatLonBox")
+	}
+	if overlay.box != (Bounds{North: 42, South: 40, East: -103, West: -105}) {
+		t.Errorf("bounds = %+v", overlay.box)
+	}
+
+	out := k.Render()
+	if !strings.Contains(out, "<href>files/scan.png</href>") || !strings.Contains(out, "gx:LatLonQuad") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestReadGeoTIFFErrors(t *testing.T) {
+	image := []tiffTag{
+		{tiffImageWidth, []uint16{1}},
+		{tiffImageLength, []uint16{1}},
+		{tiffBitsPerSample, []uint16{8}},
+		{tiffPhotometric, []uint16{1}},
+	}
+	projected := tiffTag{tiffGeoKeys, []uint16{1, 1, 0, 2, geoModelType, 0, 1, 1, geoProjected, 0, 1, 32613}}
+	float := tiffTag{tiffSampleFormat, []uint16{3}}
+
+	for _, b := range [][]byte{
+		nil,
+		[]byte("not a tiff"),
+		[]byte("II+\x00\x08\x00\x00\x00"),
+		tiffFile(tiffStripOffsets, [][]byte{{0}}, image...), // not georeferenced
+		tiffFile(tiffStripOffsets, [][]byte{{0}}, append(image, tiffTag{tiffTiepoint, []float64{0, 0, 0, 500000, 4400000, 0}}, tiffTag{tiffPixelScale, []float64{1, 1, 0}}, projected)...),
+		tiffFile(tiffStripOffsets, [][]byte{{0}}, append(image, float)...),
+		tiffFile(tiffStripOffsets, [][]byte{{0}}, append(image, tiffTag{tiffCompression, []uint16{7}})...),
+	} {
+		if _, err := ReadGeoTIFF(bytes.NewReader(b)); err == nil {
+			t.Errorf("no error for %q", b)
+		}
+	}
+}
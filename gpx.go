@@ -0,0 +1,150 @@
+package gokml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// FromGPX converts a GPX 1.0 or 1.1 file, as saved by most GPS devices, into
+// a KML document.  Waypoints become Placemarks with Points in a Waypoints
+// Folder, routes become LineStrings in a Routes Folder, and tracks become
+// gx:Tracks in a Tracks Folder, keeping the time and elevation of each
+// point.  Tracks without times become LineStrings, and tracks with several
+// segments become MultiGeometries.  Points with invalid coordinates are
+// dropped.  The bytes read are reported to progress, if it isn't nil.
+func FromGPX(r io.Reader, progress Progress) (*KML, error) {
+	d := xml.NewDecoder(readProgress(r, progress))
+
+	var root *node
+	for root == nil {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("gokml: not a GPX file")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "gpx" {
+			return nil, fmt.Errorf("gokml: not a GPX file (root element is %s)", start.Name.Local)
+		}
+
+		if root, err = readNode(d, start); err != nil {
+			return nil, err
+		}
+	}
+
+	name := root.childText("metadata", "name") // GPX 1.1
+	if len(name) == 0 {
+		name = root.childText("name") // GPX 1.0
+	}
+
+	k := NewKML(name)
+	waypoints := NewFolder("Waypoints", "")
+	routes := NewFolder("Routes", "")
+	tracks := NewFolder("Tracks", "")
+
+	for _, c := range root.children {
+		switch c.name.Local {
+		case "wpt":
+			if p := gpxPoint(c); p != nil {
+				pm := gpxPlacemark(c, p)
+				if when, err := parseTime(c.childText("time")); err == nil {
+					pm.SetTime(when, when)
+				}
+				waypoints.AddFeature(pm)
+			}
+		case "rte":
+			ls := NewLineString()
+			for _, pt := range c.children {
+				if pt.name.Local == "rtept" {
+					ls.AddPoint(gpxPoint(pt))
+				}
+			}
+			routes.AddFeature(gpxPlacemark(c, ls))
+		case "trk":
+			tracks.AddFeature(gpxPlacemark(c, gpxTrack(c)))
+		}
+	}
+
+	for _, f := range []*Folder{waypoints, routes, tracks} {
+		if len(f.features) > 0 {
+			k.AddFeature(f)
+		}
+	}
+
+	return k, nil
+}
+
+// gpxPlacemark returns a Placemark for a waypoint, route, or track, with its
+// name and description.
+func gpxPlacemark(n *node, geom renderable) *Placemark {
+	desc := n.childText("desc")
+	if len(desc) == 0 {
+		desc = n.childText("cmt")
+	}
+
+	pm := NewPlacemark(n.childText("name"), "", geom)
+	pm.description, pm.html = desc, hasMarkup(desc)
+
+	return pm
+}
+
+// gpxTrack returns the geometry of a track: a Track, or a LineString if any
+// point has no time, or a MultiGeometry of them for several segments.
+func gpxTrack(n *node) renderable {
+	mg := NewMultiGeometry()
+
+	for _, seg := range n.children {
+		if seg.name.Local != "trkseg" {
+			continue
+		}
+
+		track := NewTrack()
+		ls := NewLineString()
+		timed := true
+
+		for _, pt := range seg.children {
+			if pt.name.Local != "trkpt" {
+				continue
+			}
+
+			p := gpxPoint(pt)
+			when, err := parseTime(pt.childText("time"))
+			timed = timed && err == nil
+
+			track.AddPoint(when, p)
+			ls.AddPoint(p)
+		}
+
+		if timed {
+			mg.AddGeometry(track)
+		} else {
+			mg.AddGeometry(ls)
+		}
+	}
+
+	if len(mg.geometries) == 1 {
+		return mg.geometries[0]
+	}
+
+	return mg
+}
+
+// gpxPoint returns the Point of a wpt, rtept, or trkpt element, or nil if
+// its coordinates are invalid.
+func gpxPoint(n *node) *Point {
+	lat, latErr := strconv.ParseFloat(n.attr("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(n.attr("lon"), 64)
+	if latErr != nil || lonErr != nil {
+		return nil
+	}
+
+	return NewPoint(lat, lon, n.childFloat(0, "ele"))
+}
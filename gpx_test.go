@@ -0,0 +1,98 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const gpxFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+	<metadata><name>Saturday Ride</name></metadata>
+	<wpt lat="40.01" lon="-105.27"><ele>1655</ele><time>2024-06-01T08:00:00Z</time><name>Start</name><desc>Parking lot</desc></wpt>
+	<wpt lat="95" lon="0"><name>Bad</name></wpt>
+	<rte><name>Plan</name><rtept lat="40.0" lon="-105.0"/><rtept lat="40.1" lon="-105.1"/></rte>
+	<trk>
+		<name>Ride</name>
+		<trkseg>
+			<trkpt lat="40.01" lon="-105.27"><ele>1655.5</ele><time>2024-06-01T08:00:00Z</time></trkpt>
+			<trkpt lat="40.02" lon="-105.28"><ele>1660</ele><time>2024-06-01T08:01:00Z</time></trkpt>
+		</trkseg>
+	</trk>
+	<trk>
+		<name>Untimed</name>
+		<trkseg><trkpt lat="40.0" lon="-105.0"/><trkpt lat="40.1" lon="-105.1"/></trkseg>
+		<trkseg><trkpt lat="41.0" lon="-105.0"/><trkpt lat="41.1" lon="-105.1"/></trkseg>
+	</trk>
+</gpx>`
+
+func TestFromGPX(t *testing.T) {
+	k, err := FromGPX(strings.NewReader(gpxFixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k.rootFolder.name != "Saturday Ride" {
+		t.Errorf("document name = %q", k.rootFolder.name)
+	}
+
+	waypoints := k.FindFolders("Waypoints")
+	if len(waypoints) != 1 || len(waypoints[0].features) != 1 {
+		t.Fatalf("unexpected waypoints %v", waypoints)
+	}
+
+	start := waypoints[0].features[0].(*Placemark)
+	if start.name != "Start" || start.description != "Parking lot" || start.geometry.(*Point).Alt != 1655 {
+		t.Errorf("unexpected waypoint %+v", start)
+	}
+	if !start.hasTime || !start.beginTime.Equal(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("waypoint time not set: %v", start.beginTime)
+	}
+
+	tracks := k.FindFolders("Tracks")[0].features
+	ride := tracks[0].(*Placemark).geometry.(*Track)
+	if len(ride.points) != 2 || ride.points[0].Alt != 1655.5 || ride.times[1].Minute() != 1 {
+		t.Errorf("unexpected track %v %v", ride.points, ride.times)
+	}
+
+	untimed, ok := tracks[1].(*Placemark).geometry.(*MultiGeometry)
+	if !ok || len(untimed.geometries) != 2 {
+		t.Fatalf("segments not converted to a MultiGeometry: %v", tracks[1])
+	}
+	if _, ok := untimed.geometries[0].(*LineString); !ok {
+		t.Errorf("untimed segment is %T, want LineString", untimed.geometries[0])
+	}
+
+	out := unindent(k.Render())
+	for _, want := range []string{
+		`xmlns:gx=`,
+		"<gx:Track>\n<altitudeMode>clampToGround</altitudeMode>\n<when>2024-06-01T08:00:00Z</when>\n<when>2024-06-01T08:01:00Z</when>\n" +
+			"<gx:coord>-105.270000 40.010000 1655.500000</gx:coord>\n<gx:coord>-105.280000 40.020000 1660.000000</gx:coord>\n</gx:Track>",
+		"<name>Plan</name>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if err := k.Validate(); err != nil {
+		t.Errorf("converted document is invalid: %v", err)
+	}
+
+	// tracks survive a round trip through Parse
+	again, err := Parse(strings.NewReader(k.Render()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Render() != k.Render() {
+		t.Errorf("round trip differs:\n%s", again.Render())
+	}
+}
+
+func TestFromGPXErrors(t *testing.T) {
+	for _, doc := range []string{``, `<kml/>`, `<gpx><wpt lat="1" lon="2">`} {
+		if _, err := FromGPX(strings.NewReader(doc), nil); err == nil {
+			t.Errorf("no error for %q", doc)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package gokml
+
+import "sort"
+
+// ConvexHull returns the smallest convex Polygon holding the Points, such as
+// to outline the extent of a scattered dataset, in degrees of latitude and
+// longitude.  The ring is counterclockwise and its vertices are copies of
+// the outermost Points, with their altitudes.  Points across the
+// antimeridian are outlined the short way, as by Bounds.  Nil Points are
+// ignored, and nil is returned if the Points are all on one line.
+func ConvexHull(points []*Point) *Polygon {
+	var valid []*Point
+	var lons []float64
+	for _, p := range points {
+		if p != nil {
+			valid = append(valid, p)
+			lons = append(lons, p.Lon)
+		}
+	}
+	if len(valid) < 3 {
+		return nil
+	}
+
+	// unwrap longitudes across the antimeridian
+	west, east := lonSpan(lons)
+	unwrapped := make([]Point, len(valid))
+	for i, p := range valid {
+		unwrapped[i] = *p
+		if west > east && p.Lon < west {
+			unwrapped[i].Lon += 360
+		}
+	}
+
+	sort.Slice(unwrapped, func(i, j int) bool {
+		if unwrapped[i].Lon != unwrapped[j].Lon {
+			return unwrapped[i].Lon < unwrapped[j].Lon
+		}
+		return unwrapped[i].Lat < unwrapped[j].Lat
+	})
+
+	// cross is positive if o, a, b turn counterclockwise
+	cross := func(o, a, b Point) float64 {
+		return (a.Lon-o.Lon)*(b.Lat-o.Lat) - (a.Lat-o.Lat)*(b.Lon-o.Lon)
+	}
+
+	// Andrew's monotone chain: the lower hull west to east, then the upper
+	// hull back
+	hull := make([]Point, 0, 2*len(unwrapped))
+	for _, p := range unwrapped {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(unwrapped) - 2; i >= 0; i-- {
+		p := unwrapped[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	hull = hull[:len(hull)-1] // the first Point again
+
+	if len(hull) < 3 {
+		return nil
+	}
+
+	poly := NewPolygon()
+	for _, p := range hull {
+		poly.AddPoint(&Point{p.Lat, normalizeLon(p.Lon), p.Alt})
+	}
+
+	return poly
+}
@@ -0,0 +1,50 @@
+package gokml
+
+import "testing"
+
+func TestConvexHull(t *testing.T) {
+	points := []*Point{
+		NewPoint(0, 0, 1),
+		NewPoint(1, 1, 0),
+		NewPoint(0, 2, 2),
+		NewPoint(2, 2, 0),
+		nil,
+		NewPoint(2, 0, 0),
+		NewPoint(1, 0, 0), // on an edge
+		NewPoint(0.5, 1.5, 0),
+	}
+
+	hull := ConvexHull(points)
+	if hull == nil {
+		t.Fatal("no hull")
+	}
+
+	want := []Point{{0, 0, 1}, {0, 2, 2}, {2, 2, 0}, {2, 0, 0}}
+	if len(hull.points) != len(want) {
+		t.Fatalf("got %d points, want %d: %v", len(hull.points), len(want), hull.points)
+	}
+	for i, p := range hull.points {
+		if *p != want[i] {
+			t.Errorf("point %d = %v, want %v", i, *p, want[i])
+		}
+	}
+	if ringArea(hull.points) <= 0 {
+		t.Errorf("ring is clockwise")
+	}
+	if hull.points[0] == points[0] {
+		t.Errorf("points weren't copied")
+	}
+
+	// across the antimeridian
+	pacific := ConvexHull([]*Point{NewPoint(0, 179, 0), NewPoint(1, -179, 0), NewPoint(-1, -179, 0), NewPoint(0, -179.5, 0)})
+	if pacific == nil || len(pacific.points) != 3 {
+		t.Fatalf("unexpected pacific hull %v", pacific)
+	}
+	if b, _ := pacific.Bounds(); b != (Bounds{1, -1, -179, 179}) {
+		t.Errorf("pacific hull bounds = %v", b)
+	}
+
+	if ConvexHull([]*Point{NewPoint(0, 0, 0), NewPoint(1, 1, 0), NewPoint(2, 2, 0)}) != nil || ConvexHull(points[:2]) != nil {
+		t.Errorf("degenerate hulls weren't nil")
+	}
+}
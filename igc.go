@@ -0,0 +1,194 @@
+package gokml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// igcHeaders names the IGC header records kept as ExtendedData.
+var igcHeaders = map[string]string{
+	"PLT": "pilot",
+	"CM2": "crew",
+	"GTY": "gliderType",
+	"GID": "gliderID",
+	"CID": "competitionID",
+	"CCL": "competitionClass",
+	"SIT": "site",
+}
+
+// FromIGC converts an IGC flight log, as recorded by gliding and
+// paragliding flight instruments, into a KML document with a Placemark of
+// the flight as a gx:Track.  Altitudes are absolute, from the GPS altitude
+// of each fix, or from the pressure altitude where the recorder had no 3D
+// fix; the pressure altitudes are also kept as gx:SimpleArrayData named
+// pressureAltitude so Google Earth can chart them.  The pilot, glider,
+// competition, and date headers are kept as ExtendedData.  If wall is
+// true, the flight path is added as a second Placemark, a LineString
+// extruded down to the ground, so the flight can be seen from the side.
+// Invalid fixes are an error.  The bytes read are reported to progress, if
+// it isn't nil.
+func FromIGC(r io.Reader, wall bool, progress Progress) (*KML, error) {
+	scanner := bufio.NewScanner(readProgress(r, progress))
+
+	var date time.Time
+	var last time.Time
+	data := make(map[string]string)
+	track := NewTrack()
+	track.SetAltitudeMode(Absolute)
+	var pressure []float64
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+			if !strings.HasPrefix(text, "A") {
+				return nil, fmt.Errorf("gokml: not an IGC file")
+			}
+		}
+		if len(text) == 0 {
+			continue
+		}
+
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("gokml: IGC line %d: %s", line, fmt.Sprintf(format, args...))
+		}
+
+		switch text[0] {
+		case 'H':
+			if len(text) < 5 {
+				continue
+			}
+			code, value := text[2:5], text[5:]
+			if i := strings.IndexByte(value, ':'); i >= 0 {
+				value = value[i+1:]
+			}
+			value = strings.TrimSpace(value)
+
+			if code == "DTE" {
+				// DDMMYY, maybe followed by the flight number
+				if len(value) < 6 {
+					return nil, errorf("invalid date %q", value)
+				}
+				d, err := time.Parse("020106", value[:6])
+				if err != nil {
+					return nil, errorf("invalid date %q", value)
+				}
+				date = d
+				data["date"] = d.Format("2006-01-02")
+			} else if name, ok := igcHeaders[code]; ok && len(value) > 0 && !strings.EqualFold(value, "NIL") {
+				data[name] = value
+			}
+		case 'B':
+			if len(text) < 35 {
+				return nil, errorf("fix has %d characters, want 35", len(text))
+			}
+			if date.IsZero() {
+				return nil, errorf("fix before the date (HFDTE)")
+			}
+
+			clock, err := time.Parse("150405", text[1:7])
+			if err != nil {
+				return nil, errorf("invalid time %q", text[1:7])
+			}
+			when := time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+			for when.Before(last.Add(-12 * time.Hour)) {
+				when = when.Add(24 * time.Hour) // after midnight UTC
+			}
+			last = when
+
+			lat, latErr := parseIGCAngle(text[7:14], text[14], 'N', 'S')
+			lon, lonErr := parseIGCAngle(text[15:23], text[23], 'E', 'W')
+			pressureAlt, pErr := strconv.Atoi(text[25:30])
+			gpsAlt, gErr := strconv.Atoi(text[30:35])
+			if latErr != nil || lonErr != nil || pErr != nil || gErr != nil {
+				return nil, errorf("invalid fix %q", text)
+			}
+
+			alt := float64(gpsAlt)
+			if text[24] != 'A' || gpsAlt == 0 {
+				alt = float64(pressureAlt)
+			}
+
+			p := NewPoint(lat, lon, alt)
+			if p == nil {
+				return nil, errorf("invalid position %q", text[7:24])
+			}
+			track.AddPoint(when, p)
+			pressure = append(pressure, float64(pressureAlt))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	track.SetArrayData("pressureAltitude", pressure)
+
+	name := data["pilot"]
+	if len(name) == 0 {
+		name = "Flight"
+	}
+	if len(data["date"]) > 0 {
+		name += " " + data["date"]
+	}
+
+	k := NewKML(name)
+
+	trackStyle := NewStyle("igc-track", 0xff, 0xff, 0x80, 0x00)
+	trackStyle.SetIconScale(0)
+	k.AddFeature(trackStyle)
+	if wall {
+		wallStyle := NewStyle("igc-wall", 0x60, 0xff, 0x80, 0x00)
+		wallStyle.SetPolygonFill(true)
+		k.AddFeature(wallStyle)
+	}
+
+	pm := NewPlacemark(name, "", track)
+	pm.SetStyle("igc-track")
+	for _, key := range []string{"date", "pilot", "crew", "gliderType", "gliderID", "competitionID", "competitionClass", "site"} {
+		if v, ok := data[key]; ok {
+			pm.SetData(key, v)
+		}
+	}
+	k.AddFeature(pm)
+
+	if wall && len(track.points) > 1 {
+		path := NewLineString()
+		path.SetAltitudeMode(Absolute)
+		path.SetExtrude(true)
+		for _, p := range track.points {
+			cp := *p
+			path.AddPoint(&cp)
+		}
+
+		pm := NewPlacemark(name+" wall", "", path)
+		pm.SetStyle("igc-wall")
+		k.AddFeature(pm)
+	}
+
+	return k, nil
+}
+
+// parseIGCAngle parses a latitude or longitude of a fix, as degrees and
+// thousandths of minutes ("5110179" or "00102311"), with its hemisphere.
+func parseIGCAngle(s string, hemisphere byte, positive byte, negative byte) (float64, error) {
+	if hemisphere != positive && hemisphere != negative {
+		return 0, fmt.Errorf("invalid hemisphere %q", hemisphere)
+	}
+
+	deg, err1 := strconv.Atoi(s[:len(s)-5])
+	minutes, err2 := strconv.Atoi(s[len(s)-5:])
+	if err1 != nil || err2 != nil || minutes >= 60000 {
+		return 0, fmt.Errorf("invalid angle %q", s)
+	}
+
+	v := float64(deg) + float64(minutes)/60000
+	if hemisphere == negative {
+		v = -v
+	}
+
+	return v, nil
+}
@@ -0,0 +1,100 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const igcFixture = `AXCT7a2c9e1f0c1b4d37
+HFDTEDATE:310524,01
+HFPLTPILOTINCHARGE:Jane Doe
+HFGTYGLIDERTYPE:Ozone Enzo 3
+HFGIDGLIDERID:NIL
+HFCIDCOMPETITIONID:
+I013638FXA
+B2359584607123N00759822EA0150001580004
+B2359594607140N00759840EA0151501590004
+B0000034607180N00759900EV0153000000004
+LXCTtrack end
+GXCT1234567890
+`
+
+func TestFromIGC(t *testing.T) {
+	k, err := FromIGC(strings.NewReader(igcFixture), true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pms := k.FindPlacemarks(func(*Placemark) bool { return true })
+	if len(pms) != 2 {
+		t.Fatalf("got %d placemarks, want 2", len(pms))
+	}
+
+	pm := pms[0]
+	if pm.name != "Jane Doe 2024-05-31" {
+		t.Errorf("name = %q", pm.name)
+	}
+	for name, want := range map[string]string{"pilot": "Jane Doe", "gliderType": "Ozone Enzo 3", "date": "2024-05-31", "gliderID": "", "competitionID": ""} {
+		if v, _ := pm.Data(name); v != want {
+			t.Errorf("%s = %q, want %q", name, v, want)
+		}
+	}
+
+	track := pm.geometry.(*Track)
+	if len(track.points) != 3 || track.altitudeMode != Absolute {
+		t.Fatalf("unexpected track %v", track.points)
+	}
+	if p := track.points[0]; p.Lat != 46+7.123/60 || p.Lon != 7+59.822/60 || p.Alt != 1580 {
+		t.Errorf("unexpected first point %v", p)
+	}
+	if p := track.points[2]; p.Alt != 1530 {
+		t.Errorf("2D fix altitude = %v, want the pressure altitude", p.Alt)
+	}
+	if !track.times[2].Equal(time.Date(2024, 6, 1, 0, 0, 3, 0, time.UTC)) {
+		t.Errorf("time after midnight = %v", track.times[2])
+	}
+	if a := track.arrays; len(a) != 1 || a[0].name != "pressureAltitude" || a[0].values[1] != 1515 {
+		t.Errorf("unexpected arrays %v", a)
+	}
+
+	wall := pms[1].geometry.(*LineString)
+	if !wall.extrude || wall.altitudeMode != Absolute || len(wall.coordinates) != 3 {
+		t.Errorf("unexpected wall %v", wall)
+	}
+
+	out := k.Render()
+	if !strings.Contains(out, "<extrude>1</extrude>") {
+		t.Errorf("output has no extruded wall:\n%s", out)
+	}
+	if err := k.Validate(); err != nil {
+		t.Errorf("document is invalid: %v", err)
+	}
+
+	parsed, err := Parse(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if walls := parsed.FindPlacemarks(func(pm *Placemark) bool { return strings.HasSuffix(pm.name, "wall") }); len(walls) != 1 || !walls[0].geometry.(*LineString).extrude {
+		t.Errorf("extrude was not parsed")
+	}
+
+	k, err = FromIGC(strings.NewReader(igcFixture), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pms := k.FindPlacemarks(func(*Placemark) bool { return true }); len(pms) != 1 {
+		t.Errorf("got %d placemarks without a wall, want 1", len(pms))
+	}
+
+	for _, bad := range []string{
+		"HFDTE310524\nB2359584607123N00759822EA0150001580004\n",
+		"AXCT\nB2359584607123N00759822EA0150001580004\n",
+		"AXCT\nHFDTE310524\nB2359584607123X00759822EA0150001580004\n",
+		"AXCT\nHFDTE310524\nB23595846\n",
+	} {
+		if _, err := FromIGC(strings.NewReader(bad), false, nil); err == nil {
+			t.Errorf("no error for %q", bad)
+		}
+	}
+}
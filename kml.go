@@ -4,25 +4,35 @@
 package gokml
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
 type renderable interface {
-	render() string
+	render(e *encoder)
 }
 
 // KML represents the top-level KML document object.
 type KML struct {
 	rootFolder *Folder
+	assets     []*asset
+	options    renderOptions
+	mutex      *sync.Mutex
 }
 
 // NewKML returns a pointer to a KML struct.
 func NewKML(name string) *KML {
-	return &KML{NewFolder(name, "")}
+	return &KML{NewFolder(name, ""), nil, defaultRenderOptions(), new(sync.Mutex)}
 }
 
 // AddFeature adds a feature (Placemark, another folder, etc.) to
@@ -31,30 +41,346 @@ func (k *KML) AddFeature(feature renderable) {
 	k.rootFolder.AddFeature(feature)
 }
 
-// Renders the entire KML document.
+// FindByID returns the Folder, Placemark, or Style in the document with the
+// given id, or nil if there is none.  The id of a Style is its name.
+func (k *KML) FindByID(id string) renderable {
+	if len(id) == 0 {
+		return nil
+	}
+
+	return findByID(k.rootFolder, id)
+}
+
+// Renders the entire KML document.  Problems with the document are not
+// reported; use Write to find out about them.
 func (k *KML) Render() string {
-	ret := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
-		"<kml xmlns=\"http://www.opengis.net/kml/2.2\">\n"
+	buf := getBuffer()
+	defer putBuffer(buf)
+	k.write(buf)
+
+	return buf.String()
+}
+
+// RenderBytes is like Render, but returns the document as a byte slice, for
+// passing to HTTP responses and hash functions without a string copy.
+func (k *KML) RenderBytes() []byte {
+	var buf bytes.Buffer
+	k.write(&buf)
+
+	return buf.Bytes()
+}
+
+// Reader renders the entire KML document like Render and returns a reader
+// of it.
+func (k *KML) Reader() io.Reader {
+	return bytes.NewReader(k.RenderBytes())
+}
+
+// Write renders the entire KML document to w.  Unlike Render, it reports
+// problems that would produce broken KML, such as Placemarks that reference
+// a Style that isn't in the document or that have empty geometries (see
+// DocumentError), as well as write errors.  Nothing is written if the
+// document has problems.
+func (k *KML) Write(w io.Writer) error {
+	if err := k.check(); err != nil {
+		return err
+	}
+
+	return k.write(w)
+}
+
+// WriteGzip is like Write, but gzip-compresses the document as it is
+// rendered, for writing .kml.gz files or serving the document with
+// Content-Encoding: gzip.
+func (k *KML) WriteGzip(w io.Writer) error {
+	if err := k.check(); err != nil {
+		return err
+	}
+
+	z := gzip.NewWriter(w)
+
+	if err := k.write(z); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+// SetIndent sets the string used to indent nested elements when rendering.
+// The default is two spaces.  The indent may only contain spaces and tabs;
+// other values are ignored.
+func (k *KML) SetIndent(indent string) {
+	if strings.Trim(indent, " \t") == "" {
+		k.options.indent = indent
+	}
+}
+
+// SetNewline sets the line ending used when rendering: "\n" (the default),
+// "\r\n", "\r", or "" to render the whole document on a single line without
+// indentation.  Other values are ignored.
+func (k *KML) SetNewline(newline string) {
+	switch newline {
+	case "", "\n", "\r\n", "\r":
+		k.options.newline = newline
+	}
+}
+
+// SetPrecision sets the number of decimal places used to render latitudes
+// and longitudes, and altitudes.  The default is 6 for both (about 0.1 m of
+// latitude).  Valid values are between 0 and 15.  Invalid values are ignored.
+func (k *KML) SetPrecision(latLon int, alt int) {
+	if latLon >= 0 && latLon <= 15 {
+		k.options.latLonPrecision = latLon
+	}
+
+	if alt >= 0 && alt <= 15 {
+		k.options.altPrecision = alt
+	}
+}
+
+// SetMinify specifies whether to render the document as compactly as
+// possible: on a single line with no indentation, and with trailing zeros
+// trimmed from numbers.  This overrides SetIndent and SetNewline.
+func (k *KML) SetMinify(minify bool) {
+	k.options.minify = minify
+}
+
+// SetSorted specifies whether to render features in a deterministic order,
+// so renders of the same data diff cleanly regardless of the order it was
+// added in.  Within each Folder, Styles are rendered first, sorted by name,
+// followed by the other features sorted by id, or by name if they have no
+// id.  Features with the same id or name keep the order they were added in.
+func (k *KML) SetSorted(sorted bool) {
+	k.options.sorted = sorted
+}
+
+// WriteContext is like Write, but stops rendering and returns the context's
+// error once ctx is done, so rendering huge documents can be abandoned when,
+// for example, an HTTP request is cancelled or times out.  Partial output
+// may have been written when it returns.
+func (k *KML) WriteContext(ctx context.Context, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := k.check(); err != nil {
+		return err
+	}
+
+	return k.writeContext(ctx, w)
+}
 
-	ret += k.rootFolder.render()
+// SetParallelism sets the number of goroutines used to render the
+// document's features, for rendering huge documents on multi-core machines.
+// The features are rendered in chunks, so it helps most when the document
+// has many Folders or Placemarks at its top level.  The default is 1.
+// Values less than 1 are ignored.
+func (k *KML) SetParallelism(n int) {
+	if n >= 1 {
+		k.options.parallelism = n
+	}
+}
 
-	ret += "</kml>\n"
+// SetBidiIsolation specifies whether to wrap names containing right-to-left
+// text (Arabic, Hebrew, etc.) in Unicode isolation marks (U+2068 and
+// U+2069), so a right-to-left name doesn't reorder the left-to-right text
+// around it in Google Earth's Places panel and labels.
+func (k *KML) SetBidiIsolation(isolate bool) {
+	k.options.bidiIsolate = isolate
+}
+
+// SetStableOutput pins rendering to a documented, stable output form, so
+// the output for the same document stays byte-for-byte identical across
+// versions of this package.  Changes to how documents are rendered will only
+// apply when a later form is selected.  Form 0 (the default) uses the
+// current rendering and the layout options set on the document; other
+// unknown forms are ignored.
+//
+// StableOutput1 renders with two-space indentation, "\n" line endings, six
+// decimal places for all numbers, features sorted as by SetSorted, no bidi
+// isolation marks, and only the namespaces the document uses.  The layout
+// options of the document are ignored while a stable form is set.
+func (k *KML) SetStableOutput(form int) {
+	switch form {
+	case 0, StableOutput1:
+		k.options.stable = form
+	}
+}
+
+func (k *KML) write(w io.Writer) error {
+	return k.writeContext(context.Background(), w)
+}
+
+// writeContext renders the document, checking ctx for cancellation.
+func (k *KML) writeContext(ctx context.Context, w io.Writer) error {
+	opts := k.options.pinned()
+
+	e := newEncoder(w, opts)
+	e.ctx = ctx
+	e.header()
+	e.start("kml", namespaceAttrs(k.rootFolder)...)
+
+	features := k.rootFolder.renderStart(e, "Document", k.rootFolder.orderedFeatures(opts))
+	renderDocumentSchema(e, k.rootFolder)
+
+	if opts.parallelism > 1 {
+		renderParallel(e, features)
+	} else {
+		for _, feature := range features {
+			feature.render(e)
+		}
+	}
+
+	e.end("Document")
+
+	e.end("kml")
+	e.raw(e.opts.newline)
+
+	return e.flush()
+}
 
-	return ret
+// SetID sets the id attribute of the document, so it can be targeted by
+// NetworkLinkControl updates.
+func (k *KML) SetID(id string) {
+	k.rootFolder.SetID(id)
+}
+
+// SetDescription sets the description of the KML document.
+func (k *KML) SetDescription(desc string) {
+	k.rootFolder.description = desc
+	k.rootFolder.html = false
+}
+
+// SetDescriptionHTML sets the description of the KML document to HTML, which
+// is wrapped in CDATA so it renders in the balloon.
+func (k *KML) SetDescriptionHTML(html string) {
+	k.rootFolder.SetDescriptionHTML(html)
+}
+
+// SetOpen specifies whether the document is expanded in the Google Earth
+// Places panel when it is loaded.
+func (k *KML) SetOpen(open bool) {
+	k.rootFolder.SetOpen(open)
+}
+
+// SetLookAt sets the initial view of the document.  A nil LookAt removes the
+// initial view.
+func (k *KML) SetLookAt(lookAt *LookAt) {
+	k.rootFolder.SetLookAt(lookAt)
+}
+
+// SetAuthor sets the atom:author name of the KML document, for attribution
+// of published documents.
+func (k *KML) SetAuthor(name string) {
+	k.rootFolder.SetAuthor(name)
+}
+
+// SetLink sets the atom:link of the KML document, which should be the
+// canonical source URL of the document.
+func (k *KML) SetLink(href string) {
+	k.rootFolder.SetLink(href)
+}
+
+// tagList is the set of tags on a feature.
+type tagList []string
+
+func (tl tagList) add(tag string) tagList {
+	tag = strings.TrimSpace(tag)
+
+	if len(tag) == 0 || tl.has(tag) {
+		return tl
+	}
+
+	return append(tl, tag)
+}
+
+func (tl tagList) has(tag string) bool {
+	for _, t := range tl {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Folder represents a folder in the KML document.
 type Folder struct {
+	id          string
 	name        string
 	description string
+	html        bool
+	author      string
+	link        string
+	open        bool
+	lookAt      *LookAt
+	region      *Bounds
+	minLod      int
+	tags        tagList
 	features    []renderable
 	mutex       *sync.Mutex
+	extra       *extraXML
 }
 
 // Returns a pointer to a new Folder instance.
 func NewFolder(name string, desc string) *Folder {
 	f := make([]renderable, 0, 10)
-	return &Folder{name, desc, f, new(sync.Mutex)}
+	return &Folder{"", name, desc, false, "", "", false, nil, nil, 0, nil, f, new(sync.Mutex), nil}
+}
+
+// SetID sets the id attribute of the Folder, so it can be targeted later by
+// NetworkLinkControl updates.  See KML.FindByID.
+func (f *Folder) SetID(id string) {
+	f.id = strings.TrimSpace(id)
+}
+
+// SetDescriptionHTML sets the description of the Folder to HTML, which is
+// wrapped in CDATA so links, tables, and images render in the balloon.
+func (f *Folder) SetDescriptionHTML(html string) {
+	f.description = html
+	f.html = true
+}
+
+// SetOpen specifies whether the Folder is expanded in the Google Earth
+// Places panel when the document is loaded.
+func (f *Folder) SetOpen(open bool) {
+	f.open = open
+}
+
+// SetLookAt sets the view Google Earth flies to when the Folder is
+// double-clicked.  A nil LookAt removes the view.
+func (f *Folder) SetLookAt(lookAt *LookAt) {
+	f.lookAt = lookAt
+}
+
+// AddTag tags the Folder (and everything in it) for tag-based selection and
+// rendering.  See KML.ByTag and KML.RenderTagged.
+func (f *Folder) AddTag(tag string) {
+	f.tags = f.tags.add(tag)
+}
+
+// SetAuthor sets the atom:author name of the Folder.
+func (f *Folder) SetAuthor(name string) {
+	f.author = strings.TrimSpace(name)
+}
+
+// SetLink sets the atom:link of the Folder, the URL of the Folder's source.
+func (f *Folder) SetLink(href string) {
+	f.link = strings.TrimSpace(href)
+}
+
+// SetRegion limits the Folder to the given bounding rectangle, so Google
+// Earth only draws its features when that area is in view.
+func (f *Folder) SetRegion(b Bounds) {
+	f.region = &b
+}
+
+// SetMinLodPixels sets how many pixels across the Folder's Region must
+// appear on screen before Google Earth draws its features, so big folders
+// are only drawn when zoomed in.  It has no effect without a Region.  Values
+// less than one remove the limit.
+func (f *Folder) SetMinLodPixels(pixels int) {
+	f.minLod = maxInt(pixels, 0)
 }
 
 // AddFeature adds a feature (Placemark, another Folder, etc.) to
@@ -67,18 +393,115 @@ func (f *Folder) AddFeature(feature renderable) {
 	}
 }
 
-func (f *Folder) render() string {
-	ret := "<Folder>\n" +
-		fmt.Sprintf("<name>%s</name>\n", f.name) +
-		fmt.Sprintf("<description>%s</description>\n", f.description)
+func (f *Folder) render(e *encoder) {
+	f.renderAs(e, "Folder")
+}
 
-	for _, feature := range f.features {
-		ret += feature.render()
+// renderAs renders the Folder as the named container element (Folder or
+// Document).
+func (f *Folder) renderAs(e *encoder, element string) {
+	for _, feature := range f.renderStart(e, element, f.orderedFeatures(e.opts)) {
+		feature.render(e)
 	}
 
-	ret += "</Folder>\n"
+	e.end(element)
+}
+
+// renderStart opens the container element and renders the Folder's own
+// properties, along with the leading Styles of features, which go before
+// its Region.  It returns the other features, which are left to the caller.
+func (f *Folder) renderStart(e *encoder, element string, features []renderable) []renderable {
+	e.start(element, f.extra.startAttrs(idAttrs(f.id))...)
+	e.label(f.name)
+
+	w := f.extra.writer(e, element)
+	w.before("open")
+	if f.open {
+		e.element("open", "1")
+	}
+
+	w.before("atom:author")
+	if len(f.author) > 0 {
+		e.start("atom:author")
+		e.element("atom:name", f.author)
+		e.end("atom:author")
+	}
+
+	w.before("atom:link")
+	if len(f.link) > 0 {
+		e.start("atom:link", attr("href", f.link))
+		e.end("atom:link")
+	}
+
+	w.before("description")
+	e.description(f.description, f.html)
+
+	w.before("LookAt")
+	if f.lookAt != nil {
+		f.lookAt.render(e)
+	}
+
+	w.before("Region")
+	styles := 0
+	for styles < len(features) {
+		if _, ok := features[styles].(*Style); !ok {
+			break
+		}
+		features[styles].render(e)
+		styles++
+	}
+	renderRegion(e, f.region, f.minLod)
+
+	w.rest()
+
+	return features[styles:]
+}
+
+// orderedFeatures returns the Folder's features in the order they should be
+// rendered.
+func (f *Folder) orderedFeatures(opts renderOptions) []renderable {
+	if !opts.sorted {
+		return f.features
+	}
+
+	sorted := make([]renderable, len(f.features))
+	copy(sorted, f.features)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ri, ki := sortKey(sorted[i])
+		rj, kj := sortKey(sorted[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return ki < kj
+	})
 
-	return ret
+	return sorted
+}
+
+// sortKey returns the rank (Styles before other features) and the id or
+// name that features are sorted by.
+func sortKey(feature renderable) (int, string) {
+	switch f := feature.(type) {
+	case *Style:
+		return 0, f.name
+	case *Folder:
+		if len(f.id) > 0 {
+			return 1, f.id
+		}
+		return 1, f.name
+	case *Placemark:
+		if len(f.id) > 0 {
+			return 1, f.id
+		}
+		return 1, f.name
+	case *NetworkLink:
+		return 1, f.name
+	case *GroundOverlay:
+		return 1, f.name
+	}
+
+	return 1, ""
 }
 
 // Style represents a style used for a geometry object (point, line,
@@ -91,14 +514,16 @@ type Style struct {
 	blue      uint8
 	iconURL   string
 	iconScale float64
+	heading   float64
 	fill      int8
+	extra     *extraXML
 }
 
 // NewStyle returns a new instance of a Style.  The alpha, red, green, and
 // blue color properties are applied to point icon color as well as line and
 // polygon color.  Name must be a single word (no spaces).
 func NewStyle(name string, alpha uint8, red uint8, green uint8, blue uint8) *Style {
-	return &Style{name, alpha, red, green, blue, "http://maps.google.com/mapfiles/kml/pushpin/ylw-pushpin.png", 1.1, 1}
+	return &Style{name, alpha, red, green, blue, "http://maps.google.com/mapfiles/kml/pushpin/ylw-pushpin.png", 1.1, 0, 1, nil}
 }
 
 // SetIconURL changes the icon that will be used for point placemarks.
@@ -120,6 +545,15 @@ func (s *Style) SetIconScale(scale float64) {
 	}
 }
 
+// SetIconHeading rotates the icon of point placemarks to a heading, in
+// degrees clockwise from north, such as the direction a vehicle is moving.
+// Valid values are between 0.0 and 360.0.  Invalid values are ignored.
+func (s *Style) SetIconHeading(heading float64) {
+	if heading >= 0.0 && heading <= 360.0 {
+		s.heading = heading
+	}
+}
+
 // SetPolygonFill specifies whether to fill in polygons.  The default is to
 // not fill in the polygon.
 func (s *Style) SetPolygonFill(fill bool) {
@@ -130,27 +564,44 @@ func (s *Style) SetPolygonFill(fill bool) {
 	}
 }
 
-func (s *Style) render() string {
-	colorStr := fmt.Sprintf("<color>%02x%02x%02x%02x</color>\n", s.alpha, s.blue, s.green, s.red) // yes, ABGR
-	ret := fmt.Sprintf("<Style id=\"%s\">\n", s.name) +
-		"<IconStyle>\n" +
-		colorStr +
-		fmt.Sprintf("<scale>%f</scale>\n", s.iconScale) +
-		fmt.Sprintf("<Icon><href>%s</href></Icon>\n", s.iconURL) +
-		"</IconStyle>\n" +
-		"<LineStyle>\n" +
-		colorStr +
-		"<width>3</width>\n" +
-		"</LineStyle>\n" +
-		"<PolyStyle>\n" +
-		colorStr +
-		"<colorMode>normal</colorMode>\n" +
-		fmt.Sprintf("<fill>%d</fill>\n", s.fill) +
-		"<outline>1</outline>\n" +
-		"</PolyStyle>\n" +
-		"</Style>\n"
+func (s *Style) render(e *encoder) {
+	color := colorString(s.alpha, s.red, s.green, s.blue)
+
+	e.start("Style", s.extra.startAttrs([]xml.Attr{attr("id", s.name)})...)
+
+	e.start("IconStyle")
+	e.element("color", color)
+	e.float("scale", s.iconScale)
+	if s.heading != 0 {
+		e.float("heading", s.heading)
+	}
+	e.start("Icon")
+	e.element("href", s.iconURL)
+	e.end("Icon")
+	e.end("IconStyle")
+
+	w := s.extra.writer(e, "Style")
+	w.before("LineStyle")
+	e.start("LineStyle")
+	e.element("color", color)
+	e.int("width", 3)
+	e.end("LineStyle")
+
+	w.before("PolyStyle")
+	e.start("PolyStyle")
+	e.element("color", color)
+	e.element("colorMode", "normal")
+	e.int("fill", int(s.fill))
+	e.int("outline", 1)
+	e.end("PolyStyle")
 
-	return ret
+	w.rest()
+	e.end("Style")
+}
+
+// colorString returns a KML color string.  Yes, KML colors are ABGR.
+func colorString(alpha uint8, red uint8, green uint8, blue uint8) string {
+	return fmt.Sprintf("%02x%02x%02x%02x", alpha, blue, green, red)
 }
 
 // Point represents a point on the Earth
@@ -187,26 +638,79 @@ func NewPoint(lat float64, lon float64, alt float64) *Point {
 	return &Point{lat, lon, alt}
 }
 
-func (p *Point) render() string {
-	ret := "<Point>\n" +
-		"<extrude>0</extrude>\n" +
-		"<altitudeMode>clampToGround</altitudeMode>\n" +
-		fmt.Sprintf("<coordinates>%f,%f,%f</coordinates>\n", p.Lon, p.Lat, p.Alt) +
-		"</Point>\n"
+func (p *Point) render(e *encoder) {
+	e.start("Point")
+	e.int("extrude", 0)
+	e.element("altitudeMode", "clampToGround")
+	e.coordinates([]*Point{p}, true)
+	e.end("Point")
+}
+
+// LookAt represents a virtual camera looking at a Point on the Earth.
+type LookAt struct {
+	point   *Point
+	heading float64
+	tilt    float64
+	rng     float64
+}
+
+// NewLookAt returns a pointer to a new LookAt instance viewing point from
+// rng meters away.  Heading is the direction of the camera in degrees from
+// north and tilt is the angle of the camera in degrees from straight down
+// (0.0 to 90.0).  Returns nil if the point is nil or the tilt or range is
+// invalid.
+func NewLookAt(point *Point, heading float64, tilt float64, rng float64) *LookAt {
+	if point == nil {
+		return nil
+	}
+
+	if math.IsNaN(tilt) || tilt < 0.0 || tilt > 90.0 {
+		return nil
+	}
+
+	if math.IsNaN(rng) || math.IsInf(rng, 0) || rng < 0.0 {
+		return nil
+	}
+
+	if math.IsNaN(heading) || math.IsInf(heading, 0) {
+		heading = 0.0
+	}
+
+	return &LookAt{point, heading, tilt, rng}
+}
 
-	return ret
+func (la *LookAt) render(e *encoder) {
+	e.start("LookAt")
+	e.latLon("longitude", la.point.Lon)
+	e.latLon("latitude", la.point.Lat)
+	e.altitude("altitude", la.point.Alt)
+	e.float("heading", la.heading)
+	e.float("tilt", la.tilt)
+	e.float("range", la.rng)
+	e.end("LookAt")
 }
 
+// Altitude modes, which say how the altitudes of a geometry are measured.
+const (
+	ClampToGround    = "clampToGround"    // altitudes are ignored
+	RelativeToGround = "relativeToGround" // above the terrain
+	Absolute         = "absolute"         // above sea level
+)
+
 // LineString represents a series of lines in a KML document.
 type LineString struct {
-	coordinates []*Point
-	mutex       *sync.Mutex
+	coordinates  []*Point
+	drawOrder    int
+	hasDrawOrder bool
+	altitudeMode string
+	extrude      bool
+	mutex        *sync.Mutex
 }
 
 // NewLineString returns a new instance of LineString.
 func NewLineString() *LineString {
 	ls := make([]*Point, 0, 10)
-	return &LineString{ls, new(sync.Mutex)}
+	return &LineString{ls, 0, false, ClampToGround, false, new(sync.Mutex)}
 }
 
 // Adds a Point to the LineString.  In order to render, the LineString
@@ -219,38 +723,69 @@ func (ls *LineString) AddPoint(point *Point) {
 	}
 }
 
-func (ls *LineString) render() string {
-	if len(ls.coordinates) < 2 {
-		return ""
+// SetDrawOrder sets the z-order of the LineString relative to other
+// overlapping lines (gx:drawOrder).  Lines with a higher draw order are drawn
+// on top of lines with a lower draw order.
+func (ls *LineString) SetDrawOrder(order int) {
+	ls.drawOrder = order
+	ls.hasDrawOrder = true
+}
+
+// SetAltitudeMode sets how the altitudes of the LineString's Points are
+// measured: ClampToGround (the default), RelativeToGround, or Absolute.
+// Invalid values are ignored.
+func (ls *LineString) SetAltitudeMode(mode string) {
+	switch mode {
+	case ClampToGround, RelativeToGround, Absolute:
+		ls.altitudeMode = mode
 	}
+}
 
-	ret := "<LineString>\n" +
-		"<extrude>0</extrude>\n" +
-		"<tessellate>1</tessellate>\n" +
-		"<altitudeMode>clampToGround</altitudeMode>\n" +
-		"<coordinates>\n"
+// SetExtrude sets whether the LineString is connected to the ground by a
+// wall, drawn with the polygon color of its Style.  Walls are only drawn
+// for lines above the ground, so the altitude mode shouldn't be
+// ClampToGround.
+func (ls *LineString) SetExtrude(extrude bool) {
+	ls.extrude = extrude
+}
+
+func (ls *LineString) render(e *encoder) {
+	if len(ls.coordinates) < 2 {
+		return
+	}
 
-	for _, coord := range ls.coordinates {
-		ret += fmt.Sprintf("%f,%f,%f\n", coord.Lon, coord.Lat, coord.Alt)
+	extrude := 0
+	if ls.extrude {
+		extrude = 1
 	}
 
-	ret += "</coordinates>\n" +
-		"</LineString>\n"
+	e.start("LineString")
+	e.int("extrude", extrude)
+	e.int("tessellate", 1)
+	e.element("altitudeMode", ls.altitudeMode)
+	e.coordinates(ls.coordinates, true)
+
+	// gx:drawOrder follows the coordinates in the schema
+	if ls.hasDrawOrder {
+		e.int("gx:drawOrder", ls.drawOrder)
+	}
 
-	return ret
+	e.end("LineString")
 }
 
 // Polygon represents a polygon in the KML document.  Must be added to a
 // Placemark in order to render.
 type Polygon struct {
-	points []*Point
-	mutex  *sync.Mutex
+	points       []*Point
+	inner        [][]*Point // holes
+	altitudeMode string
+	mutex        *sync.Mutex
 }
 
 // NewPolygon returns a new instance of Polygon.
 func NewPolygon() *Polygon {
 	p := make([]*Point, 0, 4)
-	return &Polygon{p, new(sync.Mutex)}
+	return &Polygon{p, nil, ClampToGround, new(sync.Mutex)}
 }
 
 // AddPoint add a point (vertex) to the Polygon instance.  The Polygon will
@@ -266,9 +801,40 @@ func (poly *Polygon) AddPoint(point *Point) {
 	}
 }
 
-func (poly *Polygon) render() string {
+// AddInnerBoundary adds a hole to the Polygon, bounded by the points.  Like
+// the outer boundary, the ring is closed automatically.  Points that are nil
+// are ignored, and holes with fewer than three Points aren't added.
+func (poly *Polygon) AddInnerBoundary(points ...*Point) {
+	ring := make([]*Point, 0, len(points)+1)
+	for _, p := range points {
+		if p != nil {
+			ring = append(ring, p)
+		}
+	}
+
+	if len(ring) < 3 {
+		return
+	}
+
+	poly.mutex.Lock()
+	poly.inner = append(poly.inner, closeRing(ring))
+	poly.mutex.Unlock()
+}
+
+// SetAltitudeMode sets how the altitudes of the Polygon's Points are
+// measured: ClampToGround (the default), RelativeToGround, or Absolute.
+// Polygons above the ground are extruded, with walls down to the ground.
+// Invalid values are ignored.
+func (poly *Polygon) SetAltitudeMode(mode string) {
+	switch mode {
+	case ClampToGround, RelativeToGround, Absolute:
+		poly.altitudeMode = mode
+	}
+}
+
+func (poly *Polygon) render(e *encoder) {
 	if len(poly.points) == 0 {
-		return ""
+		return
 	}
 
 	firstPoint := poly.points[0]
@@ -278,43 +844,301 @@ func (poly *Polygon) render() string {
 		poly.AddPoint(firstPoint) // close the polygon
 	}
 
-	ret := "<Polygon>\n" +
-		"<extrude>1</extrude>\n" +
-		"<altitudeMode>clampToGround</altitudeMode>\n" +
-		"<outerBoundaryIs>\n" +
-		"<LinearRing>\n" +
-		"<coordinates>\n"
+	e.start("Polygon")
+	e.int("extrude", 1)
+	e.element("altitudeMode", poly.altitudeMode)
+	e.start("outerBoundaryIs")
+	e.start("LinearRing")
+	e.coordinates(poly.points, true)
+	e.end("LinearRing")
+	e.end("outerBoundaryIs")
+
+	for _, ring := range poly.inner {
+		e.start("innerBoundaryIs")
+		e.start("LinearRing")
+		e.coordinates(ring, true)
+		e.end("LinearRing")
+		e.end("innerBoundaryIs")
+	}
+
+	e.end("Polygon")
+}
+
+// closeRing returns the ring with its first Point repeated at the end, if it
+// isn't already.
+func closeRing(ring []*Point) []*Point {
+	if len(ring) > 0 && *ring[0] != *ring[len(ring)-1] {
+		ring = append(ring, ring[0])
+	}
+
+	return ring
+}
+
+// MultiGeometry represents several geometries (Points, LineStrings,
+// Polygons, or other MultiGeometries) that belong to a single Placemark,
+// such as the islands of a country.
+type MultiGeometry struct {
+	geometries []renderable
+	mutex      *sync.Mutex
+}
+
+// NewMultiGeometry returns a new, empty instance of MultiGeometry.
+func NewMultiGeometry() *MultiGeometry {
+	return &MultiGeometry{nil, new(sync.Mutex)}
+}
+
+// AddGeometry adds a geometry to the MultiGeometry.  Geometries that are nil
+// are ignored.
+func (mg *MultiGeometry) AddGeometry(geom renderable) {
+	if geom != nil {
+		mg.mutex.Lock()
+		mg.geometries = append(mg.geometries, geom)
+		mg.mutex.Unlock()
+	}
+}
+
+func (mg *MultiGeometry) render(e *encoder) {
+	if len(mg.geometries) == 0 {
+		return
+	}
+
+	e.start("MultiGeometry")
+	for _, geom := range mg.geometries {
+		geom.render(e)
+	}
+	e.end("MultiGeometry")
+}
+
+// Track represents a path with a time at each Point (gx:Track), such as a
+// recorded GPS track.  Google Earth can animate it with the time slider.
+type Track struct {
+	times        []time.Time
+	points       []*Point
+	arrays       []trackArray
+	altitudeMode string
+	mutex        *sync.Mutex
+}
+
+// trackArray is a named value for each point of a Track.
+type trackArray struct {
+	name   string
+	values []float64
+}
+
+// NewTrack returns a new, empty instance of Track.
+func NewTrack() *Track {
+	return &Track{nil, nil, nil, ClampToGround, new(sync.Mutex)}
+}
+
+// AddPoint adds a Point and the time it was reached to the Track.  Points
+// should be added in time order.  Points that are nil are ignored.
+func (t *Track) AddPoint(when time.Time, point *Point) {
+	if point != nil {
+		t.mutex.Lock()
+		t.times = append(t.times, when)
+		t.points = append(t.points, point)
+		t.mutex.Unlock()
+	}
+}
+
+// SetAltitudeMode sets how the altitudes of the Track's Points are measured:
+// ClampToGround (the default), RelativeToGround, or Absolute.  Invalid
+// values are ignored.
+func (t *Track) SetAltitudeMode(mode string) {
+	switch mode {
+	case ClampToGround, RelativeToGround, Absolute:
+		t.altitudeMode = mode
+	}
+}
+
+// trackSchemaID is the id of the Schema declaring the array data of Tracks.
+const trackSchemaID = "TrackData"
 
-	for _, point := range poly.points {
-		ret += fmt.Sprintf("%f,%f,%f\n", point.Lon, point.Lat, point.Alt)
+// renderDocumentSchema writes the Schema of the Track data of a document's
+// root folder, which goes before its features, unless a parsed document
+// kept its own.
+func renderDocumentSchema(e *encoder, root *Folder) {
+	if _, kept := keptTrackSchema(root); !kept {
+		renderTrackSchema(e, trackArrayNames(root))
 	}
+}
 
-	ret += "</coordinates>\n" +
-		"</LinearRing>\n" +
-		"</outerBoundaryIs>\n" +
-		"</Polygon>\n"
+// keptTrackSchema returns the names declared by the Schema of Track data
+// kept from a parsed document, or false if there isn't one.
+func keptTrackSchema(f *Folder) ([]string, bool) {
+	if f.extra == nil {
+		return nil, false
+	}
+
+	for _, n := range f.extra.nodes {
+		if n.name.Local == "Schema" && n.attr("id") == trackSchemaID {
+			var names []string
+			for _, c := range n.children {
+				if c.name.Local == "SimpleArrayField" {
+					names = append(names, c.attr("name"))
+				}
+			}
+			return names, true
+		}
+	}
 
-	return ret
+	return nil, false
+}
+
+// trackArrayNames returns the names of the array data of the Tracks in the
+// feature tree, in the order they're first used.
+func trackArrayNames(feature renderable) []string {
+	var names []string
+	seen := make(map[string]bool)
+	walkPlacemarks(feature, func(pm *Placemark) {
+		walkTracks(pm.geometry, func(t *Track) {
+			for _, a := range t.arrays {
+				if !seen[a.name] {
+					seen[a.name] = true
+					names = append(names, a.name)
+				}
+			}
+		})
+	})
+
+	return names
+}
+
+// renderTrackSchema writes the Schema declaring the array data names used by
+// Tracks, if there are any.
+func renderTrackSchema(e *encoder, names []string) {
+	if len(names) == 0 {
+		return
+	}
+
+	e.start("Schema", attr("id", trackSchemaID))
+	for _, name := range names {
+		e.start("gx:SimpleArrayField", attr("name", name), attr("type", "float"))
+		e.element("displayName", name)
+		e.end("gx:SimpleArrayField")
+	}
+	e.end("Schema")
+}
+
+// walkTracks calls fn for every Track in geom.
+func walkTracks(geom renderable, fn func(*Track)) {
+	switch g := geom.(type) {
+	case *Track:
+		fn(g)
+	case *MultiGeometry:
+		for _, child := range g.geometries {
+			walkTracks(child, fn)
+		}
+	}
+}
+
+// SetArrayData sets a named value for each point of the Track, such as the
+// heart rate or cadence of a workout (gx:SimpleArrayData), replacing any
+// existing values with the same name.  Google Earth charts them in the
+// elevation profile.  values should have a value for each point, in order;
+// NaN values are left empty.  Empty names are ignored.
+func (t *Track) SetArrayData(name string, values []float64) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i := range t.arrays {
+		if t.arrays[i].name == name {
+			t.arrays[i].values = values
+			return
+		}
+	}
+
+	t.arrays = append(t.arrays, trackArray{name, values})
+}
+
+func (t *Track) render(e *encoder) {
+	if len(t.points) == 0 {
+		return
+	}
+
+	e.start("gx:Track")
+	e.element("altitudeMode", t.altitudeMode)
+
+	for _, when := range t.times {
+		e.element("when", when.Format(time.RFC3339))
+	}
+
+	var b []byte
+	for _, p := range t.points {
+		b = e.appendFloat(b[:0], p.Lon, e.opts.latLonPrecision)
+		b = append(b, ' ')
+		b = e.appendFloat(b, p.Lat, e.opts.latLonPrecision)
+		b = append(b, ' ')
+		b = e.appendFloat(b, p.Alt, e.opts.altPrecision)
+		e.element("gx:coord", string(b))
+	}
+
+	if len(t.arrays) > 0 {
+		e.start("ExtendedData")
+		e.start("SchemaData", attr("schemaUrl", "#"+trackSchemaID))
+		for _, a := range t.arrays {
+			e.start("gx:SimpleArrayData", attr("name", a.name))
+			for _, v := range a.values {
+				if math.IsNaN(v) {
+					e.element("gx:value", "")
+				} else {
+					e.element("gx:value", strconv.FormatFloat(v, 'f', -1, 64))
+				}
+			}
+			e.end("gx:SimpleArrayData")
+		}
+		e.end("SchemaData")
+		e.end("ExtendedData")
+	}
+
+	e.end("gx:Track")
 }
 
 // Placemark represents a placemark in the KML document.  All geometry
 // objects (points, lines, polygons, etc.) must be within a Placemark
 // instance.
 type Placemark struct {
+	id          string
 	name        string
 	description string
+	html        bool
 	geometry    renderable
 	style       string
+	styleURL    string // a style in another file
 	beginTime   time.Time
 	endTime     time.Time
 	hasTime     bool
+	hidden      bool
+	balloon     bool
+	tags        tagList
+	data        []dataField
+	links       []sourceLink
+	extra       *extraXML
 }
 
 // NewPlacemark returns a pointer to a new Placemark instance.  It takes a
 // name, description, and a geometry object (Point, Polygon, etc.) as
 // parameters.
 func NewPlacemark(name string, desc string, geom renderable) *Placemark {
-	return &Placemark{name, desc, geom, "", time.Now(), time.Now(), false}
+	return &Placemark{"", name, desc, false, geom, "", "", time.Now(), time.Now(), false, false, false, nil, nil, nil, nil}
+}
+
+// SetID sets the id attribute of the Placemark, so it can be targeted later
+// by NetworkLinkControl updates.  See KML.FindByID.
+func (pm *Placemark) SetID(id string) {
+	pm.id = strings.TrimSpace(id)
+}
+
+// SetDescriptionHTML sets the description of the Placemark to HTML, which is
+// wrapped in CDATA so links, tables, and images render in the balloon.
+func (pm *Placemark) SetDescriptionHTML(html string) {
+	pm.description = html
+	pm.html = true
 }
 
 // SetStyle sets the style of the Placemark to the specified name.  The KML
@@ -323,7 +1147,17 @@ func (pm *Placemark) SetStyle(name string) {
 	name = strings.TrimSpace(name)
 
 	if len(name) > 0 {
-		pm.style = name
+		pm.style, pm.styleURL = name, ""
+	}
+}
+
+// SetStyleURL sets the style of the Placemark to one in another file, such
+// as "http://example.com/styles.kml#red", which is written as it is.
+func (pm *Placemark) SetStyleURL(url string) {
+	url = strings.TrimSpace(url)
+
+	if len(url) > 0 {
+		pm.style, pm.styleURL = "", url
 	}
 }
 
@@ -341,25 +1175,280 @@ func (pm *Placemark) SetTime(beginTime time.Time, endTime time.Time) {
 	pm.hasTime = true
 }
 
-func (pm *Placemark) render() string {
-	ret := "<Placemark>\n" +
-		fmt.Sprintf("<name>%s</name>\n", pm.name) +
-		fmt.Sprintf("<description>%s</description>\n", pm.description) +
-		"<visibility>1</visibility>\n"
+// AddTag tags the Placemark for tag-based selection and rendering.  See
+// KML.ByTag and KML.RenderTagged.
+func (pm *Placemark) AddTag(tag string) {
+	pm.tags = pm.tags.add(tag)
+}
+
+// SetVisibility specifies whether the Placemark is shown when the document is
+// loaded.  Placemarks are visible by default; hidden ones can be turned on
+// in the Places panel.
+func (pm *Placemark) SetVisibility(visible bool) {
+	pm.hidden = !visible
+}
+
+// SetBalloonVisibility specifies whether the Placemark's balloon is open
+// when the document is loaded (gx:balloonVisibility).  Only one balloon can
+// be open at a time, so this should be set on a single Placemark.
+func (pm *Placemark) SetBalloonVisibility(visible bool) {
+	pm.balloon = visible
+}
+
+func (pm *Placemark) render(e *encoder) {
+	// StableOutput1 has the element order of earlier versions, which didn't
+	// follow the schema
+	legacy := e.opts.stable == StableOutput1
+
+	e.start("Placemark", pm.extra.startAttrs(idAttrs(pm.id))...)
+	e.label(pm.name)
+
+	// preserved elements are written in their places in the schema, except
+	// in StableOutput1, where they all precede the geometry
+	w := pm.extra.writer(e, "Placemark")
+
+	if legacy {
+		e.description(pm.balloonDescription())
+		pm.renderVisibility(e)
+
+		if pm.balloon {
+			e.int("gx:balloonVisibility", 1)
+		}
+
+		pm.renderStyleURL(e)
+		pm.renderTime(e)
+		pm.renderData(e)
+		w.rest()
+	} else {
+		w.before("visibility")
+		pm.renderVisibility(e)
+		w.before("description")
+		e.description(pm.balloonDescription())
+		w.before("TimeSpan")
+		pm.renderTime(e)
+		w.before("styleUrl")
+		pm.renderStyleURL(e)
+		w.before("ExtendedData")
+		pm.renderData(e)
+		w.before("gx:balloonVisibility")
+		if pm.balloon {
+			e.int("gx:balloonVisibility", 1)
+		}
+		w.before("Point")
+	}
 
+	if pm.geometry != nil {
+		pm.geometry.render(e)
+	}
+
+	w.rest()
+	e.end("Placemark")
+}
+
+func (pm *Placemark) renderVisibility(e *encoder) {
+	if pm.hidden {
+		e.int("visibility", 0)
+	} else {
+		e.int("visibility", 1)
+	}
+}
+
+func (pm *Placemark) renderStyleURL(e *encoder) {
 	if len(pm.style) > 0 {
-		ret += fmt.Sprintf("<styleUrl>#%s</styleUrl>\n", pm.style)
+		e.element("styleUrl", "#"+pm.style)
+	} else if len(pm.styleURL) > 0 {
+		e.element("styleUrl", pm.styleURL)
 	}
+}
 
+func (pm *Placemark) renderTime(e *encoder) {
 	if pm.hasTime {
-		ret += "<TimeSpan>\n" +
-			fmt.Sprintf("<begin>%s</begin>\n", pm.beginTime.Format(time.RFC3339)) +
-			fmt.Sprintf("<end>%s</end>\n", pm.endTime.Format(time.RFC3339)) +
-			"</TimeSpan>\n"
+		e.start("TimeSpan")
+		e.element("begin", pm.beginTime.Format(time.RFC3339))
+		e.element("end", pm.endTime.Format(time.RFC3339))
+		e.end("TimeSpan")
 	}
+}
+
+// Bounds represents a lat/lon bounding rectangle.
+type Bounds struct {
+	North float64 // northern-most latitude
+	South float64 // southern-most latitude
+	East  float64 // eastern-most longitude
+	West  float64 // western-most longitude
+}
+
+// renderEdges writes the north, south, east, and west elements of a LatLonBox or
+// LatLonAltBox.
+func (b *Bounds) renderEdges(e *encoder) {
+	e.latLon("north", b.North)
+	e.latLon("south", b.South)
+	e.latLon("east", b.East)
+	e.latLon("west", b.West)
+}
+
+// NetworkLink represents a link to another KML or KMZ file, which Google
+// Earth will fetch and display as part of the document.
+type NetworkLink struct {
+	name        string
+	description string
+	href        string
+	region      *Bounds
+	minLod      int
+	refresh     time.Duration
+	when        time.Time
+	extra       *extraXML
+}
+
+// NewNetworkLink returns a pointer to a new NetworkLink instance.  The href
+// may be an absolute URL or a path relative to the document.
+func NewNetworkLink(name string, desc string, href string) *NetworkLink {
+	return &NetworkLink{name, desc, strings.TrimSpace(href), nil, 0, 0, time.Time{}, nil}
+}
 
-	ret += pm.geometry.render() +
-		"</Placemark>\n"
+// SetRefreshInterval makes Google Earth re-fetch the linked file every
+// interval.  Intervals are rounded to the nearest second and an interval
+// less than one second turns periodic refresh off.
+func (nl *NetworkLink) SetRefreshInterval(interval time.Duration) {
+	nl.refresh = interval.Round(time.Second)
+}
+
+// SetRegion limits the NetworkLink to the given bounding rectangle, so Google
+// Earth only fetches the linked file when that area is in view.
+func (nl *NetworkLink) SetRegion(b Bounds) {
+	nl.region = &b
+}
+
+// SetMinLodPixels sets how many pixels across the Region must appear on
+// screen before Google Earth fetches the linked file, so detail is only
+// loaded when zoomed in.  It has no effect without a Region.  Values less
+// than one remove the limit.
+func (nl *NetworkLink) SetMinLodPixels(pixels int) {
+	nl.minLod = maxInt(pixels, 0)
+}
+
+// SetTimeStamp sets the moment the NetworkLink applies to, such as when the
+// linked file was last updated.  A zero time removes the TimeStamp.
+func (nl *NetworkLink) SetTimeStamp(when time.Time) {
+	nl.when = when
+}
+
+func (nl *NetworkLink) render(e *encoder) {
+	e.start("NetworkLink", nl.extra.startAttrs(nil)...)
+	e.label(nl.name)
+
+	w := nl.extra.writer(e, "NetworkLink")
+	w.before("description")
+	e.element("description", nl.description)
+
+	w.before("TimeStamp")
+	if !nl.when.IsZero() {
+		e.start("TimeStamp")
+		e.element("when", nl.when.Format(time.RFC3339))
+		e.end("TimeStamp")
+	}
+
+	w.before("Region")
+	renderRegion(e, nl.region, nl.minLod)
+
+	w.before("Link")
+	e.start("Link")
+	e.element("href", nl.href)
+
+	if nl.refresh >= time.Second {
+		e.element("refreshMode", "onInterval")
+		e.int("refreshInterval", int(nl.refresh/time.Second))
+	}
+
+	e.end("Link")
+	w.rest()
+	e.end("NetworkLink")
+}
+
+// renderRegion writes a Region limited to the bounding rectangle, if there
+// is one, and to minLod pixels, if more than zero.
+func renderRegion(e *encoder, region *Bounds, minLod int) {
+	if region == nil {
+		return
+	}
+
+	e.start("Region")
+	e.start("LatLonAltBox")
+	region.renderEdges(e)
+	e.end("LatLonAltBox")
+	if minLod > 0 {
+		e.start("Lod")
+		e.int("minLodPixels", minLod)
+		e.end("Lod")
+	}
+	e.end("Region")
+}
+
+// GroundOverlay represents an image draped over the terrain, georeferenced
+// either by a LatLonBox or by its four corners (gx:LatLonQuad).
+type GroundOverlay struct {
+	name        string
+	description string
+	href        string
+	box         Bounds
+	rotation    float64
+	quad        []*Point
+	extra       *extraXML
+}
+
+// NewGroundOverlay returns a pointer to a new GroundOverlay instance.  The
+// href is the URL or path of the overlay image and box is the bounding
+// rectangle the image is stretched over.
+func NewGroundOverlay(name string, desc string, href string, box Bounds) *GroundOverlay {
+	return &GroundOverlay{name, desc, strings.TrimSpace(href), box, 0.0, nil, nil}
+}
+
+// SetRotation sets the rotation of the overlay image about the center of its
+// LatLonBox, in degrees counter-clockwise from north.  Valid values are
+// between -180.0 and 180.0.  Invalid values are ignored.
+func (g *GroundOverlay) SetRotation(rotation float64) {
+	if rotation >= -180.0 && rotation <= 180.0 {
+		g.rotation = rotation
+	}
+}
+
+// SetLatLonQuad georeferences the overlay image by its four corners (lower
+// left, lower right, upper right, upper left) instead of its LatLonBox, so
+// skewed or rotated images can be placed.  If any of the Points are nil, the
+// quad is ignored.
+func (g *GroundOverlay) SetLatLonQuad(ll *Point, lr *Point, ur *Point, ul *Point) {
+	if ll == nil || lr == nil || ur == nil || ul == nil {
+		return
+	}
+
+	g.quad = []*Point{ll, lr, ur, ul}
+}
+
+func (g *GroundOverlay) render(e *encoder) {
+	e.start("GroundOverlay", g.extra.startAttrs(nil)...)
+	e.label(g.name)
+
+	w := g.extra.writer(e, "GroundOverlay")
+	w.before("description")
+	e.element("description", g.description)
+	w.before("Icon")
+	e.start("Icon")
+	e.element("href", g.href)
+	e.end("Icon")
+
+	if g.quad != nil {
+		w.before("gx:LatLonQuad")
+		e.start("gx:LatLonQuad")
+		e.coordinates(g.quad, false)
+		e.end("gx:LatLonQuad")
+	} else {
+		w.before("LatLonBox")
+		e.start("LatLonBox")
+		g.box.renderEdges(e)
+		e.float("rotation", g.rotation)
+		e.end("LatLonBox")
+	}
 
-	return ret
+	w.rest()
+	e.end("GroundOverlay")
 }
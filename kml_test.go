@@ -1,7 +1,15 @@
 package gokml
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -62,3 +70,634 @@ func TestKML(t *testing.T) {
 
 	fmt.Printf("%s", k.Render())
 }
+
+func TestAtomMetadata(t *testing.T) {
+	k := NewKML("Atom")
+	out := k.Render()
+	if strings.Contains(out, "xmlns:atom") {
+		t.Error("atom namespace declared but not used")
+	}
+
+	f := NewFolder("Sub", "")
+	f.SetAuthor("Gershwin Labs")
+	k.AddFeature(f)
+	k.SetLink("https://example.com/atom.kml")
+	out = k.Render()
+
+	for _, want := range []string{
+		"xmlns:atom=\"http://www.w3.org/2005/Atom\"",
+		"<atom:name>Gershwin Labs</atom:name>",
+		"<atom:link href=\"https://example.com/atom.kml\"></atom:link>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestGroundOverlay(t *testing.T) {
+	k := NewKML("Overlays")
+	box := NewGroundOverlay("Box", "", "box.png", Bounds{40.0, 39.0, -104.0, -105.0})
+	box.SetRotation(12.5)
+	k.AddFeature(box)
+
+	out := k.Render()
+	if strings.Contains(out, "xmlns:gx") {
+		t.Error("gx namespace declared but not used")
+	}
+
+	for _, want := range []string{
+		"<href>box.png</href>",
+		"<north>40.000000</north>",
+		"<rotation>12.500000</rotation>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+
+	quad := NewGroundOverlay("Quad", "", "scene.png", Bounds{})
+	quad.SetLatLonQuad(NewPoint(39.0, -105.0, 0.0), NewPoint(39.1, -104.0, 0.0),
+		NewPoint(40.0, -104.1, 0.0), NewPoint(39.9, -105.1, 0.0))
+	k.AddFeature(quad)
+
+	out = unindent(k.Render())
+	for _, want := range []string{
+		"xmlns:gx=\"http://www.google.com/kml/ext/2.2\"",
+		"<gx:LatLonQuad>\n<coordinates>-105.000000,39.000000 -104.000000,39.100000 ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestBalloonVisibility(t *testing.T) {
+	k := NewKML("Balloon")
+	pm := NewPlacemark("Primary", "", NewPoint(0.0, 0.0, 0.0))
+	k.AddFeature(pm)
+
+	if strings.Contains(k.Render(), "balloonVisibility") {
+		t.Error("balloon visibility rendered by default")
+	}
+
+	pm.SetBalloonVisibility(true)
+	out := k.Render()
+
+	for _, want := range []string{
+		"xmlns:gx=",
+		"<gx:balloonVisibility>1</gx:balloonVisibility>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestLineStringDrawOrder(t *testing.T) {
+	k := NewKML("Draw Order")
+	route := NewLineString()
+	route.AddPoint(NewPoint(40.0, -105.0, 0.0))
+	route.AddPoint(NewPoint(41.0, -104.0, 0.0))
+	k.AddFeature(NewPlacemark("Route", "", route))
+
+	if strings.Contains(k.Render(), "drawOrder") {
+		t.Error("draw order rendered by default")
+	}
+
+	route.SetDrawOrder(2)
+	out := unindent(k.Render())
+
+	for _, want := range []string{
+		"xmlns:gx=",
+		"</coordinates>\n<gx:drawOrder>2</gx:drawOrder>\n</LineString>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestFindByID(t *testing.T) {
+	k := NewKML("IDs")
+
+	f := NewFolder("Tracks", "")
+	f.SetID("tracks")
+	k.AddFeature(f)
+
+	s := NewStyle("TrackStyle", 255, 0, 0, 0)
+	f.AddFeature(s)
+
+	pm := NewPlacemark("Truck 12", "", NewPoint(40.0, -105.0, 0.0))
+	pm.SetID("truck-12")
+	f.AddFeature(pm)
+
+	if k.FindByID("tracks") != f {
+		t.Error("folder not found by id")
+	}
+
+	if k.FindByID("truck-12") != pm {
+		t.Error("placemark not found by id")
+	}
+
+	if k.FindByID("TrackStyle") != s {
+		t.Error("style not found by id")
+	}
+
+	if k.FindByID("missing") != nil || k.FindByID("") != nil {
+		t.Error("expected nil for unknown id")
+	}
+
+	out := k.Render()
+	for _, want := range []string{"<Folder id=\"tracks\">", "<Placemark id=\"truck-12\">"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	k := NewKML("Products")
+	k.SetDescription("Daily products")
+	k.SetOpen(true)
+	k.SetLookAt(NewLookAt(NewPoint(39.74, -104.99, 0.0), 0.0, 45.0, 5000.0))
+	out := unindent(k.Render())
+
+	want := "<Document>\n" +
+		"<name>Products</name>\n" +
+		"<open>1</open>\n" +
+		"<description>Daily products</description>\n" +
+		"<LookAt>\n" +
+		"<longitude>-104.990000</longitude>\n" +
+		"<latitude>39.740000</latitude>\n" +
+		"<altitude>0.000000</altitude>\n" +
+		"<heading>0.000000</heading>\n" +
+		"<tilt>45.000000</tilt>\n" +
+		"<range>5000.000000</range>\n" +
+		"</LookAt>\n" +
+		"</Document>\n"
+
+	if !strings.Contains(out, want) {
+		t.Errorf("unexpected document element:\n%s", out)
+	}
+
+	if NewLookAt(nil, 0.0, 0.0, 0.0) != nil || NewLookAt(NewPoint(0.0, 0.0, 0.0), 0.0, 91.0, 0.0) != nil {
+		t.Error("expected nil for invalid LookAt")
+	}
+}
+
+// unindent strips the indentation from every line of rendered output, so
+// tests can match runs of elements regardless of their depth.
+func unindent(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimLeft(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestEscaping(t *testing.T) {
+	const nasty = `Fish & Chips <"Bob's">`
+
+	k := NewKML(nasty)
+	k.SetDescription(nasty)
+	k.SetAuthor(nasty)
+	k.SetLink("https://example.com/?a=1&b=\"2\"")
+
+	s := NewStyle(nasty, 255, 0, 0, 0)
+	s.SetIconURL("icon.png?size=1&color=<red>")
+	k.AddFeature(s)
+
+	f := NewFolder(nasty, nasty)
+	k.AddFeature(f)
+
+	pm := NewPlacemark(nasty, nasty, NewPoint(0.0, 0.0, 0.0))
+	pm.SetID(nasty)
+	pm.SetStyle(nasty)
+	f.AddFeature(pm)
+
+	f.AddFeature(NewNetworkLink(nasty, nasty, "layer.kml?a=1&b=2"))
+	f.AddFeature(NewGroundOverlay(nasty, nasty, "scene.png?a=1&b=2", Bounds{1, 0, 1, 0}))
+
+	d := xml.NewDecoder(strings.NewReader(k.Render()))
+	names := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("rendered document is not well-formed: %v", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			for _, a := range tok.Attr {
+				if a.Name.Local == "id" && a.Value != nasty {
+					t.Errorf("id attribute decoded as %q", a.Value)
+				}
+			}
+
+			if tok.Name.Local == "name" {
+				var text string
+				if err := d.DecodeElement(&text, &tok); err != nil {
+					t.Fatal(err)
+				}
+				if text != nasty {
+					t.Errorf("name decoded as %q", text)
+				}
+				names++
+			}
+		}
+	}
+
+	// document, author, folder, placemark, network link, ground overlay
+	if names != 6 {
+		t.Errorf("expected 6 names, got %d", names)
+	}
+}
+
+func TestDescriptionHTML(t *testing.T) {
+	const html = `<table><tr><td><a href="https://example.com/?a=1&b=2">Source</a></td></tr></table>`
+
+	k := NewKML("HTML")
+	k.SetDescriptionHTML(html)
+	pm := NewPlacemark("Site", "", NewPoint(0.0, 0.0, 0.0))
+	pm.SetDescriptionHTML(html + "]]>")
+	k.AddFeature(pm)
+
+	out := k.Render()
+	if !strings.Contains(out, "<description><![CDATA["+html+"]]></description>") {
+		t.Errorf("description not wrapped in CDATA:\n%s", out)
+	}
+
+	d := xml.NewDecoder(strings.NewReader(out))
+	descs := make([]string, 0)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("rendered document is not well-formed: %v", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "description" {
+			var text string
+			d.DecodeElement(&text, &start)
+			descs = append(descs, text)
+		}
+	}
+
+	if len(descs) != 2 || descs[0] != html || descs[1] != html+"]]>" {
+		t.Errorf("unexpected decoded descriptions %q", descs)
+	}
+}
+
+func TestIndentAndNewline(t *testing.T) {
+	k := NewKML("Layout")
+	k.AddFeature(NewPlacemark("Denver", "", NewPoint(39.74, -104.99, 0.0)))
+
+	if !strings.HasPrefix(k.Render(), "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<kml") {
+		t.Error("expected the kml element on the line after the XML declaration")
+	}
+
+	k.SetIndent("\t")
+	out := k.Render()
+	if !strings.Contains(out, "\n\t<Document>\n\t\t<name>Layout</name>\n") {
+		t.Errorf("unexpected tab-indented output:\n%s", out)
+	}
+
+	k.SetIndent("")
+	out = k.Render()
+	if !strings.Contains(out, "\n<Document>\n<name>Layout</name>\n") {
+		t.Errorf("unexpected unindented output:\n%s", out)
+	}
+
+	k.SetIndent("x")
+	if k.Render() != out {
+		t.Error("invalid indent was not ignored")
+	}
+
+	k.SetNewline("\r\n")
+	out = k.Render()
+	if strings.Count(out, "\r\n") != strings.Count(out, "\n") {
+		t.Errorf("expected CRLF line endings:\n%q", out)
+	}
+
+	k.SetNewline("")
+	out = k.Render()
+	if strings.ContainsAny(out, "\r\n") {
+		t.Errorf("expected single-line output:\n%q", out)
+	}
+	if !strings.HasPrefix(out, "<?xml version=\"1.0\" encoding=\"UTF-8\"?><kml") {
+		t.Errorf("unexpected single-line output:\n%q", out)
+	}
+}
+
+func TestMinify(t *testing.T) {
+	k := NewKML("Minify")
+	line := NewLineString()
+	line.AddPoint(NewPoint(40.0, -105.25, 0.0))
+	line.AddPoint(NewPoint(-0.0000001, 0.5, 1609.344))
+	k.AddFeature(NewPlacemark("Line", "", line))
+
+	k.SetMinify(true)
+	out := k.Render()
+
+	if strings.ContainsAny(out, "\n\t") || strings.Contains(out, "> <") {
+		t.Errorf("minified output contains whitespace:\n%s", out)
+	}
+
+	if !strings.Contains(out, "<coordinates>-105.25,40,0 0.5,0,1609.344</coordinates>") {
+		t.Errorf("trailing zeros not trimmed:\n%s", out)
+	}
+
+	k.SetMinify(false)
+	if !strings.Contains(k.Render(), "-105.250000,40.000000,0.000000") {
+		t.Error("expected full precision output when not minified")
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	k := NewKML("Precision")
+	k.AddFeature(NewPlacemark("Survey", "", NewPoint(39.123456789, -104.987654321, 1609.3456)))
+
+	k.SetPrecision(8, 2)
+	if out := k.Render(); !strings.Contains(out, "<coordinates>-104.98765432,39.12345679,1609.35</coordinates>") {
+		t.Errorf("unexpected high precision output:\n%s", out)
+	}
+
+	k.SetPrecision(4, 0)
+	if out := k.Render(); !strings.Contains(out, "<coordinates>-104.9877,39.1235,1609</coordinates>") {
+		t.Errorf("unexpected low precision output:\n%s", out)
+	}
+
+	k.SetPrecision(-1, 16)
+	if out := k.Render(); !strings.Contains(out, "<coordinates>-104.9877,39.1235,1609</coordinates>") {
+		t.Error("invalid precision was not ignored")
+	}
+
+	k.SetMinify(true)
+	if out := k.Render(); !strings.Contains(out, "<coordinates>-104.9877,39.1235,1609</coordinates>") {
+		t.Error("minify trimmed zeros from an integer")
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	k := NewKML("Benchmark")
+	f := NewFolder("Placemarks", "")
+	k.AddFeature(f)
+
+	for i := 0; i < 50000; i++ {
+		pm := NewPlacemark(fmt.Sprintf("Placemark %d", i), "", NewPoint(float64(i%180)-90.0, float64(i%360)-180.0, 0.0))
+		f.AddFeature(pm)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k.Render()
+	}
+}
+
+func TestWriteGzip(t *testing.T) {
+	k := NewKML("Compressed")
+	k.AddFeature(NewPlacemark("Place", "", NewPoint(40.0, -105.0, 0.0)))
+
+	var buf bytes.Buffer
+	if err := k.WriteGzip(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != k.Render() {
+		t.Errorf("decompressed output differs from Render:\n%s", out)
+	}
+}
+
+func TestNamespacesDeclared(t *testing.T) {
+	k := NewKML("Everything")
+	k.SetAuthor("Author")
+
+	line := NewLineString()
+	line.AddPoint(NewPoint(40.0, -105.0, 0.0))
+	line.AddPoint(NewPoint(41.0, -105.0, 0.0))
+	line.SetDrawOrder(2)
+
+	pm := NewPlacemark("Line", "", line)
+	pm.SetBalloonVisibility(false)
+	k.AddFeature(pm)
+
+	d := xml.NewDecoder(strings.NewReader(k.Render()))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the decoder leaves undeclared prefixes unresolved
+		if start, ok := tok.(xml.StartElement); ok {
+			switch start.Name.Space {
+			case kmlNamespace, gxNamespace, atomNamespace:
+			default:
+				t.Errorf("element %s has undeclared namespace %q", start.Name.Local, start.Name.Space)
+			}
+		}
+	}
+}
+
+func TestSorted(t *testing.T) {
+	build := func(reverse bool) *KML {
+		features := []renderable{
+			NewPlacemark("Bravo", "", NewPoint(40.0, -105.0, 0.0)),
+			NewStyle("zulu", 255, 0, 0, 0),
+			NewFolder("Alpha", ""),
+			NewStyle("yankee", 255, 0, 0, 0),
+		}
+
+		k := NewKML("Sorted")
+		k.SetSorted(true)
+		for i := range features {
+			if reverse {
+				i = len(features) - 1 - i
+			}
+			k.AddFeature(features[i])
+		}
+		return k
+	}
+
+	out := build(false).Render()
+	if out != build(true).Render() {
+		t.Error("sorted render depends on insertion order")
+	}
+
+	order := []string{`<Style id="yankee">`, `<Style id="zulu">`, "<name>Alpha</name>", "<name>Bravo</name>"}
+	last := -1
+	for _, s := range order {
+		i := strings.Index(out, s)
+		if i < last {
+			t.Errorf("%s out of order:\n%s", s, out)
+		}
+		last = i
+	}
+}
+
+func TestRenderBytes(t *testing.T) {
+	k := NewKML("Bytes")
+	k.AddFeature(NewPlacemark("Place", "", NewPoint(40.0, -105.0, 0.0)))
+
+	if string(k.RenderBytes()) != k.Render() {
+		t.Error("RenderBytes differs from Render")
+	}
+
+	out, err := io.ReadAll(k.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != k.Render() {
+		t.Error("Reader differs from Render")
+	}
+}
+
+func TestWriteContext(t *testing.T) {
+	k := NewKML("Huge")
+	for i := 0; i < 10*ctxCheckInterval; i++ {
+		k.AddFeature(NewPlacemark("Place", "", NewPoint(40.0, -105.0, 0.0)))
+	}
+
+	var buf bytes.Buffer
+	if err := k.WriteContext(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != k.Render() {
+		t.Error("WriteContext differs from Render")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := k.WriteContext(ctx, io.Discard); err != context.Canceled {
+		t.Errorf("got %v for a cancelled context, want context.Canceled", err)
+	}
+
+	// cancelled while rendering
+	ctx, cancel = context.WithCancel(context.Background())
+	w := &cancellingWriter{cancel: cancel}
+
+	if err := k.WriteContext(ctx, w); err != context.Canceled {
+		t.Errorf("got %v for a context cancelled while rendering, want context.Canceled", err)
+	}
+
+	if w.n >= len(k.Render()) {
+		t.Error("rendering wasn't stopped")
+	}
+}
+
+// cancellingWriter cancels its context on the first write.
+type cancellingWriter struct {
+	cancel func()
+	n      int
+}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	w.n += len(p)
+	return len(p), nil
+}
+
+func TestUnicodeText(t *testing.T) {
+	for _, s := range []string{
+		"שלום עולם",
+		"مرحبا (123) world",
+		"é combining",
+		"a]]>b",
+		"control \x01\x0b chars",
+		"invalid \xff utf-8",
+	} {
+		k := NewKML(s)
+		k.SetDescriptionHTML(s)
+		k.AddFeature(NewPlacemark(s, s, NewPoint(40.0, -105.0, 0.0)))
+
+		var doc struct {
+			Name        string `xml:"Document>name"`
+			Description string `xml:"Document>description"`
+		}
+		if err := xml.Unmarshal([]byte(k.Render()), &doc); err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+
+		want := xmlSafe(s)
+		if doc.Name != want || doc.Description != want {
+			t.Errorf("%q rendered as %q, %q", s, doc.Name, doc.Description)
+		}
+	}
+
+	if got := xmlSafe("a\x01b\xffc"); got != "a\ufffdb\ufffdc" {
+		t.Errorf("xmlSafe = %q", got)
+	}
+}
+
+func TestBidiIsolation(t *testing.T) {
+	k := NewKML("Places")
+	k.AddFeature(NewPlacemark("שלום", "", NewPoint(40.0, -105.0, 0.0)))
+	k.AddFeature(NewPlacemark("Hello", "", NewPoint(40.0, -105.0, 0.0)))
+
+	if strings.Contains(k.Render(), "\u2068") {
+		t.Error("isolation marks rendered by default")
+	}
+
+	k.SetBidiIsolation(true)
+	out := k.Render()
+
+	if !strings.Contains(out, "<name>\u2068שלום\u2069</name>") {
+		t.Errorf("right-to-left name not isolated:\n%s", out)
+	}
+
+	if !strings.Contains(out, "<name>Hello</name>") {
+		t.Errorf("left-to-right name changed:\n%s", out)
+	}
+}
+
+func TestStableOutput(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "stable1.kml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := parseFixture()
+	k.SetStableOutput(StableOutput1)
+
+	// layout options are ignored by stable forms
+	k.SetMinify(true)
+	k.SetIndent("\t")
+	k.SetPrecision(2, 1)
+	k.SetBidiIsolation(true)
+
+	if got := k.Render(); got != string(golden) {
+		t.Errorf("stable output changed:\n%s", got)
+	}
+
+	k.SetStableOutput(99)
+	if k.options.stable != StableOutput1 {
+		t.Error("unknown stable form not ignored")
+	}
+
+	k.SetStableOutput(0)
+	if k.Render() == string(golden) {
+		t.Error("stable form not turned off")
+	}
+}
@@ -0,0 +1,192 @@
+package gokml
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// asset is a file embedded in KMZ archives.
+type asset struct {
+	name      string // path within the archive
+	localPath string // file to embed, if data is nil
+	data      []byte
+}
+
+// AddAsset registers a local file (icon, overlay image, etc.) to be embedded
+// in KMZ archives written by WriteKMZ.  It returns the archive path
+// (files/<name>) that Styles and GroundOverlays should use to reference the
+// file.  Returns an error if the file can't be read.
+func (k *KML) AddAsset(localPath string) (string, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", localPath)
+	}
+
+	return k.addAsset(&asset{filepath.Base(localPath), localPath, nil}), nil
+}
+
+// AddAssetData registers generated file contents to be embedded in KMZ
+// archives written by WriteKMZ under the given file name.  It returns the
+// archive path (files/<name>) that Styles and GroundOverlays should use to
+// reference the file.
+func (k *KML) AddAssetData(name string, data []byte) string {
+	return k.addAsset(&asset{path.Base(filepath.ToSlash(name)), "", data})
+}
+
+func (k *KML) addAsset(a *asset) string {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	// the same local file is only embedded once
+	if len(a.localPath) > 0 {
+		for _, existing := range k.assets {
+			if existing.localPath == a.localPath {
+				return existing.name
+			}
+		}
+	}
+
+	ext := path.Ext(a.name)
+	base := strings.TrimSuffix(a.name, ext)
+	name := "files/" + a.name
+
+	for i := 1; k.hasAsset(name); i++ {
+		name = fmt.Sprintf("files/%s-%d%s", base, i, ext)
+	}
+
+	a.name = name
+	k.assets = append(k.assets, a)
+
+	return name
+}
+
+func (k *KML) hasAsset(name string) bool {
+	for _, a := range k.assets {
+		if a.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteKMZ writes the document to w as a KMZ archive (a zip file with the
+// document stored as doc.kml), along with all registered assets.  Like
+// Write, it reports problems with the document and nothing is written if
+// there are any.
+func (k *KML) WriteKMZ(w io.Writer) error {
+	if err := k.check(); err != nil {
+		return err
+	}
+
+	z := zip.NewWriter(w)
+
+	doc, err := z.Create("doc.kml")
+	if err != nil {
+		return err
+	}
+
+	if err := k.write(doc); err != nil {
+		return err
+	}
+
+	k.mutex.Lock()
+	assets := k.assets
+	k.mutex.Unlock()
+
+	for _, a := range assets {
+		if err := writeAsset(z, a); err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
+
+func writeAsset(z *zip.Writer, a *asset) error {
+	w, err := z.Create(a.name)
+	if err != nil {
+		return err
+	}
+
+	if a.data != nil {
+		_, err = w.Write(a.data)
+		return err
+	}
+
+	f, err := os.Open(a.localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// OpenKMZ reads the named KMZ archive and parses its root document (doc.kml,
+// or the first .kml file if there is no doc.kml) like Parse.  The files in
+// the archive, such as icons and overlay images, are returned as an fs.FS,
+// using the paths the document refers to them by.
+func OpenKMZ(name string) (*KML, fs.FS, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ReadKMZ(bytes.NewReader(data), int64(len(data)))
+}
+
+// ReadKMZ is like OpenKMZ, for a KMZ archive of the given size read from r.
+func ReadKMZ(r io.ReaderAt, size int64) (*KML, fs.FS, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := kmzRoot(z)
+	if root == nil {
+		return nil, nil, fmt.Errorf("gokml: no KML document in archive")
+	}
+
+	f, err := root.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	k, err := Parse(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return k, z, nil
+}
+
+// kmzRoot returns the root document of a KMZ archive: doc.kml by convention,
+// otherwise the first .kml file in the archive.  Returns nil if there is no
+// .kml file.
+func kmzRoot(z *zip.Reader) *zip.File {
+	var root *zip.File
+	for _, zf := range z.File {
+		if strings.ToLower(path.Ext(zf.Name)) != ".kml" {
+			continue
+		}
+		if root == nil || zf.Name == "doc.kml" {
+			root = zf
+		}
+	}
+
+	return root
+}
@@ -0,0 +1,163 @@
+package gokml
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteKMZ(t *testing.T) {
+	k := NewKML("KMZ")
+	k.AddFeature(NewPlacemark("Denver", "", NewPoint(39.74, -104.99, 0.0)))
+
+	var buf bytes.Buffer
+	if err := k.WriteKMZ(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(z.File) != 1 || z.File[0].Name != "doc.kml" {
+		t.Fatalf("unexpected archive contents %v", z.File)
+	}
+
+	r, err := z.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	doc, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(doc) != k.Render() {
+		t.Error("doc.kml does not match the rendered document")
+	}
+}
+
+func TestKMZAssets(t *testing.T) {
+	dir := t.TempDir()
+	iconPath := filepath.Join(dir, "icon.png")
+	if err := os.WriteFile(iconPath, []byte("png data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	k := NewKML("Assets")
+
+	href, err := k.AddAsset(iconPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if href != "files/icon.png" {
+		t.Errorf("unexpected asset path %q", href)
+	}
+
+	if again, _ := k.AddAsset(iconPath); again != href {
+		t.Errorf("same file registered twice as %q", again)
+	}
+
+	other := k.AddAssetData("icon.png", []byte("other png"))
+	if other != "files/icon-1.png" {
+		t.Errorf("unexpected path for colliding asset %q", other)
+	}
+
+	if _, err := k.AddAsset(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	s := NewStyle("Icon", 255, 255, 255, 255)
+	s.SetIconURL(href)
+	k.AddFeature(s)
+
+	if errs := k.CheckLinks(os.DirFS(dir), nil, 1); len(errs) != 0 {
+		t.Errorf("asset reported as a dead link: %v", errs)
+	}
+
+	var buf bytes.Buffer
+	if err := k.WriteKMZ(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := make(map[string]string)
+	for _, f := range z.File {
+		r, _ := f.Open()
+		data, _ := io.ReadAll(r)
+		r.Close()
+		contents[f.Name] = string(data)
+	}
+
+	if contents["files/icon.png"] != "png data" || contents["files/icon-1.png"] != "other png" {
+		t.Errorf("unexpected archive contents %v", contents)
+	}
+
+	if _, ok := contents["doc.kml"]; !ok {
+		t.Error("archive missing doc.kml")
+	}
+}
+
+func TestOpenKMZ(t *testing.T) {
+	k := NewKML("Archive")
+	icon := k.AddAssetData("icon.png", []byte("png data"))
+
+	s := NewStyle("custom", 255, 255, 255, 255)
+	s.SetIconURL(icon)
+	k.AddFeature(s)
+
+	pm := NewPlacemark("Denver", "", NewPoint(39.74, -104.99, 0.0))
+	pm.SetStyle("custom")
+	k.AddFeature(pm)
+
+	name := filepath.Join(t.TempDir(), "doc.kmz")
+	if err := k.WriteKMZFile(name); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, files, err := OpenKMZ(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Render() != k.Render() {
+		t.Errorf("parsed document differs:\n%s", parsed.Render())
+	}
+
+	data, err := fs.ReadFile(files, icon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "png data" {
+		t.Errorf("asset contains %q", data)
+	}
+}
+
+func TestOpenKMZErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, _, err := OpenKMZ(filepath.Join(dir, "missing.kmz")); err == nil {
+		t.Error("no error for a missing file")
+	}
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	z.Create("readme.txt")
+	z.Close()
+
+	if _, _, err := ReadKMZ(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err == nil {
+		t.Error("no error for an archive without a KML document")
+	}
+}
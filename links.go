@@ -0,0 +1,124 @@
+package gokml
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// LinkError describes an href in the document that could not be resolved.
+type LinkError struct {
+	Href string
+	Err  error
+}
+
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Href, e.Err)
+}
+
+// CheckLinks verifies that every href in the document (icons, NetworkLinks,
+// etc.) resolves and returns the ones that don't, sorted by href.  HTTP and
+// HTTPS links are checked with a HEAD request using client (or
+// http.DefaultClient if nil), with at most concurrency requests in flight.
+// Relative hrefs are checked in files, such as os.DirFS of the document's
+// directory or the archive from OpenKMZ or ReadKMZ, unless they refer to
+// registered KMZ assets (see AddAsset).  If files is nil, they are checked
+// in the current directory.  Relative hrefs reaching outside files with
+// ".." can't be checked, and are returned.  File URLs are checked on the
+// local filesystem.
+func (k *KML) CheckLinks(files fs.FS, client *http.Client, concurrency int) []*LinkError {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if files == nil {
+		files = os.DirFS(".")
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	seen := make(map[string]bool)
+	hrefs := make([]string, 0, 10)
+	k.mutex.Lock()
+	walkHrefs(k.rootFolder, func(href string) {
+		if len(href) > 0 && !seen[href] && !k.hasAsset(href) {
+			seen[href] = true
+			hrefs = append(hrefs, href)
+		}
+	})
+	k.mutex.Unlock()
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	errs := make([]*LinkError, 0)
+
+	for _, href := range hrefs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(href string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := checkLink(files, client, href); err != nil {
+				mutex.Lock()
+				errs = append(errs, &LinkError{href, err})
+				mutex.Unlock()
+			}
+		}(href)
+	}
+
+	wg.Wait()
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Href < errs[j].Href })
+
+	return errs
+}
+
+func checkLink(files fs.FS, client *http.Client, href string) error {
+	u, err := url.Parse(href)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		resp, err := client.Head(href)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusMethodNotAllowed {
+			// some servers don't support HEAD, so fall back to GET
+			resp, err = client.Get(href)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+		}
+
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("HTTP status %s", resp.Status)
+		}
+
+		return nil
+	case "file":
+		_, err := os.Stat(filepath.FromSlash(u.Path))
+		return err
+	case "":
+		_, err := fs.Stat(files, path.Clean(u.Path))
+		return err
+	}
+
+	return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+}
@@ -0,0 +1,75 @@
+package gokml
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/icon.png" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "layer.kml"), []byte("<kml/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	k := NewKML("Links")
+
+	good := NewStyle("Good", 255, 0, 0, 0)
+	good.SetIconURL(server.URL + "/icon.png")
+	k.AddFeature(good)
+
+	bad := NewStyle("Bad", 255, 0, 0, 0)
+	bad.SetIconURL(server.URL + "/missing.png")
+	k.AddFeature(bad)
+
+	k.AddFeature(NewNetworkLink("Layer", "", "layer.kml"))
+	k.AddFeature(NewNetworkLink("Gone", "", "gone.kml"))
+
+	errs := k.CheckLinks(os.DirFS(dir), server.Client(), 2)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 link errors, got %v", errs)
+	}
+
+	if errs[0].Href != "gone.kml" {
+		t.Errorf("unexpected first error %v", errs[0])
+	}
+
+	if errs[1].Href != server.URL+"/missing.png" {
+		t.Errorf("unexpected second error %v", errs[1])
+	}
+}
+
+func TestCheckLinksKMZ(t *testing.T) {
+	k := NewKML("Archive")
+	icon := k.AddAssetData("icon.png", []byte("png data"))
+
+	s := NewStyle("custom", 255, 255, 255, 255)
+	s.SetIconURL(icon)
+	k.AddFeature(s)
+	k.AddFeature(NewNetworkLink("Gone", "", "gone.kml"))
+
+	var buf bytes.Buffer
+	if err := k.WriteKMZ(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, files, err := ReadKMZ(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := parsed.CheckLinks(files, nil, 1)
+	if len(errs) != 1 || errs[0].Href != "gone.kml" {
+		t.Errorf("expected only gone.kml to be reported, got %v", errs)
+	}
+}
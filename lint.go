@@ -0,0 +1,470 @@
+package gokml
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// lintMaxDescription is the description length (in bytes) above which a
+	// description is considered oversized.
+	lintMaxDescription = 4096
+
+	// lintBigFolder is the number of placemarks above which a folder should
+	// be limited to a Region or split up by Regions.
+	lintBigFolder = 1000
+)
+
+// LintFinding describes a quality problem found in a document by Lint.
+type LintFinding struct {
+	Rule       string     // short machine-readable rule name
+	Message    string     // human-readable description of the problem
+	Suggestion string     // suggested remedy
+	Feature    renderable // the offending feature
+	Fix        func()     // applies the suggested remedy, nil if none
+}
+
+func (f *LintFinding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Rule, f.Message, f.Suggestion)
+}
+
+// Lint checks the document for size and complexity problems: oversized
+// descriptions, coordinates with more precision than will be rendered,
+// identical styles that should be shared, big folders, and unclosed polygon
+// rings.  It also checks for semantic problems: styleUrls that refer to
+// styles that don't exist (dangling-style), styles nothing refers to
+// (unused-style), empty folders (empty-folder), placemarks without a
+// geometry (no-geometry), coordinates out of range (coordinate-range), and
+// ids used by more than one feature (duplicate-id).  Findings that can be
+// corrected automatically have a Fix function; fixes should be applied
+// after linting, before the document is rendered.  Fixing big-folder limits
+// the folder to a Region, so it's only drawn when zoomed in; SplitFolder
+// moves it to a separate document instead.  oversized-description,
+// dangling-style, unused-style, no-geometry, coordinate-range, and
+// duplicate-id have no Fix, since correcting them needs content or
+// judgement only the author has.
+func (k *KML) Lint() []*LintFinding {
+	findings := make([]*LintFinding, 0)
+
+	lintFeatures(k.rootFolder, k.options.latLonPrecision, &findings)
+	lintStyles(k.rootFolder, &findings)
+	lintStyleRefs(k.rootFolder, &findings)
+	lintIDs(k.rootFolder, make(map[string]bool), &findings)
+
+	return findings
+}
+
+func lintFeatures(feature renderable, precision int, findings *[]*LintFinding) {
+	switch f := feature.(type) {
+	case *Folder:
+		lintDescription(f, f.name, &f.description, findings)
+
+		if count := unlimitedPlacemarks(f); count > lintBigFolder {
+			lintBigFolderFinding(f, count, findings)
+		}
+
+		for _, child := range f.features {
+			if sub, ok := child.(*Folder); ok && len(sub.features) == 0 {
+				lintEmptyFolder(f, sub, findings)
+			}
+			lintFeatures(child, precision, findings)
+		}
+	case *Placemark:
+		lintDescription(f, f.name, &f.description, findings)
+
+		if f.geometry == nil {
+			*findings = append(*findings, &LintFinding{
+				Rule:       "no-geometry",
+				Message:    fmt.Sprintf("placemark %q has no geometry", f.name),
+				Suggestion: "give the placemark a location or remove it",
+				Feature:    f,
+			})
+			return
+		}
+
+		lintGeometry(f, f.geometry, precision, findings)
+	}
+}
+
+// unlimitedPlacemarks counts the Placemarks in the folder and its
+// sub-folders that aren't limited to a Region.
+func unlimitedPlacemarks(f *Folder) int {
+	if f.region != nil {
+		return 0
+	}
+
+	count := 0
+	for _, child := range f.features {
+		switch c := child.(type) {
+		case *Folder:
+			count += unlimitedPlacemarks(c)
+		case *Placemark:
+			count++
+		}
+	}
+
+	return count
+}
+
+func lintBigFolderFinding(folder *Folder, count int, findings *[]*LintFinding) {
+	finding := &LintFinding{
+		Rule:       "big-folder",
+		Message:    fmt.Sprintf("folder %q contains %d placemarks", folder.name, count),
+		Suggestion: "limit the folder to a Region, or split it into Region-limited NetworkLinks",
+		Feature:    folder,
+	}
+
+	if b, ok := folder.Bounds(); ok {
+		finding.Fix = func() {
+			folder.SetRegion(b.padded(catalogMinSpan))
+			folder.SetMinLodPixels(overviewMinLodPixels)
+		}
+	}
+
+	*findings = append(*findings, finding)
+}
+
+func lintEmptyFolder(parent *Folder, folder *Folder, findings *[]*LintFinding) {
+	*findings = append(*findings, &LintFinding{
+		Rule:       "empty-folder",
+		Message:    fmt.Sprintf("folder %q is empty", folder.name),
+		Suggestion: "remove the folder",
+		Feature:    folder,
+		Fix: func() {
+			removeFeature(parent, folder)
+		},
+	})
+}
+
+func lintDescription(feature renderable, name string, desc *string, findings *[]*LintFinding) {
+	if len(*desc) <= lintMaxDescription {
+		return
+	}
+
+	*findings = append(*findings, &LintFinding{
+		Rule:       "oversized-description",
+		Message:    fmt.Sprintf("description of %q is %d bytes", name, len(*desc)),
+		Suggestion: "move the content to a linked web page",
+		Feature:    feature,
+	})
+}
+
+func lintGeometry(pm *Placemark, geom renderable, precision int, findings *[]*LintFinding) {
+	precise, outOfRange := false, false
+	walkPoints(geom, func(p *Point) {
+		if !precise && (excessivePrecision(p.Lat, precision) || excessivePrecision(p.Lon, precision)) {
+			precise = true
+		}
+		outOfRange = outOfRange || math.Abs(p.Lat) > 90 || math.Abs(p.Lon) > 180
+	})
+
+	if outOfRange {
+		*findings = append(*findings, &LintFinding{
+			Rule:       "coordinate-range",
+			Message:    fmt.Sprintf("coordinates of %q are out of range", pm.name),
+			Suggestion: "check the coordinates; latitudes must be within ±90 and longitudes within ±180",
+			Feature:    pm,
+		})
+	}
+
+	if precise {
+		*findings = append(*findings, &LintFinding{
+			Rule:       "excessive-precision",
+			Message:    fmt.Sprintf("coordinates of %q have more precision than is rendered", pm.name),
+			Suggestion: fmt.Sprintf("round coordinates to %d decimal places", precision),
+			Feature:    pm,
+			Fix: func() {
+				walkPoints(geom, func(p *Point) {
+					p.Lat = roundTo(p.Lat, precision)
+					p.Lon = roundTo(p.Lon, precision)
+				})
+			},
+		})
+	}
+
+	poly, ok := geom.(*Polygon)
+	if !ok || len(poly.points) == 0 {
+		return
+	}
+
+	first := poly.points[0]
+	last := poly.points[len(poly.points)-1]
+
+	if *first != *last {
+		*findings = append(*findings, &LintFinding{
+			Rule:       "unclosed-ring",
+			Message:    fmt.Sprintf("polygon ring of %q is not closed", pm.name),
+			Suggestion: "repeat the first vertex at the end of the ring",
+			Feature:    pm,
+			Fix: func() {
+				poly.AddPoint(first)
+			},
+		})
+	}
+}
+
+func lintStyles(root *Folder, findings *[]*LintFinding) {
+	styles := make([]*Style, 0)
+	walkStyles(root, func(s *Style) { styles = append(styles, s) })
+
+	canonical := make(map[Style]*Style)
+
+	for _, s := range styles {
+		key := *s
+		key.name = ""
+
+		shared, ok := canonical[key]
+		if !ok {
+			canonical[key] = s
+			continue
+		}
+
+		duplicate := s
+		*findings = append(*findings, &LintFinding{
+			Rule:       "unshared-style",
+			Message:    fmt.Sprintf("style %q is identical to style %q", duplicate.name, shared.name),
+			Suggestion: fmt.Sprintf("use style %q and remove %q", shared.name, duplicate.name),
+			Feature:    duplicate,
+			Fix: func() {
+				renameStyleRefs(root, duplicate.name, shared.name)
+				removeFeature(root, duplicate)
+			},
+		})
+	}
+}
+
+// renameStyleRefs points every styleUrl referring to the style named from
+// at the style named to instead, including those of Placemarks and those in
+// StyleMaps and other elements kept from a parsed document.
+func renameStyleRefs(root *Folder, from string, to string) {
+	walkPlacemarks(root, func(pm *Placemark) {
+		if pm.style == from {
+			pm.style = to
+		}
+	})
+	walkExtras(root, func(n *node) {
+		if n.name.Local == "styleUrl" && strings.TrimSpace(n.text) == "#"+from {
+			n.text = "#" + to
+		}
+	})
+}
+
+// lintStyleRefs reports styleUrls that refer to missing styles, and styles
+// nothing refers to.  Styles and StyleMaps kept from a parsed document (see
+// Parse) count as defined, and their styleUrls count as references.
+func lintStyleRefs(root *Folder, findings *[]*LintFinding) {
+	defined := make(map[string]bool)
+	used := make(map[string]bool)
+
+	walkStyles(root, func(s *Style) { defined[s.name] = true })
+	walkExtras(root, func(n *node) {
+		switch n.name.Local {
+		case "Style", "StyleMap":
+			defined[n.attr("id")] = true
+		case "styleUrl":
+			used[n.text[strings.LastIndex(n.text, "#")+1:]] = true
+		}
+	})
+
+	walkPlacemarks(root, func(pm *Placemark) {
+		if len(pm.style) == 0 {
+			return
+		}
+
+		used[pm.style] = true
+
+		if !defined[pm.style] {
+			*findings = append(*findings, &LintFinding{
+				Rule:       "dangling-style",
+				Message:    fmt.Sprintf("placemark %q refers to style %q, which doesn't exist", pm.name, pm.style),
+				Suggestion: fmt.Sprintf("add style %q or remove the reference", pm.style),
+				Feature:    pm,
+			})
+		}
+	})
+
+	// unused styles aren't removed automatically, since other documents may
+	// refer to them
+	walkStyles(root, func(s *Style) {
+		if !used[s.name] {
+			*findings = append(*findings, &LintFinding{
+				Rule:       "unused-style",
+				Message:    fmt.Sprintf("style %q isn't used", s.name),
+				Suggestion: "remove the style unless other documents refer to it",
+				Feature:    s,
+			})
+		}
+	})
+}
+
+// lintIDs reports features whose ids are already used by an earlier feature.
+// The id of a Style is its name.
+func lintIDs(feature renderable, seen map[string]bool, findings *[]*LintFinding) {
+	var id string
+	switch f := feature.(type) {
+	case *Folder:
+		id = f.id
+	case *Placemark:
+		id = f.id
+	case *Style:
+		id = f.name
+	}
+
+	if len(id) > 0 {
+		if seen[id] {
+			*findings = append(*findings, &LintFinding{
+				Rule:       "duplicate-id",
+				Message:    fmt.Sprintf("id %q is used more than once", id),
+				Suggestion: "give each feature a unique id",
+				Feature:    feature,
+			})
+		}
+		seen[id] = true
+	}
+
+	if f, ok := feature.(*Folder); ok {
+		for _, child := range f.features {
+			lintIDs(child, seen, findings)
+		}
+	}
+}
+
+// excessivePrecision reports whether v has significant digits beyond the
+// decimal places that are rendered.
+func excessivePrecision(v float64, precision int) bool {
+	return math.Abs(v-roundTo(v, precision)) > 1e-9
+}
+
+func roundTo(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
+
+// CoordinateCheck holds the expectations CheckCoordinateOrder and the
+// importers judge coordinates by.  The zero CoordinateCheck expects data
+// anywhere on the globe, and treats only exactly 0,0 as Null Island.
+type CoordinateCheck struct {
+	Region    *Bounds // where the data is expected to be, or nil for anywhere
+	Tolerance float64 // distance in degrees from 0,0 treated as Null Island
+
+	// Suspect is called by FromCSV and FromGeoJSON for each suspect
+	// position, with the CSV line or GeoJSON feature index it's in, and a
+	// description of the problem.  Positions out of range are reported and
+	// skipped instead of failing the import (CSV) or being dropped silently
+	// (GeoJSON).
+	Suspect func(row int, lat float64, lon float64, problem string)
+}
+
+// expected reports whether a location is valid and in the expected region.
+func (c *CoordinateCheck) expected(lat float64, lon float64) bool {
+	if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return false
+	}
+
+	return c.Region == nil || c.Region.contains(lat, lon)
+}
+
+// nullIsland reports whether a location is within the tolerance of 0,0.
+func (c *CoordinateCheck) nullIsland(lat float64, lon float64) bool {
+	return math.Hypot(lat, lon) <= c.Tolerance
+}
+
+// problem describes what is suspect about a location, or returns an empty
+// string if nothing is.
+func (c *CoordinateCheck) problem(lat float64, lon float64) string {
+	switch {
+	case c.nullIsland(lat, lon):
+		return "at or near 0,0"
+	case c.expected(lat, lon):
+		return ""
+	case c.expected(lon, lat):
+		return "latitude and longitude may be swapped"
+	case math.Abs(lat) > 90 || math.Abs(lon) > 180:
+		return "out of range"
+	}
+
+	return "outside the expected region"
+}
+
+// report calls Suspect if a location is suspect, and returns whether it
+// was called.  The CoordinateCheck may be nil.
+func (c *CoordinateCheck) report(row int, lat float64, lon float64) bool {
+	if c == nil || c.Suspect == nil {
+		return false
+	}
+
+	problem := c.problem(lat, lon)
+	if len(problem) == 0 {
+		return false
+	}
+
+	c.Suspect(row, lat, lon, problem)
+	return true
+}
+
+// reporter returns a function reporting the suspect locations of a row.
+// The CoordinateCheck may be nil.
+func (c *CoordinateCheck) reporter(row int) func(lat float64, lon float64) {
+	return func(lat float64, lon float64) {
+		c.report(row, lat, lon)
+	}
+}
+
+// CheckCoordinateOrder looks for Placemarks whose latitudes and longitudes
+// are likely swapped, the most common mistake when importing data.  It is
+// a heuristic, so it isn't part of Lint.  A Placemark is flagged as
+// swapped-coordinates if some of its Points are out of range or outside
+// check.Region, while all of them would be inside it (or in range, without
+// a Region) if swapped; the Fix swaps its coordinates.  Latitudes beyond
+// ±90 are possible when Point fields are set directly, since NewPoint
+// rejects them.  Placemarks whose Points are all within check.Tolerance
+// degrees of 0,0 ("Null Island", usually the result of missing or unparsed
+// coordinates) are flagged as null-island, without a Fix.
+func (k *KML) CheckCoordinateOrder(check CoordinateCheck) []*LintFinding {
+	findings := make([]*LintFinding, 0)
+
+	where := "out of range"
+	if check.Region != nil {
+		where = "outside the expected region"
+	}
+
+	walkPlacemarks(k.rootFolder, func(pm *Placemark) {
+		geom := pm.geometry
+		count, unexpected, swappable, null := 0, false, true, 0
+
+		walkPoints(geom, func(p *Point) {
+			count++
+			unexpected = unexpected || !check.expected(p.Lat, p.Lon)
+			swappable = swappable && check.expected(p.Lon, p.Lat)
+			if check.nullIsland(p.Lat, p.Lon) {
+				null++
+			}
+		})
+
+		if unexpected && swappable {
+			findings = append(findings, &LintFinding{
+				Rule:       "swapped-coordinates",
+				Message:    fmt.Sprintf("coordinates of %q are %s; latitude and longitude may be swapped", pm.name, where),
+				Suggestion: "swap latitude and longitude",
+				Feature:    pm,
+				Fix: func() {
+					walkPoints(geom, func(p *Point) {
+						p.Lat, p.Lon = p.Lon, p.Lat
+					})
+				},
+			})
+		}
+
+		if count > 0 && null == count {
+			findings = append(findings, &LintFinding{
+				Rule:       "null-island",
+				Message:    fmt.Sprintf("%q is at or near 0,0", pm.name),
+				Suggestion: "check the source data for missing coordinates",
+				Feature:    pm,
+			})
+		}
+	})
+
+	return findings
+}
@@ -0,0 +1,226 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+)
+
+func lintRules(findings []*LintFinding) map[string]*LintFinding {
+	rules := make(map[string]*LintFinding)
+	for _, f := range findings {
+		rules[f.Rule] = f
+	}
+	return rules
+}
+
+func TestLint(t *testing.T) {
+	k := NewKML("Lint")
+
+	k.AddFeature(NewStyle("A", 255, 10, 20, 30))
+	k.AddFeature(NewStyle("B", 255, 10, 20, 30))
+
+	pm := NewPlacemark("Precise", strings.Repeat("x", lintMaxDescription+1), NewPoint(40.1234567891, -105.0, 0.0))
+	pm.SetStyle("B")
+	k.AddFeature(pm)
+
+	ring := NewPolygon()
+	ring.AddPoint(NewPoint(0.0, 0.0, 0.0))
+	ring.AddPoint(NewPoint(1.0, 0.0, 0.0))
+	ring.AddPoint(NewPoint(1.0, 1.0, 0.0))
+	k.AddFeature(NewPlacemark("Ring", "", ring))
+
+	big := NewFolder("Big", "")
+	for i := 0; i <= lintBigFolder; i++ {
+		big.AddFeature(NewPlacemark("", "", NewPoint(0.0, 0.0, 0.0)))
+	}
+	k.AddFeature(big)
+
+	rules := lintRules(k.Lint())
+	for _, rule := range []string{"oversized-description", "excessive-precision", "unshared-style", "big-folder", "unclosed-ring"} {
+		if _, ok := rules[rule]; !ok {
+			t.Errorf("expected a %s finding", rule)
+		}
+	}
+
+	for _, f := range rules {
+		if f.Fix != nil {
+			f.Fix()
+		}
+	}
+
+	rules = lintRules(k.Lint())
+	for _, rule := range []string{"excessive-precision", "unshared-style", "big-folder", "unclosed-ring"} {
+		if _, ok := rules[rule]; ok {
+			t.Errorf("%s finding remains after fix", rule)
+		}
+	}
+
+	if f := rules["oversized-description"]; f == nil || f.Fix != nil {
+		t.Errorf("expected an oversized-description finding without a Fix, got %v", f)
+	}
+
+	if big.region == nil || big.minLod != overviewMinLodPixels {
+		t.Error("big folder not limited to a Region")
+	}
+	if out := k.Render(); !strings.Contains(out, "<minLodPixels>256</minLodPixels>") {
+		t.Errorf("Region of big folder not rendered:\n%s", out[:500])
+	}
+
+	if pm.style != "A" {
+		t.Errorf("placemark not repointed to shared style, got %q", pm.style)
+	}
+
+	if k.FindByID("B") != nil {
+		t.Error("duplicate style not removed")
+	}
+}
+
+func TestLintSemantic(t *testing.T) {
+	k := NewKML("Semantic")
+
+	k.AddFeature(NewStyle("used", 255, 0, 0, 255))
+	k.AddFeature(NewStyle("spare", 255, 0, 255, 0))
+
+	styled := NewPlacemark("Styled", "", NewPoint(40.0, -105.0, 0.0))
+	styled.SetID("pm")
+	styled.SetStyle("used")
+	k.AddFeature(styled)
+
+	dangling := NewPlacemark("Dangling", "", NewPoint(40.0, -105.0, 0.0))
+	dangling.SetID("pm")
+	dangling.SetStyle("missing")
+	k.AddFeature(dangling)
+
+	k.AddFeature(NewPlacemark("Nowhere", "", nil))
+	k.AddFeature(NewPlacemark("Far", "", &Point{Lat: 40.0, Lon: 200.0}))
+
+	empty := NewFolder("Empty", "")
+	k.AddFeature(empty)
+
+	want := map[string]renderable{
+		"dangling-style":   dangling,
+		"unused-style":     k.FindByID("spare"),
+		"empty-folder":     empty,
+		"no-geometry":      nil,
+		"coordinate-range": nil,
+		"duplicate-id":     dangling,
+	}
+
+	findings := k.Lint()
+	rules := lintRules(findings)
+	for rule, feature := range want {
+		f, ok := rules[rule]
+		if !ok {
+			t.Errorf("expected a %s finding", rule)
+			continue
+		}
+		if feature != nil && f.Feature != feature {
+			t.Errorf("%s finding is for the wrong feature", rule)
+		}
+	}
+
+	if len(findings) != len(want) {
+		t.Errorf("got %d findings, want %d: %v", len(findings), len(want), findings)
+	}
+
+	rules["empty-folder"].Fix()
+	if _, ok := lintRules(k.Lint())["empty-folder"]; ok {
+		t.Error("empty folder not removed")
+	}
+}
+
+func TestLintParsedStyleMap(t *testing.T) {
+	k, err := Parse(strings.NewReader(`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+	<Style id="s"><LineStyle><color>ff0000ff</color></LineStyle></Style>
+	<StyleMap id="pair"><Pair><key>normal</key><styleUrl>#s</styleUrl></Pair></StyleMap>
+	<Placemark><styleUrl>#pair</styleUrl><Point><coordinates>1,2</coordinates></Point></Placemark>
+	</Document></kml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := lintRules(k.Lint())
+	for _, rule := range []string{"dangling-style", "unused-style"} {
+		if f, ok := rules[rule]; ok {
+			t.Errorf("unexpected finding: %v", f)
+		}
+	}
+}
+
+func TestLintUnsharedStyleRefs(t *testing.T) {
+	k, err := Parse(strings.NewReader(`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+	<StyleMap id="pair"><Pair><key>normal</key><styleUrl>#b</styleUrl></Pair><Pair><key>highlight</key><styleUrl>#b</styleUrl></Pair></StyleMap>
+	<Placemark><styleUrl>#pair</styleUrl><Point><coordinates>1,2</coordinates></Point></Placemark>
+	<Placemark><styleUrl>#b</styleUrl><Point><coordinates>3,4</coordinates></Point></Placemark>
+	</Document></kml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k.AddFeature(NewStyle("a", 255, 255, 0, 0))
+	k.AddFeature(NewStyle("b", 255, 255, 0, 0))
+
+	f := lintRules(k.Lint())["unshared-style"]
+	if f == nil {
+		t.Fatal("expected an unshared-style finding")
+	}
+	f.Fix()
+
+	var buf strings.Builder
+	if err := k.Write(&buf); err != nil {
+		t.Fatalf("document not written after fix: %v", err)
+	}
+	if out := buf.String(); strings.Contains(out, "#b") || strings.Count(out, "<styleUrl>#a</styleUrl>") != 3 {
+		t.Errorf("styleUrls not repointed to the shared style:\n%s", out)
+	}
+}
+
+func TestCheckCoordinateOrder(t *testing.T) {
+	k := NewKML("Import")
+
+	swapped := &Point{Lat: -105.0, Lon: 40.0} // NewPoint would reject it
+	k.AddFeature(NewPlacemark("Swapped", "", swapped))
+	k.AddFeature(NewPlacemark("Null Island", "", NewPoint(0.0, 0.0, 0.0)))
+	k.AddFeature(NewPlacemark("Fine", "", NewPoint(40.0, -105.0, 0.0)))
+
+	findings := k.CheckCoordinateOrder(CoordinateCheck{})
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings, want 2", len(findings))
+	}
+
+	rules := lintRules(findings)
+	fix := rules["swapped-coordinates"]
+	if fix == nil || rules["null-island"] == nil {
+		t.Fatalf("missing rules: %v", findings)
+	}
+
+	fix.Fix()
+	if swapped.Lat != 40.0 || swapped.Lon != -105.0 {
+		t.Errorf("fix didn't swap coordinates: %v,%v", swapped.Lat, swapped.Lon)
+	}
+}
+
+func TestCheckCoordinateOrderRegion(t *testing.T) {
+	k := NewKML("Import")
+
+	// in range either way, but only one way round is in Germany
+	swapped := NewPoint(11.6, 48.1, 0.0)
+	k.AddFeature(NewPlacemark("Munich", "", NewPoint(48.1, 11.6, 0.0)))
+	k.AddFeature(NewPlacemark("Swapped", "", swapped))
+	k.AddFeature(NewPlacemark("Near", "", NewPoint(0.001, -0.002, 0.0)))
+
+	if findings := k.CheckCoordinateOrder(CoordinateCheck{}); len(findings) != 0 {
+		t.Errorf("unexpected findings without a region: %v", findings)
+	}
+
+	findings := k.CheckCoordinateOrder(CoordinateCheck{Region: &Bounds{55, 47, 15, 6}, Tolerance: 0.01})
+	rules := lintRules(findings)
+	if len(findings) != 2 || rules["swapped-coordinates"] == nil || rules["null-island"] == nil {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+
+	rules["swapped-coordinates"].Fix()
+	if swapped.Lat != 48.1 || swapped.Lon != 11.6 {
+		t.Errorf("fix didn't swap coordinates: %v,%v", swapped.Lat, swapped.Lon)
+	}
+}
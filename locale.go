@@ -0,0 +1,99 @@
+package gokml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale describes how numbers and dates are written for readers in a
+// region, for formatting values in Placemark descriptions.
+type Locale struct {
+	Decimal   string // decimal separator
+	Grouping  string // thousands separator, or empty for none
+	DateOrder string // order of day, month, and year: "MDY", "DMY", or "YMD"
+	DateSep   string // separator between date fields
+}
+
+var (
+	// LocaleUS writes 1,234.5 and 12/31/2024.
+	LocaleUS = Locale{".", ",", "MDY", "/"}
+
+	// LocaleUK writes 1,234.5 and 31/12/2024.
+	LocaleUK = Locale{".", ",", "DMY", "/"}
+
+	// LocaleGerman writes 1.234,5 and 31.12.2024.
+	LocaleGerman = Locale{",", ".", "DMY", "."}
+
+	// LocaleFrench writes 1 234,5 (with a narrow no-break space) and
+	// 31/12/2024.
+	LocaleFrench = Locale{",", "\u202f", "DMY", "/"}
+
+	// LocaleISO writes 1234.5 and 2024-12-31.
+	LocaleISO = Locale{".", "", "YMD", "-"}
+)
+
+// Number formats v with the given number of decimal places.
+func (l Locale) Number(v float64, decimals int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+
+	var b strings.Builder
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.Grouping)
+		}
+		b.WriteRune(c)
+	}
+
+	if len(frac) > 0 {
+		b.WriteString(l.Decimal)
+		b.WriteString(frac)
+	}
+
+	return b.String()
+}
+
+// Date formats the date of t.
+func (l Locale) Date(t time.Time) string {
+	d := fmt.Sprintf("%02d", t.Day())
+	m := fmt.Sprintf("%02d", int(t.Month()))
+	y := fmt.Sprintf("%04d", t.Year())
+
+	switch l.DateOrder {
+	case "DMY":
+		return d + l.DateSep + m + l.DateSep + y
+	case "YMD":
+		return y + l.DateSep + m + l.DateSep + d
+	}
+
+	return m + l.DateSep + d + l.DateSep + y
+}
+
+// DateTime formats the date and 24-hour time of t.
+func (l Locale) DateTime(t time.Time) string {
+	return l.Date(t) + " " + t.Format("15:04")
+}
+
+// FuncMap returns the Locale's formatting functions for use in description
+// templates (text/template or html/template): number, date, and datetime.
+func (l Locale) FuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"number":   l.Number,
+		"date":     l.Date,
+		"datetime": l.DateTime,
+	}
+}
@@ -0,0 +1,67 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestLocaleNumber(t *testing.T) {
+	tests := []struct {
+		locale   Locale
+		v        float64
+		decimals int
+		want     string
+	}{
+		{LocaleUS, 1234567.891, 2, "1,234,567.89"},
+		{LocaleGerman, 1234567.891, 2, "1.234.567,89"},
+		{LocaleFrench, -1234.5, 1, "-1\u202f234,5"},
+		{LocaleISO, 1234.5, 0, "1234"},
+		{LocaleUS, 999, 0, "999"},
+		{LocaleUS, -0.001, 2, "0.00"},
+	}
+
+	for _, test := range tests {
+		if got := test.locale.Number(test.v, test.decimals); got != test.want {
+			t.Errorf("Number(%v, %d) = %q, want %q", test.v, test.decimals, got, test.want)
+		}
+	}
+}
+
+func TestLocaleDate(t *testing.T) {
+	d := time.Date(2024, time.December, 31, 14, 5, 0, 0, time.UTC)
+
+	for locale, want := range map[*Locale]string{
+		&LocaleUS:     "12/31/2024",
+		&LocaleUK:     "31/12/2024",
+		&LocaleGerman: "31.12.2024",
+		&LocaleISO:    "2024-12-31",
+	} {
+		if got := locale.Date(d); got != want {
+			t.Errorf("Date = %q, want %q", got, want)
+		}
+	}
+
+	if got := LocaleGerman.DateTime(d); got != "31.12.2024 14:05" {
+		t.Errorf("DateTime = %q", got)
+	}
+}
+
+func TestLocaleFuncMap(t *testing.T) {
+	tmpl := template.Must(template.New("desc").Funcs(LocaleGerman.FuncMap()).Parse(
+		`Revenue: {{number .Revenue 2}} ({{date .Updated}})`))
+
+	var b strings.Builder
+	err := tmpl.Execute(&b, map[string]interface{}{
+		"Revenue": 1234.5,
+		"Updated": time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Revenue: 1.234,50 (01.03.2024)"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
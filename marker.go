@@ -0,0 +1,57 @@
+package gokml
+
+import (
+	"sort"
+	"strings"
+)
+
+// marker is a curated icon, color, and scale for a semantic marker name.
+type marker struct {
+	icon                    string
+	alpha, red, green, blue uint8
+	scale                   float64
+}
+
+const markerIconBase = "http://maps.google.com/mapfiles/kml/shapes/"
+
+var markers = map[string]marker{
+	"aircraft": {markerIconBase + "airports.png", 255, 255, 255, 255, 1.2},
+	"camera":   {markerIconBase + "camera.png", 255, 255, 255, 255, 1.0},
+	"car":      {markerIconBase + "cabs.png", 255, 255, 255, 255, 1.0},
+	"flag":     {markerIconBase + "flag.png", 255, 230, 40, 40, 1.1},
+	"info":     {markerIconBase + "info-i.png", 255, 80, 150, 255, 1.0},
+	"person":   {markerIconBase + "man.png", 255, 255, 255, 255, 1.0},
+	"ship":     {markerIconBase + "ferry.png", 255, 60, 140, 255, 1.2},
+	"warning":  {markerIconBase + "caution.png", 255, 255, 190, 0, 1.3},
+}
+
+// NewMarkerStyle returns a pointer to a new Style for one of a small set of
+// semantic markers, with a suitable icon, color, and scale: "aircraft",
+// "camera", "car", "flag", "info", "person", "ship", or "warning".  The
+// Style is named after the marker, so Placemarks can use it with
+// SetStyle(marker).  Returns nil if the marker isn't known.
+func NewMarkerStyle(name string) *Style {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	m, ok := markers[name]
+	if !ok {
+		return nil
+	}
+
+	s := NewStyle(name, m.alpha, m.red, m.green, m.blue)
+	s.SetIconURL(m.icon)
+	s.SetIconScale(m.scale)
+
+	return s
+}
+
+// Markers returns the names of the markers known to NewMarkerStyle, sorted.
+func Markers() []string {
+	names := make([]string, 0, len(markers))
+	for name := range markers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
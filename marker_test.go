@@ -0,0 +1,29 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewMarkerStyle(t *testing.T) {
+	for _, name := range Markers() {
+		s := NewMarkerStyle(name)
+		if s == nil {
+			t.Errorf("no style for marker %q", name)
+			continue
+		}
+
+		if s.name != name || !strings.HasPrefix(s.iconURL, markerIconBase) {
+			t.Errorf("marker %q has style %+v", name, s)
+		}
+	}
+
+	s := NewMarkerStyle(" Warning ")
+	if s == nil || s.name != "warning" || s.iconScale != 1.3 {
+		t.Errorf("marker names should be case insensitive: %+v", s)
+	}
+
+	if NewMarkerStyle("unicorn") != nil {
+		t.Error("style for unknown marker")
+	}
+}
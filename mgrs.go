@@ -0,0 +1,116 @@
+package gokml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// mgrsBands are the letters of the 8 degree latitude bands from 80
+	// degrees south, the last, X, being 12 degrees.
+	mgrsBands = "CDEFGHJKLMNPQRSTUVWX"
+
+	// mgrsRows are the letters of the 100 km rows, repeating every 2000 km,
+	// starting 500 km further north in even zones.
+	mgrsRows = "ABCDEFGHJKLMNPQRSTUV"
+)
+
+// mgrsColumns are the letters of the 100 km columns of zones 1, 2, and 3,
+// repeating every three zones.
+var mgrsColumns = [3]string{"ABCDEFGH", "JKLMNPQR", "STUVWXYZ"}
+
+// MGRS returns the Military Grid Reference System reference of the grid
+// square holding the Point, such as "31U DQ 48251 11932", to a precision of
+// 0 to 5 digits each of easting and northing, for squares from 100 km down
+// to 1 m.  It returns false for a precision outside that range or for
+// Points outside the UTM grid, near the poles.
+func (p *Point) MGRS(precision int) (string, bool) {
+	if precision < 0 || precision > 5 {
+		return "", false
+	}
+
+	u, ok := p.UTM()
+	if !ok {
+		return "", false
+	}
+
+	band := mgrsBands[minInt(int(math.Floor((p.Lat+80)/8)), len(mgrsBands)-1)]
+
+	column := mgrsColumns[(u.Zone-1)%3][int(u.Easting/100000)-1]
+	row := int(math.Floor(u.Northing/100000)) % 20
+	if u.Zone%2 == 0 {
+		row = (row + 5) % 20
+	}
+
+	ref := fmt.Sprintf("%02d%c %c%c", u.Zone, band, column, mgrsRows[row])
+	if precision > 0 {
+		scale := math.Pow(10, float64(5-precision))
+		e := int(math.Mod(u.Easting, 100000) / scale)
+		n := int(math.Mod(u.Northing, 100000) / scale)
+		ref += fmt.Sprintf(" %0*d %0*d", precision, e, precision, n)
+	}
+
+	return ref, true
+}
+
+// ParseMGRS returns the Point at the south-west corner of the grid square
+// of a Military Grid Reference System reference, such as "31U DQ 48251
+// 11932" or "31UDQ4825111932", with any precision from 100 km to 1 m.
+func ParseMGRS(s string) (*Point, error) {
+	invalid := fmt.Errorf("gokml: invalid MGRS reference %q", s)
+
+	ref := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	digits := 0
+	for digits < len(ref) && digits < 2 && ref[digits] >= '0' && ref[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || len(ref) < digits+3 {
+		return nil, invalid
+	}
+
+	zone, _ := strconv.Atoi(ref[:digits])
+	band := strings.IndexByte(mgrsBands, ref[digits])
+	if zone < 1 || zone > 60 || band < 0 {
+		return nil, invalid
+	}
+
+	column := strings.IndexByte(mgrsColumns[(zone-1)%3], ref[digits+1])
+	row := strings.IndexByte(mgrsRows, ref[digits+2])
+	if column < 0 || row < 0 {
+		return nil, invalid
+	}
+	if zone%2 == 0 {
+		row = (row + 15) % 20
+	}
+
+	location := ref[digits+3:]
+	if len(location)%2 != 0 || len(location) > 10 {
+		return nil, invalid
+	}
+	precision := len(location) / 2
+	scale := math.Pow(10, float64(5-precision))
+
+	var e, n float64
+	if precision > 0 {
+		ei, err1 := strconv.Atoi(location[:precision])
+		ni, err2 := strconv.Atoi(location[precision:])
+		if err1 != nil || err2 != nil || strings.ContainsAny(location, "+-") {
+			return nil, invalid
+		}
+		e, n = float64(ei)*scale, float64(ni)*scale
+	}
+
+	u := UTM{zone, band >= strings.IndexByte(mgrsBands, 'N'), float64(column+1)*100000 + e, float64(row)*100000 + n}
+
+	// the rows repeat every 2000 km, so find the repeat in the band, from
+	// a little south of where its southern edge is on the central meridian
+	south := float64(-80 + 8*band)
+	bottom := utmInZone(&Point{south, float64(6*zone - 183), 0}, zone).Northing - 100000
+	for u.Northing < bottom {
+		u.Northing += 2000000
+	}
+
+	return u.Point()
+}
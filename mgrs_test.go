@@ -0,0 +1,71 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPointMGRS(t *testing.T) {
+	eiffel := NewPoint(48.8582, 2.2945, 0)
+	want := []string{"31U DQ", "31U DQ 4 1", "31U DQ 48 11", "31U DQ 482 119", "31U DQ 4825 1193", "31U DQ 48251 11932"}
+	for precision, w := range want {
+		if got, ok := eiffel.MGRS(precision); !ok || got != w {
+			t.Errorf("MGRS(%d) = %q, %v, want %q", precision, got, ok, w)
+		}
+	}
+
+	if got, _ := NewPoint(21.3, -157.85, 0).MGRS(1); got != "04Q FJ 1 5" {
+		t.Errorf("zone 4 reference = %q", got)
+	}
+
+	if _, ok := eiffel.MGRS(6); ok {
+		t.Errorf("precision 6 accepted")
+	}
+	if _, ok := NewPoint(-85, 0, 0).MGRS(5); ok {
+		t.Errorf("point near the south pole converted")
+	}
+}
+
+func TestParseMGRS(t *testing.T) {
+	p, err := ParseMGRS("31U DQ 48251 11932")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := p.Distance(NewPoint(48.8582, 2.2945, 0)); d > 1.5 {
+		t.Errorf("parsed %v, %v m from the Eiffel tower", p, d)
+	}
+	if q, err := ParseMGRS("31udq4825111932"); err != nil || *q != *p {
+		t.Errorf("parsed %v, %v without spaces", q, err)
+	}
+
+	// round trips at each precision, around the world
+	for _, point := range []*Point{
+		NewPoint(48.8582, 2.2945, 0),
+		NewPoint(-33.8568, 151.2153, 0),
+		NewPoint(21.3, -157.85, 0),
+		NewPoint(-79.5, 60, 0),
+		NewPoint(83.5, -30, 0),
+		NewPoint(0.0001, 10, 0),
+		NewPoint(-0.0001, 10, 0),
+		NewPoint(78, 15, 0),
+	} {
+		for precision := 0; precision <= 5; precision++ {
+			ref, _ := point.MGRS(precision)
+			corner, err := ParseMGRS(ref)
+			if err != nil {
+				t.Errorf("ParseMGRS(%q): %v", ref, err)
+				continue
+			}
+			size := math.Pow(10, float64(5-precision))
+			if d := corner.Distance(point); d > size*math.Sqrt2*1.01 {
+				t.Errorf("%q is %v m from %v", ref, d, point)
+			}
+		}
+	}
+
+	for _, s := range []string{"", "31U", "31UDQ123", "61U DQ 1 1", "31I DQ 1 1", "31U IQ 1 1", "31U DW 1 1", "31U DQ 1x 11", "U DQ 1 1"} {
+		if _, err := ParseMGRS(s); err == nil {
+			t.Errorf("ParseMGRS(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package gokml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MAVLink mission commands and frames
+const (
+	missionWaypoint    = 16
+	missionFrameGlobal = 0 // altitudes above sea level
+	missionFrameHome   = 3 // altitudes above the home position
+)
+
+// missionCommands names the MAVLink mission commands with a position.
+var missionCommands = map[int]string{
+	16: "Waypoint",
+	17: "Loiter",
+	18: "Loiter",
+	19: "Loiter",
+	21: "Land",
+	22: "Takeoff",
+	31: "Loiter",
+	82: "Spline waypoint",
+}
+
+// missionItem is a MAVLink mission item.  params 5 to 7 are the latitude,
+// longitude, and altitude; nil params are unset (NaN in MAVLink).
+type missionItem struct {
+	command int
+	frame   int
+	params  [7]*float64
+}
+
+// planItem is a mission item in a QGroundControl plan file.  Complex items,
+// such as surveys, hold the simple items they generate.
+type planItem struct {
+	Type         string       `json:"type"`
+	AutoContinue bool         `json:"autoContinue"`
+	Command      int          `json:"command"`
+	DoJumpID     int          `json:"doJumpId"`
+	Frame        int          `json:"frame"`
+	Params       [7]*float64  `json:"params"`
+	Transect     *planSection `json:"TransectStyleComplexItem,omitempty"`
+}
+
+type planSection struct {
+	Items []*planItem `json:"Items"`
+}
+
+type planFile struct {
+	FileType      string `json:"fileType"`
+	GroundStation string `json:"groundStation"`
+	Version       int    `json:"version"`
+	Mission       struct {
+		Version             int         `json:"version"`
+		FirmwareType        int         `json:"firmwareType"`
+		VehicleType         int         `json:"vehicleType"`
+		CruiseSpeed         float64     `json:"cruiseSpeed"`
+		HoverSpeed          float64     `json:"hoverSpeed"`
+		PlannedHomePosition []float64   `json:"plannedHomePosition"`
+		Items               []*planItem `json:"items"`
+	} `json:"mission"`
+	GeoFence    json.RawMessage `json:"geoFence"`
+	RallyPoints json.RawMessage `json:"rallyPoints"`
+}
+
+// ReadMission converts a drone mission, either a QGroundControl plan file
+// (.plan) or a MAVLink waypoint file ("QGC WPL 110", as saved by Mission
+// Planner), into a KML document for review.  The home position becomes a
+// Placemark with the id "home", each mission item with a position becomes a
+// Placemark named by its command and number (with the command, frame, and
+// first four params as ExtendedData), and the flight path becomes a
+// LineString, with altitudes relative to the ground unless the mission uses
+// altitudes above sea level.  Items without a position, such as speed
+// changes, are skipped, and the items of complex items (surveys) are
+// included in turn.
+func ReadMission(r io.Reader) (*KML, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSpace(bytes.TrimPrefix(b, []byte("\ufeff")))
+
+	var home *Point
+	var items []*missionItem
+	if bytes.HasPrefix(b, []byte("{")) {
+		home, items, err = readPlan(bytes.NewReader(b))
+	} else {
+		home, items, err = readWPL(bytes.NewReader(b))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := NewKML("Mission")
+
+	mode := Absolute
+	for _, item := range items {
+		if item.frame != missionFrameGlobal {
+			mode = RelativeToGround
+		}
+	}
+
+	path := NewLineString()
+	path.SetAltitudeMode(mode)
+
+	if home != nil {
+		pm := NewPlacemark("Home", "", home)
+		pm.SetID("home")
+		k.AddFeature(pm)
+
+		start := *home
+		if mode != Absolute {
+			start.Alt = 0
+		}
+		path.AddPoint(&start)
+	}
+
+	for i, item := range items {
+		p := item.point()
+		if p == nil {
+			continue
+		}
+
+		pm := NewPlacemark(fmt.Sprintf("%s %d", missionCommands[item.command], i+1), "", p)
+		pm.SetData("command", strconv.Itoa(item.command))
+		pm.SetData("frame", strconv.Itoa(item.frame))
+		for j, v := range item.params[:4] {
+			if v != nil && *v != 0 {
+				pm.SetData(fmt.Sprintf("param%d", j+1), strconv.FormatFloat(*v, 'f', -1, 64))
+			}
+		}
+		k.AddFeature(pm)
+
+		path.AddPoint(p)
+	}
+
+	if len(path.coordinates) > 1 {
+		k.AddFeature(NewPlacemark("Flight path", "", path))
+	}
+
+	return k, nil
+}
+
+// point returns the position of a mission item, or nil if it has none.
+func (item *missionItem) point() *Point {
+	if _, ok := missionCommands[item.command]; !ok {
+		return nil
+	}
+
+	var v [3]float64
+	for i, p := range item.params[4:] {
+		if p != nil {
+			v[i] = *p
+		}
+	}
+	if v[0] == 0 && v[1] == 0 {
+		return nil // unset
+	}
+
+	return NewPoint(v[0], v[1], v[2])
+}
+
+// readPlan reads the home position and mission items of a QGroundControl
+// plan file.
+func readPlan(r io.Reader) (*Point, []*missionItem, error) {
+	var plan planFile
+	if err := json.NewDecoder(r).Decode(&plan); err != nil {
+		return nil, nil, fmt.Errorf("gokml: invalid plan file: %v", err)
+	}
+	if plan.FileType != "Plan" {
+		return nil, nil, fmt.Errorf("gokml: not a plan file (file type %q)", plan.FileType)
+	}
+
+	var home *Point
+	if h := plan.Mission.PlannedHomePosition; len(h) == 3 {
+		home = NewPoint(h[0], h[1], h[2])
+	}
+
+	var items []*missionItem
+	var add func(list []*planItem)
+	add = func(list []*planItem) {
+		for _, item := range list {
+			if item.Transect != nil {
+				add(item.Transect.Items)
+			} else if item.Type == "SimpleItem" {
+				items = append(items, &missionItem{item.Command, item.Frame, item.Params})
+			}
+		}
+	}
+	add(plan.Mission.Items)
+
+	return home, items, nil
+}
+
+// readWPL reads the home position (item 0) and mission items of a MAVLink
+// waypoint file.
+func readWPL(r io.Reader) (*Point, []*missionItem, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), "QGC WPL") {
+		return nil, nil, fmt.Errorf("gokml: not a mission file")
+	}
+
+	var home *Point
+	var items []*missionItem
+
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 12 {
+			return nil, nil, fmt.Errorf("gokml: mission line %d has %d fields, want 12", line, len(fields))
+		}
+
+		var v [11]float64
+		for i, f := range fields[:11] {
+			var err error
+			if v[i], err = strconv.ParseFloat(f, 64); err != nil {
+				return nil, nil, fmt.Errorf("gokml: mission line %d: invalid number %q", line, f)
+			}
+		}
+
+		item := &missionItem{command: int(v[3]), frame: int(v[2])}
+		for i := range item.params {
+			p := v[4+i]
+			item.params[i] = &p
+		}
+
+		if v[0] == 0 {
+			home = NewPoint(v[8], v[9], v[10])
+		} else {
+			items = append(items, item)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return home, items, nil
+}
+
+// missionItems returns the mission of the document: the home position (the
+// Placemark with the id "home", or else the first waypoint), and an item
+// for each Placemark with a Point, or for each Point of the LineStrings if
+// there are none.  Points are waypoints with altitudes above the home
+// position, unless their ExtendedData says otherwise (as set by
+// ReadMission).
+func (k *KML) missionItems() (*Point, []*missionItem) {
+	var home *Point
+	var items, pathItems []*missionItem
+
+	walkPlacemarks(k.rootFolder, func(pm *Placemark) {
+		switch g := pm.geometry.(type) {
+		case *Point:
+			if pm.id == "home" {
+				home = g
+				return
+			}
+
+			data := func(name string) string {
+				v, _ := pm.Data(name)
+				return v
+			}
+
+			item := &missionItem{command: missionWaypoint, frame: missionFrameHome}
+			if v, err := strconv.Atoi(data("command")); err == nil {
+				item.command = v
+			}
+			if v, err := strconv.Atoi(data("frame")); err == nil {
+				item.frame = v
+			}
+			for i := range item.params[:4] {
+				if v, err := strconv.ParseFloat(data(fmt.Sprintf("param%d", i+1)), 64); err == nil {
+					item.params[i] = &v
+				}
+			}
+			item.setPoint(g)
+			items = append(items, item)
+		case *LineString:
+			frame := missionFrameHome
+			if g.altitudeMode == Absolute {
+				frame = missionFrameGlobal
+			}
+			for _, p := range g.coordinates {
+				item := &missionItem{command: missionWaypoint, frame: frame}
+				item.setPoint(p)
+				pathItems = append(pathItems, item)
+			}
+		}
+	})
+
+	if len(items) == 0 {
+		items = pathItems
+	}
+	if home == nil && len(items) > 0 {
+		home = items[0].point()
+	}
+
+	return home, items
+}
+
+// setPoint sets the position of a mission item.
+func (item *missionItem) setPoint(p *Point) {
+	lat, lon, alt := p.Lat, p.Lon, p.Alt
+	item.params[4], item.params[5], item.params[6] = &lat, &lon, &alt
+}
+
+// WriteMissionPlan writes the Placemarks of the document as a drone mission
+// in a QGroundControl plan file (.plan), for flight planning.  The home
+// position and items are taken from the document as described by
+// WriteMissionWPL.  Returns an error if the document has no positions.
+func (k *KML) WriteMissionPlan(w io.Writer) error {
+	home, items := k.missionItems()
+	if home == nil {
+		return fmt.Errorf("gokml: document has no waypoints")
+	}
+
+	var plan planFile
+	plan.FileType = "Plan"
+	plan.GroundStation = "gokml"
+	plan.Version = 1
+	plan.Mission.Version = 2
+	plan.Mission.VehicleType = 2 // multirotor
+	plan.Mission.CruiseSpeed = 15
+	plan.Mission.HoverSpeed = 5
+	plan.Mission.PlannedHomePosition = []float64{home.Lat, home.Lon, home.Alt}
+	plan.Mission.Items = []*planItem{}
+	plan.GeoFence = json.RawMessage(`{"circles":[],"polygons":[],"version":2}`)
+	plan.RallyPoints = json.RawMessage(`{"points":[],"version":2}`)
+
+	for i, item := range items {
+		plan.Mission.Items = append(plan.Mission.Items, &planItem{
+			Type:         "SimpleItem",
+			AutoContinue: true,
+			Command:      item.command,
+			DoJumpID:     i + 1,
+			Frame:        item.frame,
+			Params:       item.params,
+		})
+	}
+
+	b, err := json.MarshalIndent(&plan, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteMissionWPL writes the Placemarks of the document as a drone mission
+// in a MAVLink waypoint file ("QGC WPL 110"), for Mission Planner and other
+// ground stations.  The home position is the Placemark with the id "home",
+// or else the first waypoint.  Each other Placemark with a Point becomes a
+// waypoint, or if there are none, each Point of the LineStrings does.
+// Altitudes are above the home position (Absolute LineStrings excepted),
+// and the command, frame, and params of items read by ReadMission are kept.
+// Returns an error if the document has no positions.
+func (k *KML) WriteMissionWPL(w io.Writer) error {
+	home, items := k.missionItems()
+	if home == nil {
+		return fmt.Errorf("gokml: document has no waypoints")
+	}
+
+	homeItem := &missionItem{command: missionWaypoint, frame: missionFrameGlobal}
+	homeItem.setPoint(home)
+
+	var b bytes.Buffer
+	b.WriteString("QGC WPL 110\n")
+
+	for i, item := range append([]*missionItem{homeItem}, items...) {
+		current := 0
+		if i == 0 {
+			current = 1
+		}
+		fmt.Fprintf(&b, "%d\t%d\t%d\t%d", i, current, item.frame, item.command)
+		for _, p := range item.params {
+			v := 0.0
+			if p != nil {
+				v = *p
+			}
+			fmt.Fprintf(&b, "\t%s", strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		b.WriteString("\t1\n")
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
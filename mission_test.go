@@ -0,0 +1,143 @@
+package gokml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const planFixture = `{
+    "fileType": "Plan",
+    "groundStation": "QGroundControl",
+    "version": 1,
+    "mission": {
+        "version": 2,
+        "plannedHomePosition": [47.3977, 8.5456, 488],
+        "items": [
+            {"type": "SimpleItem", "autoContinue": true, "command": 22, "doJumpId": 1, "frame": 3, "params": [15, 0, 0, null, 47.3978, 8.5457, 30]},
+            {"type": "SimpleItem", "autoContinue": true, "command": 178, "doJumpId": 2, "frame": 2, "params": [1, 8, -1, 0, 0, 0, 0]},
+            {"type": "ComplexItem", "complexItemType": "survey", "TransectStyleComplexItem": {"Items": [
+                {"type": "SimpleItem", "autoContinue": true, "command": 16, "doJumpId": 3, "frame": 3, "params": [0, 0, 0, null, 47.3980, 8.5460, 50]},
+                {"type": "SimpleItem", "autoContinue": true, "command": 16, "doJumpId": 4, "frame": 3, "params": [0, 0, 0, null, 47.3985, 8.5460, 50]}
+            ]}},
+            {"type": "SimpleItem", "autoContinue": true, "command": 21, "doJumpId": 5, "frame": 3, "params": [0, 0, 0, null, 47.3977, 8.5456, 0]}
+        ]
+    },
+    "geoFence": {"circles": [], "polygons": [], "version": 2},
+    "rallyPoints": {"points": [], "version": 2}
+}`
+
+const wplFixture = "QGC WPL 110\n" +
+	"0\t1\t0\t16\t0\t0\t0\t0\t-35.363262\t149.165237\t584.09\t1\n" +
+	"1\t0\t3\t22\t0\t0\t0\t0\t-35.361354\t149.163999\t20\t1\n" +
+	"2\t0\t3\t16\t5\t0\t0\t0\t-35.364114\t149.166022\t40\t1\n"
+
+func TestReadMissionPlan(t *testing.T) {
+	k, err := ReadMission(strings.NewReader(planFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range k.rootFolder.features {
+		names = append(names, f.(*Placemark).name)
+	}
+	if got := strings.Join(names, ", "); got != "Home, Takeoff 1, Waypoint 3, Waypoint 4, Land 5, Flight path" {
+		t.Errorf("placemarks = %s", got)
+	}
+
+	takeoff := k.rootFolder.features[1].(*Placemark)
+	if p := takeoff.geometry.(*Point); p.Lat != 47.3978 || p.Alt != 30 {
+		t.Errorf("unexpected takeoff point %v", p)
+	}
+	if v, _ := takeoff.Data("param1"); v != "15" {
+		t.Errorf("param1 = %q", v)
+	}
+
+	path := k.rootFolder.features[5].(*Placemark).geometry.(*LineString)
+	if len(path.coordinates) != 5 || path.altitudeMode != RelativeToGround || path.coordinates[0].Alt != 0 {
+		t.Errorf("unexpected flight path %v %s", path.coordinates, path.altitudeMode)
+	}
+	if !strings.Contains(k.Render(), "<altitudeMode>relativeToGround</altitudeMode>") {
+		t.Errorf("flight path altitude mode not rendered")
+	}
+}
+
+func TestReadMissionWPL(t *testing.T) {
+	k, err := ReadMission(strings.NewReader(wplFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	home := k.rootFolder.features[0].(*Placemark)
+	if p := home.geometry.(*Point); home.id != "home" || p.Lat != -35.363262 || p.Alt != 584.09 {
+		t.Errorf("unexpected home %v", p)
+	}
+	if n := len(k.rootFolder.features); n != 4 {
+		t.Errorf("got %d placemarks, want 4", n)
+	}
+
+	// the mission survives a round trip
+	var b bytes.Buffer
+	if err := k.WriteMissionWPL(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != wplFixture {
+		t.Errorf("round trip differs:\n%s", b.String())
+	}
+}
+
+func TestWriteMissionPlan(t *testing.T) {
+	k := NewKML("Survey")
+	ls := NewLineString()
+	ls.AddPoint(NewPoint(40.0, -105.0, 30))
+	ls.AddPoint(NewPoint(40.1, -105.0, 30))
+	k.AddFeature(NewPlacemark("Route", "", ls))
+
+	var b bytes.Buffer
+	if err := k.WriteMissionPlan(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`"fileType": "Plan"`,
+		`"plannedHomePosition": [
+            40,
+            -105,
+            30
+        ]`,
+		`"params": [
+                    null,
+                    null,
+                    null,
+                    null,
+                    40.1,
+                    -105,
+                    30
+                ]`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, b.String())
+		}
+	}
+
+	again, err := ReadMission(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(again.rootFolder.features); n != 4 {
+		t.Errorf("got %d placemarks after a round trip, want 4", n)
+	}
+
+	if err := NewKML("Empty").WriteMissionPlan(&b); err == nil {
+		t.Errorf("no error for a document without waypoints")
+	}
+}
+
+func TestReadMissionErrors(t *testing.T) {
+	for _, doc := range []string{``, `{"fileType": "GeoFence"}`, `{`, "QGC WPL 110\n1 0 3 16\n", "<kml/>"} {
+		if _, err := ReadMission(strings.NewReader(doc)); err == nil {
+			t.Errorf("no error for %q", doc)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package gokml
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadNMEA reads NMEA 0183 sentences, such as the output of a GPS logger,
+// and returns a Track of the fixes in them.  Positions are taken from GGA
+// and RMC sentences from any talker (GP, GN, etc.), and altitudes from GGA
+// sentences; a GGA and an RMC sentence for the same time make a single
+// point.  Dates come from RMC sentences, so fixes before the first RMC
+// sentence are dated by it, and later GGA times that wrap past midnight move
+// to the next day.  Sentences with a bad checksum, invalid fixes, and other
+// sentences are skipped.  The bytes read are reported to progress, if it
+// isn't nil.
+func ReadNMEA(r io.Reader, progress Progress) (*Track, error) {
+	var fixes []*nmeaFix
+	var date time.Time // midnight UTC of the current day, zero until known
+	var last time.Duration
+
+	scanner := bufio.NewScanner(readProgress(r, progress))
+	for scanner.Scan() {
+		fields, ok := nmeaFields(scanner.Text())
+		if !ok || len(fields[0]) < 5 {
+			continue
+		}
+
+		var fix *nmeaFix
+		switch fields[0][len(fields[0])-3:] {
+		case "GGA":
+			if len(fields) < 10 || fields[6] == "0" || len(fields[6]) == 0 {
+				continue
+			}
+			fix = nmeaPosition(fields[1], fields[2], fields[3], fields[4], fields[5])
+			if fix != nil {
+				fix.alt, fix.hasAlt = nmeaFloat(fields[9])
+			}
+		case "RMC":
+			if len(fields) < 10 {
+				continue
+			}
+			if d, err := time.Parse("020106", fields[9]); err == nil {
+				if date.IsZero() {
+					// date the fixes read so far
+					for _, f := range fixes {
+						f.when = d.Add(f.when.Sub(time.Time{}))
+					}
+				}
+				date = d
+			}
+			if fields[2] == "A" {
+				fix = nmeaPosition(fields[1], fields[3], fields[4], fields[5], fields[6])
+			}
+		}
+
+		if fix == nil {
+			continue
+		}
+
+		// time of day wrapped past midnight since the last fix (allowing
+		// for sentences slightly out of order)
+		tod := fix.when.Sub(time.Time{})
+		if !date.IsZero() && tod < last-12*time.Hour && !strings.HasSuffix(fields[0], "RMC") {
+			date = date.AddDate(0, 0, 1)
+		}
+		last = tod
+
+		if !date.IsZero() {
+			fix.when = date.Add(tod)
+		}
+
+		// merge the GGA and RMC sentences of the same fix
+		if n := len(fixes); n > 0 && fixes[n-1].when.Equal(fix.when) {
+			prev := fixes[n-1]
+			if fix.hasAlt {
+				prev.alt, prev.hasAlt = fix.alt, true
+			}
+			continue
+		}
+
+		fixes = append(fixes, fix)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	track := NewTrack()
+	for _, f := range fixes {
+		track.AddPoint(f.when, NewPoint(f.lat, f.lon, f.alt))
+	}
+
+	return track, nil
+}
+
+type nmeaFix struct {
+	when   time.Time // time of day from the zero time until the date is known
+	lat    float64
+	lon    float64
+	alt    float64
+	hasAlt bool
+}
+
+// nmeaFields returns the fields of a sentence, checking its checksum if it
+// has one.
+func nmeaFields(line string) ([]string, bool) {
+	line = strings.TrimSpace(line)
+	if i := strings.IndexAny(line, "$!"); i >= 0 {
+		line = line[i+1:]
+	} else {
+		return nil, false
+	}
+
+	if star := strings.LastIndexByte(line, '*'); star >= 0 {
+		want, err := strconv.ParseUint(line[star+1:], 16, 8)
+		if err != nil {
+			return nil, false
+		}
+
+		var sum byte
+		for i := 0; i < star; i++ {
+			sum ^= line[i]
+		}
+		if uint64(sum) != want {
+			return nil, false
+		}
+
+		line = line[:star]
+	}
+
+	return strings.Split(line, ","), true
+}
+
+// nmeaPosition returns the fix for a time and a position in NMEA's
+// ddmm.mmmm format, or nil if they're invalid.
+func nmeaPosition(hhmmss string, lat string, ns string, lon string, ew string) *nmeaFix {
+	if len(hhmmss) < 6 {
+		return nil
+	}
+
+	var hms [3]int
+	for i := range hms {
+		v, err := strconv.Atoi(hhmmss[2*i : 2*i+2])
+		if err != nil {
+			return nil
+		}
+		hms[i] = v
+	}
+
+	frac := 0.0
+	if len(hhmmss) > 6 {
+		var err error
+		if frac, err = strconv.ParseFloat("0"+hhmmss[6:], 64); err != nil {
+			return nil
+		}
+	}
+
+	latV, latOK := nmeaDegrees(lat, 2)
+	lonV, lonOK := nmeaDegrees(lon, 3)
+	if !latOK || !lonOK {
+		return nil
+	}
+
+	switch {
+	case ns == "S":
+		latV = -latV
+	case ns != "N":
+		return nil
+	}
+
+	switch {
+	case ew == "W":
+		lonV = -lonV
+	case ew != "E":
+		return nil
+	}
+
+	if NewPoint(latV, lonV, 0) == nil {
+		return nil
+	}
+
+	tod := time.Duration(hms[0])*time.Hour + time.Duration(hms[1])*time.Minute + time.Duration(hms[2])*time.Second +
+		time.Duration(frac*float64(time.Second)).Round(time.Millisecond)
+
+	return &nmeaFix{when: time.Time{}.Add(tod), lat: latV, lon: lonV}
+}
+
+// nmeaDegrees converts a d(d)dmm.mmmm value with the given number of degree
+// digits to degrees.
+func nmeaDegrees(s string, digits int) (float64, bool) {
+	if len(s) < digits+2 {
+		return 0, false
+	}
+
+	deg, err := strconv.Atoi(s[:digits])
+	if err != nil {
+		return 0, false
+	}
+
+	min, err := strconv.ParseFloat(s[digits:], 64)
+	if err != nil || min >= 60 {
+		return 0, false
+	}
+
+	return float64(deg) + min/60, true
+}
+
+func nmeaFloat(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
@@ -0,0 +1,72 @@
+package gokml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nmeaSentence adds the $ and checksum to a sentence.
+func nmeaSentence(body string) string {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return fmt.Sprintf("$%s*%02X", body, sum)
+}
+
+func TestReadNMEA(t *testing.T) {
+	log := strings.Join([]string{
+		"$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47",
+		"$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A",
+		nmeaSentence("GPGSV,3,1,11,03,03,111,00,04,15,270,00,06,01,010,00,13,06,292,00"),
+		nmeaSentence("GNGGA,123520.50,4807.100,N,01131.100,E,1,08,0.9,546.0,M,46.9,M,,"),
+		nmeaSentence("GPGGA,123521,4807.200,N,01131.200,E,0,00,,,M,,M,,"),   // no fix
+		"$GPGGA,123522,4807.300,N,01131.300,E,1,08,0.9,547.0,M,46.9,M,,*00", // bad checksum
+		nmeaSentence("GPRMC,123523,V,4807.400,N,01131.400,E,,,230394,,"),    // invalid
+		nmeaSentence("GPRMC,123524,A,3351.000,S,15112.000,W,0.0,0.0,230394,,"),
+		"garbage",
+	}, "\r\n")
+
+	track, err := ReadNMEA(strings.NewReader(log), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(track.points) != 3 {
+		t.Fatalf("got %d points, want 3: %v", len(track.points), track.points)
+	}
+
+	first := track.points[0]
+	if first.Alt != 545.4 || first.Lat < 48.1172 || first.Lat > 48.1173 || first.Lon < 11.5166 || first.Lon > 11.5167 {
+		t.Errorf("unexpected first point %v", first)
+	}
+	if want := time.Date(1994, 3, 23, 12, 35, 19, 0, time.UTC); !track.times[0].Equal(want) {
+		t.Errorf("first time = %v, want %v", track.times[0], want)
+	}
+
+	if want := time.Date(1994, 3, 23, 12, 35, 20, 500000000, time.UTC); !track.times[1].Equal(want) {
+		t.Errorf("fractional time = %v, want %v", track.times[1], want)
+	}
+
+	if last := track.points[2]; last.Lat != -33.85 || last.Lon != -151.2 || last.Alt != 0 {
+		t.Errorf("unexpected southern/western point %v", last)
+	}
+}
+
+func TestReadNMEAMidnight(t *testing.T) {
+	log := strings.Join([]string{
+		nmeaSentence("GPRMC,235959,A,4000.000,N,10500.000,W,0.0,0.0,311224,,"),
+		nmeaSentence("GPGGA,000001,4000.000,N,10500.000,W,1,08,0.9,1600,M,,M,,"),
+	}, "\n")
+
+	track, err := ReadNMEA(strings.NewReader(log), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(track.times) != 2 || !track.times[1].Equal(time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC)) {
+		t.Errorf("midnight wrap not handled: %v", track.times)
+	}
+}
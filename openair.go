@@ -0,0 +1,345 @@
+package gokml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	nauticalMile = 1852.0 // meters
+	foot         = 0.3048 // meters
+
+	// arcStep is the angle in degrees between the points of arcs and
+	// circles.
+	arcStep = 5.0
+)
+
+// openAirColors are the colors of airspace classes and types, as red,
+// green, and blue.  Other classes are gray.
+var openAirColors = map[string][3]uint8{
+	"A":    {0, 0, 160},
+	"B":    {0, 0, 200},
+	"C":    {0, 64, 224},
+	"D":    {0, 96, 255},
+	"E":    {0, 160, 64},
+	"CTR":  {192, 0, 192},
+	"R":    {224, 0, 0},
+	"P":    {160, 0, 0},
+	"Q":    {255, 128, 0},
+	"W":    {0, 192, 192},
+	"GP":   {255, 192, 0},
+	"TMZ":  {128, 64, 192},
+	"RMZ":  {128, 64, 192},
+	"GSEC": {255, 224, 0},
+}
+
+// openAirSpace is an airspace being read from an OpenAir file.
+type openAirSpace struct {
+	class, name, kind string
+	floor, ceiling    string
+	ring              []*Point
+}
+
+// FromOpenAir converts an OpenAir airspace file, as used by gliding and
+// drone flight software, into a KML document with a Placemark for each
+// airspace.  Boundaries (DP), arcs (DA and DB), and circles (DC) become
+// Polygons at the ceiling of the airspace, extruded down to the ground so
+// they show as volumes, and styled by airspace class (AC), with the class,
+// type (AY), floor (AL), and ceiling (AH) as ExtendedData.  Ceilings on
+// the surface or in feet or meters above ground are relative to the ground,
+// and the others (flight levels and altitudes above sea level) are
+// absolute; a flight level is taken as its pressure altitude, and unlimited
+// ceilings are drawn at FL 600.  Airspaces with fewer than three points are
+// skipped.  Invalid coordinates or numbers are an error.
+func FromOpenAir(r io.Reader) (*KML, error) {
+	var spaces []*openAirSpace
+	var space *openAirSpace
+	var center *Point
+	clockwise := true
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '*'); i >= 0 {
+			text = text[:i] // comment
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, "\ufeff"))
+		if len(text) < 2 {
+			continue
+		}
+
+		cmd, arg := strings.ToUpper(text[:2]), strings.TrimSpace(text[2:])
+		if cmd == "AC" {
+			space = &openAirSpace{class: strings.ToUpper(arg)}
+			spaces = append(spaces, space)
+			center, clockwise = nil, true
+			continue
+		}
+		if space == nil {
+			continue // records before the first airspace
+		}
+
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("gokml: OpenAir line %d: %s", line, fmt.Sprintf(format, args...))
+		}
+
+		var err error
+		switch cmd {
+		case "AN":
+			space.name = arg
+		case "AY":
+			space.kind = arg
+		case "AL":
+			space.floor = arg
+		case "AH":
+			space.ceiling = arg
+		case "V ":
+			key, value, _ := strings.Cut(arg, "=")
+			switch strings.ToUpper(strings.TrimSpace(key)) {
+			case "X":
+				if center, err = parseOpenAirPoint(value); err != nil {
+					return nil, errorf("%v", err)
+				}
+			case "D":
+				clockwise = strings.TrimSpace(value) != "-"
+			}
+		case "DP":
+			p, err := parseOpenAirPoint(arg)
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+			space.ring = append(space.ring, p)
+		case "DA", "DC":
+			if center == nil {
+				return nil, errorf("%s without a center (V X=)", cmd)
+			}
+
+			fields := strings.Split(arg, ",")
+			v := make([]float64, len(fields))
+			for i, f := range fields {
+				if v[i], err = strconv.ParseFloat(strings.TrimSpace(f), 64); err != nil {
+					return nil, errorf("invalid number %q", f)
+				}
+			}
+
+			if cmd == "DC" && len(v) == 1 {
+				space.ring = append(space.ring, arcPoints(center, v[0]*nauticalMile, 0, 360, true)...)
+			} else if cmd == "DA" && len(v) == 3 {
+				space.ring = append(space.ring, arcPoints(center, v[0]*nauticalMile, v[1], v[2], clockwise)...)
+			} else {
+				return nil, errorf("invalid %s %q", cmd, arg)
+			}
+		case "DB":
+			if center == nil {
+				return nil, errorf("DB without a center (V X=)")
+			}
+
+			ends := strings.Split(arg, ",")
+			if len(ends) != 2 {
+				return nil, errorf("invalid DB %q", arg)
+			}
+			from, err := parseOpenAirPoint(ends[0])
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+			to, err := parseOpenAirPoint(ends[1])
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+
+			arc := arcPoints(center, greatCircleDistance(center, from), initialBearing(center, from), initialBearing(center, to), clockwise)
+			space.ring = append(space.ring, from)
+			if len(arc) > 2 {
+				space.ring = append(space.ring, arc[1:len(arc)-1]...)
+			}
+			space.ring = append(space.ring, to)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	k := NewKML("Airspace")
+
+	styled := make(map[string]bool)
+	var placemarks []*Placemark
+
+	for _, space := range spaces {
+		if len(space.ring) < 3 {
+			continue
+		}
+
+		ceiling, mode := parseOpenAirAltitude(space.ceiling)
+
+		poly := NewPolygon()
+		poly.SetAltitudeMode(mode)
+		for _, p := range space.ring {
+			poly.AddPoint(&Point{p.Lat, p.Lon, ceiling})
+		}
+
+		desc := fmt.Sprintf("Class %s, %s to %s", space.class, openAirLabel(space.floor), openAirLabel(space.ceiling))
+		pm := NewPlacemark(space.name, desc, poly)
+		pm.SetData("class", space.class)
+		if len(space.kind) > 0 {
+			pm.SetData("type", space.kind)
+		}
+		pm.SetData("floor", space.floor)
+		pm.SetData("ceiling", space.ceiling)
+
+		// the type is more specific than the class in the extended format
+		key := space.class
+		if _, ok := openAirColors[strings.ToUpper(space.kind)]; ok {
+			key = strings.ToUpper(space.kind)
+		}
+		style := "airspace-" + styleWord(key)
+		if !styled[style] {
+			styled[style] = true
+			c, ok := openAirColors[key]
+			if !ok {
+				c = [3]uint8{128, 128, 128}
+			}
+			s := NewStyle(style, 0x60, c[0], c[1], c[2])
+			s.SetPolygonFill(true)
+			k.AddFeature(s)
+		}
+		pm.SetStyle(style)
+
+		placemarks = append(placemarks, pm)
+	}
+
+	for _, pm := range placemarks {
+		k.AddFeature(pm)
+	}
+
+	return k, nil
+}
+
+// styleWord returns s with the characters that aren't allowed in a Style
+// name replaced by dashes.
+func styleWord(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// openAirLabel returns an altitude as written, or "unknown" if it's empty.
+func openAirLabel(s string) string {
+	if len(s) == 0 {
+		return "unknown"
+	}
+
+	return s
+}
+
+// arcPoints returns the points of an arc around center with a radius in
+// meters, from one bearing to another (in degrees clockwise from north),
+// going clockwise or counterclockwise.  Both ends are included.
+func arcPoints(center *Point, radius float64, from float64, to float64, clockwise bool) []*Point {
+	sweep := math.Mod(to-from+720, 360)
+	if !clockwise {
+		sweep -= 360
+	}
+	if to-from == 360 {
+		sweep = 360 // a full circle
+	}
+
+	return sweepPoints(center, radius, from, sweep, 0)
+}
+
+// parseOpenAirPoint parses an OpenAir position, such as
+// "53:24:25 N 010:25:10 E" or "53:24.5N 10:25.2E".
+func parseOpenAirPoint(s string) (*Point, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	ns := strings.IndexAny(s, "NS")
+	ew := strings.IndexAny(s, "EW")
+	if ns < 0 || ew < ns {
+		return nil, fmt.Errorf("invalid position %q", s)
+	}
+
+	lat, latErr := parseDMS(s[:ns])
+	lon, lonErr := parseDMS(s[ns+1 : ew])
+	if s[ns] == 'S' {
+		lat = -lat
+	}
+	if s[ew] == 'W' {
+		lon = -lon
+	}
+
+	p := NewPoint(lat, lon, 0)
+	if latErr != nil || lonErr != nil || p == nil {
+		return nil, fmt.Errorf("invalid position %q", s)
+	}
+
+	return p, nil
+}
+
+// parseDMS parses an angle as degrees, minutes, and seconds separated by
+// colons, such as "53:24:25.5" or "53:24.5" or "53.4".
+func parseDMS(s string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid angle %q", s)
+	}
+
+	v := 0.0
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || f < 0 || (i > 0 && f >= 60) {
+			return 0, fmt.Errorf("invalid angle %q", s)
+		}
+		v += f / math.Pow(60, float64(i))
+	}
+
+	return v, nil
+}
+
+// parseOpenAirAltitude parses an OpenAir floor or ceiling, such as "SFC",
+// "FL95", "4500ft MSL", or "300 m AGL", into meters and an altitude mode.
+// Empty or unknown altitudes are on the ground.
+func parseOpenAirAltitude(s string) (float64, string) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	switch s {
+	case "", "SFC", "GND":
+		return 0, RelativeToGround
+	case "UNL", "UNLIM", "UNLTD", "UNLIMITED":
+		return 60000 * foot, Absolute
+	}
+
+	if strings.HasPrefix(s, "FL") {
+		if fl, err := strconv.ParseFloat(strings.TrimSpace(s[2:]), 64); err == nil {
+			return fl * 100 * foot, Absolute
+		}
+		return 0, RelativeToGround
+	}
+
+	// a number, then a unit and a reference in either order
+	end := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if end < 0 {
+		end = len(s)
+	}
+	v, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0, RelativeToGround
+	}
+
+	mode, unit := Absolute, foot
+	for _, word := range strings.FieldsFunc(s[end:], func(r rune) bool { return r == ' ' || r == '\t' || r == '.' }) {
+		switch word {
+		case "M", "METERS":
+			unit = 1
+		case "AGL", "GND", "SFC", "ASFC":
+			mode = RelativeToGround
+		}
+	}
+
+	return v * unit, mode
+}
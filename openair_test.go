@@ -0,0 +1,132 @@
+package gokml
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const openAirFixture = `* Test airspace
+AC D
+AN Boulder CTR
+AL SFC
+AH 4500ft MSL
+V X=40:02:22 N 105:13:34 W
+DC 5
+
+AC R
+AY R
+AN R-2601
+AL GND
+AH FL180
+DP 39:00:00 N 104:00:00 W
+DP 39:00:00 N 103:00:00 W
+V D=-
+V X=39:00:00 N 103:30:00 W
+DB 39:00:00 N 103:00:00 W, 39:00:00 N 104:00:00 W
+
+AC E
+AN Arc
+AL 1000ft AGL
+AH 3000 ft AGL
+V X=41:00:00 N 105:00:00 W
+DP 41:00:00 N 105:00:00 W
+DA 10, 0, 90
+
+AC G
+AN Too small
+DP 41:00:00 N 105:00:00 W
+`
+
+func TestFromOpenAir(t *testing.T) {
+	k, err := FromOpenAir(strings.NewReader(openAirFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pms := k.FindPlacemarks(func(*Placemark) bool { return true })
+	if len(pms) != 3 {
+		t.Fatalf("got %d placemarks, want 3", len(pms))
+	}
+
+	ctr := pms[0]
+	poly := ctr.geometry.(*Polygon)
+	center := &Point{40 + 2/60.0 + 22/3600.0, -(105 + 13/60.0 + 34/3600.0), 0}
+	if len(poly.points) != 73 || poly.altitudeMode != Absolute {
+		t.Errorf("circle has %d points, %s", len(poly.points), poly.altitudeMode)
+	}
+	for _, p := range poly.points {
+		if d := greatCircleDistance(center, p); math.Abs(d-5*nauticalMile) > 1 {
+			t.Errorf("circle point %v is %f m from the center", p, d)
+		}
+		if math.Abs(p.Alt-4500*foot) > 1e-9 {
+			t.Errorf("circle point %v not at the ceiling", p)
+		}
+	}
+	if ctr.description != "Class D, SFC to 4500ft MSL" || ctr.style != "airspace-D" {
+		t.Errorf("unexpected placemark %q %q", ctr.description, ctr.style)
+	}
+
+	// the counterclockwise arc from east to west goes north of the boundary
+	restricted := pms[1].geometry.(*Polygon)
+	if math.Abs(restricted.points[0].Alt-18000*foot) > 1e-9 || pms[1].style != "airspace-R" {
+		t.Errorf("unexpected restricted area %v %q", restricted.points[0], pms[1].style)
+	}
+	north := -90.0
+	for _, p := range restricted.points {
+		north = math.Max(north, p.Lat)
+	}
+	if north < 39.3 {
+		t.Errorf("arc goes the wrong way (north edge %f)", north)
+	}
+
+	arc := pms[2].geometry.(*Polygon)
+	if arc.altitudeMode != RelativeToGround || math.Abs(arc.points[0].Alt-3000*foot) > 1e-9 {
+		t.Errorf("unexpected arc ceiling %v %s", arc.points[0], arc.altitudeMode)
+	}
+	if n := len(arc.points); n != 20 {
+		t.Errorf("arc has %d points, want 20", n)
+	}
+
+	out := k.Render()
+	if !strings.Contains(out, `<Style id="airspace-E">`) || !strings.Contains(out, "<altitudeMode>absolute</altitudeMode>") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if err := k.Validate(); err != nil {
+		t.Errorf("converted document is invalid: %v", err)
+	}
+}
+
+func TestParseOpenAirAltitude(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		alt  float64
+		mode string
+	}{
+		{"SFC", 0, RelativeToGround},
+		{"FL 95", 9500 * foot, Absolute},
+		{"4500ft MSL", 4500 * foot, Absolute},
+		{"4500 F AMSL", 4500 * foot, Absolute},
+		{"300 m AGL", 300, RelativeToGround},
+		{"1000ft GND", 1000 * foot, RelativeToGround},
+		{"UNL", 60000 * foot, Absolute},
+		{"", 0, RelativeToGround},
+	} {
+		alt, mode := parseOpenAirAltitude(c.s)
+		if math.Abs(alt-c.alt) > 1e-9 || mode != c.mode {
+			t.Errorf("parseOpenAirAltitude(%q) = %f, %s; want %f, %s", c.s, alt, mode, c.alt, c.mode)
+		}
+	}
+}
+
+func TestFromOpenAirErrors(t *testing.T) {
+	for _, doc := range []string{
+		"AC D\nDP 95:00:00 N 105:00:00 W\n",
+		"AC D\nDC 5\n",
+		"AC D\nV X=40:00:00 N 105:00:00 W\nDA 5, x, 90\n",
+	} {
+		if _, err := FromOpenAir(strings.NewReader(doc)); err == nil {
+			t.Errorf("no error for %q", doc)
+		}
+	}
+}
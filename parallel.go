@@ -0,0 +1,76 @@
+package gokml
+
+import (
+	"bytes"
+	"sync"
+)
+
+// parallelChunksPerWorker is the number of chunks the features are split
+// into per goroutine, so a few large Folders don't leave goroutines idle.
+const parallelChunksPerWorker = 4
+
+// renderParallel renders the features into buffers using
+// e.opts.parallelism goroutines, writing each buffer in order as soon as it
+// and all of the buffers before it are done.
+func renderParallel(e *encoder, features []renderable) {
+	workers := e.opts.parallelism
+	size := (len(features) + workers*parallelChunksPerWorker - 1) / (workers * parallelChunksPerWorker)
+	if size == 0 {
+		return
+	}
+
+	type chunk struct {
+		buf *bytes.Buffer
+		err error
+	}
+
+	chunks := make([]*chunk, 0, (len(features)+size-1)/size)
+	done := make([]chan struct{}, 0, cap(chunks))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for start := 0; start < len(features); start += size {
+		end := start + size
+		if end > len(features) {
+			end = len(features)
+		}
+
+		c := &chunk{buf: getBuffer()}
+		ch := make(chan struct{})
+		chunks = append(chunks, c)
+		done = append(done, ch)
+
+		wg.Add(1)
+		go func(part []renderable) {
+			defer wg.Done()
+			defer close(ch)
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			sub := newEncoder(c.buf, e.opts)
+			sub.ctx = e.ctx
+			sub.depth = e.depth
+			sub.started = true
+
+			for _, feature := range part {
+				feature.render(sub)
+			}
+			c.err = sub.flush()
+		}(features[start:end])
+	}
+
+	for i, c := range chunks {
+		<-done[i]
+
+		if e.err == nil {
+			e.err = c.err
+		}
+		e.rawBytes(c.buf.Bytes())
+		putBuffer(c.buf)
+		chunks[i] = nil
+	}
+
+	wg.Wait()
+	e.inline = false
+}
@@ -0,0 +1,57 @@
+package gokml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParallelRender(t *testing.T) {
+	k := NewKML("Parallel")
+	k.AddFeature(NewStyle("Red", 255, 255, 0, 0))
+
+	for i := 0; i < 20; i++ {
+		f := NewFolder(fmt.Sprintf("Folder %d", i), "")
+		for j := 0; j < 50; j++ {
+			f.AddFeature(NewPlacemark(fmt.Sprintf("Place %d.%d", i, j), "", NewPoint(40.0, -105.0+float64(j)/100, 0.0)))
+		}
+		k.AddFeature(f)
+		k.AddFeature(NewPlacemark(fmt.Sprintf("Place %d", i), "", NewPoint(41.0, -105.0, 0.0)))
+	}
+
+	for _, minify := range []bool{false, true} {
+		k.SetMinify(minify)
+		k.SetParallelism(1)
+		want := k.Render()
+
+		for _, n := range []int{2, 3, 16, 100} {
+			k.SetParallelism(n)
+			if got := k.Render(); got != want {
+				t.Errorf("parallelism %d (minify %v) render differs:\n%s", n, minify, got)
+			}
+		}
+	}
+
+	empty := NewKML("Empty")
+	want := empty.Render()
+	empty.SetParallelism(4)
+	if got := empty.Render(); got != want {
+		t.Errorf("empty document render differs:\n%s", got)
+	}
+}
+
+func BenchmarkRenderParallel(b *testing.B) {
+	k := NewKML("Benchmark")
+	for i := 0; i < 100; i++ {
+		f := NewFolder(fmt.Sprintf("Folder %d", i), "")
+		for j := 0; j < 1000; j++ {
+			f.AddFeature(NewPlacemark("Place", "", NewPoint(40.0, -105.0, 0.0)))
+		}
+		k.AddFeature(f)
+	}
+	k.SetParallelism(8)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k.Render()
+	}
+}
@@ -0,0 +1,868 @@
+package gokml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse decodes a KML document, such as one saved by Google Earth, so it can
+// be modified and rendered again.  The Document (or the top-level Folder or
+// Placemark) becomes the root of the returned KML.  Folders, Placemarks,
+// Styles, NetworkLinks, and GroundOverlays are decoded, along with their
+// Point, LineString, Polygon, MultiGeometry, and gx:Track geometries.  Other elements and attributes
+// of features (vendor extensions, gx elements, etc.) are kept as they are and
+// rendered again in their places in the schema among the feature's own
+// elements, as are elements this package models but couldn't render again
+// without losing something, such as a LineStyle of another width or a
+// Point with an altitude mode.  Features kept as they are come before the
+// decoded features of their Folder.
+// Descriptions containing markup are treated as HTML.
+func Parse(r io.Reader) (*KML, error) {
+	d := xml.NewDecoder(r)
+
+	root, err := readRoot(d)
+	if err != nil {
+		return nil, err
+	}
+
+	k := NewKML("")
+
+	switch root.name.Local {
+	case "Document", "Folder":
+		k.rootFolder = parseFolder(root)
+	default:
+		if feature := parseFeature(root); feature != nil {
+			k.AddFeature(feature)
+		} else {
+			k.rootFolder.extra = &extraXML{nodes: []*node{root}}
+		}
+	}
+
+	return k, nil
+}
+
+// readRoot reads the kml element and returns the first feature in it.
+func readRoot(d *xml.Decoder) (*node, error) {
+	sawKML := false
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			if !sawKML {
+				return nil, fmt.Errorf("gokml: not a KML document")
+			}
+			return nil, fmt.Errorf("gokml: KML document has no features")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if !sawKML {
+			if start.Name.Local != "kml" {
+				return nil, fmt.Errorf("gokml: not a KML document (root element is %s)", start.Name.Local)
+			}
+			sawKML = true
+			continue
+		}
+
+		n, err := readNode(d, start)
+		if err != nil {
+			return nil, err
+		}
+
+		if isFeature(n.name.Local) {
+			return n, nil
+		}
+	}
+}
+
+// node is a decoded XML element.
+type node struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*node
+	text     string
+}
+
+// readNode reads the rest of the element opened by start.
+func readNode(d *xml.Decoder, start xml.StartElement) (*node, error) {
+	n := &node{name: start.Name, attrs: start.Attr}
+	var text strings.Builder
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := readNode(d, t)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			n.text = strings.TrimSpace(text.String())
+			return n, nil
+		}
+	}
+}
+
+// child returns the first child element with the given local name, or nil.
+func (n *node) child(local string) *node {
+	if n == nil {
+		return nil
+	}
+
+	for _, c := range n.children {
+		if c.name.Local == local {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// path returns the first descendant element along the path of local names,
+// or nil.
+func (n *node) path(locals ...string) *node {
+	for _, local := range locals {
+		n = n.child(local)
+	}
+
+	return n
+}
+
+// childText returns the text of the descendant element along the path of
+// local names, or an empty string.
+func (n *node) childText(locals ...string) string {
+	if c := n.path(locals...); c != nil {
+		return c.text
+	}
+
+	return ""
+}
+
+// childFloat returns the descendant element's text as a float64, or def if
+// it is missing or invalid.
+func (n *node) childFloat(def float64, locals ...string) float64 {
+	v, err := strconv.ParseFloat(n.childText(locals...), 64)
+	if err != nil {
+		return def
+	}
+
+	return v
+}
+
+// attr returns the value of the named attribute, or an empty string.
+func (n *node) attr(local string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// clone returns a deep copy of n.
+func (n *node) clone() *node {
+	cp := &node{n.name, append([]xml.Attr(nil), n.attrs...), make([]*node, len(n.children)), n.text}
+	for i, c := range n.children {
+		cp.children[i] = c.clone()
+	}
+
+	return cp
+}
+
+// onlyAttrs reports whether the named attributes are the only ones of n,
+// other than namespace declarations.
+func onlyAttrs(n *node, locals ...string) bool {
+	for _, a := range n.attrs {
+		if !isNamespaceDecl(a) && (len(a.Name.Space) > 0 || !containsString(locals, a.Name.Local)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// onlyChildren reports whether each child element of n is one of the named
+// ones, appearing at most once.
+func onlyChildren(n *node, locals ...string) bool {
+	seen := make(map[string]bool)
+	for _, c := range n.children {
+		if seen[c.name.Local] || !containsString(locals, c.name.Local) {
+			return false
+		}
+		seen[c.name.Local] = true
+	}
+
+	return true
+}
+
+// plainElement reports whether n exists and has no attributes and only the
+// named child elements, so decoding it loses nothing.
+func plainElement(n *node, locals ...string) bool {
+	return n != nil && onlyAttrs(n) && onlyChildren(n, locals...)
+}
+
+// coordinateCount returns the number of tuples in a coordinates element, or
+// -1 if any are invalid.
+func coordinateCount(s string) int {
+	if n := len(strings.Fields(s)); len(parseCoordinates(s)) == n {
+		return n
+	}
+
+	return -1
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isFeature(local string) bool {
+	switch local {
+	case "Document", "Folder", "Placemark", "Style", "NetworkLink", "GroundOverlay":
+		return true
+	}
+
+	return false
+}
+
+// parseFeature returns the feature for an element, or nil if it isn't one
+// this package models or can't be decoded without losing anything.
+func parseFeature(n *node) renderable {
+	switch n.name.Local {
+	case "Document", "Folder":
+		return parseFolder(n)
+	case "Placemark":
+		return parsePlacemark(n)
+	case "Style":
+		if decodableStyle(n) {
+			return parseStyle(n)
+		}
+	case "NetworkLink":
+		if decodableNetworkLink(n) {
+			return parseNetworkLink(n)
+		}
+	case "GroundOverlay":
+		if decodableGroundOverlay(n) {
+			return parseGroundOverlay(n)
+		}
+	}
+
+	return nil
+}
+
+func parseFolder(n *node) *Folder {
+	f := NewFolder(n.childText("name"), "")
+	f.SetID(n.attr("id"))
+	f.description, f.html = parseDescription(n)
+	f.SetOpen(parseBool(n.childText("open")))
+
+	if author := n.child("author"); author != nil && knownFolder.decodes(author) {
+		f.SetAuthor(author.childText("name"))
+	}
+
+	if link := n.child("link"); link != nil && knownFolder.decodes(link) {
+		f.SetLink(link.attr("href"))
+	}
+
+	if la := n.child("LookAt"); la != nil && knownFolder.decodes(la) {
+		f.SetLookAt(parseLookAt(la))
+	}
+
+	if region := n.child("Region"); region != nil && knownFolder.decodes(region) {
+		f.SetRegion(parseBounds(region.child("LatLonAltBox")))
+		f.SetMinLodPixels(int(region.childFloat(0, "Lod", "minLodPixels")))
+	}
+
+	for _, c := range n.children {
+		if feature := parseFeature(c); feature != nil {
+			f.AddFeature(feature)
+		}
+	}
+
+	f.extra = parseExtra(n, knownFolder)
+
+	return f
+}
+
+func decodableAuthor(n *node) bool {
+	return plainElement(n, "name")
+}
+
+func decodableLink(n *node) bool {
+	return onlyAttrs(n, "href") && len(n.children) == 0
+}
+
+func decodableLookAt(n *node) bool {
+	return plainElement(n, "longitude", "latitude", "altitude", "heading", "tilt", "range") && parseLookAt(n) != nil
+}
+
+func parsePlacemark(n *node) *Placemark {
+	var geom renderable
+	for _, c := range n.children {
+		if !knownPlacemark.decodes(c) {
+			continue
+		}
+		if geom = parseGeometry(c); geom != nil {
+			break
+		}
+	}
+
+	pm := NewPlacemark(n.childText("name"), "", geom)
+	pm.SetID(n.attr("id"))
+	pm.description, pm.html = parseDescription(n)
+	pm.SetVisibility(n.child("visibility") == nil || parseBool(n.childText("visibility")))
+	pm.SetBalloonVisibility(parseBool(n.childText("balloonVisibility")))
+
+	if style := n.childText("styleUrl"); strings.HasPrefix(style, "#") {
+		pm.SetStyle(style[1:])
+	} else {
+		pm.SetStyleURL(style)
+	}
+
+	if span := n.child("TimeSpan"); span != nil && knownPlacemark.decodes(span) {
+		begin, _ := parseTime(span.childText("begin"))
+		end, _ := parseTime(span.childText("end"))
+		pm.SetTime(begin, end)
+	}
+
+	if data := n.child("ExtendedData"); data != nil && knownPlacemark.decodes(data) {
+		for _, c := range data.children {
+			pm.SetData(c.attr("name"), c.childText("value"))
+		}
+	}
+
+	pm.extra = parseExtra(n, knownPlacemark)
+
+	return pm
+}
+
+func decodableBalloon(n *node) bool {
+	// a hidden balloon is the default, which isn't written
+	return parseBool(n.text)
+}
+
+func decodableTimeSpan(n *node) bool {
+	begin, beginErr := parseTime(n.childText("begin"))
+	end, endErr := parseTime(n.childText("end"))
+
+	return plainElement(n, "begin", "end") && beginErr == nil && endErr == nil && !begin.After(end)
+}
+
+func decodableData(n *node) bool {
+	if !onlyAttrs(n) {
+		return false
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range n.children {
+		name := strings.TrimSpace(c.attr("name"))
+		if c.name.Local != "Data" || !onlyAttrs(c, "name") || !onlyChildren(c, "value") || len(name) == 0 || seen[name] {
+			return false
+		}
+		seen[name] = true
+	}
+
+	return true
+}
+
+// parseGeometry returns the geometry for a Point, LineString, Polygon,
+// MultiGeometry, or gx:Track element, or nil for anything else.
+func parseGeometry(n *node) renderable {
+	switch n.name.Local {
+	case "Point":
+		points := parseCoordinates(n.childText("coordinates"))
+		if len(points) == 0 {
+			return nil
+		}
+		return points[0]
+	case "LineString":
+		ls := NewLineString()
+		for _, p := range parseCoordinates(n.childText("coordinates")) {
+			ls.AddPoint(p)
+		}
+		if order := n.child("drawOrder"); order != nil {
+			if v, err := strconv.Atoi(order.text); err == nil {
+				ls.SetDrawOrder(v)
+			}
+		}
+		ls.SetAltitudeMode(strings.TrimSpace(n.childText("altitudeMode")))
+		ls.SetExtrude(parseBool(strings.TrimSpace(n.childText("extrude"))))
+		return ls
+	case "Polygon":
+		poly := NewPolygon()
+		for _, p := range parseCoordinates(n.childText("outerBoundaryIs", "LinearRing", "coordinates")) {
+			poly.AddPoint(p)
+		}
+		for _, c := range n.children {
+			if c.name.Local == "innerBoundaryIs" {
+				poly.AddInnerBoundary(parseCoordinates(c.childText("LinearRing", "coordinates"))...)
+			}
+		}
+		poly.SetAltitudeMode(strings.TrimSpace(n.childText("altitudeMode")))
+		return poly
+	case "Track":
+		if n.name.Space != gxNamespace {
+			return nil
+		}
+		track := NewTrack()
+		track.SetAltitudeMode(strings.TrimSpace(n.childText("altitudeMode")))
+		var times []time.Time
+		for _, c := range n.children {
+			switch c.name.Local {
+			case "when":
+				when, _ := parseTime(c.text)
+				times = append(times, when)
+			case "coord":
+				fields := strings.Fields(c.text)
+				if len(times) == 0 || len(fields) < 2 {
+					continue
+				}
+				var v [3]float64
+				for i := 0; i < len(fields) && i < 3; i++ {
+					v[i], _ = strconv.ParseFloat(fields[i], 64)
+				}
+				track.AddPoint(times[0], NewPoint(v[1], v[0], v[2]))
+				times = times[1:]
+			case "ExtendedData":
+				for _, sd := range c.children {
+					if sd.name.Local != "SchemaData" {
+						continue
+					}
+					for _, a := range sd.children {
+						if a.name.Local != "SimpleArrayData" {
+							continue
+						}
+						var values []float64
+						for _, v := range a.children {
+							if v.name.Local != "value" {
+								continue
+							}
+							f, err := strconv.ParseFloat(strings.TrimSpace(v.text), 64)
+							if err != nil {
+								f = math.NaN()
+							}
+							values = append(values, f)
+						}
+						track.SetArrayData(a.attr("name"), values)
+					}
+				}
+			}
+		}
+		return track
+	case "MultiGeometry":
+		mg := NewMultiGeometry()
+		for _, c := range n.children {
+			if geom := parseGeometry(c); geom != nil {
+				mg.AddGeometry(geom)
+			}
+		}
+		return mg
+	}
+
+	return nil
+}
+
+// decodableGeometry reports whether a geometry element can be decoded
+// without losing anything: it has no elements or values that aren't modeled,
+// and renders something.
+func decodableGeometry(n *node) bool {
+	mode := n.childText("altitudeMode")
+	if !onlyAttrs(n) || !validAltitudeMode(mode) {
+		return false
+	}
+
+	switch n.name.Local {
+	case "Point":
+		return onlyChildren(n, "extrude", "altitudeMode", "coordinates") && !parseBool(n.childText("extrude")) &&
+			(mode == "" || mode == ClampToGround) && coordinateCount(n.childText("coordinates")) == 1
+	case "LineString":
+		order := n.child("drawOrder")
+		if order != nil {
+			if _, err := strconv.Atoi(order.text); err != nil {
+				return false
+			}
+		}
+		return onlyChildren(n, "extrude", "tessellate", "altitudeMode", "coordinates", "drawOrder") &&
+			n.childText("tessellate") == "1" && coordinateCount(n.childText("coordinates")) >= 2
+	case "Polygon":
+		// polygons are written extruded, which only matters above the ground
+		if mode != "" && mode != ClampToGround && !parseBool(n.childText("extrude")) {
+			return false
+		}
+		outer := 0
+		for _, c := range n.children {
+			switch c.name.Local {
+			case "extrude", "altitudeMode":
+			case "outerBoundaryIs", "innerBoundaryIs":
+				ring := c.child("LinearRing")
+				if !plainElement(c, "LinearRing") || !plainElement(ring, "coordinates") || coordinateCount(ring.childText("coordinates")) < 3 {
+					return false
+				}
+				if c.name.Local == "outerBoundaryIs" {
+					outer++
+				}
+			default:
+				return false
+			}
+		}
+		return outer == 1
+	case "Track":
+		if n.name.Space != gxNamespace {
+			return false
+		}
+		whens, coords := 0, 0
+		for _, c := range n.children {
+			switch c.name.Local {
+			case "altitudeMode":
+			case "when":
+				if _, err := parseTime(c.text); err != nil {
+					return false
+				}
+				whens++
+			case "coord":
+				for _, field := range strings.Fields(c.text) {
+					if _, err := strconv.ParseFloat(field, 64); err != nil {
+						return false
+					}
+				}
+				if len(strings.Fields(c.text)) != 3 {
+					return false
+				}
+				coords++
+			case "ExtendedData":
+				if !decodableTrackData(c) {
+					return false
+				}
+			default:
+				return false
+			}
+		}
+		return coords > 0 && whens == coords
+	case "MultiGeometry":
+		for _, c := range n.children {
+			if !decodableGeometry(c) {
+				return false
+			}
+		}
+		return len(n.children) > 0
+	}
+
+	return false
+}
+
+// decodableTrackData reports whether the ExtendedData of a gx:Track only has
+// array data declared by the Schema the Track is written with.
+func decodableTrackData(n *node) bool {
+	if !onlyAttrs(n) {
+		return false
+	}
+
+	for _, sd := range n.children {
+		if sd.name.Local != "SchemaData" || !onlyAttrs(sd, "schemaUrl") || sd.attr("schemaUrl") != "#"+trackSchemaID {
+			return false
+		}
+		for _, a := range sd.children {
+			if a.name.Local != "SimpleArrayData" || !onlyAttrs(a, "name") || len(strings.TrimSpace(a.attr("name"))) == 0 {
+				return false
+			}
+			for _, v := range a.children {
+				if v.name.Local != "value" || !onlyAttrs(v) || len(v.children) > 0 {
+					return false
+				}
+				if _, err := strconv.ParseFloat(v.text, 64); err != nil && len(v.text) > 0 {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func validAltitudeMode(mode string) bool {
+	switch mode {
+	case "", ClampToGround, RelativeToGround, Absolute:
+		return true
+	}
+
+	return false
+}
+
+func parseStyle(n *node) *Style {
+	// the color is taken from the IconStyle, or the LineStyle or PolyStyle
+	// if there is no IconStyle
+	color := n.childText("IconStyle", "color")
+	if len(color) == 0 {
+		color = n.childText("LineStyle", "color")
+	}
+	if len(color) == 0 {
+		color = n.childText("PolyStyle", "color")
+	}
+
+	a, b, g, r := parseColor(color)
+	s := NewStyle(n.attr("id"), a, r, g, b)
+	s.SetIconURL(n.childText("IconStyle", "Icon", "href"))
+	s.SetIconScale(n.childFloat(s.iconScale, "IconStyle", "scale"))
+	s.SetIconHeading(n.childFloat(0, "IconStyle", "heading"))
+
+	if fill := n.path("PolyStyle", "fill"); fill != nil {
+		s.SetPolygonFill(parseBool(fill.text))
+	}
+
+	s.extra = parseExtra(n, knownStyle)
+
+	return s
+}
+
+// decodableStyle reports whether a Style element can be decoded without
+// losing anything.  A Style has a single color, and always has an icon, a
+// line 3 pixels wide, and outlined polygons.
+func decodableStyle(n *node) bool {
+	icon, line, poly := n.child("IconStyle"), n.child("LineStyle"), n.child("PolyStyle")
+	if len(n.attr("id")) == 0 || icon == nil || line == nil || poly == nil {
+		return false
+	}
+
+	color := strings.ToLower(icon.childText("color"))
+	a, b, g, r := parseColor(color)
+	if colorString(a, r, g, b) != color || strings.ToLower(line.childText("color")) != color || strings.ToLower(poly.childText("color")) != color {
+		return false
+	}
+
+	scale := icon.childFloat(-1, "scale")
+	heading := icon.childFloat(0, "heading")
+	if !plainElement(icon, "color", "scale", "heading", "Icon") || scale < 0 || scale > 100 || heading < 0 || heading > 360 {
+		return false
+	}
+	if !plainElement(icon.child("Icon"), "href") || len(icon.childText("Icon", "href")) == 0 {
+		return false
+	}
+
+	if !plainElement(line, "color", "width") || line.childFloat(0, "width") != 3 {
+		return false
+	}
+
+	outline := poly.child("outline")
+	return plainElement(poly, "color", "colorMode", "fill", "outline") &&
+		(poly.child("colorMode") == nil || poly.childText("colorMode") == "normal") &&
+		(outline == nil || parseBool(outline.text))
+}
+
+func parseNetworkLink(n *node) *NetworkLink {
+	link := n.child("Link")
+	nl := NewNetworkLink(n.childText("name"), n.childText("description"), link.childText("href"))
+
+	if link.childText("refreshMode") == "onInterval" {
+		seconds := link.childFloat(0, "refreshInterval")
+		nl.SetRefreshInterval(time.Duration(seconds * float64(time.Second)))
+	}
+
+	if stamp := n.child("TimeStamp"); stamp != nil && knownNetworkLink.decodes(stamp) {
+		when, _ := parseTime(stamp.childText("when"))
+		nl.SetTimeStamp(when)
+	}
+
+	if region := n.child("Region"); region != nil && knownNetworkLink.decodes(region) {
+		nl.SetRegion(parseBounds(region.child("LatLonAltBox")))
+		nl.SetMinLodPixels(int(region.childFloat(0, "Lod", "minLodPixels")))
+	}
+
+	nl.extra = parseExtra(n, knownNetworkLink)
+
+	return nl
+}
+
+// decodableNetworkLink reports whether a NetworkLink element can be decoded
+// without losing anything.  Its Link may only refresh on an interval of whole
+// seconds, and KML 2.0 Url elements aren't converted.
+func decodableNetworkLink(n *node) bool {
+	link := n.child("Link")
+	if len(n.attr("id")) > 0 || link == nil || !plainElement(link, "href", "refreshMode", "refreshInterval") {
+		return false
+	}
+
+	switch link.childText("refreshMode") {
+	case "":
+		return link.child("refreshInterval") == nil
+	case "onInterval":
+		seconds := link.childFloat(0, "refreshInterval")
+		return seconds >= 1 && seconds == math.Round(seconds)
+	}
+
+	return false
+}
+
+// decodableTimeStamp reports whether a TimeStamp is written back the same,
+// which dates alone and fractional seconds aren't.
+func decodableTimeStamp(n *node) bool {
+	when, err := time.Parse(time.RFC3339, n.childText("when"))
+	return plainElement(n, "when") && err == nil && !when.IsZero() && when.Format(time.RFC3339) == n.childText("when")
+}
+
+// decodableRegion reports whether a Region has only edges and a Lod with a
+// positive, whole minLodPixels.
+func decodableRegion(n *node) bool {
+	if !plainElement(n, "LatLonAltBox", "Lod") || !plainElement(n.child("LatLonAltBox"), "north", "south", "east", "west") {
+		return false
+	}
+
+	lod := n.child("Lod")
+	if lod == nil {
+		return true
+	}
+
+	pixels := lod.childFloat(0, "minLodPixels")
+	return plainElement(lod, "minLodPixels") && pixels >= 1 && pixels == math.Round(pixels)
+}
+
+func parseGroundOverlay(n *node) *GroundOverlay {
+	box := n.child("LatLonBox")
+	g := NewGroundOverlay(n.childText("name"), n.childText("description"), n.childText("Icon", "href"), parseBounds(box))
+
+	if box != nil {
+		g.SetRotation(box.childFloat(0, "rotation"))
+	}
+
+	if quad := parseCoordinates(n.childText("LatLonQuad", "coordinates")); len(quad) == 4 {
+		g.SetLatLonQuad(quad[0], quad[1], quad[2], quad[3])
+	}
+
+	g.extra = parseExtra(n, knownGroundOverlay)
+
+	return g
+}
+
+// decodableGroundOverlay reports whether a GroundOverlay element can be
+// decoded without losing anything: its image is placed by either a
+// LatLonBox or a gx:LatLonQuad.
+func decodableGroundOverlay(n *node) bool {
+	icon, box, quad := n.child("Icon"), n.child("LatLonBox"), n.child("LatLonQuad")
+	if len(n.attr("id")) > 0 || !plainElement(icon, "href") {
+		return false
+	}
+
+	switch {
+	case quad != nil:
+		return box == nil && plainElement(quad, "coordinates") && coordinateCount(quad.childText("coordinates")) == 4
+	case box != nil:
+		rotation := box.childFloat(0, "rotation")
+		return plainElement(box, "north", "south", "east", "west", "rotation") && rotation >= -180 && rotation <= 180
+	}
+
+	return false
+}
+
+func parseLookAt(n *node) *LookAt {
+	p := NewPoint(n.childFloat(0, "latitude"), n.childFloat(0, "longitude"), n.childFloat(0, "altitude"))
+	return NewLookAt(p, n.childFloat(0, "heading"), n.childFloat(0, "tilt"), n.childFloat(0, "range"))
+}
+
+func parseBounds(n *node) Bounds {
+	return Bounds{
+		North: n.childFloat(0, "north"),
+		South: n.childFloat(0, "south"),
+		East:  n.childFloat(0, "east"),
+		West:  n.childFloat(0, "west"),
+	}
+}
+
+// parseDescription returns the description of a feature element, and
+// whether it contains markup.
+func parseDescription(n *node) (string, bool) {
+	desc := n.childText("description")
+	return desc, hasMarkup(desc)
+}
+
+func hasMarkup(s string) bool {
+	return strings.Contains(s, "<") && strings.Contains(s, ">")
+}
+
+// parseCoordinates parses space-separated lon,lat[,alt] tuples.  Invalid
+// tuples are skipped.
+func parseCoordinates(s string) []*Point {
+	points := make([]*Point, 0)
+
+	for _, tuple := range strings.Fields(s) {
+		parts := strings.Split(tuple, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			continue
+		}
+
+		var v [3]float64
+		valid := true
+		for i, part := range parts {
+			f, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			v[i] = f
+		}
+
+		if !valid {
+			continue
+		}
+
+		if p := NewPoint(v[1], v[0], v[2]); p != nil {
+			points = append(points, p)
+		}
+	}
+
+	return points
+}
+
+// parseColor parses a KML aabbggrr color.  Invalid colors are opaque white.
+func parseColor(s string) (alpha uint8, blue uint8, green uint8, red uint8) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 8 {
+		return 255, 255, 255, 255
+	}
+
+	return uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)
+}
+
+func parseBool(s string) bool {
+	return s == "1" || s == "true"
+}
+
+// parseTime parses a KML dateTime: a full RFC 3339 time, or just a date,
+// year and month, or year.
+func parseTime(s string) (time.Time, error) {
+	var err error
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006"} {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
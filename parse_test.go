@@ -0,0 +1,427 @@
+package gokml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseFixture() *KML {
+	k := NewKML("Round Trip")
+	k.SetID("doc")
+	k.SetDescription("Everything & more")
+	k.SetOpen(true)
+	k.SetAuthor("Mapper")
+	k.SetLink("https://example.com/")
+	k.SetLookAt(NewLookAt(NewPoint(40.0, -105.0, 0.0), 10.0, 45.0, 1000.0))
+
+	style := NewStyle("red", 200, 255, 0, 0)
+	style.SetIconScale(2.0)
+	style.SetPolygonFill(false)
+	k.AddFeature(style)
+
+	f := NewFolder("Sites", "")
+	f.SetID("sites")
+	k.AddFeature(f)
+
+	pm := NewPlacemark("Depot", "", NewPoint(40.0, -105.0, 1600.0))
+	pm.SetID("depot")
+	pm.SetDescriptionHTML("<b>Main</b> depot")
+	pm.SetStyle("red")
+	pm.SetBalloonVisibility(true)
+	pm.SetTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	pm.SetData("manager", "Kim")
+	f.AddFeature(pm)
+
+	line := NewLineString()
+	line.AddPoint(NewPoint(40.0, -105.0, 0.0))
+	line.AddPoint(NewPoint(41.0, -104.0, 0.0))
+	line.SetDrawOrder(3)
+	f.AddFeature(NewPlacemark("Route", "", line))
+
+	poly := NewPolygon()
+	poly.AddPoint(NewPoint(40.0, -105.0, 0.0))
+	poly.AddPoint(NewPoint(41.0, -105.0, 0.0))
+	poly.AddPoint(NewPoint(41.0, -104.0, 0.0))
+	f.AddFeature(NewPlacemark("Area", "", poly))
+
+	nl := NewNetworkLink("Live", "Vehicles", "live.kml")
+	nl.SetRefreshInterval(30 * time.Second)
+	nl.SetRegion(Bounds{41.0, 40.0, -104.0, -105.0})
+	k.AddFeature(nl)
+
+	overlay := NewGroundOverlay("Map", "Scan", "map.png", Bounds{41.0, 40.0, -104.0, -105.0})
+	overlay.SetRotation(15.0)
+	k.AddFeature(overlay)
+
+	quad := NewGroundOverlay("Skewed", "", "skewed.png", Bounds{})
+	quad.SetLatLonQuad(NewPoint(40.0, -105.0, 0.0), NewPoint(40.0, -104.0, 0.0), NewPoint(41.0, -104.0, 0.0), NewPoint(41.0, -105.0, 0.0))
+	k.AddFeature(quad)
+
+	return k
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	want := parseFixture().Render()
+
+	k, err := Parse(strings.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := k.Render(); got != want {
+		t.Errorf("round trip differs:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseFolderRegion(t *testing.T) {
+	k := NewKML("Regions")
+	f := NewFolder("Sites", "")
+	f.SetRegion(Bounds{41.0, 40.0, -104.0, -105.0})
+	f.SetMinLodPixels(128)
+	f.AddFeature(NewStyle("local", 255, 0, 0, 255))
+	f.AddFeature(NewPlacemark("Depot", "", NewPoint(40.5, -104.5, 0.0)))
+	k.AddFeature(f)
+
+	want := k.Render()
+	if err := k.Validate(); err != nil {
+		t.Errorf("Region out of order: %v", err)
+	}
+
+	parsed, err := Parse(strings.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := parsed.Render(); got != want {
+		t.Errorf("round trip differs:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseGoogleEarth(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+<Placemark>
+	<name>Saved place</name>
+	<gx:Unknown>ignored</gx:Unknown>
+	<TimeSpan><begin>2024-05</begin><end>2024-06-15</end></TimeSpan>
+	<styleUrl>styles.kml#blue</styleUrl>
+	<Point><coordinates>
+		-105.5,40.25
+	</coordinates></Point>
+</Placemark>
+</kml>`
+
+	k, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	placemarks := k.Placemarks()
+	if len(placemarks) != 1 {
+		t.Fatalf("got %d placemarks, want 1", len(placemarks))
+	}
+
+	pm := placemarks[0]
+	if pm.name != "Saved place" || pm.styleURL != "styles.kml#blue" || !pm.hasTime {
+		t.Errorf("placemark not decoded: %+v", pm)
+	}
+	if out := k.Render(); !strings.Contains(out, "<styleUrl>styles.kml#blue</styleUrl>") {
+		t.Errorf("style in another file not kept:\n%s", out)
+	}
+
+	if p, ok := pm.geometry.(*Point); !ok || p.Lat != 40.25 || p.Lon != -105.5 {
+		t.Errorf("geometry not decoded: %#v", pm.geometry)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, doc := range []string{
+		"",
+		"<html></html>",
+		"<kml><Document><name>Broken</Document></kml>",
+		"<kml><Document>",
+		`<kml xmlns="http://www.opengis.net/kml/2.2"></kml>`,
+	} {
+		if _, err := Parse(strings.NewReader(doc)); err == nil {
+			t.Errorf("no error parsing %q", doc)
+		}
+	}
+}
+
+func TestParseUnknownElements(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2" xmlns:v="urn:vendor">
+<Document>
+	<name>Extensions</name>
+	<StyleMap id="pair"><Pair><key>normal</key><styleUrl>#s</styleUrl></Pair></StyleMap>
+	<v:source v:system="crm">accounts</v:source>
+	<Style id="s"><LabelStyle><scale>0.8</scale></LabelStyle></Style>
+	<Placemark id="p" v:rank="3">
+		<name>Tour stop</name>
+		<Snippet maxLines="1">Stop 1</Snippet>
+		<gx:TimeStamp><when>2024</when></gx:TimeStamp>
+		<MultiGeometry><Point><coordinates>1,2</coordinates></Point></MultiGeometry>
+	</Placemark>
+</Document>
+</kml>`
+
+	k, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := k.Render()
+	for _, want := range []string{
+		`xmlns:gx="http://www.google.com/kml/ext/2.2"`,
+		`<StyleMap id="pair">`,
+		`<source xmlns="urn:vendor" ns1:system="crm" xmlns:ns1="urn:vendor">accounts</source>`,
+		`<scale>0.8</scale>`,
+		`<Placemark id="p" ns1:rank="3" xmlns:ns1="urn:vendor">`,
+		`<Snippet maxLines="1">Stop 1</Snippet>`,
+		`<gx:TimeStamp>`,
+		`<MultiGeometry>`,
+		`<coordinates>1.000000,2.000000,0.000000</coordinates>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("render missing %q:\n%s", want, out)
+		}
+	}
+
+	// the re-rendered document parses to the same document
+	again, err := Parse(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.Render() != out {
+		t.Errorf("second round trip differs:\n%s", again.Render())
+	}
+}
+
+func TestParseSchemaOrder(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+<Document>
+	<name>Order</name>
+	<Style id="s">
+		<IconStyle><color>ff0000ff</color></IconStyle>
+		<LabelStyle><scale>0.8</scale></LabelStyle>
+		<ListStyle><listItemType>check</listItemType></ListStyle>
+	</Style>
+	<Placemark>
+		<name>Stop</name>
+		<address>1 Main St</address>
+		<Snippet maxLines="1">Stop 1</Snippet>
+		<description>First stop</description>
+		<TimeStamp><when>2024-05-01</when></TimeStamp>
+		<styleUrl>#s</styleUrl>
+		<Region><LatLonAltBox><north>3</north><south>1</south><east>2</east><west>0</west></LatLonAltBox></Region>
+		<Point><coordinates>1,2</coordinates></Point>
+	</Placemark>
+	<NetworkLink>
+		<name>Live</name>
+		<refreshVisibility>1</refreshVisibility>
+		<flyToView>1</flyToView>
+		<Link><href>live.kml</href></Link>
+	</NetworkLink>
+	<GroundOverlay>
+		<name>Map</name>
+		<color>80ffffff</color>
+		<drawOrder>2</drawOrder>
+		<Icon><href>map.png</href></Icon>
+		<altitude>100</altitude>
+		<LatLonBox><north>3</north><south>1</south><east>2</east><west>0</west></LatLonBox>
+	</GroundOverlay>
+</Document>
+</kml>`
+
+	k, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := k.Validate(); err != nil {
+		t.Errorf("preserved elements out of order: %v\n%s", err, k.Render())
+	}
+}
+
+func TestParseGoogleEarthRoundTrip(t *testing.T) {
+	doc, err := os.ReadFile("testdata/googleearth.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := Parse(strings.NewReader(string(doc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := k.Render()
+
+	if diff := treeDiff(readTree(t, string(doc)), readTree(t, out), "kml"); len(diff) > 0 {
+		t.Errorf("round trip differs at %s\n%s", diff, out)
+	}
+
+	if err := k.Validate(); err != nil {
+		t.Errorf("round trip is invalid: %v", err)
+	}
+
+	// what can be decoded without losing anything is
+	placemarks := k.Placemarks()
+	if len(placemarks) != 4 {
+		t.Fatalf("got %d placemarks, want 4", len(placemarks))
+	}
+	if pm := placemarks[0]; !pm.hidden || pm.geometry != nil || pm.hasTime {
+		t.Errorf("Plot 7 decoded lossily: %+v", pm)
+	}
+	if _, ok := placemarks[1].geometry.(*LineString); !ok {
+		t.Errorf("Transect geometry not decoded: %#v", placemarks[1].geometry)
+	}
+	if pm := placemarks[2]; pm.style != "m_ylw-pushpin" || !pm.hasTime || len(pm.data) != 1 {
+		t.Errorf("Camp not decoded: %+v", pm)
+	}
+	if _, ok := placemarks[2].geometry.(*Point); !ok {
+		t.Errorf("Camp geometry not decoded: %#v", placemarks[2].geometry)
+	}
+	layers := k.rootFolder.features[1].(*Folder).features
+	if len(layers) != 2 {
+		t.Fatalf("got %d layers, want 2", len(layers))
+	}
+	if nl, ok := layers[0].(*NetworkLink); !ok || nl.refresh != time.Minute || nl.region == nil || nl.minLod != 128 {
+		t.Errorf("Roads not decoded: %#v", layers[0])
+	}
+
+	again, err := Parse(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Render() != out {
+		t.Errorf("second round trip differs:\n%s", again.Render())
+	}
+}
+
+// readTree parses a whole XML document.
+func readTree(t *testing.T, doc string) *node {
+	d := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			n, err := readNode(d, start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return n
+		}
+	}
+}
+
+// treeDiff returns the path of the first difference between two elements,
+// or an empty string.  Namespace declarations and empty elements are
+// ignored, and numbers are compared by value.
+func treeDiff(a *node, b *node, path string) string {
+	if a.name != b.name {
+		return fmt.Sprintf("%s: element %s:%s, want %s:%s", path, b.name.Space, b.name.Local, a.name.Space, a.name.Local)
+	}
+	if !sameText(a.text, b.text) {
+		return fmt.Sprintf("%s: text %q, want %q", path, b.text, a.text)
+	}
+
+	attrs := func(n *node) map[xml.Name]string {
+		m := make(map[xml.Name]string)
+		for _, at := range n.attrs {
+			if !isNamespaceDecl(at) {
+				m[at.Name] = at.Value
+			}
+		}
+		return m
+	}
+	if got, want := attrs(b), attrs(a); fmt.Sprint(got) != fmt.Sprint(want) {
+		return fmt.Sprintf("%s: attributes %v, want %v", path, got, want)
+	}
+
+	children := func(n *node) []*node {
+		var ret []*node
+		for _, c := range n.children {
+			if len(c.text) > 0 || len(c.children) > 0 || len(c.attrs) > 0 {
+				ret = append(ret, c)
+			}
+		}
+		return ret
+	}
+	ac, bc := children(a), children(b)
+	for i := 0; i < len(ac) || i < len(bc); i++ {
+		switch {
+		case i >= len(ac):
+			return fmt.Sprintf("%s: extra %s", path, bc[i].name.Local)
+		case i >= len(bc):
+			return fmt.Sprintf("%s: missing %s", path, ac[i].name.Local)
+		}
+		if diff := treeDiff(ac[i], bc[i], path+"/"+ac[i].name.Local); len(diff) > 0 {
+			return diff
+		}
+	}
+
+	return ""
+}
+
+// sameText reports whether two texts are equal, or are lists of the same
+// numbers.
+func sameText(a string, b string) bool {
+	if a == b {
+		return true
+	}
+
+	split := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' })
+	}
+	fa, fb := split(a), split(b)
+	if len(fa) == 0 || len(fa) != len(fb) {
+		return false
+	}
+	for i := range fa {
+		x, errA := strconv.ParseFloat(fa[i], 64)
+		y, errB := strconv.ParseFloat(fb[i], 64)
+		if errA != nil || errB != nil || x != y {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestParseTimeStamp(t *testing.T) {
+	k := NewKML("")
+	nl := NewNetworkLink("Parcels", "", "parcels.kml")
+	nl.SetTimeStamp(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC))
+	k.AddFeature(nl)
+	k.AddFeature(NewNetworkLink("Roads", "", "roads.kml"))
+
+	want := k.Render()
+	parsed, err := Parse(strings.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := parsed.Render(); got != want {
+		t.Errorf("round trip differs:\n%s\nwant:\n%s", got, want)
+	}
+
+	// dates alone would be written back as times, so they're kept as is
+	parsed, err = Parse(strings.NewReader(`<kml xmlns="http://www.opengis.net/kml/2.2"><NetworkLink>
+	<TimeStamp><when>2024-06-01</when></TimeStamp><Link><href>a.kml</href></Link>
+</NetworkLink></kml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out := parsed.Render(); !strings.Contains(out, "<when>2024-06-01</when>") || strings.Count(out, "<TimeStamp>") != 1 {
+		t.Errorf("date TimeStamp not kept:\n%s", out)
+	}
+}
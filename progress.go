@@ -0,0 +1,83 @@
+package gokml
+
+import (
+	"io"
+	"os"
+)
+
+// Progress receives reports on a long-running operation, such as an import
+// or downloading the resources of a document, for showing a progress bar
+// or emitting metrics.
+type Progress interface {
+	// Report is called as the operation advances.  stage names the part of
+	// the operation under way, current is the amount of it done so far, and
+	// total is the amount there is to do, or -1 if it isn't known.
+	Report(stage string, current int64, total int64)
+}
+
+// ProgressFunc adapts a function to the Progress interface.
+type ProgressFunc func(stage string, current int64, total int64)
+
+// Report calls f.
+func (f ProgressFunc) Report(stage string, current int64, total int64) {
+	f(stage, current, total)
+}
+
+const (
+	// progressBytes is the number of bytes read between reports of an
+	// import's progress.
+	progressBytes = 64 << 10
+
+	// progressRows is the number of rows scanned between reports of an
+	// import's progress.
+	progressRows = 1000
+)
+
+// progressReader reports the number of bytes read through it.
+type progressReader struct {
+	r        io.Reader
+	progress Progress
+	read     int64
+	total    int64
+	reported int64
+}
+
+// readProgress returns a reader reporting the bytes read from r to
+// progress, as the "reading" stage, or r itself if progress is nil.  The
+// total is the number of bytes left in r, if it's a bytes.Reader,
+// strings.Reader, or regular file.
+func readProgress(r io.Reader, progress Progress) io.Reader {
+	if progress == nil {
+		return r
+	}
+
+	total := int64(-1)
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		total = int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		offset, seekErr := r.Seek(0, io.SeekCurrent)
+		if err == nil && seekErr == nil && info.Mode().IsRegular() {
+			total = info.Size() - offset
+		}
+	}
+
+	progress.Report("reading", 0, total)
+
+	return &progressReader{r, progress, 0, total, 0}
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.read += int64(n)
+
+	// decoders may stop at the end of the data without reading to EOF
+	done := err == io.EOF || pr.read == pr.total
+	if pr.read-pr.reported >= progressBytes || (done && pr.read > pr.reported) {
+		pr.progress.Report("reading", pr.read, pr.total)
+		pr.reported = pr.read
+	}
+
+	return n, err
+}
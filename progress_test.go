@@ -0,0 +1,72 @@
+package gokml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadProgress(t *testing.T) {
+	var reports []string
+	progress := ProgressFunc(func(stage string, current int64, total int64) {
+		reports = append(reports, fmt.Sprintf("%s %d/%d", stage, current, total))
+	})
+
+	data := bytes.Repeat([]byte("x"), progressBytes*2+10)
+	if _, err := io.Copy(io.Discard, readProgress(bytes.NewReader(data), progress)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) < 3 || reports[0] != fmt.Sprintf("reading 0/%d", len(data)) || reports[len(reports)-1] != fmt.Sprintf("reading %d/%d", len(data), len(data)) {
+		t.Errorf("unexpected reports %v", reports)
+	}
+
+	// files report what's left to read, other readers no total
+	name := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Seek(10, io.SeekStart)
+
+	reports = nil
+	readProgress(f, progress)
+	readProgress(io.MultiReader(strings.NewReader("x")), progress)
+	if want := fmt.Sprintf("[reading 0/%d reading 0/-1]", len(data)-10); fmt.Sprint(reports) != want {
+		t.Errorf("got %v, want %s", reports, want)
+	}
+
+	if r := strings.NewReader(""); readProgress(r, nil) != r {
+		t.Error("reader wrapped without a Progress")
+	}
+}
+
+func TestImportProgress(t *testing.T) {
+	var last string
+	progress := ProgressFunc(func(stage string, current int64, total int64) {
+		last = fmt.Sprintf("%s %d/%d", stage, current, total)
+	})
+
+	columns := CSVColumns{Lat: "latitude", Lon: "longitude", Progress: progress}
+	if _, err := FromCSV(strings.NewReader(csvFixture), "", columns); err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("reading %d/%d", len(csvFixture), len(csvFixture)); last != want {
+		t.Errorf("CSV progress = %s, want %s", last, want)
+	}
+
+	if _, err := FromGPX(strings.NewReader(gpxFixture), progress); err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("reading %d/%d", len(gpxFixture), len(gpxFixture)); last != want {
+		t.Errorf("GPX progress = %s, want %s", last, want)
+	}
+}
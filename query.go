@@ -0,0 +1,59 @@
+package gokml
+
+// FindFolders returns every Folder in the document with the given name, in
+// document order, including Folders nested in matching Folders.
+func (k *KML) FindFolders(name string) []*Folder {
+	return k.rootFolder.FindFolders(name)
+}
+
+// FindPlacemarks returns every Placemark in the document for which match
+// returns true, in document order.
+func (k *KML) FindPlacemarks(match func(pm *Placemark) bool) []*Placemark {
+	return k.rootFolder.FindPlacemarks(match)
+}
+
+// FindFolders returns every Folder within f (not including f) with the
+// given name, in document order.
+func (f *Folder) FindFolders(name string) []*Folder {
+	ret := make([]*Folder, 0)
+
+	var walk func(folder *Folder)
+	walk = func(folder *Folder) {
+		for _, child := range folder.features {
+			if sub, ok := child.(*Folder); ok {
+				if sub.name == name {
+					ret = append(ret, sub)
+				}
+				walk(sub)
+			}
+		}
+	}
+	walk(f)
+
+	return ret
+}
+
+// FindPlacemarks returns every Placemark within f for which match returns
+// true, in document order.  Use it with FindFolders to select the
+// Placemarks of a particular Folder.
+func (f *Folder) FindPlacemarks(match func(pm *Placemark) bool) []*Placemark {
+	ret := make([]*Placemark, 0)
+
+	walkPlacemarks(f, func(pm *Placemark) {
+		if match(pm) {
+			ret = append(ret, pm)
+		}
+	})
+
+	return ret
+}
+
+// Name returns the name of the Folder.
+func (f *Folder) Name() string {
+	return f.name
+}
+
+// Name returns the name of the Placemark.
+func (pm *Placemark) Name() string {
+	return pm.name
+}
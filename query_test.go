@@ -0,0 +1,57 @@
+package gokml
+
+import (
+	"testing"
+)
+
+func TestFindFolders(t *testing.T) {
+	k := NewKML("Query")
+
+	tracks := NewFolder("Tracks", "")
+	k.AddFeature(tracks)
+	nested := NewFolder("Tracks", "")
+	tracks.AddFeature(nested)
+	k.AddFeature(NewFolder("Sites", ""))
+
+	found := k.FindFolders("Tracks")
+	if len(found) != 2 || found[0] != tracks || found[1] != nested {
+		t.Errorf("unexpected FindFolders result %v", found)
+	}
+
+	if found := k.FindFolders("Missing"); len(found) != 0 {
+		t.Errorf("found missing folder: %v", found)
+	}
+}
+
+func TestFindPlacemarks(t *testing.T) {
+	k := NewKML("Query")
+
+	tracks := NewFolder("Tracks", "")
+	morning := NewPlacemark("Morning Ride", "", NewPoint(40.0, -105.0, 0.0))
+	morning.SetData("kind", "ride")
+	tracks.AddFeature(morning)
+	walk := NewPlacemark("Walk", "", NewPoint(40.1, -105.1, 0.0))
+	walk.SetData("kind", "walk")
+	tracks.AddFeature(walk)
+	k.AddFeature(tracks)
+
+	evening := NewPlacemark("Evening Ride", "", NewPoint(40.2, -105.2, 0.0))
+	evening.SetData("kind", "ride")
+	k.AddFeature(evening)
+
+	isRide := func(pm *Placemark) bool {
+		kind, _ := pm.Data("kind")
+		return kind == "ride"
+	}
+
+	rides := k.FindPlacemarks(isRide)
+	if len(rides) != 2 || rides[0] != morning || rides[1] != evening {
+		t.Errorf("unexpected FindPlacemarks result %v", rides)
+	}
+
+	// the equivalent of "Folder[name=Tracks] > Placemark"
+	rides = k.FindFolders("Tracks")[0].FindPlacemarks(isRide)
+	if len(rides) != 1 || rides[0].Name() != "Morning Ride" {
+		t.Errorf("unexpected Folder.FindPlacemarks result %v", rides)
+	}
+}
@@ -0,0 +1,228 @@
+package gokml
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Placemarks returns every Placemark in the document, in document order.
+func (k *KML) Placemarks() []*Placemark {
+	ret := make([]*Placemark, 0)
+	walkPlacemarks(k.rootFolder, func(pm *Placemark) { ret = append(ret, pm) })
+	return ret
+}
+
+// SampleUniform returns n placemarks chosen uniformly at random, in their
+// original order.  If n is not less than the number of placemarks, all of
+// them are returned.  If rng is nil, a time-seeded source is used.
+func SampleUniform(placemarks []*Placemark, n int, rng *rand.Rand) []*Placemark {
+	if n >= len(placemarks) {
+		return append([]*Placemark(nil), placemarks...)
+	}
+
+	rng = sampleRand(rng)
+	indexes := rng.Perm(len(placemarks))[:maxInt(n, 0)]
+
+	return sampleByIndex(placemarks, indexes)
+}
+
+// SampleWeighted returns n placemarks chosen at random with probability
+// proportional to weight, in their original order.  Placemarks with a
+// weight that is not positive are never chosen.  If rng is nil, a
+// time-seeded source is used.
+func SampleWeighted(placemarks []*Placemark, n int, weight func(*Placemark) float64, rng *rand.Rand) []*Placemark {
+	rng = sampleRand(rng)
+
+	// weighted sampling without replacement (Efraimidis-Spirakis): keep the
+	// n largest keys of u^(1/w)
+	type keyed struct {
+		index int
+		key   float64
+	}
+
+	keys := make([]keyed, 0, len(placemarks))
+	for i, pm := range placemarks {
+		w := weight(pm)
+		if w <= 0.0 || math.IsNaN(w) {
+			continue
+		}
+		keys = append(keys, keyed{i, math.Pow(rng.Float64(), 1.0/w)})
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key > keys[j].key })
+
+	if n < len(keys) {
+		keys = keys[:maxInt(n, 0)]
+	}
+
+	indexes := make([]int, len(keys))
+	for i, k := range keys {
+		indexes[i] = k.index
+	}
+
+	return sampleByIndex(placemarks, indexes)
+}
+
+// SampleStratified returns n placemarks spread evenly over the area covered
+// by the placemarks, in their original order.  The area is divided into a
+// grid and placemarks are drawn at random from each grid cell in turn, so
+// sparse areas are represented as well as dense ones.  Placemarks without
+// a geometry are never chosen.  If rng is nil, a time-seeded source is used.
+func SampleStratified(placemarks []*Placemark, n int, rng *rand.Rand) []*Placemark {
+	rng = sampleRand(rng)
+
+	points := make(map[int]*Point)
+	var b Bounds
+	for i, pm := range placemarks {
+		p := representativePoint(pm)
+		if p == nil {
+			continue
+		}
+
+		if len(points) == 0 {
+			b = Bounds{p.Lat, p.Lat, p.Lon, p.Lon}
+		} else {
+			b.North = math.Max(b.North, p.Lat)
+			b.South = math.Min(b.South, p.Lat)
+			b.East = math.Max(b.East, p.Lon)
+			b.West = math.Min(b.West, p.Lon)
+		}
+		points[i] = p
+	}
+
+	if n <= 0 || len(points) == 0 {
+		return []*Placemark{}
+	}
+
+	grid := int(math.Ceil(math.Sqrt(float64(n))))
+	cellOf := func(v, min, max float64) int {
+		if max == min {
+			return 0
+		}
+		c := int((v - min) / (max - min) * float64(grid))
+		if c >= grid {
+			c = grid - 1
+		}
+		return c
+	}
+
+	cells := make(map[int][]int)
+	for i := range placemarks {
+		p, ok := points[i]
+		if !ok {
+			continue
+		}
+		cell := cellOf(p.Lat, b.South, b.North)*grid + cellOf(p.Lon, b.West, b.East)
+		cells[cell] = append(cells[cell], i)
+	}
+
+	// visit the cells in a random order, shuffling the members of each
+	order := make([][]int, 0, len(cells))
+	keys := make([]int, 0, len(cells))
+	for cell := range cells {
+		keys = append(keys, cell)
+	}
+	sort.Ints(keys)
+	for _, cell := range keys {
+		members := cells[cell]
+		rng.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+		order = append(order, members)
+	}
+	rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+
+	indexes := make([]int, 0, n)
+	for round := 0; len(indexes) < n; round++ {
+		added := false
+		for _, members := range order {
+			if round < len(members) && len(indexes) < n {
+				indexes = append(indexes, members[round])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	return sampleByIndex(placemarks, indexes)
+}
+
+// overviewMinLodPixels is how many pixels across the area of an overview
+// must appear on screen before its full detail is loaded.
+const overviewMinLodPixels = 256
+
+// NewOverview returns a document for browsing a large set of placemarks: a
+// Folder holding sample, such as from SampleStratified, and a NetworkLink to
+// href, a document holding the full set.  The link has a Region covering
+// placemarks, so Google Earth only loads the full detail when zoomed in on
+// them.  The sampled Placemarks are added to the document, not copied.
+func NewOverview(name string, sample []*Placemark, placemarks []*Placemark, href string) *KML {
+	k := NewKML(name)
+
+	f := NewFolder("Sample", fmt.Sprintf("%d of %d placemarks", len(sample), len(placemarks)))
+	for _, pm := range sample {
+		f.AddFeature(pm)
+	}
+	k.AddFeature(f)
+
+	all := NewFolder("", "")
+	for _, pm := range placemarks {
+		all.AddFeature(pm)
+	}
+
+	nl := NewNetworkLink("Full detail", "", href)
+	if b, ok := all.Bounds(); ok {
+		nl.SetRegion(b.padded(catalogMinSpan))
+		nl.SetMinLodPixels(overviewMinLodPixels)
+	}
+	k.AddFeature(nl)
+
+	return k
+}
+
+// representativePoint returns the first Point of the Placemark's geometry,
+// or nil if it has none.
+func representativePoint(pm *Placemark) *Point {
+	var ret *Point
+	walkPoints(pm.geometry, func(p *Point) {
+		if ret == nil {
+			ret = p
+		}
+	})
+	return ret
+}
+
+func sampleByIndex(placemarks []*Placemark, indexes []int) []*Placemark {
+	sort.Ints(indexes)
+
+	ret := make([]*Placemark, len(indexes))
+	for i, index := range indexes {
+		ret[i] = placemarks[index]
+	}
+
+	return ret
+}
+
+func sampleRand(rng *rand.Rand) *rand.Rand {
+	if rng == nil {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return rng
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+package gokml
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func samplePlacemarks() []*Placemark {
+	ret := make([]*Placemark, 0)
+
+	// a dense cluster near Denver and a handful of outliers
+	for i := 0; i < 200; i++ {
+		ret = append(ret, NewPlacemark("dense", "", NewPoint(39.7+float64(i)*0.0001, -105.0, 0.0)))
+	}
+	for i := 0; i < 4; i++ {
+		ret = append(ret, NewPlacemark("sparse", "", NewPoint(30.0+float64(i)*5.0, -80.0-float64(i)*5.0, 0.0)))
+	}
+
+	return ret
+}
+
+func TestSampleUniform(t *testing.T) {
+	pms := samplePlacemarks()
+	sample := SampleUniform(pms, 10, rand.New(rand.NewSource(1)))
+	if len(sample) != 10 {
+		t.Fatalf("expected 10 placemarks, got %d", len(sample))
+	}
+
+	if len(SampleUniform(pms, 1000, nil)) != len(pms) {
+		t.Error("expected all placemarks when n is too large")
+	}
+}
+
+func TestSampleWeighted(t *testing.T) {
+	pms := samplePlacemarks()
+	sample := SampleWeighted(pms, 4, func(pm *Placemark) float64 {
+		if pm.name == "sparse" {
+			return 1.0
+		}
+		return 0.0
+	}, rand.New(rand.NewSource(1)))
+
+	if len(sample) != 4 {
+		t.Fatalf("expected 4 placemarks, got %d", len(sample))
+	}
+
+	for _, pm := range sample {
+		if pm.name != "sparse" {
+			t.Errorf("zero-weight placemark chosen")
+		}
+	}
+}
+
+func TestSampleStratified(t *testing.T) {
+	pms := samplePlacemarks()
+	sample := SampleStratified(pms, 9, rand.New(rand.NewSource(1)))
+	if len(sample) != 9 {
+		t.Fatalf("expected 9 placemarks, got %d", len(sample))
+	}
+
+	sparse := 0
+	for _, pm := range sample {
+		if pm.name == "sparse" {
+			sparse++
+		}
+	}
+
+	if sparse != 4 {
+		t.Errorf("expected all 4 outliers to be represented, got %d", sparse)
+	}
+}
+
+func TestNewOverview(t *testing.T) {
+	pms := samplePlacemarks()
+	sample := SampleStratified(pms, 9, rand.New(rand.NewSource(1)))
+	k := NewOverview("Stations", sample, pms, "stations-full.kmz")
+
+	if got := len(k.Placemarks()); got != 9 {
+		t.Errorf("got %d placemarks, want 9", got)
+	}
+
+	nl, ok := k.rootFolder.features[1].(*NetworkLink)
+	if !ok || nl.href != "stations-full.kmz" || nl.minLod != overviewMinLodPixels {
+		t.Fatalf("no link to the full detail: %#v", k.rootFolder.features)
+	}
+
+	for _, pm := range pms {
+		b, _ := pm.Bounds()
+		if !nl.region.contains(b.North, b.East) {
+			t.Errorf("region %v doesn't cover %v", *nl.region, b)
+		}
+	}
+
+	out := k.Render()
+	for _, want := range []string{"<Region>", "<minLodPixels>256</minLodPixels>", "<description>9 of 204 placemarks</description>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package gokml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// aircraftIcon is the icon of aircraft positions, pointing north until
+// rotated.
+const aircraftIcon = "http://maps.google.com/mapfiles/kml/shapes/airports.png"
+
+// AircraftTracker keeps the positions of aircraft received from an SBS-1
+// (BaseStation) feed, such as port 30003 of dump1090 or readsb, and builds
+// KML documents of them for a NetworkLink to refresh.  It is safe for
+// concurrent use, so one goroutine can read the feed while others serve the
+// documents.
+type AircraftTracker struct {
+	aircraft map[string]*aircraft
+	latest   time.Time // time of the latest message
+	maxAge   time.Duration
+	trail    time.Duration
+	mutex    *sync.Mutex
+}
+
+// aircraft is the state of an aircraft, from all of its messages.
+type aircraft struct {
+	icao, callsign, squawk string
+	altitude               float64 // feet
+	speed                  float64 // knots
+	heading                float64 // degrees, of the ground track
+	verticalRate           float64 // feet per minute
+	hasHeading, onGround   bool
+	lastSeen               time.Time
+	times                  []time.Time
+	points                 []*Point
+}
+
+// NewAircraftTracker returns a new AircraftTracker, which forgets aircraft
+// not heard from in five minutes and keeps ten minutes of track.
+func NewAircraftTracker() *AircraftTracker {
+	return &AircraftTracker{make(map[string]*aircraft), time.Time{}, 5 * time.Minute, 10 * time.Minute, new(sync.Mutex)}
+}
+
+// SetMaxAge sets how long an aircraft is kept after its last message.
+// Times are those of the messages, so a recorded feed can be replayed.
+// Values that aren't positive are ignored.
+func (t *AircraftTracker) SetMaxAge(d time.Duration) {
+	if d > 0 {
+		t.mutex.Lock()
+		t.maxAge = d
+		t.mutex.Unlock()
+	}
+}
+
+// SetTrailLength sets how much of the recent track of each aircraft is
+// kept.  Values that aren't positive are ignored.
+func (t *AircraftTracker) SetTrailLength(d time.Duration) {
+	if d > 0 {
+		t.mutex.Lock()
+		t.trail = d
+		t.mutex.Unlock()
+	}
+}
+
+// ReadMessages reads SBS messages from r, one per line, until it returns an
+// error or io.EOF, which isn't returned.  Malformed lines are skipped.
+func (t *AircraftTracker) ReadMessages(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		t.HandleMessage(scanner.Text())
+	}
+
+	return scanner.Err()
+}
+
+// HandleMessage updates the tracker with an SBS message, such as
+// "MSG,3,1,1,A1B2C3,1,2024/06/01,08:00:00.000,...".  Transmission messages
+// (MSG) update the callsign, altitude, speed, heading, vertical rate, and
+// squawk of an aircraft and add its positions to its track; other messages
+// are ignored.  Message times are taken as UTC.  Returns an error for a
+// malformed message.
+func (t *AircraftTracker) HandleMessage(line string) error {
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields) == 0 || fields[0] != "MSG" {
+		return nil
+	}
+	if len(fields) < 22 {
+		return fmt.Errorf("gokml: SBS message has %d fields, want 22", len(fields))
+	}
+
+	icao := strings.ToUpper(strings.TrimSpace(fields[4]))
+	if len(icao) == 0 {
+		return fmt.Errorf("gokml: SBS message has no aircraft address")
+	}
+
+	when, err := time.Parse("2006/01/02 15:04:05.000", fields[6]+" "+fields[7])
+	if err != nil {
+		when, err = time.Parse("2006/01/02 15:04:05.000", fields[8]+" "+fields[9])
+	}
+	if err != nil {
+		when = time.Now().UTC()
+	}
+
+	number := func(i int, v *float64) bool {
+		f, err := strconv.ParseFloat(strings.TrimSpace(fields[i]), 64)
+		if err == nil {
+			*v = f
+		}
+		return err == nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	a := t.aircraft[icao]
+	if a == nil {
+		a = &aircraft{icao: icao}
+		t.aircraft[icao] = a
+	}
+	if when.After(a.lastSeen) {
+		a.lastSeen = when
+	}
+	if when.After(t.latest) {
+		t.latest = when
+	}
+
+	if callsign := strings.TrimSpace(fields[10]); len(callsign) > 0 {
+		a.callsign = callsign
+	}
+	if squawk := strings.TrimSpace(fields[17]); len(squawk) > 0 {
+		a.squawk = squawk
+	}
+	if ground := strings.TrimSpace(fields[21]); len(ground) > 0 {
+		a.onGround = ground != "0"
+	}
+	number(11, &a.altitude)
+	number(12, &a.speed)
+	number(16, &a.verticalRate)
+	if number(13, &a.heading) {
+		a.hasHeading = true
+	}
+
+	var lat, lon float64
+	if number(14, &lat) && number(15, &lon) {
+		if p := NewPoint(lat, lon, a.altitude*foot); p != nil {
+			a.times = append(a.times, when)
+			a.points = append(a.points, p)
+		}
+	}
+
+	// drop the end of the trail
+	n := 0
+	for n < len(a.times) && a.times[n].Before(when.Add(-t.trail)) {
+		n++
+	}
+	a.times, a.points = a.times[n:], a.points[n:]
+
+	return nil
+}
+
+// KML returns a document of the aircraft heard from recently, with a
+// Positions Folder of Placemarks at their latest positions, and a Tracks
+// Folder of their recent gx:Tracks at their barometric altitudes.
+// Position icons are rotated to the heading of each aircraft, and the
+// callsign, address, altitude, speed, and squawk are kept as ExtendedData.
+// Placemarks have ids from the aircraft address, so successive documents
+// can be compared with Delta.  Positions are drawn on the ground.
+func (t *AircraftTracker) KML() *KML {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var icaos []string
+	for icao, a := range t.aircraft {
+		if a.lastSeen.Before(t.latest.Add(-t.maxAge)) {
+			delete(t.aircraft, icao)
+			continue
+		}
+		if len(a.points) > 0 {
+			icaos = append(icaos, icao)
+		}
+	}
+	sort.Strings(icaos)
+
+	k := NewKML("Aircraft")
+	positions := NewFolder("Positions", "")
+	tracks := NewFolder("Tracks", "")
+
+	trackStyle := NewStyle("aircraft-track", 0xff, 0xff, 0xc0, 0x00)
+	trackStyle.SetIconScale(0)
+	k.AddFeature(trackStyle)
+
+	styled := make(map[string]bool)
+
+	for _, icao := range icaos {
+		a := t.aircraft[icao]
+		name := a.callsign
+		if len(name) == 0 {
+			name = icao
+		}
+
+		// icons are rotated in steps of 10 degrees, to share Styles
+		style := "aircraft"
+		heading := 0.0
+		if a.hasHeading {
+			heading = math.Mod(math.Round(a.heading/10)*10, 360)
+			style = fmt.Sprintf("aircraft-%03.0f", heading)
+		}
+		if !styled[style] {
+			styled[style] = true
+			s := NewStyle(style, 0xff, 0xff, 0xff, 0x00)
+			s.SetIconURL(aircraftIcon)
+			s.SetIconHeading(heading)
+			k.AddFeature(s)
+		}
+
+		last := *a.points[len(a.points)-1]
+		desc := fmt.Sprintf("%.0f ft, %.0f kt", a.altitude, a.speed)
+		if a.onGround {
+			desc = fmt.Sprintf("on the ground, %.0f kt", a.speed)
+		}
+
+		pm := NewPlacemark(name, desc, &last)
+		pm.SetID("aircraft-" + icao)
+		pm.SetStyle(style)
+		pm.SetData("icao", icao)
+		if len(a.callsign) > 0 {
+			pm.SetData("callsign", a.callsign)
+		}
+		pm.SetData("altitude", strconv.FormatFloat(a.altitude, 'f', -1, 64))
+		pm.SetData("speed", strconv.FormatFloat(a.speed, 'f', -1, 64))
+		pm.SetData("heading", strconv.FormatFloat(a.heading, 'f', -1, 64))
+		pm.SetData("verticalRate", strconv.FormatFloat(a.verticalRate, 'f', -1, 64))
+		if len(a.squawk) > 0 {
+			pm.SetData("squawk", a.squawk)
+		}
+		positions.AddFeature(pm)
+
+		if len(a.points) > 1 {
+			track := NewTrack()
+			track.SetAltitudeMode(Absolute)
+			for i, p := range a.points {
+				cp := *p
+				track.AddPoint(a.times[i], &cp)
+			}
+
+			pm := NewPlacemark(name, "", track)
+			pm.SetID("track-" + icao)
+			pm.SetStyle("aircraft-track")
+			tracks.AddFeature(pm)
+		}
+	}
+
+	k.AddFeature(positions)
+	k.AddFeature(tracks)
+
+	return k
+}
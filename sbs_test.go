@@ -0,0 +1,74 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sbsFixture = `MSG,1,1,1,A1B2C3,1,2024/06/01,08:00:00.000,2024/06/01,08:00:00.000,UAL123  ,,,,,,,,,,,0
+MSG,3,1,1,A1B2C3,1,2024/06/01,08:00:01.000,2024/06/01,08:00:01.000,,35000,,,40.0,-105.0,,,0,0,0,0
+MSG,4,1,1,A1B2C3,1,2024/06/01,08:00:02.000,2024/06/01,08:00:02.000,,,450,87,,,-64,,,,,0
+MSG,3,1,1,A1B2C3,1,2024/06/01,08:00:06.000,2024/06/01,08:00:06.000,,34900,,,40.0,-104.96,,,0,0,0,0
+MSG,6,1,1,A1B2C3,1,2024/06/01,08:00:07.000,2024/06/01,08:00:07.000,,,,,,,,7000,0,0,0,0
+MSG,3,1,1,00FFEE,1,2024/06/01,07:50:00.000,2024/06/01,07:50:00.000,,12000,,,39.5,-104.5,,,0,0,0,0
+STA,,1,1,A1B2C3,1,2024/06/01,08:00:08.000,2024/06/01,08:00:08.000,RM
+MSG,3,1,1
+`
+
+func TestAircraftTracker(t *testing.T) {
+	tracker := NewAircraftTracker()
+	if err := tracker.ReadMessages(strings.NewReader(sbsFixture)); err != nil {
+		t.Fatal(err)
+	}
+
+	k := tracker.KML()
+
+	// 00FFEE was last heard from ten minutes earlier
+	positions := k.FindFolders("Positions")[0].features
+	if len(positions) != 1 {
+		t.Fatalf("got %d positions, want 1", len(positions))
+	}
+
+	pm := positions[0].(*Placemark)
+	if pm.name != "UAL123" || pm.id != "aircraft-A1B2C3" || pm.style != "aircraft-090" {
+		t.Errorf("unexpected placemark %q %q %q", pm.name, pm.id, pm.style)
+	}
+	if p := pm.geometry.(*Point); p.Lon != -104.96 || p.Alt != 34900*foot {
+		t.Errorf("unexpected position %v", p)
+	}
+	for name, want := range map[string]string{"altitude": "34900", "speed": "450", "squawk": "7000", "verticalRate": "-64"} {
+		if v, _ := pm.Data(name); v != want {
+			t.Errorf("%s = %q, want %q", name, v, want)
+		}
+	}
+
+	track := k.FindFolders("Tracks")[0].features[0].(*Placemark).geometry.(*Track)
+	if len(track.points) != 2 || track.altitudeMode != Absolute || !track.times[1].Equal(time.Date(2024, 6, 1, 8, 0, 6, 0, time.UTC)) {
+		t.Errorf("unexpected track %v %v", track.points, track.times)
+	}
+
+	out := k.Render()
+	for _, want := range []string{
+		"<heading>90.000000</heading>",
+		"<altitudeMode>absolute</altitudeMode>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if err := k.Validate(); err != nil {
+		t.Errorf("document is invalid: %v", err)
+	}
+
+	// the trail is shortened as messages arrive
+	tracker.SetTrailLength(time.Second)
+	tracker.HandleMessage("MSG,3,1,1,A1B2C3,1,2024/06/01,08:00:10.000,2024/06/01,08:00:10.000,,34800,,,40.0,-104.93,,,0,0,0,0")
+	if n := len(tracker.aircraft["A1B2C3"].points); n != 1 {
+		t.Errorf("trail has %d points, want 1", n)
+	}
+
+	if err := tracker.HandleMessage("MSG,3,1,1"); err == nil {
+		t.Errorf("no error for a short message")
+	}
+}
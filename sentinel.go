@@ -0,0 +1,53 @@
+package gokml
+
+// DefaultSentinels are coordinates that data feeds commonly use for missing
+// or invalid positions: 0,0 ("Null Island"), the out-of-range 91,181, and
+// -999,-999.  Altitudes are ignored when matching sentinels.
+var DefaultSentinels = []Point{
+	{0, 0, 0},
+	{91, 181, 0},
+	{-999, -999, 0},
+}
+
+// SentinelPlacemarks returns the Placemarks that have any coordinate at one
+// of the sentinel positions, in document order.  If no sentinels are given,
+// DefaultSentinels are used.
+func (k *KML) SentinelPlacemarks(sentinels ...Point) []*Placemark {
+	if len(sentinels) == 0 {
+		sentinels = DefaultSentinels
+	}
+
+	ret := make([]*Placemark, 0)
+	walkPlacemarks(k.rootFolder, func(pm *Placemark) {
+		if atSentinel(pm, sentinels) {
+			ret = append(ret, pm)
+		}
+	})
+
+	return ret
+}
+
+// RemoveSentinelPlacemarks removes the Placemarks returned by
+// SentinelPlacemarks from the document and returns them.
+func (k *KML) RemoveSentinelPlacemarks(sentinels ...Point) []*Placemark {
+	removed := k.SentinelPlacemarks(sentinels...)
+
+	for _, pm := range removed {
+		removeFeature(k.rootFolder, pm)
+	}
+
+	return removed
+}
+
+func atSentinel(pm *Placemark, sentinels []Point) bool {
+	found := false
+	walkPoints(pm, func(p *Point) {
+		for _, s := range sentinels {
+			if p.Lat == s.Lat && p.Lon == s.Lon {
+				found = true
+			}
+		}
+	})
+
+	return found
+}
@@ -0,0 +1,36 @@
+package gokml
+
+import "testing"
+
+func TestSentinelPlacemarks(t *testing.T) {
+	k := NewKML("Feed")
+	f := NewFolder("Vehicles", "")
+	k.AddFeature(f)
+
+	f.AddFeature(NewPlacemark("Null Island", "", NewPoint(0.0, 0.0, 10.0)))
+	f.AddFeature(NewPlacemark("Missing", "", &Point{Lat: -999, Lon: -999}))
+	f.AddFeature(NewPlacemark("Fine", "", NewPoint(40.0, -105.0, 0.0)))
+
+	line := NewLineString()
+	line.AddPoint(NewPoint(40.0, -105.0, 0.0))
+	line.AddPoint(NewPoint(12.0, 34.0, 0.0))
+	k.AddFeature(NewPlacemark("Custom", "", line))
+
+	if got := k.SentinelPlacemarks(); len(got) != 2 {
+		t.Errorf("got %d default sentinel placemarks, want 2", len(got))
+	}
+
+	custom := k.SentinelPlacemarks(Point{Lat: 12.0, Lon: 34.0})
+	if len(custom) != 1 || custom[0].name != "Custom" {
+		t.Errorf("custom sentinel not matched: %v", custom)
+	}
+
+	removed := k.RemoveSentinelPlacemarks()
+	if len(removed) != 2 {
+		t.Errorf("removed %d placemarks, want 2", len(removed))
+	}
+
+	if got := len(k.Placemarks()); got != 2 {
+		t.Errorf("%d placemarks left, want 2", got)
+	}
+}
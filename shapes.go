@@ -0,0 +1,412 @@
+package gokml
+
+import (
+	"math"
+	"sort"
+)
+
+// defaultSegments is the number of segments of generated shapes when no
+// valid number is given: one every arcStep degrees.
+const defaultSegments = int(360 / arcStep)
+
+// Circle returns a Polygon approximating a circle (disc) with a radius in
+// meters around center, since KML has no circle element.  The vertices are
+// found along great circles from the center, so the circle keeps its shape
+// at any latitude instead of being squashed like a circle of equal
+// latitude and longitude offsets.  A circle around a pole is drawn as the
+// cap of the globe, bounded by the antimeridian and the pole.  The ring is
+// counterclockwise, has segments sides (72 if fewer than 3), and the
+// altitude of the center.  Returns nil if center is nil or the radius isn't
+// positive and less than half the circumference of the Earth.
+func Circle(center *Point, radius float64, segments int) *Polygon {
+	if center == nil || !(radius > 0) || radius >= math.Pi*earthRadius {
+		return nil
+	}
+	if segments < 3 {
+		segments = defaultSegments
+	}
+
+	ring := make([]*Point, 0, segments)
+	for i := 0; i < segments; i++ {
+		// counterclockwise, seen from above
+		ring = append(ring, destination(center, -360*float64(i)/float64(segments), radius))
+	}
+
+	return ringPolygon(center, radius, ring)
+}
+
+// Ellipse returns a Polygon approximating an ellipse around center, with
+// semi-axes in meters, the first along the orientation (in degrees clockwise
+// from north) and the second across it, such as an antenna pattern or an
+// area of uncertainty.  Like a Circle, the vertices are found along great
+// circles from the center, an ellipse around a pole is drawn as a cap, and
+// the ring is counterclockwise with segments sides (72 if fewer than 3).
+// Returns nil if center is nil or a semi-axis isn't positive and less than
+// half the circumference of the Earth.
+func Ellipse(center *Point, semiMajor float64, semiMinor float64, orientation float64, segments int) *Polygon {
+	limit := math.Pi * earthRadius
+	if center == nil || !(semiMajor > 0) || !(semiMinor > 0) || semiMajor >= limit || semiMinor >= limit {
+		return nil
+	}
+	if segments < 3 {
+		segments = defaultSegments
+	}
+
+	ring := make([]*Point, 0, segments)
+	for i := 0; i < segments; i++ {
+		angle := -360 * float64(i) / float64(segments)
+		sin, cos := math.Sincos(radians(angle))
+
+		// the distance to the ellipse in polar form, from its center
+		r := semiMajor * semiMinor / math.Hypot(semiMinor*cos, semiMajor*sin)
+		ring = append(ring, destination(center, orientation+angle, r))
+	}
+
+	return ringPolygon(center, math.Max(semiMajor, semiMinor), ring)
+}
+
+// ErrorEllipse returns the Ellipse of a position uncertainty with the
+// covariance of its east and north errors (in square meters), such as from
+// a GNSS receiver or a Kalman filter, holding the position with the
+// confidence (the probability, between 0 and 1, such as 0.95).  Returns nil
+// if center is nil, the confidence is out of range, or the covariance isn't
+// positive definite.
+func ErrorEllipse(center *Point, varEast float64, varNorth float64, covariance float64, confidence float64, segments int) *Polygon {
+	if !(confidence > 0 && confidence < 1) {
+		return nil
+	}
+
+	// the eigenvalues of the covariance matrix are the variances along the
+	// axes of the ellipse
+	mean := (varEast + varNorth) / 2
+	spread := math.Hypot((varEast-varNorth)/2, covariance)
+	major, minor := mean+spread, mean-spread
+	if !(minor > 0) {
+		return nil
+	}
+
+	// the 2D chi-squared quantile for the confidence
+	scale := math.Sqrt(-2 * math.Log(1-confidence))
+
+	// the angle of the major axis from east, counterclockwise
+	angle := degrees(math.Atan2(2*covariance, varEast-varNorth)) / 2
+
+	return Ellipse(center, scale*math.Sqrt(major), scale*math.Sqrt(minor), 90-angle, segments)
+}
+
+// Arc returns a LineString along a circle with a radius in meters around
+// center, clockwise from one bearing to another (in degrees clockwise from
+// north), such as the edge of a radar's coverage.  An arc from a bearing to
+// itself is a full circle.  The arc has segments segments (or one every 5
+// degrees if fewer than 1), and the altitude of the center.  Returns nil if
+// center is nil or the radius isn't positive and less than half the
+// circumference of the Earth.
+func Arc(center *Point, radius float64, from float64, to float64, segments int) *LineString {
+	if center == nil || !(radius > 0) || radius >= math.Pi*earthRadius {
+		return nil
+	}
+
+	ls := NewLineString()
+	for _, p := range sweepPoints(center, radius, from, clockwiseSweep(from, to), segments) {
+		ls.AddPoint(p)
+	}
+
+	return ls
+}
+
+// Sector returns a Polygon of a pie slice of a circle with a radius in
+// meters around center, clockwise from one bearing to another (in degrees
+// clockwise from north), such as a camera's field of view or the lobe of a
+// directional antenna.  A sector from a bearing to itself is a Circle.  The
+// arc has segments segments (or one every 5 degrees if fewer than 1), the
+// ring is counterclockwise, and it has the altitude of the center.  Returns
+// nil if center is nil or the radius isn't positive and less than half the
+// circumference of the Earth.
+func Sector(center *Point, radius float64, from float64, to float64, segments int) *Polygon {
+	return Wedge(center, 0, radius, from, to, segments)
+}
+
+// Wedge returns a Polygon of the part of a ring around center between two
+// radii in meters, clockwise from one bearing to another (in degrees
+// clockwise from north), such as the coverage of a radar with a minimum
+// range.  A wedge with an inner radius of 0 is a Sector, and a wedge from a
+// bearing to itself is a Circle with a hole.  Each arc has segments
+// segments (or one every 5 degrees if fewer than 1), the ring is
+// counterclockwise, and it has the altitude of the center.  Returns nil if
+// center is nil, the inner radius is negative, or the outer radius isn't
+// more than the inner radius and less than half the circumference of the
+// Earth.
+func Wedge(center *Point, inner float64, outer float64, from float64, to float64, segments int) *Polygon {
+	if center == nil || !(inner >= 0) || !(outer > inner) || outer >= math.Pi*earthRadius {
+		return nil
+	}
+
+	// a full turn is a Circle, with a clockwise hole
+	sweep := clockwiseSweep(from, to)
+	if sweep == 360 {
+		n := segments
+		if n < 1 {
+			n = defaultSegments
+		}
+		poly := Circle(center, outer, n)
+		if inner > 0 {
+			poly.AddInnerBoundary(sweepPoints(center, inner, from, 360, n)[:n]...)
+		}
+		return poly
+	}
+
+	poly := NewPolygon()
+	if inner == 0 {
+		poly.AddPoint(&Point{center.Lat, center.Lon, center.Alt})
+	} else {
+		for _, p := range sweepPoints(center, inner, from, sweep, segments) {
+			poly.AddPoint(p)
+		}
+	}
+	for _, p := range sweepPoints(center, outer, from+sweep, -sweep, segments) {
+		poly.AddPoint(p)
+	}
+
+	return poly
+}
+
+// Buffer returns a Polygon of the area within a distance in meters of the
+// Point, a Circle with one side every 5 degrees.  Returns nil if the Point
+// is nil or the distance isn't positive and less than half the
+// circumference of the Earth.
+func (p *Point) Buffer(distance float64) *Polygon {
+	if p == nil {
+		return nil
+	}
+
+	return Circle(p, distance, 0)
+}
+
+// Buffer returns a Polygon of the corridor within a distance in meters of
+// the LineString, such as the zone around a pipeline, with rounded ends and
+// outer corners.  The sides are found along great circles from the line,
+// and the ring is counterclockwise with the altitudes of the line.  Inner
+// corners are mitered, so bends sharper than the corridor is wide may fold
+// over themselves.  A LineString of one Point is buffered like a Point.
+// Returns nil if the LineString has no Points, or the distance isn't
+// positive and less than half the circumference of the Earth.
+func (ls *LineString) Buffer(distance float64) *Polygon {
+	if !(distance > 0) || distance >= math.Pi*earthRadius {
+		return nil
+	}
+
+	// repeated Points have no direction
+	var points []*Point
+	for _, p := range ls.coordinates {
+		if len(points) == 0 || greatCircleDistance(points[len(points)-1], p) > 0 {
+			points = append(points, p)
+		}
+	}
+	switch len(points) {
+	case 0:
+		return nil
+	case 1:
+		return points[0].Buffer(distance)
+	}
+
+	// the right side going forward, then the right side coming back
+	reversed := make([]*Point, len(points))
+	for i, p := range points {
+		reversed[len(points)-1-i] = p
+	}
+
+	poly := NewPolygon()
+	for _, p := range append(bufferSide(points, distance), bufferSide(reversed, distance)...) {
+		poly.AddPoint(p)
+	}
+
+	return poly
+}
+
+// bufferSide returns the outline of the right side of a line at a distance
+// in meters, from its corner at the second Point around the rounded end.
+func bufferSide(points []*Point, distance float64) []*Point {
+	var side []*Point
+	var arrive float64 // the bearing on reaching each Point
+
+	for i := 0; i < len(points)-1; i++ {
+		a, b := points[i], points[i+1]
+		start := initialBearing(a, b)
+
+		if i > 0 {
+			turn := normalizeLon(start - arrive)
+			if turn < 0 {
+				// round the outside of a left turn
+				side = append(side, sweepPoints(a, distance, arrive+90, turn, 0)...)
+			} else {
+				// miter the inside of a right turn, not too far out
+				half := radians(turn / 2)
+				side = append(side, destination(a, arrive+90+turn/2, distance/math.Max(math.Cos(half), 0.25)))
+			}
+		}
+
+		arrive = math.Mod(initialBearing(b, a)+180, 360)
+	}
+
+	// round the end
+	return append(side, sweepPoints(points[len(points)-1], distance, arrive+90, -180, 0)...)
+}
+
+// clockwiseSweep returns the angle in degrees turned clockwise from one
+// bearing to another, more than 0 and up to 360.
+func clockwiseSweep(from float64, to float64) float64 {
+	sweep := math.Mod(to-from, 360)
+	if sweep <= 0 {
+		sweep += 360
+	}
+
+	return sweep
+}
+
+// sweepPoints returns the points of an arc around center with a radius in
+// meters, from a bearing (in degrees clockwise from north) through the
+// sweep, which is negative for counterclockwise arcs.  The arc has n
+// segments, or one every arcStep degrees if n is less than 1.  Both ends are
+// included.
+func sweepPoints(center *Point, radius float64, from float64, sweep float64, n int) []*Point {
+	if n < 1 {
+		n = int(math.Ceil(math.Abs(sweep) / arcStep))
+		if n == 0 {
+			n = 1
+		}
+	}
+
+	points := make([]*Point, 0, n+1)
+	for i := 0; i <= n; i++ {
+		points = append(points, destination(center, from+sweep*float64(i)/float64(n), radius))
+	}
+
+	return points
+}
+
+// GreatCircle returns a LineString along the great circle from one Point to
+// another, the shortest route over the globe, with a Point at least every
+// interval meters, so a long route such as a flight follows its true path
+// instead of a chord.  Altitudes change evenly along the route.  Returns nil
+// if a Point is nil, the interval isn't positive, or the Points are
+// antipodal, with no single great circle between them.
+func GreatCircle(from *Point, to *Point, interval float64) *LineString {
+	if from == nil || to == nil || !(interval > 0) {
+		return nil
+	}
+
+	distance := greatCircleDistance(from, to)
+	if math.Abs(distance-math.Pi*earthRadius) < 1 {
+		return nil
+	}
+
+	n := int(math.Ceil(distance / interval))
+	if n == 0 {
+		n = 1
+	}
+
+	ls := NewLineString()
+	ls.AddPoint(&Point{from.Lat, from.Lon, from.Alt})
+	for i := 1; i < n; i++ {
+		ls.AddPoint(intermediate(from, to, float64(i)/float64(n)))
+	}
+	ls.AddPoint(&Point{to.Lat, to.Lon, to.Alt})
+
+	return ls
+}
+
+// RhumbLine returns a LineString along the rhumb line (loxodrome) from one
+// Point to another, the path of constant bearing that crosses every
+// meridian at the same angle, as plotted in classic navigation, with a
+// Point at least every interval meters.  It's a straight line on a Mercator
+// chart, and longer than the GreatCircle route.  Altitudes change evenly
+// along the line.  Returns nil if a Point is nil or a pole, or the interval
+// isn't positive.
+func RhumbLine(from *Point, to *Point, interval float64) *LineString {
+	if from == nil || to == nil || !(interval > 0) || math.Abs(from.Lat) == 90 || math.Abs(to.Lat) == 90 {
+		return nil
+	}
+
+	n := int(math.Ceil(rhumbDistance(from, to) / interval))
+	if n == 0 {
+		n = 1
+	}
+
+	ls := NewLineString()
+	ls.AddPoint(&Point{from.Lat, from.Lon, from.Alt})
+	for i := 1; i < n; i++ {
+		ls.AddPoint(rhumbIntermediate(from, to, float64(i)/float64(n)))
+	}
+	ls.AddPoint(&Point{to.Lat, to.Lon, to.Alt})
+
+	return ls
+}
+
+// ringPolygon returns a Polygon of a counterclockwise ring around center.
+// Rings that reach at most maxRadius meters from the center and enclose a
+// pole become the cap of the globe, as a ring of latitude and longitude
+// can't go around a pole.
+func ringPolygon(center *Point, maxRadius float64, ring []*Point) *Polygon {
+	poly := NewPolygon()
+
+	north, south := &Point{90, 0, 0}, &Point{-90, 0, 0}
+	pole := 0.0
+	switch {
+	case greatCircleDistance(center, north) < maxRadius && enclosesPole(ring, 1):
+		pole = 90
+	case greatCircleDistance(center, south) < maxRadius && enclosesPole(ring, -1):
+		pole = -90
+	}
+	if pole == 0 {
+		for _, p := range ring {
+			poly.AddPoint(p)
+		}
+		return poly
+	}
+
+	// go along the ring eastward around the north pole, westward around
+	// the south pole, from the antimeridian back to it
+	sorted := append([]*Point(nil), ring...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if pole > 0 {
+			return sorted[i].Lon < sorted[j].Lon
+		}
+		return sorted[i].Lon > sorted[j].Lon
+	})
+	first, last := sorted[0], sorted[len(sorted)-1]
+
+	// the latitude where the ring crosses the antimeridian
+	gap := 360 - math.Abs(last.Lon-first.Lon)
+	lat := first.Lat
+	if gap > 0 {
+		lat = last.Lat + (first.Lat-last.Lat)*(180-math.Abs(last.Lon))/gap
+	}
+
+	start, end := -180.0, 180.0
+	if pole < 0 {
+		start, end = 180, -180
+	}
+	alt := center.Alt
+	poly.AddPoint(&Point{lat, start, alt})
+	for _, p := range sorted {
+		poly.AddPoint(p)
+	}
+	poly.AddPoint(&Point{lat, end, alt})
+	poly.AddPoint(&Point{pole, end, alt})
+	poly.AddPoint(&Point{pole, start, alt})
+
+	return poly
+}
+
+// enclosesPole reports whether a ring goes around the pole (1 for north, -1
+// for south), as its longitude turns a full circle.
+func enclosesPole(ring []*Point, pole float64) bool {
+	turn := 0.0
+	for i, p := range ring {
+		next := ring[(i+1)%len(ring)]
+		turn += normalizeLon(next.Lon - p.Lon)
+	}
+
+	// counterclockwise rings go eastward around the north pole
+	return math.Abs(turn) > 180 && (turn > 0) == (pole > 0)
+}
@@ -0,0 +1,329 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+// ringArea returns the signed area of a ring in square degrees, positive if
+// it's counterclockwise.
+func ringArea(ring []*Point) float64 {
+	area := 0.0
+	for i, p := range ring {
+		next := ring[(i+1)%len(ring)]
+		area += p.Lon*next.Lat - next.Lon*p.Lat
+	}
+
+	return area / 2
+}
+
+func TestCircle(t *testing.T) {
+	center := NewPoint(60, 10, 100)
+	poly := Circle(center, 50000, 36)
+	if len(poly.points) != 36 {
+		t.Fatalf("got %d points, want 36", len(poly.points))
+	}
+	for _, p := range poly.points {
+		if d := greatCircleDistance(center, p); math.Abs(d-50000) > 1e-6 {
+			t.Errorf("point %v is %f m from the center", p, d)
+		}
+		if p.Alt != 100 {
+			t.Errorf("altitude not kept: %v", p)
+		}
+	}
+	if ringArea(poly.points) <= 0 {
+		t.Errorf("ring is clockwise")
+	}
+
+	// a circle of equal offsets would be twice as wide in degrees of
+	// longitude as of latitude at 60 degrees north
+	minLon, maxLon, minLat, maxLat := 180.0, -180.0, 90.0, -90.0
+	for _, p := range poly.points {
+		minLon, maxLon = math.Min(minLon, p.Lon), math.Max(maxLon, p.Lon)
+		minLat, maxLat = math.Min(minLat, p.Lat), math.Max(maxLat, p.Lat)
+	}
+	if ratio := (maxLon - minLon) / (maxLat - minLat); math.Abs(ratio-2) > 0.01 {
+		t.Errorf("width/height = %f, want 2", ratio)
+	}
+
+	if n := len(Circle(center, 1000, 0).points); n != defaultSegments {
+		t.Errorf("got %d points by default, want %d", n, defaultSegments)
+	}
+
+	for _, pole := range []float64{90, -90} {
+		poly := Circle(NewPoint(pole*0.99, 30, 0), 300000, 72)
+		if poly == nil {
+			t.Fatalf("no circle around %v", pole)
+		}
+		var corners int
+		for _, p := range poly.points {
+			if p.Lat == pole && math.Abs(p.Lon) == 180 {
+				corners++
+			}
+			if math.Abs(p.Lat) < 85 {
+				t.Errorf("point %v is too far from the pole", p)
+			}
+		}
+		if corners != 2 || len(poly.points) != 76 {
+			t.Errorf("pole %v: got %d points with %d corners", pole, len(poly.points), corners)
+		}
+		if ringArea(poly.points) <= 0 {
+			t.Errorf("pole %v: ring is clockwise", pole)
+		}
+	}
+
+	if Circle(nil, 1000, 10) != nil || Circle(center, 0, 10) != nil || Circle(center, math.NaN(), 10) != nil || Circle(center, 3e7, 10) != nil {
+		t.Errorf("invalid circles weren't nil")
+	}
+}
+
+func TestEllipse(t *testing.T) {
+	center := NewPoint(-33, 151, 0)
+	poly := Ellipse(center, 2000, 500, 45, 8)
+	if len(poly.points) != 8 {
+		t.Fatalf("got %d points, want 8", len(poly.points))
+	}
+	for i, want := range []struct{ bearing, distance float64 }{{45, 2000}, {315, 500}, {225, 2000}, {135, 500}} {
+		p := poly.points[2*i]
+		if b := initialBearing(center, p); math.Abs(b-want.bearing) > 1e-6 {
+			t.Errorf("point %d: bearing %f, want %f", 2*i, b, want.bearing)
+		}
+		if d := greatCircleDistance(center, p); math.Abs(d-want.distance) > 1e-6 {
+			t.Errorf("point %d: distance %f, want %f", 2*i, d, want.distance)
+		}
+	}
+	if ringArea(poly.points) <= 0 {
+		t.Errorf("ring is clockwise")
+	}
+
+	// an ellipse with equal axes is a circle
+	circle, ellipse := Circle(center, 1000, 12), Ellipse(center, 1000, 1000, 30, 12)
+	for i := range circle.points {
+		if d := greatCircleDistance(center, ellipse.points[i]); math.Abs(d-1000) > 1e-6 {
+			t.Errorf("point %d is %f m from the center", i, d)
+		}
+	}
+
+	if Ellipse(nil, 1, 1, 0, 0) != nil || Ellipse(center, 1000, -1, 0, 0) != nil {
+		t.Errorf("invalid ellipses weren't nil")
+	}
+}
+
+func TestErrorEllipse(t *testing.T) {
+	center := NewPoint(10, 20, 0)
+
+	// errors along the northeast diagonal
+	poly := ErrorEllipse(center, 5, 5, 4, 1-math.Exp(-0.5), 4)
+	if poly == nil {
+		t.Fatal("no ellipse")
+	}
+	if b := initialBearing(center, poly.points[0]); math.Abs(b-45) > 1e-6 {
+		t.Errorf("major axis bearing %f, want 45", b)
+	}
+	if d := greatCircleDistance(center, poly.points[0]); math.Abs(d-3) > 1e-6 {
+		t.Errorf("major semi-axis %f, want 3", d)
+	}
+	if d := greatCircleDistance(center, poly.points[1]); math.Abs(d-1) > 1e-6 {
+		t.Errorf("minor semi-axis %f, want 1", d)
+	}
+
+	// 95% of errors with a standard deviation of 10 m are within 24.48 m
+	poly = ErrorEllipse(center, 100, 100, 0, 0.95, 0)
+	if d := greatCircleDistance(center, poly.points[0]); math.Abs(d-24.477) > 1e-3 {
+		t.Errorf("95%% radius %f, want 24.477", d)
+	}
+
+	if ErrorEllipse(center, 1, 1, 1, 0.95, 0) != nil || ErrorEllipse(center, 1, 1, 0, 1, 0) != nil {
+		t.Errorf("invalid error ellipses weren't nil")
+	}
+}
+
+func TestArc(t *testing.T) {
+	center := NewPoint(51.5, -0.1, 25)
+
+	arc := Arc(center, 10000, 350, 20, 3)
+	if len(arc.coordinates) != 4 {
+		t.Fatalf("got %d points, want 4", len(arc.coordinates))
+	}
+	for i, want := range []float64{350, 0, 10, 20} {
+		p := arc.coordinates[i]
+		if b := initialBearing(center, p); math.Abs(math.Mod(b-want+540, 360)-180) > 1e-6 {
+			t.Errorf("point %d: bearing %f, want %f", i, b, want)
+		}
+		if d := greatCircleDistance(center, p); math.Abs(d-10000) > 1e-6 || p.Alt != 25 {
+			t.Errorf("point %d: distance %f, altitude %f", i, d, p.Alt)
+		}
+	}
+
+	if n := len(Arc(center, 10000, 90, 90, 0).coordinates); n != defaultSegments+1 {
+		t.Errorf("full circle arc has %d points, want %d", n, defaultSegments+1)
+	}
+	if Arc(nil, 1, 0, 90, 0) != nil || Arc(center, -1, 0, 90, 0) != nil {
+		t.Errorf("invalid arcs weren't nil")
+	}
+}
+
+func TestSector(t *testing.T) {
+	center := NewPoint(51.5, -0.1, 0)
+
+	sector := Sector(center, 5000, 30, 120, 0)
+	if len(sector.points) != 20 {
+		t.Fatalf("got %d points, want 20", len(sector.points))
+	}
+	if *sector.points[0] != *center {
+		t.Errorf("sector doesn't start at the center: %v", sector.points[0])
+	}
+	if b := initialBearing(center, sector.points[1]); math.Abs(b-120) > 1e-6 {
+		t.Errorf("arc starts at bearing %f, want 120", b)
+	}
+	if ringArea(sector.points) <= 0 {
+		t.Errorf("ring is clockwise")
+	}
+
+	if n := len(Sector(center, 5000, 10, 10, 24).points); n != 24 {
+		t.Errorf("full sector has %d points, want 24", n)
+	}
+
+	wedge := Wedge(center, 1000, 5000, 30, 120, 9)
+	if len(wedge.points) != 20 {
+		t.Fatalf("got %d wedge points, want 20", len(wedge.points))
+	}
+	for i, p := range wedge.points {
+		want := 1000.0
+		if i >= 10 {
+			want = 5000
+		}
+		if d := greatCircleDistance(center, p); math.Abs(d-want) > 1e-6 {
+			t.Errorf("wedge point %d is %f m from the center, want %f", i, d, want)
+		}
+	}
+	if ringArea(wedge.points) <= 0 {
+		t.Errorf("wedge ring is clockwise")
+	}
+
+	if ring := Wedge(center, 1000, 5000, 0, 0, 12); len(ring.points) != 12 || len(ring.inner) != 1 || len(ring.inner[0]) != 13 {
+		t.Errorf("full wedge isn't a circle with a hole")
+	}
+
+	if Wedge(center, 5000, 1000, 0, 90, 0) != nil || Wedge(center, -1, 1000, 0, 90, 0) != nil || Sector(nil, 1, 0, 90, 0) != nil {
+		t.Errorf("invalid sectors weren't nil")
+	}
+}
+
+func TestGreatCircle(t *testing.T) {
+	from, to := NewPoint(51.47, -0.45, 0), NewPoint(40.64, -73.78, 0)
+
+	route := GreatCircle(from, to, 100000)
+	total := greatCircleDistance(from, to)
+	if n := len(route.coordinates); n != int(math.Ceil(total/100000))+1 {
+		t.Fatalf("got %d points for %f m", n, total)
+	}
+	if *route.coordinates[0] != *from || *route.coordinates[len(route.coordinates)-1] != *to {
+		t.Errorf("route doesn't join the ends")
+	}
+	for i := 1; i < len(route.coordinates); i++ {
+		if d := greatCircleDistance(route.coordinates[i-1], route.coordinates[i]); d > 100000 {
+			t.Errorf("segment %d is %f m", i, d)
+		}
+	}
+
+	// the route goes north of both ends, unlike a chord
+	if mid := route.coordinates[len(route.coordinates)/2]; mid.Lat < 51.5 {
+		t.Errorf("route doesn't bend north: %v", mid)
+	}
+
+	if n := len(GreatCircle(from, from, 1000).coordinates); n != 2 {
+		t.Errorf("got %d points between equal points, want 2", n)
+	}
+	if GreatCircle(from, to, 0) != nil || GreatCircle(nil, to, 1) != nil || GreatCircle(NewPoint(10, 20, 0), NewPoint(-10, -160, 0), 1000) != nil {
+		t.Errorf("invalid routes weren't nil")
+	}
+}
+
+func TestRhumbLine(t *testing.T) {
+	from, to := NewPoint(40, -70, 0), NewPoint(50, -5, 500)
+
+	line := RhumbLine(from, to, 50000)
+	if *line.coordinates[0] != *from || *line.coordinates[len(line.coordinates)-1] != *to {
+		t.Errorf("line doesn't join the ends")
+	}
+
+	// the bearing is the same along the line, as a straight line on a
+	// Mercator chart
+	mercator := func(p *Point) (float64, float64) {
+		return radians(p.Lon), math.Log(math.Tan(math.Pi/4 + radians(p.Lat)/2))
+	}
+	x0, y0 := mercator(from)
+	x1, y1 := mercator(to)
+	for i, p := range line.coordinates {
+		x, y := mercator(p)
+		if cross := (x-x0)*(y1-y0) - (y-y0)*(x1-x0); math.Abs(cross) > 1e-9 {
+			t.Errorf("point %d %v is off the rhumb line", i, p)
+		}
+		if i > 0 && greatCircleDistance(line.coordinates[i-1], p) > 50000 {
+			t.Errorf("segment %d is too long", i)
+		}
+	}
+
+	if rhumbDistance(from, to) <= greatCircleDistance(from, to) {
+		t.Errorf("rhumb line isn't longer than the great circle")
+	}
+	if RhumbLine(from, NewPoint(90, 0, 0), 1000) != nil || RhumbLine(from, to, -1) != nil || RhumbLine(nil, to, 1) != nil {
+		t.Errorf("invalid rhumb lines weren't nil")
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	// 1 km either side of a line along the equator
+	line := NewLineString()
+	line.AddPoint(NewPoint(0, 10, 0))
+	line.AddPoint(NewPoint(0, 10, 0))
+	line.AddPoint(NewPoint(0, 11, 0))
+	poly := line.Buffer(1000)
+	if poly == nil {
+		t.Fatal("no buffer")
+	}
+
+	deg := degrees(1000 / earthRadius)
+	if b, _ := poly.Bounds(); math.Abs(b.North-deg) > 1e-9 || math.Abs(b.South+deg) > 1e-9 || math.Abs(b.West-(10-deg)) > 1e-9 || math.Abs(b.East-(11+deg)) > 1e-9 {
+		t.Errorf("unexpected bounds %v", b)
+	}
+	if n := len(poly.points); n != 2*(defaultSegments/2+1) {
+		t.Errorf("got %d points, want %d", n, 2*(defaultSegments/2+1))
+	}
+	if ringArea(poly.points) <= 0 {
+		t.Errorf("ring is clockwise")
+	}
+
+	// a bend to the left, with a rounded corner on the right, and a bend to
+	// the right
+	for _, end := range []*Point{NewPoint(1, 11, 0), NewPoint(-1, 11, 0)} {
+		bend := NewLineString()
+		bend.AddPoint(NewPoint(0, 10, 0))
+		bend.AddPoint(NewPoint(0, 11, 0))
+		bend.AddPoint(end)
+		poly := bend.Buffer(5000)
+		if ringArea(poly.points) <= 0 {
+			t.Errorf("bend to %v: ring is clockwise", end)
+		}
+
+		// every vertex is the distance from a vertex of the line, or
+		// further on the miter of the inner corner
+		for _, p := range poly.points {
+			near := math.Inf(1)
+			for _, q := range bend.coordinates {
+				near = math.Min(near, greatCircleDistance(p, q))
+			}
+			if near < 5000-1e-6 || near > 5000*math.Sqrt2+1 {
+				t.Errorf("bend to %v: point %v is %f m from the line", end, p, near)
+			}
+		}
+	}
+
+	if p := NewPoint(5, 5, 0).Buffer(100); len(p.points) != defaultSegments {
+		t.Errorf("point buffer has %d points", len(p.points))
+	}
+	if NewLineString().Buffer(100) != nil || line.Buffer(0) != nil {
+		t.Errorf("invalid buffers weren't nil")
+	}
+}
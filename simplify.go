@@ -0,0 +1,157 @@
+package gokml
+
+import "math"
+
+// Simplify removes the Points of the LineString that are within a tolerance
+// in meters of the line through the Points kept (the Douglas-Peucker
+// algorithm), so a long GPS track can be drawn smoothly with little change
+// to its shape.  The ends are always kept.  Tolerances that aren't positive
+// are ignored.
+func (ls *LineString) Simplify(tolerance float64) {
+	if !(tolerance > 0) {
+		return
+	}
+
+	ls.mutex.Lock()
+	ls.coordinates = keepPoints(ls.coordinates, simplifyKeep(ls.coordinates, tolerance))
+	ls.mutex.Unlock()
+}
+
+// Simplify removes the Points of the Polygon's boundaries that are within a
+// tolerance in meters of the ring through the Points kept, as for a
+// LineString.  Rings that would have fewer than three Points are left as
+// they are.  Tolerances that aren't positive are ignored.
+func (poly *Polygon) Simplify(tolerance float64) {
+	if !(tolerance > 0) {
+		return
+	}
+
+	poly.mutex.Lock()
+	defer poly.mutex.Unlock()
+
+	poly.points = simplifyRing(poly.points, tolerance)
+	for i, ring := range poly.inner {
+		poly.inner[i] = simplifyRing(ring, tolerance)
+	}
+}
+
+// Simplify removes the Points of the Track that are within a tolerance in
+// meters of the path through the Points kept, as for a LineString, with
+// their times and array data.  Tolerances that aren't positive are ignored.
+func (t *Track) Simplify(tolerance float64) {
+	if !(tolerance > 0) {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	keep := simplifyKeep(t.points, tolerance)
+
+	n := 0
+	for i := range t.points {
+		if keep[i] {
+			t.times[n], t.points[n] = t.times[i], t.points[i]
+			n++
+		}
+	}
+	t.times, t.points = t.times[:n], t.points[:n]
+
+	for a := range t.arrays {
+		var values []float64
+		for i, v := range t.arrays[a].values {
+			if i < len(keep) && keep[i] {
+				values = append(values, v)
+			}
+		}
+		t.arrays[a].values = values
+	}
+}
+
+// simplifyRing returns a ring simplified to a tolerance in meters, closed if
+// it was, or the ring if it would have fewer than three Points.
+func simplifyRing(ring []*Point, tolerance float64) []*Point {
+	if len(ring) < 4 {
+		return ring
+	}
+
+	// simplify the closed ring, starting and ending at the first Point
+	closed := *ring[0] == *ring[len(ring)-1]
+	points := ring
+	if !closed {
+		points = append(ring[:len(ring):len(ring)], ring[0])
+	}
+
+	simple := keepPoints(points, simplifyKeep(points, tolerance))
+	if len(simple) < 4 {
+		return ring
+	}
+	if !closed {
+		simple = simple[:len(simple)-1]
+	}
+
+	return simple
+}
+
+// keepPoints returns the Points that are kept.
+func keepPoints(points []*Point, keep []bool) []*Point {
+	kept := make([]*Point, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			kept = append(kept, p)
+		}
+	}
+
+	return kept
+}
+
+// simplifyKeep returns which Points of a line to keep to simplify it to a
+// tolerance in meters, by the Douglas-Peucker algorithm: the Point furthest
+// from the line between the ends is kept if it's further than the
+// tolerance, and the two halves are simplified in turn.
+func simplifyKeep(points []*Point, tolerance float64) []bool {
+	keep := make([]bool, len(points))
+	if len(points) == 0 {
+		return keep
+	}
+	keep[0], keep[len(points)-1] = true, true
+
+	// a stack of the spans left to simplify, for lines too long to recurse
+	spans := [][2]int{{0, len(points) - 1}}
+	for len(spans) > 0 {
+		span := spans[len(spans)-1]
+		spans = spans[:len(spans)-1]
+
+		furthest, distance := -1, tolerance
+		for i := span[0] + 1; i < span[1]; i++ {
+			if d := segmentDistance(points[i], points[span[0]], points[span[1]]); d > distance {
+				furthest, distance = i, d
+			}
+		}
+
+		if furthest >= 0 {
+			keep[furthest] = true
+			spans = append(spans, [2]int{span[0], furthest}, [2]int{furthest, span[1]})
+		}
+	}
+
+	return keep
+}
+
+// segmentDistance returns the distance in meters from p to the segment from
+// a to b, on a plane tangent to the Earth at a, which is accurate for the
+// short segments of simplified lines.
+func segmentDistance(p *Point, a *Point, b *Point) float64 {
+	ky := earthRadius * math.Pi / 180 // meters per degree
+	kx := ky * math.Cos(radians(a.Lat))
+
+	bx, by := normalizeLon(b.Lon-a.Lon)*kx, (b.Lat-a.Lat)*ky
+	px, py := normalizeLon(p.Lon-a.Lon)*kx, (p.Lat-a.Lat)*ky
+
+	f := 0.0
+	if length := bx*bx + by*by; length > 0 {
+		f = math.Max(0, math.Min(1, (px*bx+py*by)/length))
+	}
+
+	return math.Hypot(px-f*bx, py-f*by)
+}
@@ -0,0 +1,102 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSimplify(t *testing.T) {
+	// a zigzag 10 m either side of a line along the equator, with a 1 km
+	// spike in the middle, which is kept with the points at its foot
+	line := NewLineString()
+	track := NewTrack()
+	start := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	var heights []float64
+	for i := 0; i <= 100; i++ {
+		north := 10.0
+		if i%2 == 1 {
+			north = -10
+		}
+		if i == 50 {
+			north = 1000
+		}
+		p := NewPoint(degrees(north/earthRadius), float64(i)*0.001, 0)
+		line.AddPoint(p)
+		track.AddPoint(start.Add(time.Duration(i)*time.Second), p)
+		heights = append(heights, float64(i))
+	}
+	track.SetArrayData("height", heights)
+
+	line.Simplify(50)
+	if n := len(line.coordinates); n != 5 {
+		t.Fatalf("got %d points, want 5", n)
+	}
+	if line.coordinates[2].Lon != 0.05 {
+		t.Errorf("spike wasn't kept: %v", line.coordinates[2])
+	}
+
+	track.Simplify(50)
+	if len(track.points) != 5 || len(track.times) != 5 || !track.times[2].Equal(start.Add(50*time.Second)) {
+		t.Errorf("unexpected track %v %v", track.points, track.times)
+	}
+	if v := track.arrays[0].values; len(v) != 5 || v[2] != 50 {
+		t.Errorf("unexpected array data %v", v)
+	}
+
+	// a tighter tolerance keeps the zigzag
+	zigzag := NewLineString()
+	for i := 0; i < 10; i++ {
+		zigzag.AddPoint(NewPoint(float64(i%2)*0.001, float64(i)*0.001, 0))
+	}
+	zigzag.Simplify(1)
+	zigzag.Simplify(-1)
+	if n := len(zigzag.coordinates); n != 10 {
+		t.Errorf("got %d points, want 10", n)
+	}
+
+	// a square with a point halfway along each side, unclosed and closed
+	for _, closed := range []bool{false, true} {
+		poly := NewPolygon()
+		for _, p := range [][2]float64{{0, 0}, {0, 0.5}, {0, 1}, {0.5, 1}, {1, 1}, {1, 0.5}, {1, 0}, {0.5, 0}} {
+			poly.AddPoint(NewPoint(p[0], p[1], 0))
+		}
+		if closed {
+			poly.AddPoint(NewPoint(0, 0, 0))
+		}
+		poly.AddInnerBoundary(NewPoint(0.4, 0.4, 0), NewPoint(0.4, 0.6, 0), NewPoint(0.6, 0.6, 0))
+		poly.Simplify(1000)
+
+		want := 4
+		if closed {
+			want = 5
+		}
+		if len(poly.points) != want {
+			t.Errorf("closed %v: got %d points, want %d", closed, len(poly.points), want)
+		}
+		if len(poly.inner[0]) != 4 {
+			t.Errorf("closed %v: small hole was changed: %v", closed, poly.inner[0])
+		}
+	}
+}
+
+func TestSegmentDistance(t *testing.T) {
+	a, b := NewPoint(0, 0, 0), NewPoint(0, 1, 0)
+	for _, test := range []struct {
+		p    *Point
+		want float64
+	}{
+		{NewPoint(0.01, 0.5, 0), 0.01 * math.Pi / 180 * earthRadius},
+		{NewPoint(0, 2, 0), math.Pi / 180 * earthRadius},
+		{NewPoint(0, 0, 0), 0},
+	} {
+		if d := segmentDistance(test.p, a, b); math.Abs(d-test.want) > 1e-6 {
+			t.Errorf("distance to %v = %f, want %f", test.p, d, test.want)
+		}
+	}
+
+	// to a segment of no length
+	if d := segmentDistance(NewPoint(1, 0, 0), a, a); math.Abs(d-math.Pi/180*earthRadius) > 1e-6 {
+		t.Errorf("distance to a point = %f", d)
+	}
+}
@@ -0,0 +1,133 @@
+package gokml
+
+import (
+	"sync"
+	"time"
+)
+
+// Snapshot returns a deep copy of the document, so a consistent view can be
+// rendered while other goroutines keep adding to the live document.  Each
+// Folder and geometry is locked only while its own contents are copied.
+func (k *KML) Snapshot() *KML {
+	k.mutex.Lock()
+	assets := append([]*asset(nil), k.assets...)
+	k.mutex.Unlock()
+
+	return &KML{cloneFolder(k.rootFolder), assets, k.options, new(sync.Mutex)}
+}
+
+func cloneFeature(feature renderable) renderable {
+	switch f := feature.(type) {
+	case *Folder:
+		return cloneFolder(f)
+	case *Placemark:
+		cp := *f
+		cp.geometry = cloneFeature(f.geometry)
+		cp.tags = append(tagList(nil), f.tags...)
+		cp.data = append([]dataField(nil), f.data...)
+		cp.links = append([]sourceLink(nil), f.links...)
+		return &cp
+	case *Style:
+		cp := *f
+		return &cp
+	case *NetworkLink:
+		cp := *f
+		if f.region != nil {
+			region := *f.region
+			cp.region = &region
+		}
+		return &cp
+	case *GroundOverlay:
+		cp := *f
+		cp.quad = clonePoints(f.quad)
+		return &cp
+	case *Point:
+		cp := *f
+		return &cp
+	case *LineString:
+		f.mutex.Lock()
+		cp := *f
+		cp.coordinates = clonePoints(f.coordinates)
+		f.mutex.Unlock()
+		cp.mutex = new(sync.Mutex)
+		return &cp
+	case *Polygon:
+		f.mutex.Lock()
+		cp := *f
+		cp.points = clonePoints(f.points)
+		cp.inner = nil
+		for _, ring := range f.inner {
+			cp.inner = append(cp.inner, clonePoints(ring))
+		}
+		f.mutex.Unlock()
+		cp.mutex = new(sync.Mutex)
+		return &cp
+	case *Track:
+		f.mutex.Lock()
+		cp := *f
+		cp.times = append([]time.Time(nil), f.times...)
+		cp.points = clonePoints(f.points)
+		cp.arrays = nil
+		for _, a := range f.arrays {
+			cp.arrays = append(cp.arrays, trackArray{a.name, append([]float64(nil), a.values...)})
+		}
+		f.mutex.Unlock()
+		cp.mutex = new(sync.Mutex)
+		return &cp
+	case *MultiGeometry:
+		f.mutex.Lock()
+		cp := *f
+		cp.geometries = make([]renderable, len(f.geometries))
+		for i, geom := range f.geometries {
+			cp.geometries[i] = cloneFeature(geom)
+		}
+		f.mutex.Unlock()
+		cp.mutex = new(sync.Mutex)
+		return &cp
+	}
+
+	return feature
+}
+
+func cloneFolder(f *Folder) *Folder {
+	f.mutex.Lock()
+	cp := *f
+	children := append([]renderable(nil), f.features...)
+	f.mutex.Unlock()
+
+	cp.features = make([]renderable, len(children), cap(children))
+	for i, child := range children {
+		cp.features[i] = cloneFeature(child)
+	}
+
+	if f.lookAt != nil {
+		lookAt := *f.lookAt
+		point := *lookAt.point
+		lookAt.point = &point
+		cp.lookAt = &lookAt
+	}
+
+	if f.region != nil {
+		region := *f.region
+		cp.region = &region
+	}
+
+	cp.tags = append(tagList(nil), f.tags...)
+	cp.mutex = new(sync.Mutex)
+
+	return &cp
+}
+
+func clonePoints(points []*Point) []*Point {
+	if points == nil {
+		return nil
+	}
+
+	ret := make([]*Point, len(points))
+	for i, p := range points {
+		cp := *p
+		ret[i] = &cp
+	}
+
+	return ret
+}
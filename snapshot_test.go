@@ -0,0 +1,58 @@
+package gokml
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	k := NewKML("Live")
+	f := NewFolder("Vehicles", "")
+	k.AddFeature(f)
+
+	p := NewPoint(40.0, -105.0, 0.0)
+	pm := NewPlacemark("Truck 12", "", p)
+	f.AddFeature(pm)
+
+	snap := k.Snapshot()
+	before := snap.Render()
+
+	p.Lat = 41.0
+	pm.SetStyle("Moved")
+	f.AddFeature(NewPlacemark("Truck 13", "", NewPoint(39.0, -104.0, 0.0)))
+
+	if snap.Render() != before {
+		t.Error("snapshot changed when the live document changed")
+	}
+
+	if !strings.Contains(k.Render(), "Truck 13") {
+		t.Error("live document not updated")
+	}
+}
+
+func TestSnapshotConcurrent(t *testing.T) {
+	k := NewKML("Live")
+	f := NewFolder("Vehicles", "")
+	k.AddFeature(f)
+
+	line := NewLineString()
+	pm := NewPlacemark("Track", "", line)
+	f.AddFeature(pm)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			line.AddPoint(NewPoint(0.0, float64(i%180), 0.0))
+			f.AddFeature(NewPlacemark("", "", NewPoint(0.0, 0.0, 0.0)))
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		k.Snapshot().Render()
+	}
+
+	wg.Wait()
+}
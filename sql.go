@@ -0,0 +1,195 @@
+package gokml
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLColumns names the columns FromRows reads Placemark fields from.
+// Column names are matched without regard to case.  All of the columns are
+// optional, and are ignored if empty or not in the result.
+type SQLColumns struct {
+	Geometry    string // WKB, EWKB, or WKT, or WKB in hex (see FromRows)
+	Name        string
+	Description string // markup is treated as HTML
+	Time        string // a time.Time, or KML dateTime text
+
+	Progress Progress // receives the number of rows scanned, if not nil
+}
+
+// DefaultSQLColumns reads the geom, name, description, and time columns.
+var DefaultSQLColumns = SQLColumns{"geom", "name", "description", "time", nil}
+
+// FromRows scans the rows of a query, such as one against PostGIS or
+// SpatiaLite, and returns a Folder with the given name containing a
+// Placemark for each row, with the fields named by columns.  The geometry
+// column may hold WKB or EWKB (from ST_AsBinary or ST_AsEWKB), WKT or EWKT
+// (from ST_AsText or ST_AsEWKT), or WKB as hex (a PostGIS geometry column
+// selected as is); a NULL geometry leaves the Placemark without one.  The
+// other columns of each row become ExtendedData, named by the column, with
+// NULL columns left out.  rows is read to the end, but not closed.
+func FromRows(rows *sql.Rows, name string, columns SQLColumns) (*Folder, error) {
+	f := NewFolder(name, "")
+
+	err := readRows(rows, columns, func(pm *Placemark) error {
+		f.AddFeature(pm)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// StreamRows is like FromRows, but writes the Folder to sw as the rows are
+// scanned, so results larger than memory can be converted in a single pass.
+func StreamRows(sw *StreamWriter, rows *sql.Rows, name string, columns SQLColumns) error {
+	if err := sw.StartFolder(NewFolder(name, "")); err != nil {
+		return err
+	}
+
+	err := readRows(rows, columns, func(pm *Placemark) error {
+		return sw.AddFeature(pm)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sw.EndFolder()
+}
+
+// readRows scans rows as Placemarks, passing each to add.
+func readRows(rows *sql.Rows, columns SQLColumns, add func(*Placemark) error) error {
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	index := func(column string) int {
+		for i, n := range names {
+			if len(column) > 0 && strings.EqualFold(n, column) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	geom, nameCol, desc, when := index(columns.Geometry), index(columns.Name), index(columns.Description), index(columns.Time)
+	mapped := map[int]bool{geom: true, nameCol: true, desc: true, when: true}
+
+	values := make([]interface{}, len(names))
+	dest := make([]interface{}, len(names))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	report := func(scanned int) {
+		if columns.Progress != nil {
+			columns.Progress.Report("reading", int64(scanned), -1)
+		}
+	}
+	report(0)
+
+	row := 1
+	for ; rows.Next(); row++ {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+
+		field := func(i int) interface{} {
+			if i < 0 {
+				return nil
+			}
+			return values[i]
+		}
+
+		g, err := sqlGeometry(field(geom))
+		if err != nil {
+			return fmt.Errorf("gokml: row %d: %v", row, strings.TrimPrefix(err.Error(), "gokml: "))
+		}
+
+		pm := NewPlacemark(sqlText(field(nameCol)), "", g)
+		pm.description = sqlText(field(desc))
+		pm.html = hasMarkup(pm.description)
+
+		switch t := field(when).(type) {
+		case nil:
+		case time.Time:
+			pm.SetTime(t, t)
+		default:
+			s := sqlText(t)
+			when, err := parseTime(s)
+			if err != nil {
+				return fmt.Errorf("gokml: row %d: invalid time %q", row, s)
+			}
+			pm.SetTime(when, when)
+		}
+
+		for i, n := range names {
+			if !mapped[i] && values[i] != nil {
+				pm.SetData(n, sqlText(values[i]))
+			}
+		}
+
+		if err := add(pm); err != nil {
+			return err
+		}
+
+		if row%progressRows == 0 {
+			report(row)
+		}
+	}
+
+	if (row-1)%progressRows != 0 {
+		report(row - 1)
+	}
+
+	return rows.Err()
+}
+
+// sqlGeometry decodes a geometry column value: WKB, WKT, or hex WKB.
+func sqlGeometry(v interface{}) (renderable, error) {
+	var b []byte
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil, fmt.Errorf("gokml: geometry column is a %T", v)
+	}
+
+	if len(b) == 0 || b[0] == 0 || b[0] == 1 {
+		// binary: the byte order flag of WKB
+		return ParseWKB(b)
+	}
+
+	s := strings.TrimSpace(string(b))
+	if raw, err := hex.DecodeString(s); err == nil {
+		return ParseWKB(raw)
+	}
+
+	return ParseWKT(s)
+}
+
+// sqlText formats a column value as text.
+func sqlText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+
+	return fmt.Sprint(v)
+}
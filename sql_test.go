@@ -0,0 +1,168 @@
+package gokml
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// sqlFixture is the result of every query on the "gokml" test driver.
+var sqlFixture = struct {
+	columns []string
+	rows    [][]driver.Value
+}{
+	columns: []string{"Name", "geom", "time", "population", "notes"},
+	rows: [][]driver.Value{
+		{"Denver", "POINT (-104.99 39.74)", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), int64(715522), nil},
+		{"Boulder", mustHex("01010000003333333333535ac00000000000004440"), "2024-06-02", int64(108250), []byte("<b>home</b>")},
+		{"Nowhere", nil, nil, nil, nil},
+	},
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+type sqlTestDriver struct{}
+type sqlTestConn struct{}
+type sqlTestStmt struct{}
+type sqlTestRows struct{ row int }
+
+func (sqlTestDriver) Open(string) (driver.Conn, error)  { return sqlTestConn{}, nil }
+func (sqlTestConn) Prepare(string) (driver.Stmt, error) { return sqlTestStmt{}, nil }
+func (sqlTestConn) Close() error                        { return nil }
+func (sqlTestConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (sqlTestStmt) Close() error                        { return nil }
+func (sqlTestStmt) NumInput() int                       { return -1 }
+func (sqlTestStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (sqlTestStmt) Query([]driver.Value) (driver.Rows, error) { return &sqlTestRows{}, nil }
+func (*sqlTestRows) Columns() []string                        { return sqlFixture.columns }
+func (*sqlTestRows) Close() error                             { return nil }
+func (r *sqlTestRows) Next(dest []driver.Value) error {
+	if r.row == len(sqlFixture.rows) {
+		return io.EOF
+	}
+	copy(dest, sqlFixture.rows[r.row])
+	r.row++
+	return nil
+}
+
+func init() {
+	sql.Register("gokml", sqlTestDriver{})
+}
+
+func TestFromRows(t *testing.T) {
+	db, err := sql.Open("gokml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT name, geom, time, population, notes FROM cities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	f, err := FromRows(rows, "Cities", DefaultSQLColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.name != "Cities" || len(f.features) != 3 {
+		t.Fatalf("unexpected folder %v", f)
+	}
+
+	denver := f.features[0].(*Placemark)
+	if p := denver.geometry.(*Point); denver.name != "Denver" || p.Lat != 39.74 || p.Lon != -104.99 {
+		t.Errorf("unexpected placemark %+v", denver)
+	}
+	if !denver.hasTime || denver.beginTime.Day() != 1 {
+		t.Errorf("time not set: %v", denver.beginTime)
+	}
+	if pop, _ := denver.Data("population"); pop != "715522" {
+		t.Errorf("population = %q", pop)
+	}
+	if _, ok := denver.Data("notes"); ok {
+		t.Errorf("NULL column included")
+	}
+
+	boulder := f.features[1].(*Placemark)
+	if p, ok := boulder.geometry.(*Point); !ok || p.Lat != 40 || p.Lon != -105.3 {
+		t.Errorf("WKB geometry not decoded: %v", boulder.geometry)
+	}
+	if !boulder.hasTime || boulder.beginTime.Day() != 2 {
+		t.Errorf("text time not parsed: %v", boulder.beginTime)
+	}
+	if notes, _ := boulder.Data("notes"); notes != "<b>home</b>" {
+		t.Errorf("notes = %q", notes)
+	}
+
+	if nowhere := f.features[2].(*Placemark); nowhere.geometry != nil {
+		t.Errorf("NULL geometry decoded as %v", nowhere.geometry)
+	}
+}
+
+func TestSQLGeometry(t *testing.T) {
+	wkb := mustHex("0101000000000000000000f03f0000000000000040")
+	for _, v := range []interface{}{
+		wkb,
+		hex.EncodeToString(wkb),
+		[]byte(hex.EncodeToString(wkb)),
+		"SRID=4326;POINT(1 2)",
+	} {
+		g, err := sqlGeometry(v)
+		if p, ok := g.(*Point); err != nil || !ok || p.Lon != 1 || p.Lat != 2 {
+			t.Errorf("sqlGeometry(%v) = %v, %v", v, g, err)
+		}
+	}
+
+	for _, v := range []interface{}{int64(1), "POINT (1", []byte{1, 2}} {
+		if _, err := sqlGeometry(v); err == nil {
+			t.Errorf("no error for %v", v)
+		}
+	}
+}
+
+func TestStreamRows(t *testing.T) {
+	db, err := sql.Open("gokml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	query := func() *sql.Rows {
+		rows, err := db.Query("SELECT name, geom, time, population, notes FROM cities")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	rows := query()
+	f, err := FromRows(rows, "Cities", DefaultSQLColumns)
+	rows.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows = query()
+	defer rows.Close()
+	got := streamed(t, func(sw *StreamWriter) error {
+		return StreamRows(sw, rows, "Cities", DefaultSQLColumns)
+	})
+
+	want := make([]*Placemark, 0)
+	walkPlacemarks(f, func(pm *Placemark) { want = append(want, pm) })
+	samePlacemarks(t, got, want)
+}
@@ -0,0 +1,307 @@
+package gokml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultStreamBudget is the number of bytes of features added with
+// AddToFolder a StreamWriter holds in memory before moving them to
+// temporary files.
+const defaultStreamBudget = 64 << 20
+
+// StreamWriter writes a KML document directly to an io.Writer one feature at
+// a time, so documents with millions of features can be generated without
+// holding them in memory.  A StreamWriter is not safe for concurrent use.
+type StreamWriter struct {
+	e       *encoder
+	depth   int // depth of the Document's features
+	folders int // number of open Folders
+	closed  bool
+
+	groups   []*streamGroup
+	byName   map[string]*streamGroup
+	buffered int // bytes of groups held in memory
+	budget   int
+
+	arrays map[string]bool // declared names of Track array data
+	schema bool            // whether the Schema of Track data is written
+}
+
+// streamGroup holds the rendered features of a Folder written by Close: the
+// ones spilled to a temporary file, followed by those still in memory.
+type streamGroup struct {
+	name string
+	file *os.File
+	buf  bytes.Buffer
+}
+
+// Stream starts writing the document to w and returns a StreamWriter for
+// appending more features to it.  The document's properties, render options,
+// and current features (typically its Styles) are written first, along with
+// the Schema of the array data of their Tracks (see DeclareArrayData).
+// Features added to the StreamWriter are written immediately and are not
+// added to the document.  Since the features aren't known in advance, the gx
+// and atom namespaces are always declared.  Close must be called to finish
+// the document.  StreamCSV, StreamRows, StreamGeoJSON, and StreamFlatGeobuf
+// import directly into a StreamWriter.
+func (k *KML) Stream(w io.Writer) (*StreamWriter, error) {
+	e := newEncoder(w, k.options)
+	e.header()
+	e.start("kml", attr("xmlns", kmlNamespace), attr("xmlns:gx", gxNamespace), attr("xmlns:atom", atomNamespace))
+
+	features := k.rootFolder.renderStart(e, "Document", k.rootFolder.orderedFeatures(e.opts))
+	renderDocumentSchema(e, k.rootFolder)
+	for _, feature := range features {
+		feature.render(e)
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	sw := &StreamWriter{e, e.depth, 0, false, nil, make(map[string]*streamGroup), 0, defaultStreamBudget, make(map[string]bool), false}
+
+	names, kept := keptTrackSchema(k.rootFolder)
+	if !kept {
+		names = trackArrayNames(k.rootFolder)
+	}
+	for _, name := range names {
+		sw.arrays[name] = true
+	}
+	sw.schema = kept || len(names) > 0
+
+	return sw, nil
+}
+
+// DeclareArrayData writes the Schema declaring the names of the array data
+// of the Tracks that will be streamed (see Track.SetArrayData), such as the
+// heartRate, cadence, and power of the Tracks from FromTCX and FromFIT.  A
+// document written in one go declares them itself, but a stream can't know
+// them in advance.  The Schema can only be written once, at the top level
+// of the document, and Stream writes it if the document already has Tracks
+// with array data, so all the names must be declared together.
+func (sw *StreamWriter) DeclareArrayData(names ...string) error {
+	if sw.closed {
+		return fmt.Errorf("gokml: write to closed StreamWriter")
+	}
+
+	undeclared := false
+	for _, name := range names {
+		undeclared = undeclared || !sw.arrays[name]
+	}
+
+	switch {
+	case !undeclared:
+		return nil
+	case sw.schema:
+		return fmt.Errorf("gokml: Track array data already declared")
+	case sw.folders > 0:
+		return fmt.Errorf("gokml: DeclareArrayData in a Folder")
+	}
+
+	renderTrackSchema(sw.e, names)
+	for _, name := range names {
+		sw.arrays[name] = true
+	}
+	sw.schema = true
+
+	return sw.e.err
+}
+
+// checkArrays returns an error if the Tracks of a feature have array data
+// that isn't declared.
+func (sw *StreamWriter) checkArrays(feature renderable) error {
+	for _, name := range trackArrayNames(feature) {
+		if !sw.arrays[name] {
+			return fmt.Errorf("gokml: Track array data %q not declared (see DeclareArrayData)", name)
+		}
+	}
+
+	return nil
+}
+
+// SetMemoryBudget sets how many bytes of the features added with
+// AddToFolder are held in memory.  Beyond that, they're moved to temporary
+// files until Close writes them, so datasets larger than memory can be
+// grouped in a single pass.  The default is 64 MiB.  A budget of zero or
+// less keeps them all in memory.
+func (sw *StreamWriter) SetMemoryBudget(bytes int) {
+	sw.budget = bytes
+}
+
+// AddToFolder writes a feature into the Folder with the given name, such as
+// when grouping features by an attribute while they're read.  The Folders
+// are written at the top level of the document when the StreamWriter is
+// closed, after the features added directly, in the order they were first
+// named.  Until then their features are held in memory up to the memory
+// budget, and in temporary files beyond it.
+func (sw *StreamWriter) AddToFolder(name string, feature renderable) error {
+	if sw.closed {
+		return fmt.Errorf("gokml: write to closed StreamWriter")
+	}
+
+	if feature == nil {
+		return nil
+	}
+
+	if err := sw.checkArrays(feature); err != nil {
+		return err
+	}
+
+	g := sw.byName[name]
+	if g == nil {
+		g = &streamGroup{name: name}
+		sw.byName[name] = g
+		sw.groups = append(sw.groups, g)
+	}
+
+	// render at the depth the features will have inside their Folder
+	sub := newEncoder(&g.buf, sw.e.opts)
+	sub.depth = sw.depth + 1
+	sub.started = true
+
+	before := g.buf.Len()
+	feature.render(sub)
+	if err := sub.flush(); err != nil {
+		return err
+	}
+
+	sw.buffered += g.buf.Len() - before
+	if sw.budget > 0 && sw.buffered > sw.budget {
+		return sw.spill()
+	}
+
+	return nil
+}
+
+// spill moves the features of every group held in memory to the group's
+// temporary file.
+func (sw *StreamWriter) spill() error {
+	for _, g := range sw.groups {
+		if g.buf.Len() == 0 {
+			continue
+		}
+
+		if g.file == nil {
+			f, err := os.CreateTemp("", "gokml-stream-*")
+			if err != nil {
+				return err
+			}
+			g.file = f
+		}
+
+		if _, err := g.buf.WriteTo(g.file); err != nil {
+			return err
+		}
+	}
+
+	sw.buffered = 0
+
+	return nil
+}
+
+// writeGroups writes the Folders of the features added with AddToFolder,
+// and removes their temporary files.
+func (sw *StreamWriter) writeGroups() error {
+	var err error
+
+	for _, g := range sw.groups {
+		NewFolder(g.name, "").renderStart(sw.e, "Folder", nil)
+
+		if g.file != nil {
+			if _, err = g.file.Seek(0, io.SeekStart); err == nil {
+				sw.e.rawFrom(g.file)
+			}
+
+			g.file.Close()
+			os.Remove(g.file.Name())
+		}
+
+		sw.e.rawBytes(g.buf.Bytes())
+		sw.e.inline = false
+		sw.e.end("Folder")
+	}
+
+	sw.groups, sw.byName, sw.buffered = nil, nil, 0
+
+	if err != nil {
+		return err
+	}
+
+	return sw.e.err
+}
+
+// AddFeature writes a feature (Placemark, Style, complete Folder, etc.) to
+// the stream.
+func (sw *StreamWriter) AddFeature(feature renderable) error {
+	if sw.closed {
+		return fmt.Errorf("gokml: write to closed StreamWriter")
+	}
+
+	if err := sw.checkArrays(feature); err != nil {
+		return err
+	}
+
+	if feature != nil {
+		feature.render(sw.e)
+	}
+
+	return sw.e.err
+}
+
+// StartFolder opens a Folder in the stream.  Features added until the
+// matching EndFolder are written into the Folder.  Only the Folder's own
+// properties are written; any features already in it are ignored.
+func (sw *StreamWriter) StartFolder(folder *Folder) error {
+	if sw.closed {
+		return fmt.Errorf("gokml: write to closed StreamWriter")
+	}
+
+	folder.renderStart(sw.e, "Folder", nil)
+	sw.folders++
+
+	return sw.e.err
+}
+
+// EndFolder closes the Folder most recently opened by StartFolder.
+func (sw *StreamWriter) EndFolder() error {
+	if sw.closed {
+		return fmt.Errorf("gokml: write to closed StreamWriter")
+	}
+
+	if sw.folders == 0 {
+		return fmt.Errorf("gokml: EndFolder without StartFolder")
+	}
+
+	sw.e.end("Folder")
+	sw.folders--
+
+	return sw.e.err
+}
+
+// Close closes any open Folders, finishes the document, and flushes it to
+// the underlying writer.  It does not close the underlying writer.
+func (sw *StreamWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+
+	for sw.folders > 0 {
+		sw.EndFolder()
+	}
+
+	if err := sw.writeGroups(); err != nil {
+		sw.closed = true
+		return err
+	}
+
+	sw.e.end("Document")
+	sw.e.end("kml")
+	sw.e.raw(sw.e.opts.newline)
+	sw.closed = true
+
+	return sw.e.flush()
+}
@@ -0,0 +1,226 @@
+package gokml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamWriter(t *testing.T) {
+	k := NewKML("Stream")
+	k.AddFeature(NewStyle("Dot", 255, 255, 0, 0))
+
+	var buf bytes.Buffer
+	sw, err := k.Stream(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.StartFolder(NewFolder("Points", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		pm := NewPlacemark(fmt.Sprintf("Point %d", i), "", NewPoint(float64(i)*0.1, 0.0, 0.0))
+		pm.SetStyle("Dot")
+		if err := sw.AddFeature(pm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.AddFeature(NewPlacemark("Late", "", nil)); err == nil {
+		t.Error("expected an error writing to a closed stream")
+	}
+
+	out := buf.String()
+	if strings.Count(out, "<Placemark>") != 100 || !strings.Contains(out, "<Style id=\"Dot\">") {
+		t.Errorf("unexpected stream output:\n%s", out)
+	}
+
+	if !strings.HasSuffix(out, "    </Folder>\n  </Document>\n</kml>\n") {
+		t.Errorf("document not closed properly:\n%s", out[len(out)-100:])
+	}
+
+	d := xml.NewDecoder(strings.NewReader(out))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("streamed document is not well-formed: %v", err)
+		}
+	}
+
+	if len(k.Placemarks()) != 0 {
+		t.Error("streamed features were added to the document")
+	}
+}
+
+func TestStreamWriterEndFolder(t *testing.T) {
+	sw, err := NewKML("Stream").Stream(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.EndFolder(); err == nil {
+		t.Error("expected an error for EndFolder without StartFolder")
+	}
+}
+
+func TestStreamWriterArrayData(t *testing.T) {
+	track := func() *Placemark {
+		t := NewTrack()
+		start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+		t.AddPoint(start, NewPoint(-122.0, 37.0, 0))
+		t.AddPoint(start.Add(time.Second), NewPoint(-122.001, 37.0, 0))
+		t.SetArrayData("heartRate", []float64{120, 121})
+		return NewPlacemark("Ride", "", t)
+	}
+
+	var buf bytes.Buffer
+	sw, err := NewKML("Stream").Stream(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.AddFeature(track()); err == nil {
+		t.Error("expected an error for undeclared array data")
+	}
+	if err := sw.DeclareArrayData("heartRate"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.DeclareArrayData("power"); err == nil {
+		t.Error("expected an error declaring array data twice")
+	}
+	if err := sw.AddFeature(track()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Count(out, `<Schema id="TrackData">`) != 1 || !strings.Contains(out, `<gx:SimpleArrayField name="heartRate"`) {
+		t.Errorf("Schema not declared:\n%s", out)
+	}
+	if strings.Index(out, "<Schema") > strings.Index(out, "<Placemark>") {
+		t.Errorf("Schema declared after the Track:\n%s", out)
+	}
+
+	// a document with Tracks declares their array data when streamed
+	k := NewKML("Stream")
+	k.AddFeature(track())
+	buf.Reset()
+	if sw, err = k.Stream(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.DeclareArrayData("heartRate"); err != nil {
+		t.Error(err)
+	}
+	if err := sw.AddFeature(track()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Count(out, `<Schema id="TrackData">`) != 1 {
+		t.Errorf("Schema not declared once:\n%s", out)
+	}
+}
+
+func TestStreamWriterGroups(t *testing.T) {
+	stream := func(budget int) string {
+		var buf bytes.Buffer
+		sw, err := NewKML("Groups").Stream(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sw.SetMemoryBudget(budget)
+
+		for i := 0; i < 20; i++ {
+			pm := NewPlacemark(fmt.Sprintf("Point %d", i), "", NewPoint(float64(i), 0.0, 0.0))
+			if err := sw.AddToFolder([]string{"Even", "Odd"}[i%2], pm); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sw.AddFeature(NewPlacemark("Loose", "", nil))
+
+		if err := sw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		return buf.String()
+	}
+
+	// spill after every feature
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	spilled := stream(1)
+
+	if want := stream(0); spilled != want {
+		t.Errorf("spilled output differs:\n%s\nwant:\n%s", spilled, want)
+	}
+
+	if files, _ := os.ReadDir(tmp); len(files) != 0 {
+		t.Errorf("temporary files left: %v", files)
+	}
+
+	even, odd, loose := strings.Index(spilled, "<name>Even</name>"), strings.Index(spilled, "<name>Odd</name>"), strings.Index(spilled, "<name>Loose</name>")
+	if loose < 0 || even < loose || odd < even || strings.Count(spilled, "<Placemark>") != 21 {
+		t.Errorf("unexpected grouped output:\n%s", spilled)
+	}
+
+	if !strings.Contains(spilled, "\n      <Placemark>\n        <name>Point 1</name>") {
+		t.Errorf("grouped features not indented inside their Folder:\n%s", spilled)
+	}
+
+	if _, err := Parse(strings.NewReader(spilled)); err != nil {
+		t.Errorf("grouped output doesn't parse: %v", err)
+	}
+}
+
+// streamed returns the Placemarks of a document written by fn through a
+// StreamWriter.
+func streamed(t *testing.T, fn func(sw *StreamWriter) error) []*Placemark {
+	var buf bytes.Buffer
+	sw, err := NewKML("").Stream(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fn(sw); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k.Placemarks()
+}
+
+// samePlacemarks reports an error if the Placemarks don't render the same.
+func samePlacemarks(t *testing.T, got []*Placemark, want []*Placemark) {
+	if len(got) != len(want) {
+		t.Fatalf("got %d placemarks, want %d", len(got), len(want))
+	}
+
+	for i := range got {
+		if g, w := renderFeature(got[i]), renderFeature(want[i]); g != w {
+			t.Errorf("placemark %d = %s, want %s", i, g, w)
+		}
+	}
+}
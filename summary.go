@@ -0,0 +1,206 @@
+package gokml
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteSummary writes a plain-text summary of the document to w, as a text
+// alternative for readers who can't use the map: the name and description
+// of every Folder, followed by the name, location, description, and
+// ExtendedData of each of its Placemarks.
+func (k *KML) WriteSummary(w io.Writer) error {
+	s := &summary{bufio.NewWriter(w), k.options.latLonPrecision, false, 0}
+	s.folder(k.rootFolder, 0)
+
+	return s.w.Flush()
+}
+
+// WriteSummaryHTML writes the summary of WriteSummary as an accessible HTML
+// page, with a table of contents linking to a section for each Folder and a
+// table of its Placemarks.
+func (k *KML) WriteSummaryHTML(w io.Writer) error {
+	s := &summary{bufio.NewWriter(w), k.options.latLonPrecision, true, 0}
+	title := html.EscapeString(k.rootFolder.name)
+
+	fmt.Fprintf(s.w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", title)
+	s.contents(k.rootFolder)
+	s.folder(k.rootFolder, 0)
+	fmt.Fprint(s.w, "</body>\n</html>\n")
+
+	return s.w.Flush()
+}
+
+type summary struct {
+	w         *bufio.Writer
+	precision int
+	html      bool
+	sections  int // sections written so far, for anchors
+}
+
+// contents writes the HTML table of contents.
+func (s *summary) contents(root *Folder) {
+	fmt.Fprint(s.w, "<nav aria-label=\"Contents\">\n<h2>Contents</h2>\n")
+
+	n := 0
+	var list func(f *Folder)
+	list = func(f *Folder) {
+		n++
+		fmt.Fprintf(s.w, "<li><a href=\"#section-%d\">%s</a>", n, html.EscapeString(summaryName(f.name, "Untitled folder")))
+
+		subs := subFolders(f)
+		if len(subs) > 0 {
+			fmt.Fprint(s.w, "\n<ul>\n")
+			for _, sub := range subs {
+				list(sub)
+			}
+			fmt.Fprint(s.w, "</ul>\n")
+		}
+		fmt.Fprint(s.w, "</li>\n")
+	}
+
+	fmt.Fprint(s.w, "<ul>\n")
+	list(root)
+	fmt.Fprint(s.w, "</ul>\n</nav>\n")
+}
+
+func (s *summary) folder(f *Folder, depth int) {
+	s.sections++
+	name := summaryName(f.name, "Untitled folder")
+	desc := plainText(f.description, f.html)
+
+	placemarks := make([]*Placemark, 0)
+	for _, child := range f.features {
+		if pm, ok := child.(*Placemark); ok {
+			placemarks = append(placemarks, pm)
+		}
+	}
+
+	if s.html {
+		level := depth + 1
+		if level > 6 {
+			level = 6
+		}
+
+		fmt.Fprintf(s.w, "<section id=\"section-%d\">\n<h%d>%s</h%d>\n", s.sections, level, html.EscapeString(name), level)
+		if len(desc) > 0 {
+			fmt.Fprintf(s.w, "<p>%s</p>\n", html.EscapeString(desc))
+		}
+		if len(placemarks) > 0 {
+			s.table(name, placemarks)
+		}
+	} else {
+		indent := strings.Repeat("  ", depth)
+		fmt.Fprintf(s.w, "%s%s\n", indent, name)
+		if len(desc) > 0 {
+			fmt.Fprintf(s.w, "%s%s\n", indent, desc)
+		}
+		for _, pm := range placemarks {
+			s.item(pm, indent+"  ")
+		}
+		fmt.Fprintln(s.w)
+	}
+
+	for _, sub := range subFolders(f) {
+		s.folder(sub, depth+1)
+	}
+
+	if s.html {
+		fmt.Fprint(s.w, "</section>\n")
+	}
+}
+
+// item writes a Placemark as a plain-text list item.
+func (s *summary) item(pm *Placemark, indent string) {
+	fmt.Fprintf(s.w, "%s- %s", indent, summaryName(pm.name, "Untitled placemark"))
+	if loc := s.location(pm); len(loc) > 0 {
+		fmt.Fprintf(s.w, " (%s)", loc)
+	}
+	fmt.Fprintln(s.w)
+
+	if desc := plainText(pm.description, pm.html); len(desc) > 0 {
+		fmt.Fprintf(s.w, "%s  %s\n", indent, desc)
+	}
+
+	for _, d := range pm.data {
+		fmt.Fprintf(s.w, "%s  %s: %s\n", indent, d.name, d.value)
+	}
+}
+
+// table writes Placemarks as an HTML table.
+func (s *summary) table(caption string, placemarks []*Placemark) {
+	fmt.Fprintf(s.w, "<table>\n<caption>Places in %s</caption>\n", html.EscapeString(caption))
+	fmt.Fprint(s.w, "<thead><tr><th scope=\"col\">Name</th><th scope=\"col\">Location</th><th scope=\"col\">Description</th><th scope=\"col\">Details</th></tr></thead>\n<tbody>\n")
+
+	for _, pm := range placemarks {
+		var details []string
+		for _, d := range pm.data {
+			details = append(details, html.EscapeString(d.name)+": "+html.EscapeString(d.value))
+		}
+
+		fmt.Fprintf(s.w, "<tr><th scope=\"row\">%s</th><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			html.EscapeString(summaryName(pm.name, "Untitled placemark")),
+			html.EscapeString(s.location(pm)),
+			html.EscapeString(plainText(pm.description, pm.html)),
+			strings.Join(details, "<br>"))
+	}
+
+	fmt.Fprint(s.w, "</tbody>\n</table>\n")
+}
+
+// location returns the Placemark's representative point as "lat, lon", or
+// an empty string if it has none.
+func (s *summary) location(pm *Placemark) string {
+	p := representativePoint(pm)
+	if p == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(p.Lat, 'f', s.precision, 64) + ", " + strconv.FormatFloat(p.Lon, 'f', s.precision, 64)
+}
+
+func subFolders(f *Folder) []*Folder {
+	ret := make([]*Folder, 0)
+	for _, child := range f.features {
+		if sub, ok := child.(*Folder); ok {
+			ret = append(ret, sub)
+		}
+	}
+
+	return ret
+}
+
+func summaryName(name string, fallback string) string {
+	if name = strings.TrimSpace(name); len(name) > 0 {
+		return name
+	}
+
+	return fallback
+}
+
+// plainText returns the text of a description, without tags if it is HTML,
+// with runs of whitespace collapsed.
+func plainText(desc string, isHTML bool) string {
+	if isHTML {
+		var b strings.Builder
+		inTag := false
+		for _, r := range desc {
+			switch {
+			case r == '<':
+				inTag = true
+				b.WriteByte(' ')
+			case r == '>' && inTag:
+				inTag = false
+			case !inTag:
+				b.WriteRune(r)
+			}
+		}
+		desc = html.UnescapeString(b.String())
+	}
+
+	return strings.Join(strings.Fields(desc), " ")
+}
@@ -0,0 +1,66 @@
+package gokml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func summaryFixture() *KML {
+	k := NewKML("Sites")
+	k.SetDescription("All sites")
+
+	f := NewFolder("North & East", "")
+	k.AddFeature(f)
+
+	pm := NewPlacemark("Depot", "", NewPoint(40.0, -105.0, 0.0))
+	pm.SetDescriptionHTML("<b>Main</b> depot &amp; yard")
+	pm.SetData("manager", "Kim")
+	f.AddFeature(pm)
+
+	k.AddFeature(NewPlacemark("", "Unnamed", NewPoint(41.5, -104.25, 0.0)))
+
+	return k
+}
+
+func TestWriteSummary(t *testing.T) {
+	var buf bytes.Buffer
+	if err := summaryFixture().WriteSummary(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Sites
+All sites
+  - Untitled placemark (41.500000, -104.250000)
+    Unnamed
+
+  North & East
+    - Depot (40.000000, -105.000000)
+      Main depot & yard
+      manager: Kim
+
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteSummaryHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := summaryFixture().WriteSummaryHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"<title>Sites</title>",
+		`<li><a href="#section-2">North &amp; East</a></li>`,
+		`<section id="section-2">
+<h2>North &amp; East</h2>`,
+		`<tr><th scope="row">Depot</th><td>40.000000, -105.000000</td><td>Main depot &amp; yard</td><td>manager: Kim</td></tr>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package gokml
+
+import (
+	"sync"
+)
+
+// ByTag returns every Folder and Placemark in the document with the given
+// tag, in document order.  Features inside a tagged Folder are not returned
+// separately.
+func (k *KML) ByTag(tag string) []renderable {
+	ret := make([]renderable, 0)
+
+	var walk func(f *Folder)
+	walk = func(f *Folder) {
+		for _, child := range f.features {
+			switch c := child.(type) {
+			case *Folder:
+				if c.tags.has(tag) {
+					ret = append(ret, c)
+				} else {
+					walk(c)
+				}
+			case *Placemark:
+				if c.tags.has(tag) {
+					ret = append(ret, c)
+				}
+			}
+		}
+	}
+	walk(k.rootFolder)
+
+	return ret
+}
+
+// RenderTagged renders the document with only the features that have the
+// given tag.  Tagged Folders are rendered with all of their contents.
+// Untagged Folders are rendered only if they contain tagged features.
+// Styles are always rendered so tagged features keep their styling.
+func (k *KML) RenderTagged(tag string) string {
+	root := filterTagged(k.rootFolder, tag)
+	if root == nil {
+		root = copyFolder(k.rootFolder, nil)
+	}
+
+	cp := *k
+	cp.rootFolder = root
+
+	return cp.Render()
+}
+
+// filterTagged returns a copy of f containing only Styles and the features
+// with the given tag, or nil if f has no tagged features.
+func filterTagged(f *Folder, tag string) *Folder {
+	features := make([]renderable, 0, len(f.features))
+	found := false
+
+	for _, child := range f.features {
+		switch c := child.(type) {
+		case *Style:
+			features = append(features, c)
+		case *Folder:
+			if c.tags.has(tag) {
+				features = append(features, c)
+				found = true
+			} else if sub := filterTagged(c, tag); sub != nil {
+				features = append(features, sub)
+				found = true
+			}
+		case *Placemark:
+			if c.tags.has(tag) {
+				features = append(features, c)
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	return copyFolder(f, features)
+}
+
+// copyFolder returns a shallow copy of f with the given features.
+func copyFolder(f *Folder, features []renderable) *Folder {
+	cp := *f
+	cp.features = features
+	cp.mutex = new(sync.Mutex)
+
+	return &cp
+}
@@ -0,0 +1,46 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	k := NewKML("Tags")
+	k.AddFeature(NewStyle("Shared", 255, 0, 0, 0))
+
+	rivers := NewFolder("Rivers", "")
+	rivers.AddTag("water")
+	rivers.AddFeature(NewPlacemark("Colorado River", "", NewPoint(36.0, -112.0, 0.0)))
+	k.AddFeature(rivers)
+
+	cities := NewFolder("Cities", "")
+	denver := NewPlacemark("Denver", "", NewPoint(39.74, -104.99, 0.0))
+	denver.AddTag("capital")
+	cities.AddFeature(denver)
+	boulder := NewPlacemark("Boulder", "", NewPoint(40.01, -105.27, 0.0))
+	boulder.AddTag("water")
+	cities.AddFeature(boulder)
+	k.AddFeature(cities)
+
+	water := k.ByTag("water")
+	if len(water) != 2 || water[0] != rivers || water[1] != boulder {
+		t.Errorf("unexpected ByTag result %v", water)
+	}
+
+	out := k.RenderTagged("capital")
+	for _, want := range []string{"<Style id=\"Shared\">", "<name>Cities</name>", "<name>Denver</name>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("tagged render missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"Rivers", "Boulder"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("tagged render contains %q", unwanted)
+		}
+	}
+
+	if !strings.Contains(k.Render(), "Boulder") {
+		t.Error("tagged render modified the document")
+	}
+}
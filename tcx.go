@@ -0,0 +1,136 @@
+package gokml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+)
+
+// sensorArrays holds the heart rate, cadence, and power of each point of an
+// activity, for Tracks imported by FromTCX and FromFIT.
+type sensorArrays struct {
+	heartRate, cadence, power []float64
+}
+
+// add appends the readings for a point, using NaN for missing ones.
+func (s *sensorArrays) add(heartRate, cadence, power float64) {
+	s.heartRate = append(s.heartRate, heartRate)
+	s.cadence = append(s.cadence, cadence)
+	s.power = append(s.power, power)
+}
+
+// setOn sets the readings on t as array data named heartrate, cadence, and
+// power, as Google Earth expects, skipping any that were never recorded.
+func (s *sensorArrays) setOn(t *Track) {
+	for _, a := range []struct {
+		name   string
+		values []float64
+	}{{"heartrate", s.heartRate}, {"cadence", s.cadence}, {"power", s.power}} {
+		for _, v := range a.values {
+			if !math.IsNaN(v) {
+				t.SetArrayData(a.name, a.values)
+				break
+			}
+		}
+	}
+}
+
+// FromTCX converts a Garmin Training Center (TCX) file into a KML document
+// with a Placemark for each activity and course.  The trackpoints of all
+// laps become a single gx:Track, with the heart rate, cadence, and power of
+// each point as gx:SimpleArrayData so Google Earth can chart them.
+// Trackpoints without a position are dropped.  The bytes read are reported
+// to progress, if it isn't nil.
+func FromTCX(r io.Reader, progress Progress) (*KML, error) {
+	d := xml.NewDecoder(readProgress(r, progress))
+
+	var root *node
+	for root == nil {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("gokml: not a TCX file")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "TrainingCenterDatabase" {
+			return nil, fmt.Errorf("gokml: not a TCX file (root element is %s)", start.Name.Local)
+		}
+
+		if root, err = readNode(d, start); err != nil {
+			return nil, err
+		}
+	}
+
+	k := NewKML("")
+
+	for _, c := range root.children {
+		switch c.name.Local {
+		case "Activities":
+			for _, a := range c.children {
+				if a.name.Local != "Activity" {
+					continue
+				}
+				pm := NewPlacemark(a.childText("Id"), a.childText("Notes"), tcxTrack(a))
+				if sport := a.attr("Sport"); len(sport) > 0 {
+					pm.SetData("sport", sport)
+				}
+				k.AddFeature(pm)
+			}
+		case "Courses":
+			for _, course := range c.children {
+				if course.name.Local == "Course" {
+					k.AddFeature(NewPlacemark(course.childText("Name"), course.childText("Notes"), tcxTrack(course)))
+				}
+			}
+		}
+	}
+
+	return k, nil
+}
+
+// tcxTrack returns the Track of the trackpoints in an Activity or Course,
+// whose trackpoints are grouped by Lap for activities.
+func tcxTrack(n *node) *Track {
+	track := NewTrack()
+	var sensors sensorArrays
+
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, c := range n.children {
+			switch c.name.Local {
+			case "Lap", "Track":
+				walk(c)
+			case "Trackpoint":
+				pos := c.child("Position")
+				when, err := parseTime(c.childText("Time"))
+				if pos == nil || err != nil {
+					continue
+				}
+
+				p := NewPoint(pos.childFloat(math.NaN(), "LatitudeDegrees"), pos.childFloat(math.NaN(), "LongitudeDegrees"), c.childFloat(0, "AltitudeMeters"))
+				if p == nil {
+					continue
+				}
+
+				track.AddPoint(when, p)
+				sensors.add(
+					c.childFloat(math.NaN(), "HeartRateBpm", "Value"),
+					c.childFloat(math.NaN(), "Cadence"),
+					c.childFloat(math.NaN(), "Extensions", "TPX", "Watts"),
+				)
+			}
+		}
+	}
+	walk(n)
+
+	sensors.setOn(track)
+
+	return track
+}
@@ -0,0 +1,101 @@
+package gokml
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const tcxFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2" xmlns:ns3="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+	<Activities>
+		<Activity Sport="Biking">
+			<Id>2024-06-01T08:00:00Z</Id>
+			<Lap StartTime="2024-06-01T08:00:00Z">
+				<Track>
+					<Trackpoint>
+						<Time>2024-06-01T08:00:00Z</Time>
+						<Position><LatitudeDegrees>40.01</LatitudeDegrees><LongitudeDegrees>-105.27</LongitudeDegrees></Position>
+						<AltitudeMeters>1655</AltitudeMeters>
+						<HeartRateBpm><Value>120</Value></HeartRateBpm>
+						<Cadence>85</Cadence>
+						<Extensions><ns3:TPX><ns3:Watts>210</ns3:Watts></ns3:TPX></Extensions>
+					</Trackpoint>
+					<Trackpoint>
+						<Time>2024-06-01T08:00:05Z</Time>
+						<HeartRateBpm><Value>121</Value></HeartRateBpm>
+					</Trackpoint>
+				</Track>
+			</Lap>
+			<Lap StartTime="2024-06-01T08:01:00Z">
+				<Track>
+					<Trackpoint>
+						<Time>2024-06-01T08:01:00Z</Time>
+						<Position><LatitudeDegrees>40.02</LatitudeDegrees><LongitudeDegrees>-105.28</LongitudeDegrees></Position>
+						<AltitudeMeters>1660</AltitudeMeters>
+						<HeartRateBpm><Value>130</Value></HeartRateBpm>
+						<Cadence>90</Cadence>
+					</Trackpoint>
+				</Track>
+			</Lap>
+		</Activity>
+	</Activities>
+</TrainingCenterDatabase>`
+
+func TestFromTCX(t *testing.T) {
+	k, err := FromTCX(strings.NewReader(tcxFixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pms := k.FindPlacemarks(func(*Placemark) bool { return true })
+	if len(pms) != 1 || pms[0].name != "2024-06-01T08:00:00Z" {
+		t.Fatalf("unexpected placemarks %v", pms)
+	}
+	if sport, _ := pms[0].Data("sport"); sport != "Biking" {
+		t.Errorf("sport = %q", sport)
+	}
+
+	track := pms[0].geometry.(*Track)
+	if len(track.points) != 2 || track.points[1].Alt != 1660 || track.times[1].Minute() != 1 {
+		t.Errorf("unexpected track %v %v", track.points, track.times)
+	}
+	if len(track.arrays) != 3 || track.arrays[0].name != "heartrate" || track.arrays[0].values[1] != 130 {
+		t.Fatalf("unexpected array data %v", track.arrays)
+	}
+	if power := track.arrays[2].values; power[0] != 210 || !math.IsNaN(power[1]) {
+		t.Errorf("unexpected power %v", power)
+	}
+
+	out := unindent(k.Render())
+	for _, want := range []string{
+		"<Schema id=\"TrackData\">\n<gx:SimpleArrayField name=\"heartrate\" type=\"float\">\n<displayName>heartrate</displayName>\n</gx:SimpleArrayField>\n",
+		"</gx:coord>\n<ExtendedData>\n<SchemaData schemaUrl=\"#TrackData\">\n<gx:SimpleArrayData name=\"heartrate\">\n<gx:value>120</gx:value>\n<gx:value>130</gx:value>\n</gx:SimpleArrayData>\n",
+		"<gx:SimpleArrayData name=\"power\">\n<gx:value>210</gx:value>\n<gx:value></gx:value>\n</gx:SimpleArrayData>\n</SchemaData>\n</ExtendedData>\n</gx:Track>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if err := k.Validate(); err != nil {
+		t.Errorf("converted document is invalid: %v", err)
+	}
+
+	// array data and the Schema survive a round trip through Parse
+	again, err := Parse(strings.NewReader(k.Render()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Render() != k.Render() {
+		t.Errorf("round trip differs:\n%s", again.Render())
+	}
+}
+
+func TestFromTCXErrors(t *testing.T) {
+	for _, doc := range []string{``, `<kml/>`, `<TrainingCenterDatabase><Activities>`} {
+		if _, err := FromTCX(strings.NewReader(doc), nil); err == nil {
+			t.Errorf("no error for %q", doc)
+		}
+	}
+}
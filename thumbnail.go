@@ -0,0 +1,283 @@
+package gokml
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Thumbnail renders a quick raster preview of the document's geometries on a
+// blank (white) background.  Placemarks are drawn in the color of their
+// Style when the style can be found in the document, otherwise black.  The
+// view is fitted to the extent of all geometries.  Returns nil if the width
+// or height is not positive.
+func (k *KML) Thumbnail(width int, height int) image.Image {
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	k.drawThumbnail(img, nil)
+
+	return img
+}
+
+// ThumbnailOn renders the preview of Thumbnail over a copy of the background
+// image, such as a logo or a map prepared for the listing, at its size.
+// Returns nil if the background is empty.
+func (k *KML) ThumbnailOn(background image.Image) image.Image {
+	b := background.Bounds()
+	if b.Empty() {
+		return nil
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), background, b.Min, draw.Src)
+	k.drawThumbnail(img, nil)
+
+	return img
+}
+
+// TileFunc returns the web map tile at a zoom level, column, and row, in the
+// Web Mercator scheme of OpenStreetMap and most tile servers, or nil if
+// there is no tile there.
+type TileFunc func(zoom int, x int, y int) (image.Image, error)
+
+// ThumbnailWithTiles renders the preview of Thumbnail over web map tiles
+// fetched with tiles, at the zoom level closest to the thumbnail's scale, so
+// the geometries are shown in context.  Each tile is fetched once; places
+// without a tile are left white.  Returns an error if tiles does, or if the
+// width or height is not positive.
+func (k *KML) ThumbnailWithTiles(width int, height int, tiles TileFunc) (image.Image, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("gokml: invalid thumbnail size %dx%d", width, height)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	var err error
+	k.drawThumbnail(img, func(t *thumbProjection) {
+		err = t.drawTiles(img, tiles)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// drawThumbnail fits the view to the extent of the document's geometries
+// and draws them on img, after calling background, if any, with the view.
+func (k *KML) drawThumbnail(img draw.Image, background func(*thumbProjection)) {
+	var minLat, maxLat, minLon, maxLon float64
+	first := true
+	walkPoints(k.rootFolder, func(p *Point) {
+		if first {
+			minLat, maxLat, minLon, maxLon = p.Lat, p.Lat, p.Lon, p.Lon
+			first = false
+			return
+		}
+		minLat = math.Min(minLat, p.Lat)
+		maxLat = math.Max(maxLat, p.Lat)
+		minLon = math.Min(minLon, p.Lon)
+		maxLon = math.Max(maxLon, p.Lon)
+	})
+
+	if first {
+		return // nothing to draw
+	}
+
+	b := img.Bounds()
+	t := newThumbProjection(minLat, maxLat, minLon, maxLon, b.Dx(), b.Dy())
+	if background != nil {
+		background(t)
+	}
+
+	styles := make(map[string]*Style)
+	collectStyles(k.rootFolder, styles)
+
+	walkPlacemarks(k.rootFolder, func(pm *Placemark) {
+		c := color.RGBA{0, 0, 0, 255}
+		if s, ok := styles[pm.style]; ok {
+			c = color.RGBA{s.red, s.green, s.blue, 255}
+		}
+		t.drawGeometry(img, pm.geometry, c)
+	})
+}
+
+// thumbProjection maps lat/lon onto thumbnail pixels using a simple
+// equirectangular projection scaled by the cosine of the middle latitude.
+type thumbProjection struct {
+	minLon, maxLat float64
+	lonScale       float64
+	scale          float64
+	offX, offY     float64
+}
+
+func newThumbProjection(minLat, maxLat, minLon, maxLon float64, width, height int) *thumbProjection {
+	lonScale := math.Cos((minLat + maxLat) / 2.0 * math.Pi / 180.0)
+	spanX := (maxLon - minLon) * lonScale
+	spanY := maxLat - minLat
+
+	// leave a small margin so edge geometries aren't clipped
+	usableW := float64(width) * 0.9
+	usableH := float64(height) * 0.9
+
+	scale := 1.0
+	switch {
+	case spanX == 0 && spanY == 0:
+		scale = 1.0
+	case spanX == 0:
+		scale = usableH / spanY
+	case spanY == 0:
+		scale = usableW / spanX
+	default:
+		scale = math.Min(usableW/spanX, usableH/spanY)
+	}
+
+	return &thumbProjection{
+		minLon:   minLon,
+		maxLat:   maxLat,
+		lonScale: lonScale,
+		scale:    scale,
+		offX:     (float64(width) - spanX*scale) / 2.0,
+		offY:     (float64(height) - spanY*scale) / 2.0,
+	}
+}
+
+func (t *thumbProjection) project(p *Point) (int, int) {
+	x := t.offX + (p.Lon-t.minLon)*t.lonScale*t.scale
+	y := t.offY + (t.maxLat-p.Lat)*t.scale
+	return int(math.Floor(x)), int(math.Floor(y))
+}
+
+// unproject returns the latitude and longitude at a thumbnail pixel.
+func (t *thumbProjection) unproject(x float64, y float64) (float64, float64) {
+	return t.maxLat - (y-t.offY)/t.scale, t.minLon + (x-t.offX)/(t.lonScale*t.scale)
+}
+
+// drawTiles fills img with the web map tiles under it, each pixel taking
+// the color of the tile pixel under its center.
+func (t *thumbProjection) drawTiles(img draw.Image, tiles TileFunc) error {
+	// the zoom level whose tiles have at least as many pixels per degree
+	zoom := int(math.Ceil(math.Log2(t.lonScale * t.scale * 360 / 256)))
+	zoom = minInt(maxInt(zoom, 0), 19)
+	size := 256 * math.Exp2(float64(zoom)) // of the whole map, in pixels
+
+	type key struct{ x, y int }
+	cache := make(map[key]image.Image)
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			lat, lon := t.unproject(float64(x-b.Min.X)+0.5, float64(y-b.Min.Y)+0.5)
+			if math.Abs(lat) > 85.0511 {
+				continue // beyond the Web Mercator map
+			}
+
+			phi := radians(lat)
+			mx := (normalizeLon(lon) + 180) / 360 * size
+			my := (1 - math.Log(math.Tan(phi)+1/math.Cos(phi))/math.Pi) / 2 * size
+			k := key{int(mx) / 256, int(my) / 256}
+
+			tile, ok := cache[k]
+			if !ok {
+				var err error
+				if tile, err = tiles(zoom, k.x, k.y); err != nil {
+					return err
+				}
+				cache[k] = tile
+			}
+			if tile == nil {
+				continue
+			}
+
+			tb := tile.Bounds()
+			tx := tb.Min.X + int(math.Mod(mx, 256)*float64(tb.Dx())/256)
+			ty := tb.Min.Y + int(math.Mod(my, 256)*float64(tb.Dy())/256)
+			img.Set(x, y, tile.At(tx, ty))
+		}
+	}
+
+	return nil
+}
+
+func (t *thumbProjection) drawGeometry(img draw.Image, geom renderable, c color.Color) {
+	switch g := geom.(type) {
+	case *Point:
+		x, y := t.project(g)
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				img.Set(x+dx, y+dy, c)
+			}
+		}
+	case *LineString:
+		t.drawPath(img, g.coordinates, c, false)
+	case *Polygon:
+		t.drawPath(img, g.points, c, true)
+		for _, ring := range g.inner {
+			t.drawPath(img, ring, c, true)
+		}
+	case *Track:
+		t.drawPath(img, g.points, c, false)
+	case *MultiGeometry:
+		for _, geom := range g.geometries {
+			t.drawGeometry(img, geom, c)
+		}
+	}
+}
+
+func (t *thumbProjection) drawPath(img draw.Image, points []*Point, c color.Color, closed bool) {
+	for i := 1; i < len(points); i++ {
+		x0, y0 := t.project(points[i-1])
+		x1, y1 := t.project(points[i])
+		drawLine(img, x0, y0, x1, y1, c)
+	}
+
+	if closed && len(points) > 2 {
+		x0, y0 := t.project(points[len(points)-1])
+		x1, y1 := t.project(points[0])
+		drawLine(img, x0, y0, x1, y1, c)
+	}
+}
+
+// drawLine draws a line using Bresenham's algorithm.
+func drawLine(img draw.Image, x0, y0, x1, y1 int, c color.Color) {
+	dx := x1 - x0
+	if dx < 0 {
+		dx = -dx
+	}
+	dy := y1 - y0
+	if dy > 0 {
+		dy = -dy
+	}
+
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	e := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package gokml
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	k := NewKML("Thumbnail")
+
+	red := NewStyle("Red", 255, 255, 0, 0)
+	k.AddFeature(red)
+
+	line := NewLineString()
+	line.AddPoint(NewPoint(40.0, -105.0, 0.0))
+	line.AddPoint(NewPoint(41.0, -104.0, 0.0))
+	pm := NewPlacemark("Line", "", line)
+	pm.SetStyle("Red")
+	k.AddFeature(pm)
+
+	img := k.Thumbnail(64, 48)
+	if img == nil {
+		t.Fatal("Thumbnail returned nil")
+	}
+
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 48 {
+		t.Fatalf("unexpected bounds %v", b)
+	}
+
+	found := false
+	b := img.Bounds()
+	for x := b.Min.X; x < b.Max.X && !found; x++ {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			if color.RGBAModel.Convert(img.At(x, y)) == (color.RGBA{255, 0, 0, 255}) {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		t.Error("expected the line to be drawn in the style color")
+	}
+
+	if k.Thumbnail(0, 10) != nil {
+		t.Error("expected nil for invalid size")
+	}
+
+	if NewKML("Empty").Thumbnail(8, 8) == nil {
+		t.Error("expected a blank image for an empty document")
+	}
+}
+
+func TestThumbnailBackground(t *testing.T) {
+	k := NewKML("Background")
+	red := NewStyle("Red", 255, 255, 0, 0)
+	k.AddFeature(red)
+	line := NewLineString()
+	line.AddPoint(NewPoint(40.0, -105.0, 0.0))
+	line.AddPoint(NewPoint(41.0, -104.0, 0.0))
+	pm := NewPlacemark("Line", "", line)
+	pm.SetStyle("Red")
+	k.AddFeature(pm)
+
+	blue := color.RGBA{0, 0, 255, 255}
+	has := func(img image.Image, c color.RGBA) bool {
+		b := img.Bounds()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				if color.RGBAModel.Convert(img.At(x, y)) == c {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	canvas := image.NewRGBA(image.Rect(10, 10, 74, 58))
+	for x := 10; x < 74; x++ {
+		for y := 10; y < 58; y++ {
+			canvas.Set(x, y, blue)
+		}
+	}
+	img := k.ThumbnailOn(canvas)
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 48 {
+		t.Fatalf("unexpected bounds %v", b)
+	}
+	if !has(img, blue) || !has(img, color.RGBA{255, 0, 0, 255}) {
+		t.Error("expected the line over the background")
+	}
+	if canvas.At(40, 30) != blue {
+		t.Error("background modified")
+	}
+
+	var zooms []int
+	img, err := k.ThumbnailWithTiles(64, 48, func(zoom int, x int, y int) (image.Image, error) {
+		zooms = append(zooms, zoom)
+		// the tile of Denver at zoom 6
+		if zoom != 6 || x != 13 || y != 24 {
+			return nil, nil
+		}
+		return image.NewUniform(blue), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zooms) == 0 || zooms[0] != 6 {
+		t.Errorf("tiles fetched at zooms %v, want 6", zooms)
+	}
+	if !has(img, blue) || !has(img, color.RGBA{255, 0, 0, 255}) {
+		t.Error("expected the line over the tiles")
+	}
+
+	failed := errors.New("offline")
+	if _, err := k.ThumbnailWithTiles(64, 48, func(int, int, int) (image.Image, error) { return nil, failed }); err != failed {
+		t.Errorf("tile error = %v", err)
+	}
+	if _, err := k.ThumbnailWithTiles(0, 48, nil); err == nil {
+		t.Error("invalid size accepted")
+	}
+}
@@ -0,0 +1,242 @@
+package gokml
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// RewriteHrefs replaces every href in the document (icons, overlays,
+// NetworkLinks, etc.) with the result of fn, so resources can be relocated
+// consistently.
+func (k *KML) RewriteHrefs(fn func(href string) string) {
+	rewriteHrefs(k.rootFolder, fn)
+}
+
+// Coordinates calls fn for every Point of every Placemark geometry in the
+// document, along with the Placemark, so bounds or statistics can be
+// computed without knowing the geometry types.  fn may modify the Point
+// (to reproject it, for example); the changes are rendered.
+func (k *KML) Coordinates(fn func(pm *Placemark, p *Point)) {
+	walkPlacemarks(k.rootFolder, func(pm *Placemark) {
+		walkPoints(pm.geometry, func(p *Point) { fn(pm, p) })
+	})
+}
+
+// Externalize rewrites every relative href in the document to an absolute
+// URL under baseURL, so resources that were shipped alongside the document
+// can be served from a CDN instead.  Absolute URLs are left untouched.
+// Assets (see AddAsset) referred to by the rewritten hrefs are no longer
+// embedded by WriteKMZ, so they must be uploaded to baseURL.
+func (k *KML) Externalize(baseURL string) error {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+
+	// make sure the last path element of the base is treated as a directory
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	rewritten := make(map[string]bool)
+	k.RewriteHrefs(func(href string) string {
+		u, err := url.Parse(href)
+		if err != nil || u.IsAbs() || strings.HasPrefix(u.Path, "/") || len(href) == 0 {
+			return href
+		}
+
+		rewritten[path.Clean(u.Path)] = true
+		return base.ResolveReference(u).String()
+	})
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	assets := make([]*asset, 0, len(k.assets))
+	for _, a := range k.assets {
+		if !rewritten[a.name] {
+			assets = append(assets, a)
+		}
+	}
+	k.assets = assets
+
+	return nil
+}
+
+// InlineResources downloads every remote (http or https) resource in the
+// document of at most maxSize bytes with client, embeds it in KMZ archives
+// written by WriteKMZ (see AddAssetData), and rewrites its hrefs to the
+// embedded file, so the KMZ can be used offline.  Larger resources are left
+// remote.  If client is nil, http.DefaultClient is used.  The number of
+// resources downloaded is reported to progress, if it isn't nil, as the
+// "downloading" stage.  Returns an error if a resource can't be downloaded,
+// in which case the document is unchanged.
+func (k *KML) InlineResources(client *http.Client, maxSize int64, progress Progress) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var remote []string
+	seen := make(map[string]bool)
+	walkHrefs(k.rootFolder, func(href string) {
+		if isRemote(href) && !seen[href] {
+			seen[href] = true
+			remote = append(remote, href)
+		}
+	})
+
+	type download struct {
+		href string
+		data []byte
+	}
+
+	report := func(done int) {
+		if progress != nil {
+			progress.Report("downloading", int64(done), int64(len(remote)))
+		}
+	}
+	report(0)
+
+	var downloads []download
+	for i, href := range remote {
+		data, err := fetchResource(client, href, maxSize)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			downloads = append(downloads, download{href, data})
+		}
+		report(i + 1)
+	}
+
+	inlined := make(map[string]string)
+	for _, d := range downloads {
+		u, _ := url.Parse(d.href)
+		name := path.Base(u.Path)
+		if name == "/" || name == "." {
+			name = "resource"
+		}
+		inlined[d.href] = k.AddAssetData(name, d.data)
+	}
+
+	k.RewriteHrefs(func(href string) string {
+		if name, ok := inlined[href]; ok {
+			return name
+		}
+		return href
+	})
+
+	return nil
+}
+
+// isRemote reports whether href is an http or https URL.
+func isRemote(href string) bool {
+	u, err := url.Parse(href)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// fetchResource downloads a resource, or returns nil if it is larger than
+// maxSize bytes.
+func fetchResource(client *http.Client, href string, maxSize int64) ([]byte, error) {
+	resp, err := client.Get(href)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gokml: fetching %s: %s", href, resp.Status)
+	}
+
+	if resp.ContentLength > maxSize {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, nil
+	}
+
+	return data, nil
+}
+
+// SplitFolder moves folder out of the document into a separate document and
+// replaces it with a NetworkLink to href that refreshes every interval, so
+// fast-changing layers can be refreshed without re-sending the rest of the
+// document.  The new document contains the folder and copies of the Styles
+// and StyleMaps it uses, including the Styles the StyleMaps refer to, and
+// should be served at href.  Returns an error if the folder isn't in the
+// document.
+func (k *KML) SplitFolder(folder *Folder, href string, interval time.Duration) (*KML, error) {
+	nl := NewNetworkLink(folder.name, folder.description, href)
+	nl.SetRefreshInterval(interval)
+
+	if folder == k.rootFolder || !replaceFeature(k.rootFolder, folder, nl) {
+		return nil, fmt.Errorf("folder %q is not in the document", folder.name)
+	}
+
+	used := make(map[string]bool)
+	use := func(id string) {
+		if len(id) > 0 {
+			used[id] = true
+		}
+	}
+
+	walkPlacemarks(folder, func(pm *Placemark) { use(pm.style) })
+	walkExtras(folder, func(n *node) {
+		if n.name.Local == "styleUrl" {
+			use(localStyleRef(n.text))
+		}
+	})
+
+	// StyleMaps go first, so the Styles they refer to count as used
+	var kept []*node
+	walkExtras(k.rootFolder, func(n *node) {
+		if n.name.Local == "StyleMap" && used[n.attr("id")] {
+			kept = append(kept, n.clone())
+			walkNode(n, func(c *node) {
+				if c.name.Local == "styleUrl" {
+					use(localStyleRef(c.text))
+				}
+			})
+		}
+	})
+	walkExtras(k.rootFolder, func(n *node) {
+		if n.name.Local == "Style" && used[n.attr("id")] {
+			kept = append(kept, n.clone())
+		}
+	})
+
+	doc := NewKML(folder.name)
+	if len(kept) > 0 {
+		doc.rootFolder.extra = &extraXML{nil, kept}
+	}
+
+	walkStyles(k.rootFolder, func(s *Style) {
+		if used[s.name] {
+			doc.AddFeature(cloneFeature(s))
+		}
+	})
+	doc.AddFeature(folder)
+
+	return doc, nil
+}
+
+// localStyleRef returns the id a styleUrl refers to within its own document,
+// or an empty string if it refers to another document.
+func localStyleRef(styleURL string) string {
+	styleURL = strings.TrimSpace(styleURL)
+	if !strings.HasPrefix(styleURL, "#") {
+		return ""
+	}
+
+	return styleURL[1:]
+}
@@ -0,0 +1,269 @@
+package gokml
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExternalize(t *testing.T) {
+	k := NewKML("Externalize")
+
+	local := NewStyle("Local", 255, 0, 0, 0)
+	local.SetIconURL("files/icon.png")
+	k.AddFeature(local)
+
+	remote := NewStyle("Remote", 255, 0, 0, 0)
+	k.AddFeature(remote)
+
+	overlay := NewGroundOverlay("Overlay", "", k.AddAssetData("scans/scene.png", []byte("scene")), Bounds{1, 0, 1, 0})
+	k.AddFeature(overlay)
+	unused := k.AddAssetData("unused.txt", []byte("kept"))
+
+	if err := k.Externalize("https://cdn.example.com/products"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := k.WriteKMZ(&buf); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range z.File {
+		names = append(names, f.Name)
+	}
+	if want := "[doc.kml " + unused + "]"; fmt.Sprint(names) != want {
+		t.Errorf("KMZ holds %v, want %s", names, want)
+	}
+
+	if local.iconURL != "https://cdn.example.com/products/files/icon.png" {
+		t.Errorf("unexpected icon href %q", local.iconURL)
+	}
+
+	if remote.iconURL != "http://maps.google.com/mapfiles/kml/pushpin/ylw-pushpin.png" {
+		t.Errorf("absolute href was rewritten to %q", remote.iconURL)
+	}
+
+	if overlay.href != "https://cdn.example.com/products/files/scene.png" {
+		t.Errorf("unexpected overlay href %q", overlay.href)
+	}
+}
+
+func TestInlineResources(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		switch r.URL.Path {
+		case "/icons/pin.png":
+			w.Write([]byte("pin"))
+		case "/scans/sheet.jpg":
+			w.Write(bytes.Repeat([]byte("x"), 100))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	k := NewKML("Inline")
+	for _, name := range []string{"A", "B"} {
+		s := NewStyle(name, 255, 0, 0, 0)
+		s.SetIconURL(server.URL + "/icons/pin.png")
+		k.AddFeature(s)
+	}
+	local := NewStyle("Local", 255, 0, 0, 0)
+	local.SetIconURL("files/local.png")
+	k.AddFeature(local)
+	overlay := NewGroundOverlay("Scan", "", server.URL+"/scans/sheet.jpg", Bounds{1, 0, 1, 0})
+	k.AddFeature(overlay)
+
+	var reports []string
+	progress := ProgressFunc(func(stage string, current int64, total int64) {
+		reports = append(reports, fmt.Sprintf("%s %d/%d", stage, current, total))
+	})
+
+	if err := k.InlineResources(server.Client(), 10, progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "[downloading 0/2 downloading 1/2 downloading 2/2]"; fmt.Sprint(reports) != want {
+		t.Errorf("progress reports = %v, want %s", reports, want)
+	}
+
+	if fetches != 2 {
+		t.Errorf("%d fetches, want 2", fetches)
+	}
+	for _, s := range k.rootFolder.features[:2] {
+		if href := s.(*Style).iconURL; href != "files/pin.png" {
+			t.Errorf("icon href = %q", href)
+		}
+	}
+	if local.iconURL != "files/local.png" {
+		t.Errorf("local href rewritten to %q", local.iconURL)
+	}
+	if overlay.href != server.URL+"/scans/sheet.jpg" {
+		t.Errorf("resource over the size limit inlined as %q", overlay.href)
+	}
+
+	var buf bytes.Buffer
+	if err := k.WriteKMZ(&buf); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range z.File {
+		if f.Name == "files/pin.png" {
+			r, _ := f.Open()
+			data, _ := io.ReadAll(r)
+			found = string(data) == "pin"
+		}
+	}
+	if !found {
+		t.Error("icon not embedded in the KMZ")
+	}
+
+	missing := NewKML("Missing")
+	missing.AddFeature(NewGroundOverlay("Gone", "", server.URL+"/gone.png", Bounds{1, 0, 1, 0}))
+	if err := missing.InlineResources(server.Client(), 10, nil); err == nil {
+		t.Error("missing resource not reported")
+	}
+}
+
+func TestSplitFolder(t *testing.T) {
+	k := NewKML("Operations")
+	k.AddFeature(NewStyle("Vehicle", 255, 0, 0, 255))
+	k.AddFeature(NewStyle("Unused", 255, 255, 0, 0))
+
+	base := NewFolder("Base Layer", "")
+	k.AddFeature(base)
+
+	live := NewFolder("Vehicles", "Live positions")
+	pm := NewPlacemark("Truck 12", "", NewPoint(40.0, -105.0, 0.0))
+	pm.SetStyle("Vehicle")
+	live.AddFeature(pm)
+	base.AddFeature(live)
+
+	doc, err := k.SplitFolder(live, "vehicles.kml", 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := unindent(k.Render())
+	if strings.Contains(out, "Truck 12") {
+		t.Error("split folder still rendered in the parent document")
+	}
+
+	for _, want := range []string{
+		"<NetworkLink>\n<name>Vehicles</name>",
+		"<href>vehicles.kml</href>\n<refreshMode>onInterval</refreshMode>\n<refreshInterval>10</refreshInterval>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("parent document missing %q", want)
+		}
+	}
+
+	out = doc.Render()
+	for _, want := range []string{"<Style id=\"Vehicle\">", "<name>Truck 12</name>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("split document missing %q", want)
+		}
+	}
+	if strings.Contains(out, "Unused") {
+		t.Error("split document contains unused style")
+	}
+
+	if _, err := k.SplitFolder(live, "again.kml", time.Minute); err == nil {
+		t.Error("expected an error splitting a folder that isn't in the document")
+	}
+
+	// the split document has its own copies of the Styles
+	k.FindByID("Vehicle").(*Style).SetIconScale(3.0)
+	if doc.Render() != out {
+		t.Error("changing a Style of the document changed the split document")
+	}
+}
+
+func TestSplitFolderStyleMaps(t *testing.T) {
+	k, err := Parse(strings.NewReader(`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>
+	<StyleMap id="pair"><Pair><key>normal</key><styleUrl>#low</styleUrl></Pair><Pair><key>highlight</key><styleUrl>#high</styleUrl></Pair></StyleMap>
+	<StyleMap id="spare"><Pair><key>normal</key><styleUrl>#other</styleUrl></Pair></StyleMap>
+	<Folder><name>Live</name>
+		<Placemark><styleUrl>#pair</styleUrl><Point><coordinates>1,2</coordinates></Point></Placemark>
+	</Folder>
+	</Document></kml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"low", "high", "other"} {
+		k.AddFeature(NewStyle(name, 255, 255, 0, 0))
+	}
+
+	doc, err := k.SplitFolder(k.FindFolders("Live")[0], "live.kml", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	if err := doc.Write(&buf); err != nil {
+		t.Fatalf("split document not written: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{`<StyleMap id="pair">`, `<Style id="low">`, `<Style id="high">`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("split document missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "spare") || strings.Contains(out, `<Style id="other">`) {
+		t.Errorf("split document contains unused styles:\n%s", out)
+	}
+}
+
+func TestCoordinates(t *testing.T) {
+	k := NewKML("Coordinates")
+
+	pt := NewPlacemark("Point", "", NewPoint(40.0, -105.0, 0.0))
+	k.AddFeature(pt)
+
+	line := NewLineString()
+	line.AddPoint(NewPoint(41.0, -104.0, 0.0))
+	line.AddPoint(NewPoint(42.0, -103.0, 0.0))
+	f := NewFolder("Lines", "")
+	f.AddFeature(NewPlacemark("Line", "", line))
+	k.AddFeature(f)
+
+	k.AddFeature(NewPlacemark("Nowhere", "", nil))
+
+	count := 0
+	north := -90.0
+	k.Coordinates(func(pm *Placemark, p *Point) {
+		count++
+		if p.Lat > north {
+			north = p.Lat
+		}
+		if pm.name == "Point" {
+			p.Lon += 1
+		}
+	})
+
+	if count != 3 || north != 42.0 {
+		t.Errorf("got %d points, north %v; want 3, 42", count, north)
+	}
+
+	if !strings.Contains(k.Render(), "<coordinates>-104.000000,40.000000,0.000000</coordinates>") {
+		t.Error("modified point not rendered")
+	}
+}
@@ -0,0 +1,326 @@
+package gokml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Update represents a NetworkLinkControl Update, which changes a document
+// that Google Earth previously loaded through a NetworkLink without
+// re-sending the whole document.
+type Update struct {
+	targetHref string
+	deletes    []updateTarget
+	creates    []updateCreate
+	changes    []updateChange
+	options    renderOptions
+}
+
+type updateTarget struct {
+	element string // element name (Placemark, Folder, etc.)
+	id      string
+}
+
+type updateCreate struct {
+	parent  updateTarget
+	feature renderable
+}
+
+type updateChange struct {
+	target      updateTarget
+	name        string
+	description string
+	html        bool
+}
+
+// NewUpdate returns a pointer to a new, empty Update of the document at
+// targetHref (the href of the NetworkLink that loaded it).  It is rendered
+// with the default render options.
+func NewUpdate(targetHref string) *Update {
+	return &Update{targetHref: strings.TrimSpace(targetHref), options: defaultRenderOptions()}
+}
+
+// Empty reports whether the Update makes no changes.
+func (u *Update) Empty() bool {
+	return len(u.deletes) == 0 && len(u.creates) == 0 && len(u.changes) == 0
+}
+
+// Delta returns the Update that turns a client's copy of old into new, for
+// example two Snapshots of a live document, so a live feed can send small
+// changes instead of the whole document on every refresh.  Features are
+// matched by id, so only Folders, Placemarks, and Styles with an id are
+// tracked.  New features are created in their parent, which must have an id
+// (see KML.SetID for the document itself).  Placemarks and Styles whose
+// content changed are replaced; changes to only the name or description
+// are sent as a Change.  The Update is rendered with the render options of
+// new, such as its precision.
+func Delta(old *KML, new *KML, targetHref string) (*Update, error) {
+	u := NewUpdate(targetHref)
+	u.options = new.options
+	oldIndex := indexIDs(old.rootFolder)
+	newIndex := indexIDs(new.rootFolder)
+
+	// entries are handled in document order, so parents are created before
+	// their children and children of created features are skipped (they are
+	// rendered with their parent)
+	created := make(map[string]bool)
+	replaced := make([]updateTarget, 0)
+
+	for _, entry := range newIndex.ordered {
+		if entry.ancestorIn(created) {
+			continue
+		}
+
+		if prev, ok := oldIndex.entries[entry.id]; ok {
+			if prev.parentID() == entry.parentID() && prev.element == entry.element {
+				change, replace := compareFeatures(prev, entry)
+				if !replace {
+					if change != nil {
+						u.changes = append(u.changes, *change)
+					}
+					continue
+				}
+			}
+
+			// moved or changed, so it has to be re-created
+			replaced = append(replaced, prev.target())
+		}
+
+		parent := updateTarget{"Document", new.rootFolder.id}
+		if entry.parent != nil {
+			parent = entry.parent.target()
+		}
+
+		if len(parent.id) == 0 {
+			return nil, fmt.Errorf("gokml: %s %q is in a %s without an id", entry.element, entry.id, parent.element)
+		}
+
+		created[entry.id] = true
+		u.creates = append(u.creates, updateCreate{parent, entry.feature})
+	}
+
+	for _, entry := range oldIndex.ordered {
+		if _, ok := newIndex.entries[entry.id]; ok {
+			continue
+		}
+
+		// deleting (or re-creating) an ancestor deletes the entry too
+		if entry.ancestorIn(created) || entry.ancestorMissing(newIndex) {
+			continue
+		}
+
+		u.deletes = append(u.deletes, entry.target())
+	}
+
+	u.deletes = append(u.deletes, replaced...)
+
+	return u, nil
+}
+
+// Render renders the Update as a NetworkLinkControl document.
+func (u *Update) Render() string {
+	var buf bytes.Buffer
+	u.Write(&buf)
+
+	return buf.String()
+}
+
+// Write renders the Update as a NetworkLinkControl document to w.
+func (u *Update) Write(w io.Writer) error {
+	features := make([]renderable, len(u.creates))
+	for i, c := range u.creates {
+		features[i] = c.feature
+	}
+
+	e := newEncoder(w, u.options)
+	e.header()
+	e.start("kml", namespaceAttrs(features...)...)
+	e.start("NetworkLinkControl")
+	u.render(e)
+	e.end("NetworkLinkControl")
+	e.end("kml")
+	e.raw(e.opts.newline)
+
+	return e.flush()
+}
+
+func (u *Update) render(e *encoder) {
+	e.start("Update")
+	e.element("targetHref", u.targetHref)
+
+	if len(u.deletes) > 0 {
+		e.start("Delete")
+		for _, d := range u.deletes {
+			e.start(d.element, attr("targetId", d.id))
+			e.end(d.element)
+		}
+		e.end("Delete")
+	}
+
+	for _, c := range u.creates {
+		e.start("Create")
+		e.start(c.parent.element, attr("targetId", c.parent.id))
+		c.feature.render(e)
+		e.end(c.parent.element)
+		e.end("Create")
+	}
+
+	if len(u.changes) > 0 {
+		e.start("Change")
+		for _, c := range u.changes {
+			e.start(c.target.element, attr("targetId", c.target.id))
+			e.label(c.name)
+			e.description(c.description, c.html)
+			e.end(c.target.element)
+		}
+		e.end("Change")
+	}
+
+	e.end("Update")
+}
+
+// idEntry is a feature with an id and its position in the document.
+type idEntry struct {
+	id      string
+	element string
+	feature renderable
+	parent  *idEntry // nearest ancestor Folder, nil for the document
+}
+
+func (entry *idEntry) target() updateTarget {
+	return updateTarget{entry.element, entry.id}
+}
+
+func (entry *idEntry) parentID() string {
+	if entry.parent == nil {
+		return ""
+	}
+	return entry.parent.id
+}
+
+// ancestorIn reports whether the id of any ancestor of the entry is in ids.
+func (entry *idEntry) ancestorIn(ids map[string]bool) bool {
+	for p := entry.parent; p != nil; p = p.parent {
+		if len(p.id) > 0 && ids[p.id] {
+			return true
+		}
+	}
+	return false
+}
+
+// ancestorMissing reports whether any ancestor of the entry with an id isn't
+// in the index.
+func (entry *idEntry) ancestorMissing(index *idIndex) bool {
+	for p := entry.parent; p != nil; p = p.parent {
+		if _, ok := index.entries[p.id]; len(p.id) > 0 && !ok {
+			return true
+		}
+	}
+	return false
+}
+
+type idIndex struct {
+	entries map[string]*idEntry
+	ordered []*idEntry
+}
+
+// indexIDs indexes the Folders, Placemarks, and Styles with ids in the tree.
+func indexIDs(root *Folder) *idIndex {
+	index := &idIndex{make(map[string]*idEntry), nil}
+
+	var walk func(f *Folder, parent *idEntry)
+	walk = func(f *Folder, parent *idEntry) {
+		for _, child := range f.features {
+			var entry *idEntry
+
+			switch c := child.(type) {
+			case *Folder:
+				entry = &idEntry{c.id, "Folder", c, parent}
+				if len(c.id) == 0 {
+					// id-less folders can't be targeted, but their
+					// children can
+					walk(c, entry)
+					continue
+				}
+			case *Placemark:
+				entry = &idEntry{c.id, "Placemark", c, parent}
+			case *Style:
+				entry = &idEntry{c.name, "Style", c, parent}
+			}
+
+			if entry == nil || len(entry.id) == 0 {
+				continue
+			}
+
+			if _, dup := index.entries[entry.id]; !dup {
+				index.entries[entry.id] = entry
+				index.ordered = append(index.ordered, entry)
+			}
+
+			if sub, ok := child.(*Folder); ok {
+				walk(sub, entry)
+			}
+		}
+	}
+	walk(root, nil)
+
+	return index
+}
+
+// compareFeatures compares two versions of a feature.  It returns a Change
+// if only the name or description differ, or replace == true if anything
+// else about the feature differs.  Folder contents are compared separately,
+// so only a Folder's own properties are compared.
+func compareFeatures(prev *idEntry, next *idEntry) (change *updateChange, replace bool) {
+	var name, desc string
+	var html bool
+
+	switch n := next.feature.(type) {
+	case *Folder:
+		p := prev.feature.(*Folder)
+		if renderFolderProperties(p, "", "") != renderFolderProperties(n, "", "") {
+			return nil, true
+		}
+		if p.name == n.name && p.description == n.description && p.html == n.html {
+			return nil, false
+		}
+		name, desc, html = n.name, n.description, n.html
+	case *Placemark:
+		p := prev.feature.(*Placemark)
+		pc, nc := *p, *n
+		pc.name, pc.description, pc.html = "", "", false
+		nc.name, nc.description, nc.html = "", "", false
+		if renderFeature(&pc) != renderFeature(&nc) {
+			return nil, true
+		}
+		if p.name == n.name && p.description == n.description && p.html == n.html {
+			return nil, false
+		}
+		name, desc, html = n.name, n.description, n.html
+	default:
+		return nil, renderFeature(prev.feature) != renderFeature(next.feature)
+	}
+
+	return &updateChange{next.target(), name, desc, html}, false
+}
+
+func renderFeature(feature renderable) string {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	e := newEncoder(buf, defaultRenderOptions())
+	feature.render(e)
+	e.flush()
+
+	return buf.String()
+}
+
+func renderFolderProperties(f *Folder, name string, desc string) string {
+	cp := *f
+	cp.name, cp.description, cp.html = name, desc, false
+	cp.features = nil
+
+	return renderFeature(&cp)
+}
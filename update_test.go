@@ -0,0 +1,187 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+)
+
+func deltaFixture() (*KML, *Folder, *Placemark) {
+	k := NewKML("Live")
+	k.SetID("doc")
+
+	f := NewFolder("Vehicles", "")
+	f.SetID("vehicles")
+	k.AddFeature(f)
+
+	pm := NewPlacemark("Truck 12", "", NewPoint(40.0, -105.0, 0.0))
+	pm.SetID("truck-12")
+	f.AddFeature(pm)
+
+	return k, f, pm
+}
+
+func TestDeltaUnchanged(t *testing.T) {
+	k, _, _ := deltaFixture()
+
+	u, err := Delta(k.Snapshot(), k.Snapshot(), "live.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !u.Empty() {
+		t.Errorf("update of identical documents not empty:\n%s", u.Render())
+	}
+}
+
+func TestDeltaCreateDelete(t *testing.T) {
+	k, f, pm := deltaFixture()
+	old := k.Snapshot()
+
+	removeFeature(k.rootFolder, pm)
+	added := NewPlacemark("Truck 13", "", NewPoint(39.0, -104.0, 0.0))
+	added.SetID("truck-13")
+	f.AddFeature(added)
+
+	u, err := Delta(old, k.Snapshot(), "live.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := unindent(u.Render())
+	for _, want := range []string{
+		"<targetHref>live.kml</targetHref>",
+		`<Delete>
+<Placemark targetId="truck-12"></Placemark>
+</Delete>`,
+		`<Create>
+<Folder targetId="vehicles">
+<Placemark id="truck-13">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("update missing %q:\n%s", want, out)
+		}
+	}
+
+	if strings.Contains(out, "<Change>") {
+		t.Errorf("unexpected Change:\n%s", out)
+	}
+}
+
+func TestDeltaRenderOptions(t *testing.T) {
+	k, f, _ := deltaFixture()
+	old := k.Snapshot()
+
+	added := NewPlacemark("Truck 13", "", NewPoint(39.123456, -104.123456, 0.0))
+	added.SetID("truck-13")
+	f.AddFeature(added)
+	k.SetPrecision(2, 1)
+
+	u, err := Delta(old, k.Snapshot(), "live.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out := u.Render(); !strings.Contains(out, "<coordinates>-104.12,39.12,0.0</coordinates>") {
+		t.Errorf("update not rendered with the precision of the new document:\n%s", out)
+	}
+
+	if out := NewUpdate("live.kml").Render(); !strings.Contains(out, "\n  <NetworkLinkControl>") {
+		t.Errorf("update without a document not rendered with the default options:\n%s", out)
+	}
+}
+
+func TestDeltaChange(t *testing.T) {
+	k, _, pm := deltaFixture()
+	old := k.Snapshot()
+
+	pm.name = "Truck 12 (late)"
+
+	u, err := Delta(old, k.Snapshot(), "live.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := unindent(u.Render())
+	if !strings.Contains(out, `<Placemark targetId="truck-12">
+<name>Truck 12 (late)</name>`) {
+		t.Errorf("name change not sent as a Change:\n%s", out)
+	}
+
+	if strings.Contains(out, "<Create>") || strings.Contains(out, "<Delete>") {
+		t.Errorf("name change replaced the placemark:\n%s", out)
+	}
+}
+
+func TestDeltaReplace(t *testing.T) {
+	k, _, pm := deltaFixture()
+	old := k.Snapshot()
+
+	pm.geometry.(*Point).Lat = 41.0
+
+	u, err := Delta(old, k.Snapshot(), "live.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := unindent(u.Render())
+	if !strings.Contains(out, `<Placemark targetId="truck-12"></Placemark>`) ||
+		!strings.Contains(out, `<Placemark id="truck-12">`) {
+		t.Errorf("moved placemark not replaced:\n%s", out)
+	}
+}
+
+func TestDeltaCreateFolder(t *testing.T) {
+	k, _, _ := deltaFixture()
+	old := k.Snapshot()
+
+	f := NewFolder("Trailers", "")
+	f.SetID("trailers")
+	pm := NewPlacemark("Trailer 1", "", NewPoint(40.0, -105.0, 0.0))
+	pm.SetID("trailer-1")
+	f.AddFeature(pm)
+	k.AddFeature(f)
+
+	u, err := Delta(old, k.Snapshot(), "live.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := u.Render()
+	if strings.Count(out, "<Create>") != 1 || !strings.Contains(out, `<Document targetId="doc">`) {
+		t.Errorf("folder not created once in the document:\n%s", out)
+	}
+}
+
+func TestDeltaParentWithoutID(t *testing.T) {
+	k := NewKML("Live")
+	old := k.Snapshot()
+
+	pm := NewPlacemark("Truck 12", "", NewPoint(40.0, -105.0, 0.0))
+	pm.SetID("truck-12")
+	k.AddFeature(pm)
+
+	if _, err := Delta(old, k, "live.kml"); err == nil {
+		t.Error("no error creating a placemark in a document without an id")
+	}
+}
+
+func TestUpdateNamespaces(t *testing.T) {
+	k, f, _ := deltaFixture()
+	old := k.Snapshot()
+
+	line := NewLineString()
+	line.SetDrawOrder(1)
+	pm := NewPlacemark("Route", "", line)
+	pm.SetID("route")
+	f.AddFeature(pm)
+
+	u, err := Delta(old, k.Snapshot(), "live.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := u.Render()
+	if !strings.Contains(out, "xmlns:gx=") || strings.Contains(out, "xmlns:atom=") {
+		t.Errorf("update should declare only the gx namespace:\n%s", out)
+	}
+}
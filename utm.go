@@ -0,0 +1,215 @@
+package gokml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// utmScale is the scale factor on the central meridian of a UTM zone.
+const utmScale = 0.9996
+
+// utmFalseNorthing is added to the northing of Points in the southern
+// hemisphere, so it isn't negative.
+const utmFalseNorthing = 10000000
+
+// UTM represents a position in the Universal Transverse Mercator grid on
+// WGS84, as used for survey and engineering data.
+type UTM struct {
+	Zone     int     // 1 to 60, each 6 degrees of longitude wide
+	North    bool    // northern hemisphere
+	Easting  float64 // meters, 500000 on the central meridian
+	Northing float64 // meters from the equator, plus 10000000 in the south
+}
+
+// UTM returns the UTM coordinates of the Point, in its zone, including the
+// exceptions for Norway and Svalbard.  It returns false for Points outside
+// the UTM grid, north of 84 or south of 80 degrees.
+func (p *Point) UTM() (UTM, bool) {
+	if !(p.Lat >= -80 && p.Lat <= 84) {
+		return UTM{}, false
+	}
+
+	lon := normalizeLon(p.Lon)
+	zone := int(math.Floor((lon+180)/6)) + 1
+
+	// wider zones for Norway and Svalbard
+	if p.Lat >= 56 && p.Lat < 64 && lon >= 3 && lon < 12 {
+		zone = 32
+	} else if p.Lat >= 72 {
+		switch {
+		case lon >= 0 && lon < 9:
+			zone = 31
+		case lon >= 9 && lon < 21:
+			zone = 33
+		case lon >= 21 && lon < 33:
+			zone = 35
+		case lon >= 33 && lon < 42:
+			zone = 37
+		}
+	}
+
+	return utmInZone(p, zone), true
+}
+
+// utmInZone returns the UTM coordinates of a Point in a zone (the
+// transverse Mercator projection by Krüger's series, accurate to within a
+// millimeter near the zone).
+func utmInZone(p *Point, zone int) UTM {
+	e := wgs84
+	n := e.f / (2 - e.f)
+	ecc := math.Sqrt(e.f * (2 - e.f))
+	alpha := e.kruger(utmAlpha)
+
+	lat := radians(p.Lat)
+	lon := radians(normalizeLon(p.Lon - float64(6*zone-183)))
+
+	// the conformal latitude
+	tau := math.Tan(lat)
+	sigma := math.Sinh(ecc * math.Atanh(ecc*tau/math.Sqrt(1+tau*tau)))
+	tauC := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+
+	sinLon, cosLon := math.Sincos(lon)
+	xiC := math.Atan2(tauC, cosLon)
+	etaC := math.Asinh(sinLon / math.Sqrt(tauC*tauC+cosLon*cosLon))
+
+	xi, eta := xiC, etaC
+	for j := 1; j <= len(alpha); j++ {
+		k := 2 * float64(j)
+		xi += alpha[j-1] * math.Sin(k*xiC) * math.Cosh(k*etaC)
+		eta += alpha[j-1] * math.Cos(k*xiC) * math.Sinh(k*etaC)
+	}
+
+	a := utmScale * e.a / (1 + n) * (1 + n*n/4 + math.Pow(n, 4)/64 + math.Pow(n, 6)/256)
+	u := UTM{zone, p.Lat >= 0, 500000 + a*eta, a * xi}
+	if !u.North {
+		u.Northing += utmFalseNorthing
+	}
+
+	return u
+}
+
+// Point returns the Point at the UTM coordinates, with no altitude.  It
+// returns an error if the zone isn't from 1 to 60.
+func (u UTM) Point() (*Point, error) {
+	if u.Zone < 1 || u.Zone > 60 {
+		return nil, fmt.Errorf("gokml: invalid UTM zone %d", u.Zone)
+	}
+
+	e := wgs84
+	n := e.f / (2 - e.f)
+	ecc := math.Sqrt(e.f * (2 - e.f))
+	beta := e.kruger(utmBeta)
+
+	northing := u.Northing
+	if !u.North {
+		northing -= utmFalseNorthing
+	}
+
+	a := utmScale * e.a / (1 + n) * (1 + n*n/4 + math.Pow(n, 4)/64 + math.Pow(n, 6)/256)
+	xi, eta := northing/a, (u.Easting-500000)/a
+
+	xiC, etaC := xi, eta
+	for j := 1; j <= len(beta); j++ {
+		k := 2 * float64(j)
+		xiC -= beta[j-1] * math.Sin(k*xi) * math.Cosh(k*eta)
+		etaC -= beta[j-1] * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+
+	sinhEta := math.Sinh(etaC)
+	sinXi, cosXi := math.Sincos(xiC)
+	tauC := sinXi / math.Sqrt(sinhEta*sinhEta+cosXi*cosXi)
+
+	// the latitude from the conformal latitude, by Newton's method
+	tau := tauC
+	for i := 0; i < 20; i++ {
+		sigma := math.Sinh(ecc * math.Atanh(ecc*tau/math.Sqrt(1+tau*tau)))
+		t := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+		delta := (tauC - t) / math.Sqrt(1+t*t) * (1 + (1-ecc*ecc)*tau*tau) / ((1 - ecc*ecc) * math.Sqrt(1+tau*tau))
+		tau += delta
+		if math.Abs(delta) < 1e-12 {
+			break
+		}
+	}
+
+	lon := degrees(math.Atan2(sinhEta, cosXi)) + float64(6*u.Zone-183)
+
+	return &Point{degrees(math.Atan(tau)), normalizeLon(lon), 0}, nil
+}
+
+// String returns the UTM coordinates as the zone and hemisphere, then the
+// easting and northing in meters, such as "31N 448251.795 5411932.678".
+func (u UTM) String() string {
+	hemisphere := "S"
+	if u.North {
+		hemisphere = "N"
+	}
+
+	return fmt.Sprintf("%d%s %.3f %.3f", u.Zone, hemisphere, u.Easting, u.Northing)
+}
+
+// ParseUTM parses UTM coordinates written as by UTM.String, with the zone
+// and hemisphere (N or S) optionally apart, such as "31 N 448252 5411933".
+func ParseUTM(s string) (UTM, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 4 {
+		fields = append([]string{fields[0] + fields[1]}, fields[2:]...)
+	}
+	if len(fields) != 3 || len(fields[0]) < 2 {
+		return UTM{}, fmt.Errorf("gokml: invalid UTM coordinates %q", s)
+	}
+
+	var u UTM
+	zone, hemisphere := fields[0][:len(fields[0])-1], strings.ToUpper(fields[0][len(fields[0])-1:])
+	z, err := strconv.Atoi(zone)
+	if err != nil || z < 1 || z > 60 || (hemisphere != "N" && hemisphere != "S") {
+		return UTM{}, fmt.Errorf("gokml: invalid UTM zone %q", fields[0])
+	}
+	u.Zone, u.North = z, hemisphere == "N"
+
+	if u.Easting, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return UTM{}, fmt.Errorf("gokml: invalid UTM easting %q", fields[1])
+	}
+	if u.Northing, err = strconv.ParseFloat(fields[2], 64); err != nil {
+		return UTM{}, fmt.Errorf("gokml: invalid UTM northing %q", fields[2])
+	}
+
+	return u, nil
+}
+
+// utmAlpha and utmBeta are the coefficients of Krüger's series for the
+// transverse Mercator projection and its inverse, as polynomials in the
+// third flattening n from n to n^6.
+var (
+	utmAlpha = [6][6]float64{
+		{1.0 / 2, -2.0 / 3, 5.0 / 16, 41.0 / 180, -127.0 / 288, 7891.0 / 37800},
+		{0, 13.0 / 48, -3.0 / 5, 557.0 / 1440, 281.0 / 630, -1983433.0 / 1935360},
+		{0, 0, 61.0 / 240, -103.0 / 140, 15061.0 / 26880, 167603.0 / 181440},
+		{0, 0, 0, 49561.0 / 161280, -179.0 / 168, 6601661.0 / 7257600},
+		{0, 0, 0, 0, 34729.0 / 80640, -3418889.0 / 1995840},
+		{0, 0, 0, 0, 0, 212378941.0 / 319334400},
+	}
+	utmBeta = [6][6]float64{
+		{1.0 / 2, -2.0 / 3, 37.0 / 96, -1.0 / 360, -81.0 / 512, 96199.0 / 604800},
+		{0, 1.0 / 48, 1.0 / 15, -437.0 / 1440, 46.0 / 105, -1118711.0 / 3870720},
+		{0, 0, 17.0 / 480, -37.0 / 840, -209.0 / 4480, 5569.0 / 90720},
+		{0, 0, 0, 4397.0 / 161280, -11.0 / 504, -830251.0 / 7257600},
+		{0, 0, 0, 0, 4583.0 / 161280, -108847.0 / 3991680},
+		{0, 0, 0, 0, 0, 20648693.0 / 638668800},
+	}
+)
+
+// kruger returns the coefficients of Krüger's series for the ellipsoid.
+func (e ellipsoid) kruger(series [6][6]float64) [6]float64 {
+	n := e.f / (2 - e.f)
+
+	var coefficients [6]float64
+	for j, poly := range series {
+		for k, c := range poly {
+			coefficients[j] += c * math.Pow(n, float64(k+1))
+		}
+	}
+
+	return coefficients
+}
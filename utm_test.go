@@ -0,0 +1,59 @@
+package gokml
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPointUTM(t *testing.T) {
+	tests := []struct {
+		lat, lon float64
+		want     UTM
+	}{
+		{0, 0, UTM{31, true, 166021.443, 0}},
+		{0, 3, UTM{31, true, 500000, 0}},
+		{48.8582, 2.2945, UTM{31, true, 448251.795, 5411932.678}},
+		{-33.8568, 151.2153, UTM{56, false, 0, 0}},
+		{60, 5, UTM{32, true, 0, 0}},  // Norway
+		{78, 15, UTM{33, true, 0, 0}}, // Svalbard
+	}
+	for _, test := range tests {
+		u, ok := NewPoint(test.lat, test.lon, 0).UTM()
+		if !ok || u.Zone != test.want.Zone || u.North != test.want.North {
+			t.Errorf("(%v, %v) is in zone %v, want %v", test.lat, test.lon, u, test.want)
+			continue
+		}
+		if test.want.Easting != 0 && (math.Abs(u.Easting-test.want.Easting) > 0.01 || math.Abs(u.Northing-test.want.Northing) > 0.01) {
+			t.Errorf("(%v, %v) = %v, want %v", test.lat, test.lon, u, test.want)
+		}
+
+		p, err := u.Point()
+		if err != nil || math.Abs(p.Lat-test.lat) > 1e-9 || math.Abs(p.Lon-test.lon) > 1e-9 {
+			t.Errorf("%v is at %v, %v, want (%v, %v)", u, p, err, test.lat, test.lon)
+		}
+	}
+
+	if _, ok := NewPoint(85, 0, 0).UTM(); ok {
+		t.Errorf("point north of the grid converted")
+	}
+	if _, err := (UTM{0, true, 500000, 0}).Point(); err == nil {
+		t.Errorf("zone 0 converted")
+	}
+}
+
+func TestParseUTM(t *testing.T) {
+	u := UTM{56, false, 334873.893, 6252266.412}
+	if s := u.String(); s != "56S 334873.893 6252266.412" {
+		t.Errorf("String() = %q", s)
+	}
+	for _, s := range []string{"56S 334873.893 6252266.412", "56 s 334873.893 6252266.412"} {
+		if got, err := ParseUTM(s); err != nil || got != u {
+			t.Errorf("ParseUTM(%q) = %v, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"", "56 334873 6252266", "61N 1 2", "56X 1 2", "56N east 2", "56N 1 north"} {
+		if _, err := ParseUTM(s); err == nil {
+			t.Errorf("ParseUTM(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package gokml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValidationError describes a place where a document doesn't follow the
+// KML 2.2 schema.
+type ValidationError struct {
+	Line    int    // line of the element in the document
+	Element string // path of the element, e.g. kml/Document/Placemark[2]/name
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Element, e.Message)
+}
+
+// ValidationErrors is the error returned by Validate for an invalid
+// document.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+
+	return "gokml: invalid KML:\n" + strings.Join(msgs, "\n")
+}
+
+// Validate checks the KML document read from r against the rules of the
+// OGC KML 2.2 schema: the order of elements, elements that may only appear
+// once, and the ranges and formats of values (coordinates, angles, colors,
+// enumerations, times, etc.).  It returns ValidationErrors listing every
+// problem found, or an error if the document isn't well-formed XML.
+// Elements in other namespaces, and the contents of elements this package
+// doesn't know, aren't checked.
+func Validate(r io.Reader) error {
+	v := &validator{d: xml.NewDecoder(r)}
+
+	if err := v.run(); err != nil {
+		return err
+	}
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+
+	return nil
+}
+
+// Validate renders the document and checks it like the Validate function.
+// Line numbers refer to the rendered document.
+func (k *KML) Validate() error {
+	var buf bytes.Buffer
+	if err := k.write(&buf); err != nil {
+		return err
+	}
+
+	return Validate(&buf)
+}
+
+// schemaGroup is a set of elements that may appear in any order at the same
+// position in their parent.
+type schemaGroup struct {
+	names []string
+	many  bool // the elements may be repeated
+}
+
+func one(names ...string) schemaGroup  { return schemaGroup{names, false} }
+func many(names ...string) schemaGroup { return schemaGroup{names, true} }
+
+// schemaOrder lists the groups of child elements of each element, in the
+// order the schema requires.  gx and atom elements are prefixed.
+var schemaOrder = map[string][]schemaGroup{}
+
+var (
+	featureNames  = []string{"Document", "Folder", "Placemark", "NetworkLink", "GroundOverlay", "ScreenOverlay", "PhotoOverlay", "gx:Tour"}
+	geometryNames = []string{"Point", "LineString", "LinearRing", "Polygon", "MultiGeometry", "Model", "gx:Track", "gx:MultiTrack"}
+	altitudeModes = []string{"altitudeMode", "gx:altitudeMode"}
+	linkGroups    = []schemaGroup{one("href"), one("refreshMode"), one("refreshInterval"), one("viewRefreshMode"), one("viewRefreshTime"), one("viewBoundScale"), one("viewFormat"), one("httpQuery")}
+)
+
+func init() {
+	feature := []schemaGroup{
+		one("name"), one("visibility"), one("open"), one("atom:author"), one("atom:link"),
+		one("address"), one("phoneNumber"), one("Snippet", "snippet"), one("description"),
+		one("LookAt", "Camera"), one("TimeStamp", "TimeSpan"), one("styleUrl"),
+		many("Style", "StyleMap"), one("Region"), one("Metadata", "ExtendedData"),
+		one("gx:balloonVisibility"),
+	}
+	withFeature := func(groups ...schemaGroup) []schemaGroup {
+		return append(append([]schemaGroup(nil), feature...), groups...)
+	}
+
+	schemaOrder["kml"] = []schemaGroup{one("NetworkLinkControl"), one(featureNames...)}
+	schemaOrder["Document"] = withFeature(many("Schema"), many(featureNames...))
+	schemaOrder["Folder"] = withFeature(many(featureNames...))
+	schemaOrder["Placemark"] = withFeature(one(geometryNames...))
+	schemaOrder["NetworkLink"] = withFeature(one("refreshVisibility"), one("flyToView"), one("Link", "Url"))
+	schemaOrder["GroundOverlay"] = withFeature(one("color"), one("drawOrder"), one("Icon"), one("altitude"), one(altitudeModes...), one("LatLonBox"), one("gx:LatLonQuad"))
+
+	schemaOrder["Style"] = []schemaGroup{one("IconStyle"), one("LabelStyle"), one("LineStyle"), one("PolyStyle"), one("BalloonStyle"), one("ListStyle")}
+	schemaOrder["IconStyle"] = []schemaGroup{one("color"), one("colorMode"), one("scale"), one("heading"), one("Icon"), one("hotSpot")}
+	schemaOrder["LabelStyle"] = []schemaGroup{one("color"), one("colorMode"), one("scale")}
+	schemaOrder["LineStyle"] = []schemaGroup{one("color"), one("colorMode"), one("width"), one("gx:outerColor"), one("gx:outerWidth"), one("gx:physicalWidth"), one("gx:labelVisibility")}
+	schemaOrder["PolyStyle"] = []schemaGroup{one("color"), one("colorMode"), one("fill"), one("outline")}
+
+	schemaOrder["Point"] = []schemaGroup{one("extrude"), one(altitudeModes...), one("coordinates")}
+	schemaOrder["LineString"] = []schemaGroup{one("extrude"), one("tessellate"), one(altitudeModes...), one("coordinates"), one("gx:altitudeOffset"), one("gx:drawOrder")}
+	schemaOrder["LinearRing"] = []schemaGroup{one("extrude"), one("tessellate"), one(altitudeModes...), one("coordinates"), one("gx:altitudeOffset")}
+	schemaOrder["Polygon"] = []schemaGroup{one("extrude"), one("tessellate"), one(altitudeModes...), one("outerBoundaryIs"), many("innerBoundaryIs")}
+	schemaOrder["outerBoundaryIs"] = []schemaGroup{one("LinearRing")}
+	schemaOrder["innerBoundaryIs"] = []schemaGroup{one("LinearRing")}
+	schemaOrder["MultiGeometry"] = []schemaGroup{many(geometryNames...)}
+	schemaOrder["gx:Track"] = []schemaGroup{one(altitudeModes...), many("when"), many("gx:coord"), many("gx:angles"), one("Model"), one("ExtendedData")}
+
+	schemaOrder["LookAt"] = []schemaGroup{one("gx:TimeStamp", "gx:TimeSpan"), one("longitude"), one("latitude"), one("altitude"), one("heading"), one("tilt"), one("range"), one(altitudeModes...)}
+	schemaOrder["TimeSpan"] = []schemaGroup{one("begin"), one("end")}
+	schemaOrder["TimeStamp"] = []schemaGroup{one("when")}
+	schemaOrder["Region"] = []schemaGroup{one("LatLonAltBox"), one("Lod")}
+	schemaOrder["LatLonAltBox"] = []schemaGroup{one("north"), one("south"), one("east"), one("west"), one("minAltitude"), one("maxAltitude"), one(altitudeModes...)}
+	schemaOrder["LatLonBox"] = []schemaGroup{one("north"), one("south"), one("east"), one("west"), one("rotation")}
+	schemaOrder["Link"] = linkGroups
+	schemaOrder["Url"] = linkGroups
+	schemaOrder["Icon"] = linkGroups
+	schemaOrder["ExtendedData"] = []schemaGroup{many("Data", "SchemaData")}
+	schemaOrder["Data"] = []schemaGroup{one("displayName"), one("value")}
+	schemaOrder["atom:author"] = []schemaGroup{one("atom:name"), one("atom:uri"), one("atom:email")}
+
+	schemaOrder["NetworkLinkControl"] = []schemaGroup{
+		one("minRefreshPeriod"), one("maxSessionLength"), one("cookie"), one("message"),
+		one("linkName"), one("linkDescription"), one("linkSnippet"), one("expires"),
+		one("Update"), one("LookAt", "Camera"),
+	}
+	schemaOrder["Update"] = []schemaGroup{one("targetHref"), many("Create", "Delete", "Change")}
+}
+
+// schemaValues checks the text of leaf elements.  Each returns a problem
+// description, or an empty string if the value is valid.
+var schemaValues = map[string]func(string) string{
+	"latitude":             floatRange(-90, 90),
+	"north":                floatRange(-90, 90),
+	"south":                floatRange(-90, 90),
+	"longitude":            floatRange(-180, 180),
+	"east":                 floatRange(-180, 180),
+	"west":                 floatRange(-180, 180),
+	"rotation":             floatRange(-180, 180),
+	"heading":              floatRange(-360, 360),
+	"tilt":                 floatRange(0, 180),
+	"range":                floatRange(0, 1e300),
+	"altitude":             floatRange(-1e300, 1e300),
+	"scale":                floatRange(-1e300, 1e300),
+	"width":                floatRange(0, 1e300),
+	"refreshInterval":      floatRange(0, 1e300),
+	"minRefreshPeriod":     floatRange(0, 1e300),
+	"drawOrder":            isInt,
+	"gx:drawOrder":         isInt,
+	"visibility":           isBool,
+	"open":                 isBool,
+	"extrude":              isBool,
+	"tessellate":           isBool,
+	"fill":                 isBool,
+	"outline":              isBool,
+	"flyToView":            isBool,
+	"refreshVisibility":    isBool,
+	"gx:balloonVisibility": isBool,
+	"color":                isColor,
+	"colorMode":            oneOf("normal", "random"),
+	"altitudeMode":         oneOf("clampToGround", "relativeToGround", "absolute"),
+	"gx:altitudeMode":      oneOf("clampToSeaFloor", "relativeToSeaFloor"),
+	"refreshMode":          oneOf("onChange", "onInterval", "onExpire"),
+	"viewRefreshMode":      oneOf("never", "onRequest", "onStop", "onRegion"),
+	"begin":                isDateTime,
+	"end":                  isDateTime,
+	"when":                 isDateTime,
+	"coordinates":          isCoordinates,
+}
+
+func floatRange(min float64, max float64) func(string) string {
+	return func(s string) string {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Sprintf("%q is not a number", s)
+		}
+		if v < min || v > max {
+			return fmt.Sprintf("%s is out of range [%g, %g]", s, min, max)
+		}
+		return ""
+	}
+}
+
+func isInt(s string) string {
+	if _, err := strconv.Atoi(s); err != nil {
+		return fmt.Sprintf("%q is not an integer", s)
+	}
+	return ""
+}
+
+func isBool(s string) string {
+	return oneOf("0", "1", "true", "false")(s)
+}
+
+var colorPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}$`)
+
+func isColor(s string) string {
+	if !colorPattern.MatchString(s) {
+		return fmt.Sprintf("%q is not an aabbggrr color", s)
+	}
+	return ""
+}
+
+func oneOf(values ...string) func(string) string {
+	return func(s string) string {
+		for _, v := range values {
+			if s == v {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%q is not one of %s", s, strings.Join(values, ", "))
+	}
+}
+
+func isDateTime(s string) string {
+	if _, err := parseTime(s); err != nil {
+		return fmt.Sprintf("%q is not a dateTime", s)
+	}
+	return ""
+}
+
+func isCoordinates(s string) string {
+	for _, tuple := range strings.Fields(s) {
+		parts := strings.Split(tuple, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Sprintf("%q is not a lon,lat[,alt] tuple", tuple)
+		}
+
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return fmt.Sprintf("%q is not a lon,lat[,alt] tuple", tuple)
+			}
+			if i == 0 && (v < -180 || v > 180) || i == 1 && (v < -90 || v > 90) {
+				return fmt.Sprintf("%q is out of range", tuple)
+			}
+		}
+	}
+	return ""
+}
+
+// validatorFrame is an open element.
+type validatorFrame struct {
+	name   string // prefixed name
+	path   string
+	line   int
+	rank   int            // schema group of the last child, -1 before the first
+	counts map[string]int // number of each child element, for paths
+	text   strings.Builder
+	points int // coordinate tuples, for rings and lines
+}
+
+type validator struct {
+	d     *xml.Decoder
+	stack []*validatorFrame
+	skip  int // depth within an element that isn't checked
+	errs  ValidationErrors
+}
+
+func (v *validator) errorf(f *validatorFrame, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{f.line, f.path, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) run() error {
+	for {
+		line, _ := v.d.InputPos()
+		tok, err := v.d.Token()
+		if err == io.EOF {
+			if len(v.stack) > 0 || v.skip > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			v.start(t, line)
+		case xml.CharData:
+			if v.skip == 0 && len(v.stack) > 0 {
+				v.stack[len(v.stack)-1].text.Write(t)
+			}
+		case xml.EndElement:
+			v.end()
+		}
+	}
+}
+
+// schemaName returns the prefixed name of an element, or an empty string if
+// it isn't in the KML, gx, or atom namespace.
+func schemaName(name xml.Name) string {
+	switch name.Space {
+	case kmlNamespace, "":
+		return name.Local
+	case gxNamespace:
+		return "gx:" + name.Local
+	case atomNamespace:
+		return "atom:" + name.Local
+	}
+
+	return ""
+}
+
+func (v *validator) start(t xml.StartElement, line int) {
+	if v.skip > 0 {
+		v.skip++
+		return
+	}
+
+	name := schemaName(t.Name)
+	f := &validatorFrame{name: name, line: line, rank: -1, counts: make(map[string]int)}
+
+	if len(v.stack) == 0 {
+		f.path = t.Name.Local
+		if t.Name.Local != "kml" || t.Name.Space != kmlNamespace {
+			v.errorf(f, "root element must be kml in the %s namespace", kmlNamespace)
+		}
+		v.stack = append(v.stack, f)
+		return
+	}
+
+	parent := v.stack[len(v.stack)-1]
+	parent.counts[t.Name.Local]++
+	f.path = fmt.Sprintf("%s/%s", parent.path, t.Name.Local)
+	if n := parent.counts[t.Name.Local]; n > 1 {
+		f.path = fmt.Sprintf("%s[%d]", f.path, n)
+	}
+
+	if len(name) == 0 {
+		// other namespaces aren't checked
+		v.skip = 1
+		return
+	}
+
+	v.checkPosition(parent, f)
+	v.checkAttrs(t, f)
+
+	if _, ok := schemaOrder[name]; !ok && schemaValues[name] == nil {
+		// contents of elements without rules aren't checked
+		v.skip = 1
+		return
+	}
+
+	v.stack = append(v.stack, f)
+}
+
+// checkPosition checks that the child f is allowed in parent, in order, and
+// not repeated if it may only appear once.
+func (v *validator) checkPosition(parent *validatorFrame, f *validatorFrame) {
+	groups, ok := schemaOrder[parent.name]
+	if !ok {
+		return
+	}
+
+	for rank, g := range groups {
+		for _, name := range g.names {
+			if name != f.name {
+				continue
+			}
+
+			switch {
+			case rank < parent.rank:
+				v.errorf(f, "%s must come before %s in %s", f.name, strings.Join(groups[parent.rank].names, "/"), parent.name)
+			case rank == parent.rank && !g.many:
+				v.errorf(f, "%s may only appear once in %s", strings.Join(g.names, "/"), parent.name)
+			default:
+				parent.rank = rank
+			}
+			return
+		}
+	}
+
+	v.errorf(f, "%s is not allowed in %s", f.name, parent.name)
+}
+
+func (v *validator) checkAttrs(t xml.StartElement, f *validatorFrame) {
+	for _, a := range t.Attr {
+		if a.Name.Local == "id" && len(a.Name.Space) == 0 && !isXMLName(a.Value) {
+			v.errorf(f, "id %q is not a valid XML name", a.Value)
+		}
+	}
+
+	if f.name == "Data" {
+		for _, a := range t.Attr {
+			if a.Name.Local == "name" {
+				return
+			}
+		}
+		v.errorf(f, "Data must have a name attribute")
+	}
+}
+
+func (v *validator) end() {
+	if v.skip > 0 {
+		v.skip--
+		return
+	}
+
+	f := v.stack[len(v.stack)-1]
+	v.stack = v.stack[:len(v.stack)-1]
+
+	text := strings.TrimSpace(f.text.String())
+	if check := schemaValues[f.name]; check != nil && len(text) > 0 {
+		if msg := check(text); len(msg) > 0 {
+			v.errorf(f, "%s", msg)
+		}
+	}
+
+	if f.name == "coordinates" && len(v.stack) > 0 {
+		parent := v.stack[len(v.stack)-1]
+		parent.points = len(strings.Fields(text))
+
+		switch {
+		case parent.name == "LineString" && parent.points < 2:
+			v.errorf(f, "a LineString needs at least 2 coordinates")
+		case parent.name == "LinearRing" && parent.points < 4:
+			v.errorf(f, "a LinearRing needs at least 4 coordinates")
+		case parent.name == "LinearRing" && !ringClosed(text):
+			v.errorf(f, "a LinearRing must be closed (the last coordinate must repeat the first)")
+		}
+	}
+}
+
+func ringClosed(coordinates string) bool {
+	tuples := strings.Fields(coordinates)
+	if len(tuples) == 0 {
+		return false
+	}
+
+	first := parseCoordinates(tuples[0])
+	last := parseCoordinates(tuples[len(tuples)-1])
+
+	return len(first) == 1 && len(last) == 1 && *first[0] == *last[0]
+}
+
+// isXMLName reports whether s is a valid XML name (NCName), as ids must be.
+func isXMLName(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+
+	for i, r := range s {
+		letter := r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f
+		if i == 0 && !letter {
+			return false
+		}
+		if !letter && !(r >= '0' && r <= '9') && r != '-' && r != '.' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,79 @@
+package gokml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRendered(t *testing.T) {
+	k := parseFixture()
+
+	if err := k.Validate(); err != nil {
+		t.Errorf("rendered document is invalid: %v", err)
+	}
+
+	k.SetStableOutput(StableOutput1)
+	if err := k.Validate(); err == nil {
+		t.Error("StableOutput1 document passed validation")
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:x="urn:example">
+<Document id="1doc">
+<description>late name</description>
+<name>Bad</name>
+<x:note>skipped <b>entirely</b></x:note>
+<Placemark>
+<name>One</name>
+<name>Two</name>
+<Point><coordinates>200,10</coordinates></Point>
+</Placemark>
+<Style id="s"><LineStyle><color>red</color></LineStyle></Style>
+<Placemark>
+<Polygon><outerBoundaryIs><LinearRing><coordinates>0,0 1,0 1,1 0,1</coordinates></LinearRing></outerBoundaryIs></Polygon>
+<ExtendedData><Data><value>1</value></Data></ExtendedData>
+</Placemark>
+<Bogus/>
+</Document>
+</kml>`
+
+	err := Validate(strings.NewReader(doc))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("got %v, want ValidationErrors", err)
+	}
+
+	want := []ValidationError{
+		{3, "kml/Document", `id "1doc" is not a valid XML name`},
+		{5, "kml/Document/name", "name must come before description in Document"},
+		{9, "kml/Document/Placemark/name[2]", "name may only appear once in Placemark"},
+		{10, "kml/Document/Placemark/Point/coordinates", `"200,10" is out of range`},
+		{12, "kml/Document/Style", "Style must come before Document/Folder/Placemark/NetworkLink/GroundOverlay/ScreenOverlay/PhotoOverlay/gx:Tour in Document"},
+		{12, "kml/Document/Style/LineStyle/color", `"red" is not an aabbggrr color`},
+		{14, "kml/Document/Placemark[2]/Polygon/outerBoundaryIs/LinearRing/coordinates", "a LinearRing must be closed (the last coordinate must repeat the first)"},
+		{15, "kml/Document/Placemark[2]/ExtendedData", "ExtendedData must come before Point/LineString/LinearRing/Polygon/MultiGeometry/Model/gx:Track/gx:MultiTrack in Placemark"},
+		{15, "kml/Document/Placemark[2]/ExtendedData/Data", "Data must have a name attribute"},
+		{17, "kml/Document/Bogus", "Bogus is not allowed in Document"},
+	}
+
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d", len(errs), len(want))
+	}
+	for i, e := range errs {
+		if *e != want[i] {
+			t.Errorf("error %d = %v, want %v", i, e, &want[i])
+		}
+	}
+}
+
+func TestValidateMalformed(t *testing.T) {
+	err := Validate(strings.NewReader(`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`))
+	if err == nil {
+		t.Fatal("no error for truncated document")
+	}
+	if _, ok := err.(ValidationErrors); ok {
+		t.Errorf("got ValidationErrors for malformed XML: %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package gokml
+
+// walkPlacemarks calls fn for every Placemark in the feature tree.
+func walkPlacemarks(feature renderable, fn func(*Placemark)) {
+	switch f := feature.(type) {
+	case *Folder:
+		for _, child := range f.features {
+			walkPlacemarks(child, fn)
+		}
+	case *Placemark:
+		fn(f)
+	}
+}
+
+// walkPoints calls fn for every Point of every geometry in the feature tree.
+func walkPoints(feature renderable, fn func(*Point)) {
+	switch f := feature.(type) {
+	case *Folder:
+		for _, child := range f.features {
+			walkPoints(child, fn)
+		}
+	case *Placemark:
+		walkPoints(f.geometry, fn)
+	case *Point:
+		fn(f)
+	case *LineString:
+		for _, p := range f.coordinates {
+			fn(p)
+		}
+	case *Polygon:
+		for _, p := range f.points {
+			fn(p)
+		}
+		for _, ring := range f.inner {
+			for _, p := range ring {
+				fn(p)
+			}
+		}
+	case *MultiGeometry:
+		for _, geom := range f.geometries {
+			walkPoints(geom, fn)
+		}
+	case *Track:
+		for _, p := range f.points {
+			fn(p)
+		}
+	}
+}
+
+// walkStyles calls fn for every Style in the feature tree.
+func walkStyles(feature renderable, fn func(*Style)) {
+	switch f := feature.(type) {
+	case *Folder:
+		for _, child := range f.features {
+			walkStyles(child, fn)
+		}
+	case *Style:
+		fn(f)
+	}
+}
+
+// walkExtras calls fn for every element, at any depth, kept from a parsed
+// document in the feature tree.
+func walkExtras(feature renderable, fn func(*node)) {
+	if x := extraOf(feature); x != nil {
+		for _, n := range x.nodes {
+			walkNode(n, fn)
+		}
+	}
+
+	if f, ok := feature.(*Folder); ok {
+		for _, child := range f.features {
+			walkExtras(child, fn)
+		}
+	}
+}
+
+func walkNode(n *node, fn func(*node)) {
+	fn(n)
+	for _, c := range n.children {
+		walkNode(c, fn)
+	}
+}
+
+// collectStyles gathers every Style in the feature tree keyed by name.
+func collectStyles(feature renderable, styles map[string]*Style) {
+	walkStyles(feature, func(s *Style) { styles[s.name] = s })
+}
+
+// removeFeature removes feature from whichever Folder in the tree contains
+// it.  Returns false if the feature wasn't found.
+func removeFeature(folder *Folder, feature renderable) bool {
+	return replaceFeature(folder, feature, nil)
+}
+
+// replaceFeature replaces feature with replacement in whichever Folder in the
+// tree contains it, or removes it if replacement is nil.  Returns false if
+// the feature wasn't found.
+func replaceFeature(folder *Folder, feature renderable, replacement renderable) bool {
+	folder.mutex.Lock()
+	for i, child := range folder.features {
+		if child == feature {
+			if replacement == nil {
+				folder.features = append(folder.features[:i], folder.features[i+1:]...)
+			} else {
+				folder.features[i] = replacement
+			}
+			folder.mutex.Unlock()
+			return true
+		}
+	}
+	children := folder.features
+	folder.mutex.Unlock()
+
+	for _, child := range children {
+		if sub, ok := child.(*Folder); ok && replaceFeature(sub, feature, replacement) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// usesAtom reports whether anything in the feature tree renders atom
+// elements.
+func usesAtom(feature renderable) bool {
+	if extraOf(feature).uses(atomNamespace) {
+		return true
+	}
+
+	f, ok := feature.(*Folder)
+	if !ok {
+		return false
+	}
+
+	if len(f.author) > 0 || len(f.link) > 0 {
+		return true
+	}
+
+	for _, child := range f.features {
+		if usesAtom(child) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// walkHrefs calls fn for every href in the feature tree (icons, NetworkLinks,
+// etc.), including those kept from a parsed document, such as of Models.
+func walkHrefs(feature renderable, fn func(string)) {
+	if x := extraOf(feature); x != nil {
+		for _, n := range x.nodes {
+			walkNode(n, func(n *node) {
+				if n.name.Local == "href" {
+					fn(n.text)
+				}
+			})
+		}
+	}
+
+	switch f := feature.(type) {
+	case *Folder:
+		for _, child := range f.features {
+			walkHrefs(child, fn)
+		}
+	case *Style:
+		fn(f.iconURL)
+	case *NetworkLink:
+		fn(f.href)
+	case *GroundOverlay:
+		fn(f.href)
+	}
+}
+
+// usesGx reports whether anything in the feature tree renders gx extension
+// elements.
+func usesGx(feature renderable) bool {
+	if extraOf(feature).uses(gxNamespace) {
+		return true
+	}
+
+	switch f := feature.(type) {
+	case *Folder:
+		for _, child := range f.features {
+			if usesGx(child) {
+				return true
+			}
+		}
+	case *Placemark:
+		return f.balloon || usesGx(f.geometry)
+	case *LineString:
+		return f.hasDrawOrder
+	case *Track:
+		return true
+	case *MultiGeometry:
+		for _, geom := range f.geometries {
+			if usesGx(geom) {
+				return true
+			}
+		}
+	case *GroundOverlay:
+		return f.quad != nil
+	}
+
+	return false
+}
+
+// rewriteHrefs replaces every href in the feature tree with the result of fn.
+func rewriteHrefs(feature renderable, fn func(string) string) {
+	if x, ok := extraOf(feature).rewriteHrefs(fn); ok {
+		setExtra(feature, x)
+	}
+
+	switch f := feature.(type) {
+	case *Folder:
+		for _, child := range f.features {
+			rewriteHrefs(child, fn)
+		}
+	case *Style:
+		f.iconURL = fn(f.iconURL)
+	case *NetworkLink:
+		f.href = fn(f.href)
+	case *GroundOverlay:
+		f.href = fn(f.href)
+	}
+}
+
+// findByID returns the feature in the tree with the given id, or nil.
+func findByID(feature renderable, id string) renderable {
+	switch f := feature.(type) {
+	case *Folder:
+		if f.id == id {
+			return f
+		}
+		for _, child := range f.features {
+			if found := findByID(child, id); found != nil {
+				return found
+			}
+		}
+	case *Placemark:
+		if f.id == id {
+			return f
+		}
+	case *Style:
+		if f.name == id {
+			return f
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package gokml
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// WKB geometry types, and the flags EWKB (PostGIS) adds to them.
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// ParseWKB decodes a geometry in Well-Known Binary, as stored by spatial
+// databases.  ISO WKB (with Z, M, and ZM types) and PostGIS EWKB are
+// supported; use ParseEWKB to get the SRID of an EWKB geometry.  Geometry
+// types are mapped like ParseWKT.  Databases often return WKB as hex, which
+// must be decoded first (see encoding/hex).
+func ParseWKB(b []byte) (renderable, error) {
+	geom, _, err := ParseEWKB(b)
+	return geom, err
+}
+
+// ParseEWKB decodes a geometry in PostGIS Extended Well-Known Binary (or
+// ISO WKB) like ParseWKB, and returns its SRID, or 0 if it has none.  The
+// SRID isn't used to transform the coordinates, which must be longitude and
+// latitude (SRID 4326).
+func ParseEWKB(b []byte) (renderable, int, error) {
+	r := &wkbReader{b: b}
+
+	geom, err := r.geometry()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if r.pos != len(r.b) {
+		return nil, 0, r.errorf("%d extra bytes", len(r.b)-r.pos)
+	}
+
+	return geom, r.srid, nil
+}
+
+type wkbReader struct {
+	b     []byte
+	pos   int
+	order binary.ByteOrder
+	srid  int
+}
+
+func (r *wkbReader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("gokml: invalid WKB at offset %d: %s", r.pos, fmt.Sprintf(format, args...))
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.b)-r.pos < 4 {
+		return 0, r.errorf("unexpected end of data")
+	}
+
+	v := r.order.Uint32(r.b[r.pos:])
+	r.pos += 4
+
+	return v, nil
+}
+
+func (r *wkbReader) float() (float64, error) {
+	if len(r.b)-r.pos < 8 {
+		return 0, r.errorf("unexpected end of data")
+	}
+
+	v := math.Float64frombits(r.order.Uint64(r.b[r.pos:]))
+	r.pos += 8
+
+	return v, nil
+}
+
+// count reads a number of items that are at least size bytes each, checking
+// that there is room for them.
+func (r *wkbReader) count(size int) (int, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+
+	if uint64(n)*uint64(size) > uint64(len(r.b)-r.pos) {
+		return 0, r.errorf("count %d exceeds the data", n)
+	}
+
+	return int(n), nil
+}
+
+// geometry reads a geometry, including its byte order and type.
+func (r *wkbReader) geometry() (renderable, error) {
+	if r.pos >= len(r.b) {
+		return nil, r.errorf("unexpected end of data")
+	}
+
+	switch r.b[r.pos] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return nil, r.errorf("invalid byte order %d", r.b[r.pos])
+	}
+	r.pos++
+
+	t, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	z, m := t&ewkbZ != 0, t&ewkbM != 0
+	if t&ewkbSRID != 0 {
+		srid, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		r.srid = int(srid)
+	}
+	t &^= ewkbZ | ewkbM | ewkbSRID
+
+	// ISO WKB adds 1000 for Z, 2000 for M, and 3000 for ZM
+	switch t / 1000 {
+	case 1:
+		z = true
+	case 2:
+		m = true
+	case 3:
+		z, m = true, true
+	}
+	t %= 1000
+
+	dims := 2
+	if z {
+		dims++
+	}
+	if m {
+		dims++
+	}
+
+	switch t {
+	case wkbPoint:
+		p, err := r.point(dims, z)
+		if p == nil {
+			return nil, err
+		}
+		return p, nil
+	case wkbLineString:
+		points, err := r.points(dims, z)
+		if err != nil {
+			return nil, err
+		}
+		ls := NewLineString()
+		for _, p := range points {
+			ls.AddPoint(p)
+		}
+		return ls, nil
+	case wkbPolygon:
+		rings, err := r.count(4)
+		if err != nil {
+			return nil, err
+		}
+		poly := NewPolygon()
+		for i := 0; i < rings; i++ {
+			points, err := r.points(dims, z)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				for _, p := range points {
+					poly.AddPoint(p)
+				}
+			} else {
+				poly.AddInnerBoundary(points...)
+			}
+		}
+		return poly, nil
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+		parts, err := r.count(5)
+		if err != nil {
+			return nil, err
+		}
+		mg := NewMultiGeometry()
+		for i := 0; i < parts; i++ {
+			part, err := r.geometry()
+			if err != nil {
+				return nil, err
+			}
+			if part != nil {
+				mg.AddGeometry(part)
+			}
+		}
+		return mg, nil
+	}
+
+	return nil, r.errorf("unsupported geometry type %d", t)
+}
+
+func (r *wkbReader) points(dims int, z bool) ([]*Point, error) {
+	n, err := r.count(dims * 8)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*Point, 0, n)
+	for i := 0; i < n; i++ {
+		p, err := r.point(dims, z)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+
+	return points, nil
+}
+
+// point reads the coordinates of a point.  An empty point (NaN coordinates)
+// is nil.
+func (r *wkbReader) point(dims int, z bool) (*Point, error) {
+	v := make([]float64, dims)
+	for i := range v {
+		f, err := r.float()
+		if err != nil {
+			return nil, err
+		}
+		v[i] = f
+	}
+
+	if math.IsNaN(v[0]) && math.IsNaN(v[1]) {
+		return nil, nil
+	}
+
+	alt := 0.0
+	if z {
+		alt = v[2]
+	}
+
+	p := NewPoint(v[1], v[0], alt)
+	if p == nil {
+		return nil, r.errorf("coordinates %v out of range", v[:2])
+	}
+
+	return p, nil
+}
@@ -0,0 +1,123 @@
+package gokml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// wkbBuilder writes WKB for tests.
+type wkbBuilder struct {
+	bytes.Buffer
+	order binary.ByteOrder
+}
+
+func newWKBBuilder(order binary.ByteOrder) *wkbBuilder {
+	return &wkbBuilder{order: order}
+}
+
+func (b *wkbBuilder) header(t uint32) *wkbBuilder {
+	if b.order == binary.BigEndian {
+		b.WriteByte(0)
+	} else {
+		b.WriteByte(1)
+	}
+	return b.uint32(t)
+}
+
+func (b *wkbBuilder) uint32(v uint32) *wkbBuilder {
+	binary.Write(b, b.order, v)
+	return b
+}
+
+func (b *wkbBuilder) floats(v ...float64) *wkbBuilder {
+	for _, f := range v {
+		binary.Write(b, b.order, math.Float64bits(f))
+	}
+	return b
+}
+
+func TestParseWKB(t *testing.T) {
+	le, be := binary.LittleEndian, binary.BigEndian
+
+	for _, test := range []struct {
+		wkb  []byte
+		want string
+	}{
+		{newWKBBuilder(le).header(1).floats(1, 2).Bytes(), "POINT (1 2)"},
+		{newWKBBuilder(be).header(1).floats(1, 2).Bytes(), "POINT (1 2)"},
+		{newWKBBuilder(le).header(1001).floats(1, 2, 3).Bytes(), "POINT Z (1 2 3)"},
+		{newWKBBuilder(le).header(2001).floats(1, 2, 99).Bytes(), "POINT (1 2)"},
+		{newWKBBuilder(le).header(3001).floats(1, 2, 3, 99).Bytes(), "POINT Z (1 2 3)"},
+		{newWKBBuilder(le).header(2).uint32(2).floats(1, 2, 3, 4).Bytes(), "LINESTRING (1 2, 3 4)"},
+		{
+			newWKBBuilder(le).header(3).
+				uint32(2).
+				uint32(4).floats(0, 0, 10, 0, 10, 10, 0, 0).
+				uint32(4).floats(1, 1, 2, 1, 2, 2, 1, 1).Bytes(),
+			"POLYGON ((0 0, 10 0, 10 10, 0 0), (1 1, 2 1, 2 2, 1 1))",
+		},
+		{
+			// parts may have a different byte order
+			append(newWKBBuilder(le).header(4).uint32(2).Bytes(),
+				append(newWKBBuilder(be).header(1).floats(1, 2).Bytes(),
+					newWKBBuilder(le).header(1).floats(3, 4).Bytes()...)...),
+			"MULTIPOINT ((1 2), (3 4))",
+		},
+		{
+			append(newWKBBuilder(le).header(7).uint32(2).Bytes(),
+				append(newWKBBuilder(le).header(1).floats(1, 2).Bytes(),
+					newWKBBuilder(le).header(2).uint32(2).floats(1, 2, 3, 4).Bytes()...)...),
+			"GEOMETRYCOLLECTION (POINT (1 2), LINESTRING (1 2, 3 4))",
+		},
+	} {
+		geom, err := ParseWKB(test.wkb)
+		if err != nil {
+			t.Errorf("ParseWKB(%x): %v", test.wkb, err)
+			continue
+		}
+
+		if got, _ := FormatWKT(geom); got != test.want {
+			t.Errorf("ParseWKB(%x) = %s, want %s", test.wkb, got, test.want)
+		}
+	}
+
+	empty := newWKBBuilder(le).header(1).floats(math.NaN(), math.NaN()).Bytes()
+	if geom, err := ParseWKB(empty); geom != nil || err != nil {
+		t.Errorf("empty point = %v, %v", geom, err)
+	}
+}
+
+func TestParseEWKB(t *testing.T) {
+	// ST_AsEWKB('SRID=4326;POINT Z (1 2 3)')
+	b, _ := hex.DecodeString("01010000A0E6100000000000000000F03F00000000000000400000000000000840")
+
+	geom, srid, err := ParseEWKB(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := FormatWKT(geom); got != "POINT Z (1 2 3)" || srid != 4326 {
+		t.Errorf("got %s, SRID %d", got, srid)
+	}
+}
+
+func TestParseWKBErrors(t *testing.T) {
+	le := binary.LittleEndian
+
+	for _, b := range [][]byte{
+		nil,
+		{2, 1, 0, 0, 0},
+		newWKBBuilder(le).header(1).floats(1).Bytes(),
+		newWKBBuilder(le).header(1).floats(200, 2).Bytes(),
+		newWKBBuilder(le).header(1).floats(1, 2, 3).Bytes(),
+		newWKBBuilder(le).header(2).uint32(1 << 30).Bytes(),
+		newWKBBuilder(le).header(17).Bytes(),
+	} {
+		if _, err := ParseWKB(b); err == nil {
+			t.Errorf("no error for %x", b)
+		}
+	}
+}
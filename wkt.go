@@ -0,0 +1,410 @@
+package gokml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseWKT parses a geometry in Well-Known Text, such as
+// "POLYGON ((30 10, 40 40, 20 40, 30 10))".  POINT, LINESTRING, POLYGON,
+// MULTIPOINT, MULTILINESTRING, MULTIPOLYGON, and GEOMETRYCOLLECTION are
+// supported, with optional Z, M, or ZM dimensions (M values are ignored) and
+// an optional EWKT "SRID=n;" prefix (the SRID is ignored; coordinates must
+// be longitude and latitude).  Multi-part geometries and collections become
+// MultiGeometries.  "POINT EMPTY" returns a nil geometry.
+func ParseWKT(wkt string) (renderable, error) {
+	p := &wktParser{s: wkt}
+
+	p.skipSpace()
+	if strings.HasPrefix(strings.ToUpper(p.s[p.pos:]), "SRID=") {
+		semi := strings.IndexByte(p.s[p.pos:], ';')
+		if semi < 0 {
+			return nil, p.errorf("missing ; after SRID")
+		}
+		p.pos += semi + 1
+	}
+
+	geom, err := p.geometry()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+
+	return geom, nil
+}
+
+// FormatWKT formats a geometry as Well-Known Text.  Z coordinates are
+// included if any Point has a non-zero altitude.  A MultiGeometry is
+// formatted as a MULTIPOINT, MULTILINESTRING, or MULTIPOLYGON if all of its
+// geometries are of that type, and as a GEOMETRYCOLLECTION otherwise.
+// Tracks are formatted as LINESTRINGs, without their times.
+func FormatWKT(geom renderable) (string, error) {
+	z := false
+	walkPoints(geom, func(p *Point) { z = z || p.Alt != 0 })
+
+	var b strings.Builder
+	if err := writeWKT(&b, geom, z, true); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// writeWKT writes the WKT of geom.  tagged is false for the parts of a
+// MULTI geometry, which don't have a type name.
+func writeWKT(b *strings.Builder, geom renderable, z bool, tagged bool) error {
+	tag := func(name string) {
+		if tagged {
+			b.WriteString(name)
+			if z {
+				b.WriteString(" Z")
+			}
+			b.WriteByte(' ')
+		}
+	}
+
+	switch g := geom.(type) {
+	case *Point:
+		tag("POINT")
+		writeWKTPoints(b, []*Point{g}, z)
+	case *LineString:
+		tag("LINESTRING")
+		writeWKTPoints(b, g.coordinates, z)
+	case *Track:
+		tag("LINESTRING")
+		writeWKTPoints(b, g.points, z)
+	case *Polygon:
+		tag("POLYGON")
+		b.WriteByte('(')
+		writeWKTPoints(b, closeRing(g.points), z)
+		for _, ring := range g.inner {
+			b.WriteString(", ")
+			writeWKTPoints(b, ring, z)
+		}
+		b.WriteByte(')')
+	case *MultiGeometry:
+		kind := wktMultiKind(g.geometries)
+		tag(kind)
+		if len(g.geometries) == 0 {
+			b.WriteString("EMPTY")
+			return nil
+		}
+
+		b.WriteByte('(')
+		for i, part := range g.geometries {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if err := writeWKT(b, part, z, kind == "GEOMETRYCOLLECTION"); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+	default:
+		return fmt.Errorf("gokml: can't format %T as WKT", geom)
+	}
+
+	return nil
+}
+
+// wktMultiKind returns the WKT type for a MultiGeometry of the geometries.
+func wktMultiKind(geometries []renderable) string {
+	kind := ""
+
+	for _, geom := range geometries {
+		var k string
+		switch geom.(type) {
+		case *Point:
+			k = "MULTIPOINT"
+		case *LineString, *Track:
+			k = "MULTILINESTRING"
+		case *Polygon:
+			k = "MULTIPOLYGON"
+		default:
+			return "GEOMETRYCOLLECTION"
+		}
+
+		if len(kind) > 0 && k != kind {
+			return "GEOMETRYCOLLECTION"
+		}
+		kind = k
+	}
+
+	if len(kind) == 0 {
+		return "GEOMETRYCOLLECTION"
+	}
+
+	return kind
+}
+
+func writeWKTPoints(b *strings.Builder, points []*Point, z bool) {
+	b.WriteByte('(')
+	for i, p := range points {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.FormatFloat(p.Lon, 'f', -1, 64))
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(p.Lat, 'f', -1, 64))
+		if z {
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatFloat(p.Alt, 'f', -1, 64))
+		}
+	}
+	b.WriteByte(')')
+}
+
+type wktParser struct {
+	s   string
+	pos int
+	z   bool // the current geometry has Z values
+	m   bool // the current geometry has M values
+}
+
+func (p *wktParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("gokml: invalid WKT at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// word returns the next word, in upper case.
+func (p *wktParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z' || p.s[p.pos] >= 'A' && p.s[p.pos] <= 'Z') {
+		p.pos++
+	}
+
+	return strings.ToUpper(p.s[start:p.pos])
+}
+
+// accept consumes c if it is next, and reports whether it was.
+func (p *wktParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *wktParser) expect(c byte) error {
+	if !p.accept(c) {
+		return p.errorf("expected %q", c)
+	}
+
+	return nil
+}
+
+// list parses a parenthesized, comma-separated list, calling item for each
+// element.
+func (p *wktParser) list(item func() error) error {
+	if err := p.expect('('); err != nil {
+		return err
+	}
+
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		if !p.accept(',') {
+			break
+		}
+	}
+
+	return p.expect(')')
+}
+
+func (p *wktParser) geometry() (renderable, error) {
+	kind := p.word()
+
+	p.z, p.m = false, false
+	save := p.pos
+	switch p.word() {
+	case "Z":
+		p.z = true
+	case "M":
+		p.m = true
+	case "ZM":
+		p.z, p.m = true, true
+	default:
+		p.pos = save
+	}
+
+	save = p.pos
+	empty := p.word() == "EMPTY"
+	if !empty {
+		p.pos = save
+	}
+
+	switch kind {
+	case "POINT":
+		if empty {
+			return nil, nil
+		}
+		var pt *Point
+		err := p.list(func() (err error) {
+			pt, err = p.point()
+			return err
+		})
+		return pt, err
+	case "LINESTRING":
+		ls := NewLineString()
+		if empty {
+			return ls, nil
+		}
+		points, err := p.points()
+		for _, pt := range points {
+			ls.AddPoint(pt)
+		}
+		return ls, err
+	case "POLYGON":
+		if empty {
+			return NewPolygon(), nil
+		}
+		return p.polygon()
+	case "MULTIPOINT", "MULTILINESTRING", "MULTIPOLYGON", "GEOMETRYCOLLECTION":
+		mg := NewMultiGeometry()
+		if empty {
+			return mg, nil
+		}
+		return mg, p.list(func() error {
+			part, err := p.part(kind)
+			mg.AddGeometry(part)
+			return err
+		})
+	case "":
+		return nil, p.errorf("expected a geometry type")
+	}
+
+	return nil, p.errorf("unsupported geometry type %s", kind)
+}
+
+// part parses an element of a MULTI geometry or GEOMETRYCOLLECTION.
+func (p *wktParser) part(kind string) (renderable, error) {
+	switch kind {
+	case "MULTIPOINT":
+		// the points may or may not be parenthesized
+		if p.accept('(') {
+			pt, err := p.point()
+			if err != nil {
+				return nil, err
+			}
+			return pt, p.expect(')')
+		}
+		return p.point()
+	case "MULTILINESTRING":
+		ls := NewLineString()
+		points, err := p.points()
+		for _, pt := range points {
+			ls.AddPoint(pt)
+		}
+		return ls, err
+	case "MULTIPOLYGON":
+		return p.polygon()
+	}
+
+	// each geometry of a collection has its own dimensions
+	z, m := p.z, p.m
+	geom, err := p.geometry()
+	p.z, p.m = z, m
+
+	return geom, err
+}
+
+func (p *wktParser) polygon() (*Polygon, error) {
+	poly := NewPolygon()
+	outer := true
+
+	err := p.list(func() error {
+		points, err := p.points()
+		if outer {
+			for _, pt := range points {
+				poly.AddPoint(pt)
+			}
+			outer = false
+		} else {
+			poly.AddInnerBoundary(points...)
+		}
+		return err
+	})
+
+	return poly, err
+}
+
+func (p *wktParser) points() ([]*Point, error) {
+	points := make([]*Point, 0)
+
+	err := p.list(func() error {
+		pt, err := p.point()
+		points = append(points, pt)
+		return err
+	})
+
+	return points, err
+}
+
+// point parses the coordinates of a point: x (longitude), y (latitude), and
+// z and m values as given by the dimensions.
+func (p *wktParser) point() (*Point, error) {
+	var v []float64
+
+	for {
+		p.skipSpace()
+		start := p.pos
+		for p.pos < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.pos]) >= 0 {
+			p.pos++
+		}
+		if p.pos == start {
+			break
+		}
+
+		text := p.s[start:p.pos]
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", text)
+		}
+		v = append(v, f)
+	}
+
+	want := 2
+	if p.z {
+		want++
+	}
+	if p.m {
+		want++
+	}
+
+	// without dimensions, a third value is Z and a fourth is M
+	if !p.z && !p.m && len(v) >= 3 && len(v) <= 4 {
+		want = len(v)
+		p.z = true
+		p.m = len(v) == 4
+	}
+
+	if len(v) != want {
+		return nil, p.errorf("expected %d coordinates, got %d", want, len(v))
+	}
+
+	alt := 0.0
+	if p.z {
+		alt = v[2]
+	}
+
+	pt := NewPoint(v[1], v[0], alt)
+	if pt == nil {
+		return nil, p.errorf("coordinates %v out of range", v[:2])
+	}
+
+	return pt, nil
+}
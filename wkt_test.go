@@ -0,0 +1,83 @@
+package gokml
+
+import (
+	"testing"
+)
+
+func TestWKTRoundTrip(t *testing.T) {
+	for _, wkt := range []string{
+		"POINT (-105.27 40.01)",
+		"POINT Z (-105.27 40.01 1655.5)",
+		"LINESTRING (30 10, 10 30, 40 40)",
+		"POLYGON ((35 10, 45 45, 15 40, 10 20, 35 10), (20 30, 35 35, 30 20, 20 30))",
+		"MULTIPOINT ((10 40), (40 30))",
+		"MULTILINESTRING ((10 10, 20 20), (40 40, 30 30))",
+		"MULTIPOLYGON (((30 20, 45 40, 10 40, 30 20)), ((15 5, 40 10, 10 20, 15 5)))",
+		"GEOMETRYCOLLECTION (POINT (40 10), LINESTRING (10 10, 20 20))",
+		"GEOMETRYCOLLECTION EMPTY",
+	} {
+		geom, err := ParseWKT(wkt)
+		if err != nil {
+			t.Errorf("ParseWKT(%q): %v", wkt, err)
+			continue
+		}
+
+		got, err := FormatWKT(geom)
+		if err != nil || got != wkt {
+			t.Errorf("FormatWKT = %q, %v; want %q", got, err, wkt)
+		}
+	}
+}
+
+func TestParseWKTVariants(t *testing.T) {
+	for wkt, want := range map[string]string{
+		"point(1 2)":                               "POINT (1 2)",
+		"SRID=4326;POINT(1 2)":                     "POINT (1 2)",
+		"POINT M (1 2 99)":                         "POINT (1 2)",
+		"POINT ZM (1 2 3 99)":                      "POINT Z (1 2 3)",
+		"POINT (1 2 3)":                            "POINT Z (1 2 3)",
+		"MULTIPOINT (10 40, 40 30)":                "MULTIPOINT ((10 40), (40 30))",
+		"POLYGON ((0 0, 1 0, 1 1))":                "POLYGON ((0 0, 1 0, 1 1, 0 0))",
+		"  LINESTRING\n(1e1 -2.5, +3 4)  ":         "LINESTRING (10 -2.5, 3 4)",
+		"GEOMETRYCOLLECTION (POINT Z (1 2 3))":     "MULTIPOINT Z ((1 2 3))",
+		"MULTILINESTRING ((1 2, 3 4), (5 6, 7 8))": "MULTILINESTRING ((1 2, 3 4), (5 6, 7 8))",
+	} {
+		geom, err := ParseWKT(wkt)
+		if err != nil {
+			t.Errorf("ParseWKT(%q): %v", wkt, err)
+			continue
+		}
+
+		if got, _ := FormatWKT(geom); got != want {
+			t.Errorf("ParseWKT(%q) formats as %q, want %q", wkt, got, want)
+		}
+	}
+
+	if geom, err := ParseWKT("POINT EMPTY"); geom != nil || err != nil {
+		t.Errorf("POINT EMPTY = %v, %v", geom, err)
+	}
+}
+
+func TestParseWKTErrors(t *testing.T) {
+	for _, wkt := range []string{
+		"",
+		"CIRCLE (1 2)",
+		"POINT (1)",
+		"POINT (1 2",
+		"POINT (200 2)",
+		"POINT Z (1 2)",
+		"LINESTRING (1 2, x 4)",
+		"POINT (1 2) extra",
+		"SRID=4326 POINT (1 2)",
+	} {
+		if _, err := ParseWKT(wkt); err == nil {
+			t.Errorf("no error for %q", wkt)
+		}
+	}
+}
+
+func TestFormatWKTUnsupported(t *testing.T) {
+	if _, err := FormatWKT(NewStyle("s", 255, 0, 0, 0)); err == nil {
+		t.Error("no error for a Style")
+	}
+}